@@ -1004,6 +1004,36 @@ func (t *testGossipHandler) HandleSignedOrders(nodeID ids.NodeID, msg message.Si
 	return nil
 }
 
+func (t *testGossipHandler) HandleSignedOrderAmendments(nodeID ids.NodeID, msg message.SignedOrderAmendmentsGossip) error {
+	t.received = true
+	t.nodeID = nodeID
+	return nil
+}
+
+func (t *testGossipHandler) HandleCancelAllOrders(nodeID ids.NodeID, msg message.CancelAllOrdersGossip) error {
+	t.received = true
+	t.nodeID = nodeID
+	return nil
+}
+
+func (t *testGossipHandler) HandleHeartbeats(nodeID ids.NodeID, msg message.HeartbeatsGossip) error {
+	t.received = true
+	t.nodeID = nodeID
+	return nil
+}
+
+func (t *testGossipHandler) HandleEncodedSignedOrders(nodeID ids.NodeID, msg message.EncodedSignedOrdersGossip) error {
+	t.received = true
+	t.nodeID = nodeID
+	return nil
+}
+
+func (t *testGossipHandler) HandleTriggerOrders(nodeID ids.NodeID, msg message.TriggerOrdersGossip) error {
+	t.received = true
+	t.nodeID = nodeID
+	return nil
+}
+
 type testRequestHandler struct {
 	message.RequestHandler
 	calls              uint32
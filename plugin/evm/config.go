@@ -53,6 +53,12 @@ const (
 	defaulOrderGossipNumValidators                    = 10
 	defaultOrderGossipNumNonValidators                = 5
 	defaultOrderGossipNumPeers                        = 15
+	defaultOrderSyncFrequency                         = 30 * time.Second
+	defaultOrderGossipPeerRate                        = 1000
+	defaultOrderGossipPeerBurst                       = 2000
+	defaultOrderGossipPeerMaxInvalid                  = 500
+	defaultOrderGossipPeerInvalidWindow               = time.Minute
+	defaultOrderGossipPeerMuteDuration                = 5 * time.Minute
 
 	// defaultStateSyncMinBlocks is the minimum number of blocks the blockchain
 	// should be ahead of local last accepted to perform state sync.
@@ -69,6 +75,9 @@ const (
 	defaultLoadFromSnapshotEnabled = true
 	defaultSnapshotFilePath        = "/tmp/snapshot"
 	defaultMakerbookDatabasePath   = "/tmp/makerbook"
+	defaultStateAuditInterval      = 0 // state auditor is disabled by default
+	defaultTradeIndexerEnabled     = false
+	defaultTradingAPIRequireAuth   = false
 )
 
 var (
@@ -180,6 +189,17 @@ type Config struct {
 	OrderGossipNumValidators    int `json:"order-gossip-num-validators"`
 	OrderGossipNumNonValidators int `json:"order-gossip-num-non-validators"`
 	OrderGossipNumPeers         int `json:"order-gossip-num-peers"`
+	// OrderSyncFrequency is how often the node pulls the live signed orders it is missing from a peer, 0 disables the sync
+	OrderSyncFrequency Duration `json:"order-sync-frequency"`
+	// OrderGossipPeerRate is the number of gossiped signed orders per second processed for each peer, with bursts of up to
+	// OrderGossipPeerBurst orders. The orders over the budget are dropped, 0 disables the limit
+	OrderGossipPeerRate  float64 `json:"order-gossip-peer-rate"`
+	OrderGossipPeerBurst int     `json:"order-gossip-peer-burst"`
+	// A peer that gossips more than OrderGossipPeerMaxInvalid invalid signed orders within OrderGossipPeerInvalidWindow is muted,
+	// its signed orders are dropped for OrderGossipPeerMuteDuration. 0 disables muting
+	OrderGossipPeerMaxInvalid    int      `json:"order-gossip-peer-max-invalid"`
+	OrderGossipPeerInvalidWindow Duration `json:"order-gossip-peer-invalid-window"`
+	OrderGossipPeerMuteDuration  Duration `json:"order-gossip-peer-mute-duration"`
 
 	// Log
 	LogLevel      string `json:"log-level"`
@@ -250,6 +270,10 @@ type Config struct {
 	// TradingAPI is for the sdk
 	TradingAPIEnabled bool `json:"trading-api-enabled"`
 
+	// TradingAPIRequireAuth = true if trader updates are only streamed to subscribers that prove control of the trader
+	// with a signed challenge (trading_streamPrivateTraderUpdates). trading_streamTraderUpdates is rejected
+	TradingAPIRequireAuth bool `json:"trading-api-require-auth"`
+
 	// LoadFromSnapshotEnabled = true if the node should load the memory db from a snapshot
 	LoadFromSnapshotEnabled bool `json:"load-from-snapshot-enabled"`
 
@@ -258,6 +282,13 @@ type Config struct {
 
 	// MakerbookDatabasePath is the path to the file which saves the makerbook orders
 	MakerbookDatabasePath string `json:"makerbook-database-path"`
+
+	// StateAuditInterval is how often the memory DB is compared with the on-chain state. 0 disables the background auditor
+	StateAuditInterval Duration `json:"state-audit-interval"`
+
+	// TradeIndexerEnabled = true if the trades, fills and funding rates of accepted blocks, and the orders removed from the order book, should be stored in hubbleDB
+	// for the trading_getTrades, trading_getCandles, trading_getUserFills, funding history and order history APIs
+	TradeIndexerEnabled bool `json:"trade-indexer-enabled"`
 }
 
 // EthAPIs returns an array of strings representing the Eth APIs that should be enabled
@@ -324,9 +355,18 @@ func (c *Config) SetDefaults() {
 	c.LoadFromSnapshotEnabled = defaultLoadFromSnapshotEnabled
 	c.SnapshotFilePath = defaultSnapshotFilePath
 	c.MakerbookDatabasePath = defaultMakerbookDatabasePath
+	c.StateAuditInterval.Duration = defaultStateAuditInterval
+	c.TradeIndexerEnabled = defaultTradeIndexerEnabled
+	c.TradingAPIRequireAuth = defaultTradingAPIRequireAuth
 	c.OrderGossipNumValidators = defaulOrderGossipNumValidators
 	c.OrderGossipNumNonValidators = defaultOrderGossipNumNonValidators
 	c.OrderGossipNumPeers = defaultOrderGossipNumPeers
+	c.OrderSyncFrequency.Duration = defaultOrderSyncFrequency
+	c.OrderGossipPeerRate = defaultOrderGossipPeerRate
+	c.OrderGossipPeerBurst = defaultOrderGossipPeerBurst
+	c.OrderGossipPeerMaxInvalid = defaultOrderGossipPeerMaxInvalid
+	c.OrderGossipPeerInvalidWindow.Duration = defaultOrderGossipPeerInvalidWindow
+	c.OrderGossipPeerMuteDuration.Duration = defaultOrderGossipPeerMuteDuration
 }
 
 func (d *Duration) UnmarshalJSON(data []byte) (err error) {
@@ -365,5 +405,9 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("cannot use commit interval of 0 with pruning enabled")
 	}
 
+	if c.OrderGossipPeerRate > 0 && c.OrderGossipPeerBurst < 1 {
+		return fmt.Errorf("cannot limit the order gossip of peers without a burst of at least one order (burst: %d)", c.OrderGossipPeerBurst)
+	}
+
 	return nil
 }
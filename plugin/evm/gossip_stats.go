@@ -3,7 +3,13 @@
 
 package evm
 
-import "github.com/ava-labs/subnet-evm/metrics"
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/subnet-evm/metrics"
+)
 
 var _ GossipStats = &gossipStats{}
 
@@ -19,11 +25,34 @@ type GossipStats interface {
 	IncSignedOrdersGossipReceivedKnown()
 	IncSignedOrdersGossipReceivedNew()
 	IncSignedOrdersGossipReceiveError()
+	IncSignedOrdersGossipReceivedInvalid(nodeID ids.NodeID, count int64)
+	IncSignedOrdersGossipRateLimited(count int64)
+	IncSignedOrdersGossipPeerMuted()
 
 	IncSignedOrdersGossipSent(count int64)
 	IncSignedOrdersGossipBatchSent()
 	IncSignedOrdersGossipSendError()
 	IncSignedOrdersGossipOrderExpired()
+
+	IncCancelAllGossipReceived(count int64)
+	IncCancelAllGossipBatchReceived()
+	IncCancelAllGossipReceivedKnown()
+	IncCancelAllGossipReceivedNew()
+	IncCancelAllGossipReceiveError()
+
+	IncCancelAllGossipSent(count int64)
+	IncCancelAllGossipBatchSent()
+	IncCancelAllGossipSendError()
+
+	IncHeartbeatsGossipReceived(count int64)
+	IncHeartbeatsGossipBatchReceived()
+	IncHeartbeatsGossipReceivedKnown()
+	IncHeartbeatsGossipReceivedNew()
+	IncHeartbeatsGossipReceiveError()
+
+	IncHeartbeatsGossipSent(count int64)
+	IncHeartbeatsGossipBatchSent()
+	IncHeartbeatsGossipSendError()
 }
 
 // gossipStats implements stats for incoming and outgoing gossip stats.
@@ -39,10 +68,37 @@ type gossipStats struct {
 	signedOrdersGossipReceivedNew   metrics.Counter
 	signedOrdersGossipReceiveError  metrics.Counter
 
+	// invalid orders received, in total and per peer
+	signedOrdersGossipReceivedInvalid       metrics.Counter
+	signedOrdersGossipReceivedInvalidByPeer map[ids.NodeID]metrics.Counter
+	invalidByPeerLock                       sync.Mutex
+	signedOrdersGossipRateLimited           metrics.Counter
+	signedOrdersGossipPeerMuted             metrics.Counter
+
 	signedOrdersGossipSent         metrics.Counter
 	signedOrdersGossipBatchSent    metrics.Counter
 	signedOrdersGossipSendError    metrics.Counter
 	signedOrdersGossipOrderExpired metrics.Counter
+
+	cancelAllGossipReceived      metrics.Counter
+	cancelAllGossipBatchReceived metrics.Counter
+	cancelAllGossipReceivedKnown metrics.Counter
+	cancelAllGossipReceivedNew   metrics.Counter
+	cancelAllGossipReceiveError  metrics.Counter
+
+	cancelAllGossipSent      metrics.Counter
+	cancelAllGossipBatchSent metrics.Counter
+	cancelAllGossipSendError metrics.Counter
+
+	heartbeatsGossipReceived      metrics.Counter
+	heartbeatsGossipBatchReceived metrics.Counter
+	heartbeatsGossipReceivedKnown metrics.Counter
+	heartbeatsGossipReceivedNew   metrics.Counter
+	heartbeatsGossipReceiveError  metrics.Counter
+
+	heartbeatsGossipSent      metrics.Counter
+	heartbeatsGossipBatchSent metrics.Counter
+	heartbeatsGossipSendError metrics.Counter
 }
 
 func NewGossipStats() GossipStats {
@@ -58,10 +114,35 @@ func NewGossipStats() GossipStats {
 		signedOrdersGossipOrderExpired:  metrics.GetOrRegisterCounter("gossip_signed_orders_expired", nil),
 		signedOrdersGossipReceived:      metrics.GetOrRegisterCounter("gossip_signed_orders_received", nil),
 		signedOrdersGossipBatchReceived: metrics.GetOrRegisterCounter("gossip_signed_orders_batch_received", nil),
-		signedOrdersGossipReceiveError:  metrics.GetOrRegisterCounter("gossip_signed_orders_received", nil),
+		signedOrdersGossipReceiveError:  metrics.GetOrRegisterCounter("gossip_signed_orders_received_error", nil),
+
+		signedOrdersGossipReceivedInvalid:       metrics.GetOrRegisterCounter("gossip_signed_orders_received_invalid", nil),
+		signedOrdersGossipReceivedInvalidByPeer: make(map[ids.NodeID]metrics.Counter),
+		signedOrdersGossipRateLimited:           metrics.GetOrRegisterCounter("gossip_signed_orders_rate_limited", nil),
+		signedOrdersGossipPeerMuted:             metrics.GetOrRegisterCounter("gossip_signed_orders_peer_muted", nil),
 
 		signedOrdersGossipReceivedKnown: metrics.GetOrRegisterCounter("gossip_signed_orders_received_known", nil),
 		signedOrdersGossipReceivedNew:   metrics.GetOrRegisterCounter("gossip_signed_orders_received_new", nil),
+
+		cancelAllGossipReceived:      metrics.GetOrRegisterCounter("gossip_cancel_all_received", nil),
+		cancelAllGossipBatchReceived: metrics.GetOrRegisterCounter("gossip_cancel_all_batch_received", nil),
+		cancelAllGossipReceivedKnown: metrics.GetOrRegisterCounter("gossip_cancel_all_received_known", nil),
+		cancelAllGossipReceivedNew:   metrics.GetOrRegisterCounter("gossip_cancel_all_received_new", nil),
+		cancelAllGossipReceiveError:  metrics.GetOrRegisterCounter("gossip_cancel_all_received_error", nil),
+
+		cancelAllGossipSent:      metrics.GetOrRegisterCounter("gossip_cancel_all_sent", nil),
+		cancelAllGossipBatchSent: metrics.GetOrRegisterCounter("gossip_cancel_all_batch_sent", nil),
+		cancelAllGossipSendError: metrics.GetOrRegisterCounter("gossip_cancel_all_send_error", nil),
+
+		heartbeatsGossipReceived:      metrics.GetOrRegisterCounter("gossip_heartbeats_received", nil),
+		heartbeatsGossipBatchReceived: metrics.GetOrRegisterCounter("gossip_heartbeats_batch_received", nil),
+		heartbeatsGossipReceivedKnown: metrics.GetOrRegisterCounter("gossip_heartbeats_received_known", nil),
+		heartbeatsGossipReceivedNew:   metrics.GetOrRegisterCounter("gossip_heartbeats_received_new", nil),
+		heartbeatsGossipReceiveError:  metrics.GetOrRegisterCounter("gossip_heartbeats_received_error", nil),
+
+		heartbeatsGossipSent:      metrics.GetOrRegisterCounter("gossip_heartbeats_sent", nil),
+		heartbeatsGossipBatchSent: metrics.GetOrRegisterCounter("gossip_heartbeats_batch_sent", nil),
+		heartbeatsGossipSendError: metrics.GetOrRegisterCounter("gossip_heartbeats_send_error", nil),
 	}
 }
 
@@ -84,8 +165,51 @@ func (g *gossipStats) IncSignedOrdersGossipReceivedKnown() { g.signedOrdersGossi
 func (g *gossipStats) IncSignedOrdersGossipReceivedNew()   { g.signedOrdersGossipReceivedNew.Inc(1) }
 func (g *gossipStats) IncSignedOrdersGossipReceiveError()  { g.signedOrdersGossipReceiveError.Inc(1) }
 
+// invalid orders are counted per peer, for the first maxOrderGossipLimiterPeers peers that sent one
+func (g *gossipStats) IncSignedOrdersGossipReceivedInvalid(nodeID ids.NodeID, count int64) {
+	g.signedOrdersGossipReceivedInvalid.Inc(count)
+
+	g.invalidByPeerLock.Lock()
+	defer g.invalidByPeerLock.Unlock()
+	counter, ok := g.signedOrdersGossipReceivedInvalidByPeer[nodeID]
+	if !ok {
+		if len(g.signedOrdersGossipReceivedInvalidByPeer) >= maxOrderGossipLimiterPeers {
+			return
+		}
+		counter = metrics.GetOrRegisterCounter(fmt.Sprintf("gossip_signed_orders_received_invalid/%x", nodeID.Bytes()), nil)
+		g.signedOrdersGossipReceivedInvalidByPeer[nodeID] = counter
+	}
+	counter.Inc(count)
+}
+
+// orders dropped because the peer went over its budget or is muted
+func (g *gossipStats) IncSignedOrdersGossipRateLimited(count int64) {
+	g.signedOrdersGossipRateLimited.Inc(count)
+}
+func (g *gossipStats) IncSignedOrdersGossipPeerMuted() { g.signedOrdersGossipPeerMuted.Inc(1) }
+
 // outgoing messages
 func (g *gossipStats) IncSignedOrdersGossipSent(count int64) { g.signedOrdersGossipSent.Inc(count) }
 func (g *gossipStats) IncSignedOrdersGossipBatchSent()       { g.signedOrdersGossipBatchSent.Inc(1) }
 func (g *gossipStats) IncSignedOrdersGossipSendError()       { g.signedOrdersGossipSendError.Inc(1) }
 func (g *gossipStats) IncSignedOrdersGossipOrderExpired()    { g.signedOrdersGossipOrderExpired.Inc(1) }
+
+// cancel all messages
+func (g *gossipStats) IncCancelAllGossipReceived(count int64) { g.cancelAllGossipReceived.Inc(count) }
+func (g *gossipStats) IncCancelAllGossipBatchReceived()       { g.cancelAllGossipBatchReceived.Inc(1) }
+func (g *gossipStats) IncCancelAllGossipReceivedKnown()       { g.cancelAllGossipReceivedKnown.Inc(1) }
+func (g *gossipStats) IncCancelAllGossipReceivedNew()         { g.cancelAllGossipReceivedNew.Inc(1) }
+func (g *gossipStats) IncCancelAllGossipReceiveError()        { g.cancelAllGossipReceiveError.Inc(1) }
+func (g *gossipStats) IncCancelAllGossipSent(count int64)     { g.cancelAllGossipSent.Inc(count) }
+func (g *gossipStats) IncCancelAllGossipBatchSent()           { g.cancelAllGossipBatchSent.Inc(1) }
+func (g *gossipStats) IncCancelAllGossipSendError()           { g.cancelAllGossipSendError.Inc(1) }
+
+// heartbeats
+func (g *gossipStats) IncHeartbeatsGossipReceived(count int64) { g.heartbeatsGossipReceived.Inc(count) }
+func (g *gossipStats) IncHeartbeatsGossipBatchReceived()       { g.heartbeatsGossipBatchReceived.Inc(1) }
+func (g *gossipStats) IncHeartbeatsGossipReceivedKnown()       { g.heartbeatsGossipReceivedKnown.Inc(1) }
+func (g *gossipStats) IncHeartbeatsGossipReceivedNew()         { g.heartbeatsGossipReceivedNew.Inc(1) }
+func (g *gossipStats) IncHeartbeatsGossipReceiveError()        { g.heartbeatsGossipReceiveError.Inc(1) }
+func (g *gossipStats) IncHeartbeatsGossipSent(count int64)     { g.heartbeatsGossipSent.Inc(count) }
+func (g *gossipStats) IncHeartbeatsGossipBatchSent()           { g.heartbeatsGossipBatchSent.Inc(1) }
+func (g *gossipStats) IncHeartbeatsGossipSendError()           { g.heartbeatsGossipSendError.Inc(1) }
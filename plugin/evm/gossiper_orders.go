@@ -14,6 +14,7 @@ import (
 	"github.com/ava-labs/subnet-evm/plugin/evm/orderbook"
 	"github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
 	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/log"
 )
 
@@ -34,6 +35,21 @@ const (
 type OrderGossiper interface {
 	// GossipSignedOrders sends signed orders to the network
 	GossipSignedOrders(orders []*hubbleutils.SignedOrder) error
+	// GossipSignedOrderAmendments sends signed order amendments to the network
+	GossipSignedOrderAmendments(amendments []*SignedOrderAmendment) error
+	// GossipCancelAllOrders sends signed cancel all messages to the network
+	GossipCancelAllOrders(cancels []*hu.CancelAllOrders) error
+	// GossipHeartbeats sends the heartbeats of dead man's switches to the network
+	GossipHeartbeats(heartbeats []*hu.Heartbeat) error
+	// GossipTriggerOrders sends trigger orders to the network
+	GossipTriggerOrders(orders []*hu.TriggerOrder) error
+}
+
+// SignedOrderAmendment replaces the signed order with AmendedOrderId by Order. Cancel is the trader's message that cancels the amended order
+type SignedOrderAmendment struct {
+	AmendedOrderId common.Hash
+	Order          *hu.SignedOrder
+	Cancel         *hu.CancelOrder
 }
 
 type orderPushGossiper struct {
@@ -47,6 +63,22 @@ type orderPushGossiper struct {
 	ordersToGossip     []*hubbleutils.SignedOrder
 	lastOrdersGossiped time.Time
 
+	amendmentsToGossipChan chan []*SignedOrderAmendment
+	amendmentsToGossip     []*SignedOrderAmendment
+	lastAmendmentsGossiped time.Time
+
+	cancelsToGossipChan chan []*hu.CancelAllOrders
+	cancelsToGossip     []*hu.CancelAllOrders
+	lastCancelsGossiped time.Time
+
+	heartbeatsToGossipChan chan []*hu.Heartbeat
+	heartbeatsToGossip     []*hu.Heartbeat
+	lastHeartbeatsGossiped time.Time
+
+	triggerOrdersToGossipChan chan []*hu.TriggerOrder
+	triggerOrdersToGossip     []*hu.TriggerOrder
+	lastTriggerOrdersGossiped time.Time
+
 	codec codec.Manager
 	stats GossipStats
 
@@ -67,6 +99,18 @@ func (vm *VM) createOrderGossiper(
 		ordersToGossipChan: make(chan []*hubbleutils.SignedOrder),
 		ordersToGossip:     []*hubbleutils.SignedOrder{},
 		appSender:          vm.p2pSender,
+
+		amendmentsToGossipChan: make(chan []*SignedOrderAmendment),
+		amendmentsToGossip:     []*SignedOrderAmendment{},
+
+		cancelsToGossipChan: make(chan []*hu.CancelAllOrders),
+		cancelsToGossip:     []*hu.CancelAllOrders{},
+
+		heartbeatsToGossipChan: make(chan []*hu.Heartbeat),
+		heartbeatsToGossip:     []*hu.Heartbeat{},
+
+		triggerOrdersToGossipChan: make(chan []*hu.TriggerOrder),
+		triggerOrdersToGossip:     []*hu.TriggerOrder{},
 	}
 
 	net.awaitSignedOrderGossip()
@@ -81,6 +125,38 @@ func (n *orderPushGossiper) GossipSignedOrders(orders []*hu.SignedOrder) error {
 	return nil
 }
 
+func (n *orderPushGossiper) GossipSignedOrderAmendments(amendments []*SignedOrderAmendment) error {
+	select {
+	case n.amendmentsToGossipChan <- amendments:
+	case <-n.shutdownChan:
+	}
+	return nil
+}
+
+func (n *orderPushGossiper) GossipCancelAllOrders(cancels []*hu.CancelAllOrders) error {
+	select {
+	case n.cancelsToGossipChan <- cancels:
+	case <-n.shutdownChan:
+	}
+	return nil
+}
+
+func (n *orderPushGossiper) GossipHeartbeats(heartbeats []*hu.Heartbeat) error {
+	select {
+	case n.heartbeatsToGossipChan <- heartbeats:
+	case <-n.shutdownChan:
+	}
+	return nil
+}
+
+func (n *orderPushGossiper) GossipTriggerOrders(orders []*hu.TriggerOrder) error {
+	select {
+	case n.triggerOrdersToGossipChan <- orders:
+	case <-n.shutdownChan:
+	}
+	return nil
+}
+
 func (n *orderPushGossiper) awaitSignedOrderGossip() {
 	n.shutdownWg.Add(1)
 	go executeFuncAndRecoverPanic(func() {
@@ -102,6 +178,34 @@ func (n *orderPushGossiper) awaitSignedOrderGossip() {
 						"err", err,
 					)
 				}
+				if attempted, err := n.gossipSignedOrderAmendments(); err != nil {
+					log.Warn(
+						"failed to send signed order amendments",
+						"len(amendments)", attempted,
+						"err", err,
+					)
+				}
+				if attempted, err := n.gossipCancelAllOrders(); err != nil {
+					log.Warn(
+						"failed to send cancel all messages",
+						"len(cancels)", attempted,
+						"err", err,
+					)
+				}
+				if attempted, err := n.gossipHeartbeats(); err != nil {
+					log.Warn(
+						"failed to send heartbeats",
+						"len(heartbeats)", attempted,
+						"err", err,
+					)
+				}
+				if attempted, err := n.gossipTriggerOrders(); err != nil {
+					log.Warn(
+						"failed to send trigger orders",
+						"len(orders)", attempted,
+						"err", err,
+					)
+				}
 			case orders := <-n.ordersToGossipChan:
 				for _, order := range orders {
 					n.ordersToGossip = append(n.ordersToGossip, order)
@@ -113,6 +217,42 @@ func (n *orderPushGossiper) awaitSignedOrderGossip() {
 						"err", err,
 					)
 				}
+			case amendments := <-n.amendmentsToGossipChan:
+				n.amendmentsToGossip = append(n.amendmentsToGossip, amendments...)
+				if attempted, err := n.gossipSignedOrderAmendments(); err != nil {
+					log.Warn(
+						"failed to send signed order amendments",
+						"len(amendments)", attempted,
+						"err", err,
+					)
+				}
+			case cancels := <-n.cancelsToGossipChan:
+				n.cancelsToGossip = append(n.cancelsToGossip, cancels...)
+				if attempted, err := n.gossipCancelAllOrders(); err != nil {
+					log.Warn(
+						"failed to send cancel all messages",
+						"len(cancels)", attempted,
+						"err", err,
+					)
+				}
+			case heartbeats := <-n.heartbeatsToGossipChan:
+				n.heartbeatsToGossip = append(n.heartbeatsToGossip, heartbeats...)
+				if attempted, err := n.gossipHeartbeats(); err != nil {
+					log.Warn(
+						"failed to send heartbeats",
+						"len(heartbeats)", attempted,
+						"err", err,
+					)
+				}
+			case orders := <-n.triggerOrdersToGossipChan:
+				n.triggerOrdersToGossip = append(n.triggerOrdersToGossip, orders...)
+				if attempted, err := n.gossipTriggerOrders(); err != nil {
+					log.Warn(
+						"failed to send trigger orders",
+						"len(orders)", attempted,
+						"err", err,
+					)
+				}
 			case <-n.shutdownChan:
 				return
 			}
@@ -159,24 +299,291 @@ func (n *orderPushGossiper) sendSignedOrders(orders []*hu.SignedOrder) error {
 		return nil
 	}
 
-	var buf bytes.Buffer
-	err := gob.NewEncoder(&buf).Encode(&orders)
+	encodedOrders, err := encodeSignedOrders(orders)
 	if err != nil {
 		return err
 	}
-	ordersBytes := buf.Bytes()
-	msg := message.SignedOrdersGossip{
-		Orders: ordersBytes,
+	msg := message.EncodedSignedOrdersGossip{
+		Version: message.SignedOrdersEncodingV1,
+		Orders:  encodedOrders,
 	}
 	msgBytes, err := message.BuildGossipMessage(n.codec, msg)
 	if err != nil {
 		return err
 	}
+	gossipMsgs := [][]byte{msgBytes}
+
+	// nodes that haven't upgraded drop EncodedSignedOrdersGossip, they get the orders in the legacy message till the activation
+	if !hu.IsEncodedSignedOrdersGossipActive(uint64(time.Now().Unix())) {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(&orders); err != nil {
+			return err
+		}
+		legacyMsgBytes, err := message.BuildGossipMessage(n.codec, message.SignedOrdersGossip{Orders: buf.Bytes()})
+		if err != nil {
+			return err
+		}
+		gossipMsgs = append(gossipMsgs, legacyMsgBytes)
+	}
 
 	log.Trace(
 		"gossiping signed orders",
 		"len(orders)", len(orders),
-		"size(orders)", len(msg.Orders),
+		"size(msg)", len(msgBytes),
+		"len(msgs)", len(gossipMsgs),
+	)
+
+	validators := n.config.OrderGossipNumValidators
+	nonValidators := n.config.OrderGossipNumNonValidators
+	peers := n.config.OrderGossipNumPeers
+	for _, gossipMsg := range gossipMsgs {
+		err = n.appSender.SendAppGossip(context.TODO(), gossipMsg, validators, nonValidators, peers)
+		if err != nil {
+			log.Error("failed to gossip orders")
+			return err
+		}
+	}
+	n.stats.IncSignedOrdersGossipSent(int64(len(orders)))
+	n.stats.IncSignedOrdersGossipBatchSent()
+	return nil
+}
+
+func (n *orderPushGossiper) gossipSignedOrderAmendments() (int, error) {
+	if (time.Since(n.lastAmendmentsGossiped) < minGossipOrdersBatchInterval) || len(n.amendmentsToGossip) == 0 {
+		return 0, nil
+	}
+	n.lastAmendmentsGossiped = time.Now()
+	now := time.Now().Unix()
+	selectedAmendments := []*SignedOrderAmendment{}
+	numConsumed := 0
+	for _, amendment := range n.amendmentsToGossip {
+		if len(selectedAmendments) >= maxSignedOrdersGossipBatchSize {
+			break
+		}
+		numConsumed++
+		if amendment.Order.ExpireAt.Int64() < now {
+			n.stats.IncSignedOrdersGossipOrderExpired()
+			log.Warn("signed order amendment expired before gossip", "amendment", amendment, "now", now)
+			continue
+		}
+		selectedAmendments = append(selectedAmendments, amendment)
+	}
+	n.amendmentsToGossip = n.amendmentsToGossip[numConsumed:]
+
+	if len(selectedAmendments) == 0 {
+		return 0, nil
+	}
+
+	err := n.sendSignedOrderAmendments(selectedAmendments)
+	if err != nil {
+		n.stats.IncSignedOrdersGossipSendError()
+	}
+	return len(selectedAmendments), err
+}
+
+func (n *orderPushGossiper) sendSignedOrderAmendments(amendments []*SignedOrderAmendment) error {
+	if len(amendments) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&amendments)
+	if err != nil {
+		return err
+	}
+	msg := message.SignedOrderAmendmentsGossip{
+		Amendments: buf.Bytes(),
+	}
+	msgBytes, err := message.BuildGossipMessage(n.codec, msg)
+	if err != nil {
+		return err
+	}
+
+	log.Trace(
+		"gossiping signed order amendments",
+		"len(amendments)", len(amendments),
+		"size(amendments)", len(msg.Amendments),
+	)
+
+	validators := n.config.OrderGossipNumValidators
+	nonValidators := n.config.OrderGossipNumNonValidators
+	peers := n.config.OrderGossipNumPeers
+	err = n.appSender.SendAppGossip(context.TODO(), msgBytes, validators, nonValidators, peers)
+	if err != nil {
+		log.Error("failed to gossip signed order amendments")
+		return err
+	}
+	n.stats.IncSignedOrdersGossipSent(int64(len(amendments)))
+	n.stats.IncSignedOrdersGossipBatchSent()
+	return nil
+}
+
+func (n *orderPushGossiper) gossipCancelAllOrders() (int, error) {
+	if (time.Since(n.lastCancelsGossiped) < minGossipOrdersBatchInterval) || len(n.cancelsToGossip) == 0 {
+		return 0, nil
+	}
+	n.lastCancelsGossiped = time.Now()
+	numConsumed := len(n.cancelsToGossip)
+	if numConsumed > maxSignedOrdersGossipBatchSize {
+		numConsumed = maxSignedOrdersGossipBatchSize
+	}
+	selectedCancels := n.cancelsToGossip[:numConsumed]
+	n.cancelsToGossip = n.cancelsToGossip[numConsumed:]
+
+	err := n.sendCancelAllOrders(selectedCancels)
+	if err != nil {
+		n.stats.IncCancelAllGossipSendError()
+	}
+	return len(selectedCancels), err
+}
+
+func (n *orderPushGossiper) sendCancelAllOrders(cancels []*hu.CancelAllOrders) error {
+	if len(cancels) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&cancels)
+	if err != nil {
+		return err
+	}
+	msg := message.CancelAllOrdersGossip{
+		Cancels: buf.Bytes(),
+	}
+	msgBytes, err := message.BuildGossipMessage(n.codec, msg)
+	if err != nil {
+		return err
+	}
+
+	log.Trace(
+		"gossiping cancel all messages",
+		"len(cancels)", len(cancels),
+		"size(cancels)", len(msg.Cancels),
+	)
+
+	validators := n.config.OrderGossipNumValidators
+	nonValidators := n.config.OrderGossipNumNonValidators
+	peers := n.config.OrderGossipNumPeers
+	err = n.appSender.SendAppGossip(context.TODO(), msgBytes, validators, nonValidators, peers)
+	if err != nil {
+		log.Error("failed to gossip cancel all messages")
+		return err
+	}
+	n.stats.IncCancelAllGossipSent(int64(len(cancels)))
+	n.stats.IncCancelAllGossipBatchSent()
+	return nil
+}
+
+func (n *orderPushGossiper) gossipHeartbeats() (int, error) {
+	if (time.Since(n.lastHeartbeatsGossiped) < minGossipOrdersBatchInterval) || len(n.heartbeatsToGossip) == 0 {
+		return 0, nil
+	}
+	n.lastHeartbeatsGossiped = time.Now()
+	numConsumed := len(n.heartbeatsToGossip)
+	if numConsumed > maxSignedOrdersGossipBatchSize {
+		numConsumed = maxSignedOrdersGossipBatchSize
+	}
+	selectedHeartbeats := n.heartbeatsToGossip[:numConsumed]
+	n.heartbeatsToGossip = n.heartbeatsToGossip[numConsumed:]
+
+	err := n.sendHeartbeats(selectedHeartbeats)
+	if err != nil {
+		n.stats.IncHeartbeatsGossipSendError()
+	}
+	return len(selectedHeartbeats), err
+}
+
+func (n *orderPushGossiper) sendHeartbeats(heartbeats []*hu.Heartbeat) error {
+	if len(heartbeats) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&heartbeats)
+	if err != nil {
+		return err
+	}
+	msg := message.HeartbeatsGossip{
+		Heartbeats: buf.Bytes(),
+	}
+	msgBytes, err := message.BuildGossipMessage(n.codec, msg)
+	if err != nil {
+		return err
+	}
+
+	log.Trace(
+		"gossiping heartbeats",
+		"len(heartbeats)", len(heartbeats),
+		"size(heartbeats)", len(msg.Heartbeats),
+	)
+
+	validators := n.config.OrderGossipNumValidators
+	nonValidators := n.config.OrderGossipNumNonValidators
+	peers := n.config.OrderGossipNumPeers
+	err = n.appSender.SendAppGossip(context.TODO(), msgBytes, validators, nonValidators, peers)
+	if err != nil {
+		log.Error("failed to gossip heartbeats")
+		return err
+	}
+	n.stats.IncHeartbeatsGossipSent(int64(len(heartbeats)))
+	n.stats.IncHeartbeatsGossipBatchSent()
+	return nil
+}
+
+func (n *orderPushGossiper) gossipTriggerOrders() (int, error) {
+	if (time.Since(n.lastTriggerOrdersGossiped) < minGossipOrdersBatchInterval) || len(n.triggerOrdersToGossip) == 0 {
+		return 0, nil
+	}
+	n.lastTriggerOrdersGossiped = time.Now()
+	now := time.Now().Unix()
+	selectedOrders := []*hu.TriggerOrder{}
+	numConsumed := 0
+	for _, order := range n.triggerOrdersToGossip {
+		if len(selectedOrders) >= maxSignedOrdersGossipBatchSize {
+			break
+		}
+		numConsumed++
+		if order.ExpireAt.Int64() < now {
+			n.stats.IncSignedOrdersGossipOrderExpired()
+			log.Warn("trigger order expired before gossip", "order", order, "now", now)
+			continue
+		}
+		selectedOrders = append(selectedOrders, order)
+	}
+	n.triggerOrdersToGossip = n.triggerOrdersToGossip[numConsumed:]
+
+	if len(selectedOrders) == 0 {
+		return 0, nil
+	}
+
+	err := n.sendTriggerOrders(selectedOrders)
+	if err != nil {
+		n.stats.IncSignedOrdersGossipSendError()
+	}
+	return len(selectedOrders), err
+}
+
+func (n *orderPushGossiper) sendTriggerOrders(orders []*hu.TriggerOrder) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	encodedOrders, err := encodeTriggerOrders(orders)
+	if err != nil {
+		return err
+	}
+	msg := message.TriggerOrdersGossip{
+		Orders: encodedOrders,
+	}
+	msgBytes, err := message.BuildGossipMessage(n.codec, msg)
+	if err != nil {
+		return err
+	}
+
+	log.Trace(
+		"gossiping trigger orders",
+		"len(orders)", len(orders),
+		"size(msg)", len(msgBytes),
 	)
 
 	validators := n.config.OrderGossipNumValidators
@@ -184,7 +591,7 @@ func (n *orderPushGossiper) sendSignedOrders(orders []*hu.SignedOrder) error {
 	peers := n.config.OrderGossipNumPeers
 	err = n.appSender.SendAppGossip(context.TODO(), msgBytes, validators, nonValidators, peers)
 	if err != nil {
-		log.Error("failed to gossip orders")
+		log.Error("failed to gossip trigger orders")
 		return err
 	}
 	n.stats.IncSignedOrdersGossipSent(int64(len(orders)))
@@ -0,0 +1,64 @@
+package evm
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	commonEng "github.com/ava-labs/avalanchego/snow/engine/common"
+	"github.com/ava-labs/subnet-evm/plugin/evm/message"
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendSignedOrders(t *testing.T) {
+	defer func(activationTime uint64) { hu.EncodedSignedOrdersGossipActivationTime = activationTime }(hu.EncodedSignedOrdersGossipActivationTime)
+
+	orders := []*hu.SignedOrder{{
+		LimitOrder: hu.LimitOrder{
+			BaseOrder: hu.BaseOrder{
+				AmmIndex:          big.NewInt(0),
+				Trader:            common.HexToAddress("0x22Bb736b64A0b4D4081E103f83bccF864F0404aa"),
+				BaseAssetQuantity: big.NewInt(1e18),
+				Price:             big.NewInt(10e6),
+				Salt:              big.NewInt(1),
+			},
+		},
+		OrderType: uint8(hu.Signed),
+		ExpireAt:  big.NewInt(1e10),
+		Sig:       []byte{},
+	}}
+
+	getSentMessages := func(t *testing.T) []message.GossipMessage {
+		sent := []message.GossipMessage{}
+		gossiper := &orderPushGossiper{
+			codec: message.Codec,
+			stats: NewGossipStats(),
+			appSender: &commonEng.SenderTest{
+				SendAppGossipF: func(_ context.Context, msgBytes []byte, _ int, _ int, _ int) error {
+					msg, err := message.ParseGossipMessage(message.Codec, msgBytes)
+					assert.Nil(t, err)
+					sent = append(sent, msg)
+					return nil
+				},
+			},
+		}
+		assert.Nil(t, gossiper.sendSignedOrders(orders))
+		return sent
+	}
+
+	t.Run("legacy message is sent too before the activation", func(t *testing.T) {
+		sent := getSentMessages(t)
+		assert.Equal(t, 2, len(sent))
+		assert.IsType(t, message.EncodedSignedOrdersGossip{}, sent[0])
+		assert.IsType(t, message.SignedOrdersGossip{}, sent[1])
+	})
+
+	t.Run("only the encoded message is sent after the activation", func(t *testing.T) {
+		hu.EncodedSignedOrdersGossipActivationTime = 0
+		sent := getSentMessages(t)
+		assert.Equal(t, 1, len(sent))
+		assert.IsType(t, message.EncodedSignedOrdersGossip{}, sent[0])
+	})
+}
@@ -6,10 +6,15 @@ package evm
 import (
 	"bytes"
 	"encoding/gob"
+	"errors"
 	"sync"
+	"time"
 
+	"github.com/ava-labs/avalanchego/cache"
 	"github.com/ava-labs/avalanchego/ids"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rlp"
 
@@ -19,19 +24,36 @@ import (
 	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
 )
 
+// seenSignedOrdersCacheSize is the number of encoded signed orders whose hash is remembered to skip them when they are gossiped again
+const seenSignedOrdersCacheSize = 20_000
+
+const (
+	// maxLegacySignedOrderSize bounds the size of a gob encoded signed order in SignedOrdersGossip, about 180 bytes with a 65 byte signature
+	maxLegacySignedOrderSize = 256
+	// legacySignedOrdersGossipOverhead bounds the gob type information at the start of SignedOrdersGossip
+	legacySignedOrdersGossipOverhead = 1024
+)
+
 // GossipHandler handles incoming gossip messages
 type GossipHandler struct {
 	mu     sync.RWMutex
 	vm     *VM
 	txPool *txpool.TxPool
 	stats  GossipStats
+
+	// hashes of the encoded signed orders that are in the makerbook or were in it, see HandleEncodedSignedOrders
+	seenSignedOrders *cache.LRU[common.Hash, struct{}]
+	// per peer budget of signed orders, see orderGossipLimiter
+	orderGossipLimiter *orderGossipLimiter
 }
 
 func NewGossipHandler(vm *VM, stats GossipStats) *GossipHandler {
 	return &GossipHandler{
-		vm:     vm,
-		txPool: vm.txPool,
-		stats:  stats,
+		vm:                 vm,
+		txPool:             vm.txPool,
+		stats:              stats,
+		seenSignedOrders:   &cache.LRU[common.Hash, struct{}]{Size: seenSignedOrdersCacheSize},
+		orderGossipLimiter: vm.orderGossipLimiter,
 	}
 }
 
@@ -81,10 +103,8 @@ func (h *GossipHandler) HandleEthTxs(nodeID ids.NodeID, msg message.EthTxsGossip
 	return nil
 }
 
+// HandleSignedOrders handles the gob encoded signed orders of the nodes that don't send EncodedSignedOrdersGossip yet
 func (h *GossipHandler) HandleSignedOrders(nodeID ids.NodeID, msg message.SignedOrdersGossip) error {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-
 	log.Trace(
 		"AppGossip called with SignedOrdersGossip",
 		"peerID", nodeID,
@@ -99,41 +119,371 @@ func (h *GossipHandler) HandleSignedOrders(nodeID ids.NodeID, msg message.Signed
 		return nil
 	}
 
+	// the orders are counted after the blob is decoded, so a peer without budget or sending more bytes than its budget of orders
+	// can take is dropped before decoding
+	budget := h.orderGossipLimiter.available(nodeID, time.Now())
+	if budget == 0 || (len(msg.Orders)-legacySignedOrdersGossipOverhead)/maxLegacySignedOrderSize >= budget {
+		h.stats.IncSignedOrdersGossipRateLimited(1)
+		log.Debug("dropping SignedOrdersGossip over the peer's order budget", "peerID", nodeID, "bytes(orders)", len(msg.Orders), "budget", budget)
+		return nil
+	}
+
 	orders := make([]*hu.SignedOrder, 0)
 	buf := bytes.NewBuffer(msg.Orders)
 	err := gob.NewDecoder(buf).Decode(&orders)
 	if err != nil {
 		log.Error("failed to decode signed orders", "err", err)
+		h.reportInvalidSignedOrders(nodeID, 1)
 		return err
 	}
 
 	h.stats.IncSignedOrdersGossipReceived(int64(len(orders)))
 	h.stats.IncSignedOrdersGossipBatchReceived()
 
+	// upgraded nodes send the orders in EncodedSignedOrdersGossip too till hu.EncodedSignedOrdersGossipActivationTime.
+	// The orders are re-encoded so that the ones already received in that message are skipped and don't use the peer's budget twice
+	encodedOrders, err := encodeSignedOrders(orders)
+	if err != nil {
+		log.Debug("failed to encode signed orders", "peerID", nodeID, "err", err)
+		h.reportInvalidSignedOrders(nodeID, 1)
+		return nil
+	}
+	h.handleEncodedSignedOrders(nodeID, message.SignedOrdersEncodingV1, encodedOrders)
+	return nil
+}
+
+func (h *GossipHandler) HandleEncodedSignedOrders(nodeID ids.NodeID, msg message.EncodedSignedOrdersGossip) error {
+	log.Trace(
+		"AppGossip called with EncodedSignedOrdersGossip",
+		"peerID", nodeID,
+		"version", msg.Version,
+		"len(orders)", len(msg.Orders),
+	)
+
+	if len(msg.Orders) == 0 {
+		log.Warn(
+			"AppGossip received empty EncodedSignedOrdersGossip Message",
+			"peerID", nodeID,
+		)
+		return nil
+	}
+
+	h.stats.IncSignedOrdersGossipReceived(int64(len(msg.Orders)))
+	h.stats.IncSignedOrdersGossipBatchReceived()
+
+	h.handleEncodedSignedOrders(nodeID, msg.Version, msg.Orders)
+	return nil
+}
+
+// handleEncodedSignedOrders places the gossiped signed orders that were not seen before, within the peer's budget
+func (h *GossipHandler) handleEncodedSignedOrders(nodeID ids.NodeID, version uint8, gossipedOrders [][]byte) {
+	// orders seen in an earlier message are skipped before they are decoded and their signature is verified.
+	// The encoding is deterministic, so the hash of an encoded order identifies the order
+	encodedOrders := make([][]byte, 0, len(gossipedOrders))
+	orderKeys := make([]common.Hash, 0, len(gossipedOrders))
+	for _, encodedOrder := range gossipedOrders {
+		key := crypto.Keccak256Hash(encodedOrder)
+		if _, seen := h.seenSignedOrders.Get(key); seen {
+			h.stats.IncSignedOrdersGossipReceivedKnown()
+			continue
+		}
+		encodedOrders = append(encodedOrders, encodedOrder)
+		orderKeys = append(orderKeys, key)
+	}
+	if len(encodedOrders) == 0 {
+		return
+	}
+
+	// only the new orders use the peer's budget
+	allowed := h.orderGossipLimiter.allow(nodeID, len(encodedOrders), time.Now())
+	if allowed < len(encodedOrders) {
+		h.stats.IncSignedOrdersGossipRateLimited(int64(len(encodedOrders) - allowed))
+		encodedOrders = encodedOrders[:allowed]
+	}
+
+	orders := make([]*hu.SignedOrder, 0, len(encodedOrders))
+	decodedOrderKeys := make([]common.Hash, 0, len(encodedOrders))
+	numInvalid := 0
+	for i, encodedOrder := range encodedOrders {
+		order, err := decodeSignedOrder(version, encodedOrder)
+		if err != nil {
+			h.stats.IncSignedOrdersGossipReceiveError()
+			log.Debug("failed to decode signed order", "peerID", nodeID, "err", err)
+			numInvalid++
+			continue
+		}
+		orders = append(orders, order)
+		decodedOrderKeys = append(decodedOrderKeys, orderKeys[i])
+	}
+	h.reportInvalidSignedOrders(nodeID, numInvalid)
+	if len(orders) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, known := range h.placeSignedOrders(nodeID, orders) {
+		if known {
+			h.seenSignedOrders.Put(decodedOrderKeys[i], struct{}{})
+		}
+	}
+}
+
+// placeSignedOrders places gossiped signed orders and gossips the ones that were new to the node.
+// Returns whether each order is in the node's makerbook now, either because it was placed or because it was already there
+func (h *GossipHandler) placeSignedOrders(nodeID ids.NodeID, orders []*hu.SignedOrder) []bool {
 	tradingAPI := h.vm.limitOrderProcesser.GetTradingAPI()
 
 	// re-gossip orders, but not when we already knew the orders
 	ordersToGossip := make([]*hu.SignedOrder, 0)
-	for _, order := range orders {
-		_, shouldTriggerMatching, err := tradingAPI.PlaceOrder(order)
+	known := make([]bool, len(orders))
+	numInvalid := 0
+	_, shouldTriggerMatching, errs := tradingAPI.PlaceOrders(orders)
+	for i, order := range orders {
+		err := errs[i]
 		if err == nil {
 			h.stats.IncSignedOrdersGossipReceivedNew()
 			ordersToGossip = append(ordersToGossip, order)
-			if shouldTriggerMatching {
-				log.Info("received new match-able signed order, triggering matching pipeline...")
-				h.vm.limitOrderProcesser.RunMatchingPipeline()
-			}
+			known[i] = true
 		} else if err == hu.ErrOrderAlreadyExists {
 			h.stats.IncSignedOrdersGossipReceivedKnown()
+			known[i] = true
 		} else {
 			h.stats.IncSignedOrdersGossipReceiveError()
-			log.Error("failed to place order", "err", err)
+			log.Debug("failed to place order", "peerID", nodeID, "err", err)
+			if isInvalidGossipedOrder(err) {
+				numInvalid++
+			}
 		}
 	}
+	h.reportInvalidSignedOrders(nodeID, numInvalid)
+	if shouldTriggerMatching {
+		log.Info("received new match-able signed orders, triggering matching pipeline...")
+		h.vm.limitOrderProcesser.RunMatchingPipeline()
+	}
 
 	if len(ordersToGossip) > 0 {
 		h.vm.orderGossiper.GossipSignedOrders(ordersToGossip)
 	}
+	return known
+}
+
+// isInvalidGossipedOrder returns true if a gossiped order was rejected for a reason that doesn't depend on the chain or the makerbook,
+// so that an honest peer wouldn't have relayed it: a bad signature or a signer that isn't the trader's trading authority, or a
+// malformed order. Orders that are expired, filled, cancelled or without margin on this node are relayed routinely by honest peers
+// whose state lags, and don't count towards muting the peer.
+func isInvalidGossipedOrder(err error) bool {
+	if hu.IsSignatureError(err) {
+		return true
+	}
+	switch err {
+	case hu.ErrNoTradingAuthority,
+		hu.ErrNotSignedOrder, hu.ErrNotPostOnly, hu.ErrBaseAssetQuantityZero, hu.ErrInvalidPrice,
+		hu.ErrNotTriggerOrder, hu.ErrPostOnlyTriggerOrder, hu.ErrInvalidTriggerPrice, hu.ErrInvalidTriggerKind:
+		return true
+	}
+	return false
+}
+
+// reportInvalidSignedOrders counts the invalid signed orders gossiped by the peer, and mutes it if it sent too many
+func (h *GossipHandler) reportInvalidSignedOrders(nodeID ids.NodeID, numInvalid int) {
+	if numInvalid == 0 {
+		return
+	}
+	h.stats.IncSignedOrdersGossipReceivedInvalid(nodeID, int64(numInvalid))
+	if h.orderGossipLimiter.reportInvalid(nodeID, numInvalid, time.Now()) {
+		h.stats.IncSignedOrdersGossipPeerMuted()
+		log.Warn("muting peer that gossiped too many invalid signed orders", "peerID", nodeID, "mutedFor", h.orderGossipLimiter.muteDuration)
+	}
+}
+
+func (h *GossipHandler) HandleSignedOrderAmendments(nodeID ids.NodeID, msg message.SignedOrderAmendmentsGossip) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	tradingAPI := h.vm.limitOrderProcesser.GetTradingAPI()
+	stats := messageGossipStats{
+		received:      h.stats.IncSignedOrdersGossipReceived,
+		batchReceived: h.stats.IncSignedOrdersGossipBatchReceived,
+		receivedKnown: h.stats.IncSignedOrdersGossipReceivedKnown,
+		receivedNew:   h.stats.IncSignedOrdersGossipReceivedNew,
+		receiveError:  h.stats.IncSignedOrdersGossipReceiveError,
+	}
+	return handleMessagesGossip(nodeID, "SignedOrderAmendmentsGossip", msg.Amendments, stats, hu.ErrOrderAlreadyExists, func(amendment *SignedOrderAmendment) error {
+		if amendment.Order == nil || amendment.Cancel == nil || amendment.Cancel.Order == nil {
+			// amendments from nodes that don't cancel the amended order
+			return errors.New("amendment without a cancel message")
+		}
+		_, shouldTriggerMatching, err := tradingAPI.AmendOrder(amendment.Cancel, amendment.Order)
+		if err == nil && shouldTriggerMatching {
+			log.Info("received new match-able signed order amendment, triggering matching pipeline...")
+			h.vm.limitOrderProcesser.RunMatchingPipeline()
+		}
+		return err
+	}, h.vm.orderGossiper.GossipSignedOrderAmendments)
+}
+
+func (h *GossipHandler) HandleCancelAllOrders(nodeID ids.NodeID, msg message.CancelAllOrdersGossip) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	tradingAPI := h.vm.limitOrderProcesser.GetTradingAPI()
+	stats := messageGossipStats{
+		received:      h.stats.IncCancelAllGossipReceived,
+		batchReceived: h.stats.IncCancelAllGossipBatchReceived,
+		receivedKnown: h.stats.IncCancelAllGossipReceivedKnown,
+		receivedNew:   h.stats.IncCancelAllGossipReceivedNew,
+		receiveError:  h.stats.IncCancelAllGossipReceiveError,
+	}
+	return handleMessagesGossip(nodeID, "CancelAllOrdersGossip", msg.Cancels, stats, hu.ErrStaleCancelNonce, func(cancel *hu.CancelAllOrders) error {
+		_, err := tradingAPI.CancelAllOrders(cancel)
+		return err
+	}, h.vm.orderGossiper.GossipCancelAllOrders)
+}
+
+func (h *GossipHandler) HandleHeartbeats(nodeID ids.NodeID, msg message.HeartbeatsGossip) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	tradingAPI := h.vm.limitOrderProcesser.GetTradingAPI()
+	stats := messageGossipStats{
+		received:      h.stats.IncHeartbeatsGossipReceived,
+		batchReceived: h.stats.IncHeartbeatsGossipBatchReceived,
+		receivedKnown: h.stats.IncHeartbeatsGossipReceivedKnown,
+		receivedNew:   h.stats.IncHeartbeatsGossipReceivedNew,
+		receiveError:  h.stats.IncHeartbeatsGossipReceiveError,
+	}
+	return handleMessagesGossip(nodeID, "HeartbeatsGossip", msg.Heartbeats, stats, hu.ErrStaleHeartbeat, tradingAPI.Heartbeat, h.vm.orderGossiper.GossipHeartbeats)
+}
+
+// messageGossipStats are the counters of a type of signed message, see handleMessagesGossip
+type messageGossipStats struct {
+	received      func(count int64)
+	batchReceived func()
+	receivedKnown func()
+	receivedNew   func()
+	receiveError  func()
+}
+
+// handleMessagesGossip decodes a gob encoded batch of signed messages gossiped by a peer and applies each of them. The messages that were
+// new to this node are gossiped again, apply returns knownErr for the ones that it already had.
+func handleMessagesGossip[T any](nodeID ids.NodeID, name string, data []byte, stats messageGossipStats, knownErr error, apply func(msg T) error, gossip func(msgs []T) error) error {
+	log.Trace(
+		"AppGossip called with "+name,
+		"peerID", nodeID,
+		"bytes", len(data),
+	)
+
+	if len(data) == 0 {
+		log.Warn(
+			"AppGossip received empty "+name+" Message",
+			"peerID", nodeID,
+		)
+		return nil
+	}
+
+	msgs := make([]T, 0)
+	err := gob.NewDecoder(bytes.NewBuffer(data)).Decode(&msgs)
+	if err != nil {
+		log.Error("failed to decode "+name, "err", err)
+		return err
+	}
+
+	stats.received(int64(len(msgs)))
+	stats.batchReceived()
+
+	// re-gossip messages, but not when we already knew them
+	msgsToGossip := make([]T, 0)
+	for _, msg := range msgs {
+		err := apply(msg)
+		if err == nil {
+			stats.receivedNew()
+			msgsToGossip = append(msgsToGossip, msg)
+		} else if err == knownErr {
+			stats.receivedKnown()
+		} else {
+			// includes messages that are not active yet, when the peer's clock is ahead of ours around the activation time
+			stats.receiveError()
+			log.Error("failed to apply "+name+" message", "err", err)
+		}
+	}
+
+	if len(msgsToGossip) > 0 {
+		gossip(msgsToGossip)
+	}
+
+	return nil
+}
+
+func (h *GossipHandler) HandleTriggerOrders(nodeID ids.NodeID, msg message.TriggerOrdersGossip) error {
+	log.Trace(
+		"AppGossip called with TriggerOrdersGossip",
+		"peerID", nodeID,
+		"len(orders)", len(msg.Orders),
+	)
+
+	if len(msg.Orders) == 0 {
+		log.Warn(
+			"AppGossip received empty TriggerOrdersGossip Message",
+			"peerID", nodeID,
+		)
+		return nil
+	}
+
+	h.stats.IncSignedOrdersGossipReceived(int64(len(msg.Orders)))
+	h.stats.IncSignedOrdersGossipBatchReceived()
+
+	encodedOrders := msg.Orders
+	allowed := h.orderGossipLimiter.allow(nodeID, len(encodedOrders), time.Now())
+	if allowed < len(encodedOrders) {
+		h.stats.IncSignedOrdersGossipRateLimited(int64(len(encodedOrders) - allowed))
+		encodedOrders = encodedOrders[:allowed]
+	}
+
+	orders := make([]*hu.TriggerOrder, 0, len(encodedOrders))
+	numInvalid := 0
+	for _, encodedOrder := range encodedOrders {
+		order, err := hu.DecodeTriggerOrder(encodedOrder)
+		if err != nil {
+			h.stats.IncSignedOrdersGossipReceiveError()
+			log.Debug("failed to decode trigger order", "peerID", nodeID, "err", err)
+			numInvalid++
+			continue
+		}
+		orders = append(orders, order)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	tradingAPI := h.vm.limitOrderProcesser.GetTradingAPI()
+
+	// re-gossip orders, but not when we already knew the orders
+	ordersToGossip := make([]*hu.TriggerOrder, 0)
+	for _, order := range orders {
+		_, err := tradingAPI.PlaceTriggerOrder(order)
+		if err == nil {
+			h.stats.IncSignedOrdersGossipReceivedNew()
+			ordersToGossip = append(ordersToGossip, order)
+		} else if err == hu.ErrOrderAlreadyExists {
+			h.stats.IncSignedOrdersGossipReceivedKnown()
+		} else if err == hu.ErrTriggerOrdersNotActive {
+			// the peer's clock is ahead of ours around the activation time
+			h.stats.IncSignedOrdersGossipReceiveError()
+		} else {
+			h.stats.IncSignedOrdersGossipReceiveError()
+			log.Debug("failed to place trigger order", "peerID", nodeID, "err", err)
+			if isInvalidGossipedOrder(err) {
+				numInvalid++
+			}
+		}
+	}
+	h.reportInvalidSignedOrders(nodeID, numInvalid)
+
+	if len(ordersToGossip) > 0 {
+		h.vm.orderGossiper.GossipTriggerOrders(ordersToGossip)
+	}
 
 	return nil
 }
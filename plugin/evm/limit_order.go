@@ -1,7 +1,6 @@
 package evm
 
 import (
-	"bytes"
 	"context"
 	"encoding/gob"
 	"fmt"
@@ -10,6 +9,7 @@ import (
 	"runtime"
 	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ava-labs/subnet-evm/core"
@@ -28,8 +28,7 @@ import (
 )
 
 const (
-	memoryDBSnapshotKey string = "memoryDBSnapshot"
-	snapshotInterval    uint64 = 10 // save snapshot every 1000 blocks
+	snapshotInterval uint64 = 10 // save snapshot every 1000 blocks
 )
 
 type LimitOrderProcesser interface {
@@ -60,10 +59,16 @@ type limitOrderProcesser struct {
 	blockBuilder             *blockBuilder
 	isValidator              bool
 	tradingAPIEnabled        bool
+	tradingAPIRequireAuth    bool
 	loadFromSnapshotEnabled  bool
 	snapshotSavedBlockNumber uint64
 	snapshotFilePath         string
 	tradingAPI               *orderbook.TradingAPI
+	wal                      *memoryDBWAL
+	walDisabled              bool        // set if an append fails, so that the WAL never has a gap
+	compactionRunning        atomic.Bool // only one base snapshot compaction runs at a time
+	stateAuditInterval       time.Duration
+	tradeIndexer             *orderbook.TradeIndexer // nil if the trade indexer is disabled
 }
 
 func NewLimitOrderProcesser(ctx *snow.Context, txPool *txpool.TxPool, shutdownChan <-chan struct{}, shutdownWg *sync.WaitGroup, backend *eth.EthAPIBackend, blockChain *core.BlockChain, hubbleDB database.Database, validatorPrivateKey string, config Config) LimitOrderProcesser {
@@ -88,6 +93,12 @@ func NewLimitOrderProcesser(ctx *snow.Context, txPool *txpool.TxPool, shutdownCh
 	gob.RegisterName("*orderbook.LimitOrder", &hu.LimitOrder{})
 	gob.RegisterName("*orderbook.IOCOrder", &hu.IOCOrder{})
 	gob.Register(&hu.SignedOrder{})
+	gob.Register(&hu.TriggerOrder{})
+
+	var tradeIndexer *orderbook.TradeIndexer
+	if config.TradeIndexerEnabled {
+		tradeIndexer = orderbook.NewTradeIndexer(hubbleDB)
+	}
 	return &limitOrderProcesser{
 		ctx:                     ctx,
 		mu:                      &sync.Mutex{},
@@ -105,8 +116,12 @@ func NewLimitOrderProcesser(ctx *snow.Context, txPool *txpool.TxPool, shutdownCh
 		configService:           configService,
 		isValidator:             config.IsValidator,
 		tradingAPIEnabled:       config.TradingAPIEnabled,
+		tradingAPIRequireAuth:   config.TradingAPIRequireAuth,
 		loadFromSnapshotEnabled: config.LoadFromSnapshotEnabled,
 		snapshotFilePath:        config.SnapshotFilePath,
+		wal:                     newMemoryDBWAL(hubbleDB),
+		stateAuditInterval:      config.StateAuditInterval.Duration,
+		tradeIndexer:            tradeIndexer,
 	}
 }
 
@@ -122,7 +137,8 @@ func (lop *limitOrderProcesser) ListenAndProcessTransactions(blockBuilder *block
 			// first load the last snapshot containing finalised data till block x and query the logs of [x+1, latest]
 			acceptedBlockNumber, err := lop.loadMemoryDBSnapshot()
 			if err != nil {
-				log.Error("ListenAndProcessTransactions - error in loading snapshot", "err", err)
+				// memory DB is untouched when the snapshot is rejected, so it is safe to replay all the logs
+				log.Error("ListenAndProcessTransactions - error in loading snapshot, replaying logs from genesis", "err", err)
 			} else {
 				if acceptedBlockNumber > 0 {
 					fromBlock = big.NewInt(int64(acceptedBlockNumber) + 1)
@@ -138,6 +154,7 @@ func (lop *limitOrderProcesser) ListenAndProcessTransactions(blockBuilder *block
 		logHandler := log.Root().GetHandler()
 		errorOnlyHandler := ErrorOnlyHandler(logHandler)
 		log.Info("ListenAndProcessTransactions - beginning sync", " till block number", lastAcceptedBlockNumber)
+		lop.startIndexing(fromBlock.Uint64(), lastAccepted.Time())
 		JUMP := big.NewInt(3999)
 		toBlock := utils.BigIntMin(lastAcceptedBlockNumber, big.NewInt(0).Add(fromBlock, JUMP))
 		for toBlock.Cmp(fromBlock) > 0 {
@@ -146,7 +163,9 @@ func (lop *limitOrderProcesser) ListenAndProcessTransactions(blockBuilder *block
 			log.Root().SetHandler(errorOnlyHandler)
 			lop.contractEventProcessor.ProcessEvents(logs)
 			lop.contractEventProcessor.ProcessAcceptedEvents(logs, true)
+			lop.indexAcceptedLogs(logs)
 			lop.memoryDb.Accept(toBlock.Uint64(), 0) // will delete stale orders from the memorydb
+			lop.archiveRemovedOrders()
 			log.Root().SetHandler(logHandler)
 			log.Info("ListenAndProcessTransactions - processed log chunk", "fromBlock", fromBlock.String(), "toBlock", toBlock.String(), "number of logs", len(logs))
 
@@ -154,9 +173,17 @@ func (lop *limitOrderProcesser) ListenAndProcessTransactions(blockBuilder *block
 			toBlock = utils.BigIntMin(lastAcceptedBlockNumber, big.NewInt(0).Add(fromBlock, JUMP))
 		}
 		lop.memoryDb.Accept(lastAcceptedBlockNumber.Uint64(), lastAccepted.Time()) // will delete stale orders from the memorydb
+		lop.archiveRemovedOrders()
+		log.Root().SetHandler(logHandler)
+
+		// matching hasn't started yet, so this is the only time the live memory DB is copied for a snapshot
+		// after this, base snapshots are compacted from the WAL in the background
+		if err := lop.saveBaseSnapshot(lastAccepted.Hash(), lastAcceptedBlockNumber.Uint64()); err != nil {
+			orderbook.SnapshotWriteFailuresCounter.Inc(1)
+			log.Error("ListenAndProcessTransactions - error in saving base snapshot", "err", err)
+		}
 		lop.snapshotSavedBlockNumber = lastAcceptedBlockNumber.Uint64()
 		log.Info("Set snapshotSavedBlockNumber", "snapshotSavedBlockNumber", lop.snapshotSavedBlockNumber)
-		log.Root().SetHandler(logHandler)
 	}
 
 	lop.mu.Unlock()
@@ -164,6 +191,20 @@ func (lop *limitOrderProcesser) ListenAndProcessTransactions(blockBuilder *block
 	lop.blockBuilder = blockBuilder
 	lop.runMatchingTimer()
 	lop.listenAndStoreLimitOrderTransactions()
+	lop.runStateAuditor()
+}
+
+// periodically compares the memory DB with the on-chain state, if enabled
+func (lop *limitOrderProcesser) runStateAuditor() {
+	if lop.stateAuditInterval <= 0 {
+		return
+	}
+	auditor := orderbook.NewStateAuditor(lop.memoryDb, lop.backend)
+	lop.shutdownWg.Add(1)
+	go func() {
+		defer lop.shutdownWg.Done()
+		auditor.RunStateAuditor(lop.stateAuditInterval, lop.shutdownChan)
+	}()
 }
 
 func (lop *limitOrderProcesser) RunMatchingPipeline() {
@@ -190,7 +231,7 @@ func (lop *limitOrderProcesser) GetOrderBookAPI() *orderbook.OrderBookAPI {
 
 func (lop *limitOrderProcesser) GetTradingAPI() *orderbook.TradingAPI {
 	if lop.tradingAPI == nil {
-		lop.tradingAPI = orderbook.NewTradingAPI(lop.memoryDb, lop.backend, lop.configService, lop.shutdownChan, lop.shutdownWg)
+		lop.tradingAPI = orderbook.NewTradingAPI(lop.memoryDb, lop.backend, lop.configService, lop.tradeIndexer, lop.tradingAPIRequireAuth, lop.shutdownChan, lop.shutdownWg)
 	}
 	return lop.tradingAPI
 }
@@ -260,28 +301,23 @@ func (lop *limitOrderProcesser) listenAndStoreLimitOrderTransactions() {
 					blockNumber := logs[0].BlockNumber
 					block := lop.blockChain.GetBlockByHash(logs[0].BlockHash)
 
-					// If n is the block at which snapshot should be saved(n is multiple of [snapshotInterval]), save the snapshot
-					// when logs of block number >= n + 1 are received before applying them in memory db
-
-					// snapshot should be saved at block number = blockNumber - 1 because Accepted logs
-					// have been applied in memory DB at this point
-					snapshotBlockNumber := blockNumber - 1
-					blockNumberFloor := ((snapshotBlockNumber) / snapshotInterval) * snapshotInterval
-					if blockNumberFloor > lop.snapshotSavedBlockNumber {
-						log.Info("Saving memory DB snapshot", "snapshotBlockNumber", snapshotBlockNumber, "current blockNumber", blockNumber, "blockNumberFloor", blockNumberFloor)
-						snapshotBlock := lop.blockChain.GetBlockByNumber(snapshotBlockNumber)
-						lop.memoryDb.Accept(snapshotBlockNumber, snapshotBlock.Timestamp())
-						executeFuncAndRecoverPanic(func() {
-							err := lop.saveMemoryDBSnapshot(big.NewInt(int64(snapshotBlockNumber)))
-							if err != nil {
-								orderbook.SnapshotWriteFailuresCounter.Inc(1)
-								log.Error("Error in saving memory DB snapshot", "err", err, "snapshotBlockNumber", snapshotBlockNumber, "current blockNumber", blockNumber, "blockNumberFloor", blockNumberFloor)
-							}
-						}, orderbook.SaveSnapshotPanicMessage, orderbook.SaveSnapshotPanicsCounter)
-					}
+					lop.appendToWAL(&walEntry{
+						BlockNumber:    blockNumber,
+						BlockHash:      block.Hash(),
+						BlockTimestamp: block.Timestamp(),
+						Logs:           logs,
+					})
 
 					lop.contractEventProcessor.ProcessAcceptedEvents(logs, false)
+					lop.indexAcceptedLogs(logs)
 					lop.memoryDb.Accept(blockNumber, block.Timestamp())
+					lop.archiveRemovedOrders()
+
+					// save a new base snapshot every [snapshotInterval] blocks
+					blockNumberFloor := (blockNumber / snapshotInterval) * snapshotInterval
+					if blockNumberFloor > lop.snapshotSavedBlockNumber && lop.compactBaseSnapshotInBackground(block.Hash(), blockNumber) {
+						lop.snapshotSavedBlockNumber = blockNumber
+					}
 				}, orderbook.HandleChainAcceptedLogsPanicMessage, orderbook.HandleChainAcceptedLogsPanicsCounter)
 			case <-lop.shutdownChan:
 				return
@@ -334,6 +370,39 @@ func (lop *limitOrderProcesser) listenAndStoreLimitOrderTransactions() {
 	}()
 }
 
+// startIndexing is called before the logs from fromBlock are replayed while bootstrapping, so that the trade indexer knows which blocks it has
+// the logs of. lastAcceptedTime is used if fromBlock is not accepted yet
+func (lop *limitOrderProcesser) startIndexing(fromBlock uint64, lastAcceptedTime uint64) {
+	if lop.tradeIndexer == nil {
+		return
+	}
+	fromTimestamp := lastAcceptedTime
+	if block := lop.blockChain.GetBlockByNumber(fromBlock); block != nil {
+		fromTimestamp = block.Time()
+	}
+	if err := lop.tradeIndexer.StartIndexing(fromBlock, fromTimestamp); err != nil {
+		log.Error("startIndexing - error in updating the indexed range of the trade indexer", "fromBlock", fromBlock, "err", err)
+	}
+}
+
+// logs of the blocks replayed while bootstrapping are indexed again, which is a no-op if they were indexed before
+func (lop *limitOrderProcesser) indexAcceptedLogs(logs []*types.Log) {
+	if lop.tradeIndexer == nil {
+		return
+	}
+	lop.tradeIndexer.IndexAcceptedLogs(logs)
+}
+
+// archiveRemovedOrders stores the orders removed from the memory db in the trade indexer. They are removed from the memory db either way,
+// so that they don't pile up when the trade indexer is disabled
+func (lop *limitOrderProcesser) archiveRemovedOrders() {
+	removedOrders := lop.memoryDb.PopRemovedOrders()
+	if lop.tradeIndexer == nil {
+		return
+	}
+	lop.tradeIndexer.IndexRemovedOrders(removedOrders)
+}
+
 // executes the matching pipeline periodically
 func (lop *limitOrderProcesser) runMatchingTimer() {
 	lop.shutdownWg.Add(1)
@@ -357,47 +426,27 @@ func (lop *limitOrderProcesser) runMatchingTimer() {
 	}, orderbook.RunMatchingPipelinePanicMessage, orderbook.RunMatchingPipelinePanicsCounter)
 }
 
+// loadMemoryDBSnapshot loads the base snapshot and replays the WAL after it.
+// Returns the last block number that has been applied to the memory DB.
 func (lop *limitOrderProcesser) loadMemoryDBSnapshot() (acceptedBlockNumber uint64, err error) {
 	acceptedBlockNumber, err = lop.loadMemoryDBSnapshotFromFile()
 	if err != nil || acceptedBlockNumber == 0 {
-		acceptedBlockNumber, err = lop.loadMemoryDBSnapshotFromHubbleDB()
-	}
-	return acceptedBlockNumber, err
-}
-
-func (lop *limitOrderProcesser) loadMemoryDBSnapshotFromHubbleDB() (uint64, error) {
-	snapshotFound, err := lop.hubbleDB.Has([]byte(memoryDBSnapshotKey))
-	if err != nil {
-		return 0, fmt.Errorf("Error in checking snapshot in hubbleDB: err=%v", err)
-	}
-
-	if !snapshotFound {
-		return 0, nil
+		// the WAL is truncated relative to the base snapshot, so it can't be replayed without it
+		return acceptedBlockNumber, err
 	}
 
-	memorySnapshotBytes, err := lop.hubbleDB.Get([]byte(memoryDBSnapshotKey))
+	entries, err := lop.getWALEntriesToReplay(acceptedBlockNumber)
 	if err != nil {
-		return 0, fmt.Errorf("Error in fetching snapshot from hubbleDB; err=%v", err)
+		// base snapshot is already loaded, the rest of the blocks will be fetched from the chain
+		log.Error("loadMemoryDBSnapshot - error in reading WAL", "err", err)
+		return acceptedBlockNumber, nil
 	}
-
-	buf := bytes.NewBuffer(memorySnapshotBytes)
-	var snapshot orderbook.Snapshot
-	err = gob.NewDecoder(buf).Decode(&snapshot)
-	if err != nil {
-		return 0, fmt.Errorf("Error in snapshot parsing from hubbleDB; err=%v", err)
-	}
-
-	if snapshot.AcceptedBlockNumber != nil && snapshot.AcceptedBlockNumber.Uint64() > 0 {
-		err = lop.memoryDb.LoadFromSnapshot(snapshot)
-		if err != nil {
-			return 0, fmt.Errorf("Error in loading snapshot from hubbleDB: err=%v", err)
-		} else {
-			log.Info("memory DB snapshot loaded from hubbleDB", "acceptedBlockNumber", snapshot.AcceptedBlockNumber)
-			return snapshot.AcceptedBlockNumber.Uint64(), nil
-		}
-	} else {
-		return 0, nil
+	if len(entries) > 0 {
+		replayWALEntries(lop.contractEventProcessor, lop.memoryDb, entries)
+		log.Info("loadMemoryDBSnapshot - replayed WAL", "fromBlock", entries[0].BlockNumber, "toBlock", entries[len(entries)-1].BlockNumber, "entries", len(entries))
+		acceptedBlockNumber = entries[len(entries)-1].BlockNumber
 	}
+	return acceptedBlockNumber, nil
 }
 
 func (lop *limitOrderProcesser) loadMemoryDBSnapshotFromFile() (uint64, error) {
@@ -406,92 +455,63 @@ func (lop *limitOrderProcesser) loadMemoryDBSnapshotFromFile() (uint64, error) {
 	}
 
 	memorySnapshotBytes, err := os.ReadFile(lop.snapshotFilePath)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
 	if err != nil {
 		return 0, fmt.Errorf("Error in reading snapshot file: err=%v", err)
 	}
 
-	buf := bytes.NewBuffer(memorySnapshotBytes)
-	var snapshot orderbook.Snapshot
-	err = gob.NewDecoder(buf).Decode(&snapshot)
+	return lop.loadMemoryDBSnapshotFromBytes(memorySnapshotBytes, "file")
+}
+
+// loadMemoryDBSnapshotFromBytes loads the snapshot into the memory DB only if it is intact and belongs to the canonical chain.
+// Memory DB is not modified if any check fails.
+func (lop *limitOrderProcesser) loadMemoryDBSnapshotFromBytes(memorySnapshotBytes []byte, source string) (uint64, error) {
+	snapshot, header, err := orderbook.DecodeSnapshot(memorySnapshotBytes)
 	if err != nil {
-		return 0, fmt.Errorf("Error in snapshot parsing from file; err=%v", err)
+		orderbook.SnapshotLoadFailuresCounter.Inc(1)
+		return 0, fmt.Errorf("Error in snapshot parsing from %s; err=%w", source, err)
 	}
 
-	if snapshot.AcceptedBlockNumber != nil && snapshot.AcceptedBlockNumber.Uint64() > 0 {
-		err = lop.memoryDb.LoadFromSnapshot(snapshot)
-		if err != nil {
-			return 0, fmt.Errorf("Error in loading snapshot from file: err=%v", err)
-		} else {
-			log.Info("memory DB snapshot loaded from file", "acceptedBlockNumber", snapshot.AcceptedBlockNumber)
-		}
-
-		return snapshot.AcceptedBlockNumber.Uint64(), nil
-	} else {
+	if snapshot.AcceptedBlockNumber.Sign() == 0 {
 		return 0, nil
 	}
-}
-
-// assumes that memory DB lock is held
-func (lop *limitOrderProcesser) saveMemoryDBSnapshot(acceptedBlockNumber *big.Int) error {
-	start := time.Now()
-	currentHeadBlock := lop.blockChain.CurrentBlock()
 
-	if lop.snapshotFilePath == "" {
-		return fmt.Errorf("snapshot file path not set")
+	if err := lop.verifySnapshotBlock(snapshot, header); err != nil {
+		orderbook.SnapshotLoadFailuresCounter.Inc(1)
+		return 0, fmt.Errorf("Error in verifying snapshot from %s: err=%w", source, err)
 	}
 
-	memoryDBCopy, err := lop.memoryDb.GetOrderBookDataCopy()
+	err = lop.memoryDb.LoadFromSnapshot(*snapshot)
 	if err != nil {
-		return fmt.Errorf("Error in getting memory DB copy: err=%v", err)
+		orderbook.SnapshotLoadFailuresCounter.Inc(1)
+		return 0, fmt.Errorf("Error in loading snapshot from %s: err=%v", source, err)
 	}
-	if currentHeadBlock.Number.Cmp(acceptedBlockNumber) == 1 {
-		// if current head is ahead of the accepted block, then certain events(OrderBook)
-		// need to be removed from the saved state
-		logsToRemove := []*types.Log{}
-		for {
-			logs := lop.blockChain.GetLogs(currentHeadBlock.Hash(), currentHeadBlock.Number.Uint64())
-			flattenedLogs := types.FlattenLogs(logs)
-			logsToRemove = append(logsToRemove, flattenedLogs...)
-
-			currentHeadBlock = lop.blockChain.GetHeaderByHash(currentHeadBlock.ParentHash)
-			if currentHeadBlock.Number.Cmp(acceptedBlockNumber) == 0 {
-				break
-			}
-		}
 
-		for i := 0; i < len(logsToRemove); i++ {
-			logsToRemove[i].Removed = true
-		}
+	log.Info("memory DB snapshot loaded", "source", source, "version", header.Version, "acceptedBlockNumber", snapshot.AcceptedBlockNumber, "acceptedBlockHash", snapshot.AcceptedBlockHash)
+	return snapshot.AcceptedBlockNumber.Uint64(), nil
+}
 
-		cev := orderbook.NewContractEventsProcessor(memoryDBCopy, lop.configService.GetSignedOrderbookContract())
-		// @todo: find a way to not log events removal here(add a logger in ContractEventsProcessor struct and use that to log everything)
-		cev.ProcessEvents(logsToRemove)
+func (lop *limitOrderProcesser) verifySnapshotBlock(snapshot *orderbook.Snapshot, header *orderbook.SnapshotHeader) error {
+	lastAcceptedBlockNumber := lop.blockChain.LastAcceptedBlock().NumberU64()
+	if header.AcceptedBlockNumber > lastAcceptedBlockNumber {
+		return fmt.Errorf("snapshot block %d is ahead of last accepted block %d", header.AcceptedBlockNumber, lastAcceptedBlockNumber)
 	}
 
-	// these SHOULD be re-populated while loading from snapshot
-	memoryDBCopy.LongOrders = nil
-	memoryDBCopy.ShortOrders = nil
-	snapshot := orderbook.Snapshot{
-		Data:                memoryDBCopy,
-		AcceptedBlockNumber: acceptedBlockNumber,
+	if header.Version == 0 {
+		// unversioned snapshots did not record the block hash
+		log.Warn("loading unversioned memory DB snapshot without block hash verification", "acceptedBlockNumber", header.AcceptedBlockNumber)
+		return nil
 	}
 
-	var buf bytes.Buffer
-	err = gob.NewEncoder(&buf).Encode(&snapshot)
-	if err != nil {
-		return fmt.Errorf("error in gob encoding: err=%v", err)
+	canonicalHeader := lop.blockChain.GetHeaderByNumber(header.AcceptedBlockNumber)
+	if canonicalHeader == nil {
+		return fmt.Errorf("block %d not found", header.AcceptedBlockNumber)
 	}
-
-	snapshotBytes := buf.Bytes()
-	// write to snapshot file
-	err = os.WriteFile(lop.snapshotFilePath, snapshotBytes, 0644)
-	if err != nil {
-		return fmt.Errorf("Error in writing to snapshot file: err=%v", err)
+	if canonicalHeader.Hash() != snapshot.AcceptedBlockHash {
+		return fmt.Errorf("snapshot block hash %s does not match canonical block hash %s at %d", snapshot.AcceptedBlockHash.Hex(), canonicalHeader.Hash().Hex(), header.AcceptedBlockNumber)
 	}
-
-	lop.snapshotSavedBlockNumber = acceptedBlockNumber.Uint64()
-	log.Info("Saved memory DB snapshot successfully", "accepted block", acceptedBlockNumber, "head block number", currentHeadBlock.Number, "head block hash", currentHeadBlock.Hash(), "duration", time.Since(start))
-
 	return nil
 }
 
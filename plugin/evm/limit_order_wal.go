@@ -0,0 +1,237 @@
+package evm
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/subnet-evm/core/types"
+	"github.com/ava-labs/subnet-evm/plugin/evm/orderbook"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var memoryDBWALPrefix = []byte("memoryDBWAL")
+
+// walEntry holds the logs of an accepted block that were applied to the memory DB
+type walEntry struct {
+	BlockNumber    uint64       `json:"block_number"`
+	BlockHash      common.Hash  `json:"block_hash"`
+	BlockTimestamp uint64       `json:"block_timestamp"`
+	Logs           []*types.Log `json:"logs"`
+}
+
+// memoryDBWAL is a write-ahead log of accepted blocks stored in hubbleDB.
+// Together with the last base snapshot, it can be replayed to restore the memory DB without fetching logs from the chain.
+type memoryDBWAL struct {
+	db database.Database
+}
+
+func newMemoryDBWAL(db database.Database) *memoryDBWAL {
+	return &memoryDBWAL{db: db}
+}
+
+func walKey(blockNumber uint64) []byte {
+	key := make([]byte, len(memoryDBWALPrefix)+8)
+	copy(key, memoryDBWALPrefix)
+	binary.BigEndian.PutUint64(key[len(memoryDBWALPrefix):], blockNumber)
+	return key
+}
+
+func (w *memoryDBWAL) append(entry *walEntry) error {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error in encoding WAL entry: err=%v", err)
+	}
+	return w.db.Put(walKey(entry.BlockNumber), value)
+}
+
+// entriesAfter returns all the entries for blocks greater than blockNumber, in increasing order of block number
+func (w *memoryDBWAL) entriesAfter(blockNumber uint64) ([]*walEntry, error) {
+	iterator := w.db.NewIteratorWithStartAndPrefix(walKey(blockNumber+1), memoryDBWALPrefix)
+	defer iterator.Release()
+
+	entries := []*walEntry{}
+	for iterator.Next() {
+		entry := &walEntry{}
+		if err := json.Unmarshal(iterator.Value(), entry); err != nil {
+			return nil, fmt.Errorf("error in decoding WAL entry with key %x: err=%v", iterator.Key(), err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := iterator.Error(); err != nil {
+		return nil, fmt.Errorf("error in iterating WAL: err=%v", err)
+	}
+	return entries, nil
+}
+
+// truncate deletes all the entries up to and including blockNumber
+func (w *memoryDBWAL) truncate(blockNumber uint64) error {
+	iterator := w.db.NewIteratorWithPrefix(memoryDBWALPrefix)
+	defer iterator.Release()
+
+	batch := w.db.NewBatch()
+	for iterator.Next() {
+		key := iterator.Key()
+		if binary.BigEndian.Uint64(key[len(memoryDBWALPrefix):]) > blockNumber {
+			break
+		}
+		if err := batch.Delete(common.CopyBytes(key)); err != nil {
+			return err
+		}
+	}
+	if err := iterator.Error(); err != nil {
+		return fmt.Errorf("error in iterating WAL: err=%v", err)
+	}
+	return batch.Write()
+}
+
+// caller is expected to hold lop.mu
+func (lop *limitOrderProcesser) appendToWAL(entry *walEntry) {
+	if lop.snapshotFilePath == "" || lop.walDisabled {
+		return
+	}
+	if err := lop.wal.append(entry); err != nil {
+		// entries after a missing block can't be replayed, so stop writing to the WAL. Base snapshots are copies of the memory DB,
+		// so they are still saved. A restart will replay the WAL till the last entry and fetch the rest of the logs from the chain
+		lop.walDisabled = true
+		orderbook.WALWriteFailuresCounter.Inc(1)
+		log.Error("appendToWAL - error in writing WAL entry, WAL is disabled till restart", "blockNumber", entry.BlockNumber, "err", err)
+	}
+}
+
+// getWALEntriesToReplay returns the entries after blockNumber that belong to the canonical chain
+func (lop *limitOrderProcesser) getWALEntriesToReplay(blockNumber uint64) ([]*walEntry, error) {
+	entries, err := lop.wal.entriesAfter(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	lastAcceptedBlockNumber := lop.blockChain.LastAcceptedBlock().NumberU64()
+	for i, entry := range entries {
+		header := lop.blockChain.GetHeaderByNumber(entry.BlockNumber)
+		if entry.BlockNumber > lastAcceptedBlockNumber || header == nil || header.Hash() != entry.BlockHash {
+			log.Warn("getWALEntriesToReplay - WAL entry is not part of the accepted chain, ignoring the rest of the WAL", "blockNumber", entry.BlockNumber, "blockHash", entry.BlockHash)
+			return entries[:i], nil
+		}
+	}
+	return entries, nil
+}
+
+func replayWALEntries(cep *orderbook.ContractEventsProcessor, db orderbook.LimitOrderDatabase, entries []*walEntry) {
+	for _, entry := range entries {
+		cep.ProcessEvents(entry.Logs)
+		cep.ProcessAcceptedEvents(entry.Logs, true)
+		db.Accept(entry.BlockNumber, entry.BlockTimestamp)
+	}
+}
+
+// saveBaseSnapshot copies the live memory DB into a base snapshot. It should only be called while matching is not running.
+// caller is expected to hold lop.mu
+func (lop *limitOrderProcesser) saveBaseSnapshot(blockHash common.Hash, blockNumber uint64) error {
+	if lop.snapshotFilePath == "" {
+		return fmt.Errorf("snapshot file path not set")
+	}
+
+	memoryDBCopy, err := lop.memoryDb.GetOrderBookDataCopy()
+	if err != nil {
+		return fmt.Errorf("Error in getting memory DB copy: err=%v", err)
+	}
+	return lop.writeBaseSnapshot(&orderbook.Snapshot{
+		Data:                memoryDBCopy,
+		AcceptedBlockNumber: new(big.Int).SetUint64(blockNumber),
+		AcceptedBlockHash:   blockHash,
+	})
+}
+
+// compactBaseSnapshotInBackground replaces the base snapshot with a copy of the live memory DB at the accepted block, unless a compaction is already running.
+// The copy is taken right away, so that it has the state that doesn't come from contract events too, like signed and trigger orders, cancel nonces,
+// heartbeats and cancellations. The events of the blocks after the accepted block are reverted on the copy in the background, so matching is not blocked.
+// Returns true if the compaction was started
+// caller is expected to hold lop.mu
+func (lop *limitOrderProcesser) compactBaseSnapshotInBackground(blockHash common.Hash, blockNumber uint64) bool {
+	if lop.snapshotFilePath == "" || !lop.compactionRunning.CompareAndSwap(false, true) {
+		return false
+	}
+
+	memoryDBCopy, err := lop.memoryDb.GetOrderBookDataCopy()
+	if err != nil {
+		lop.compactionRunning.Store(false)
+		orderbook.SnapshotWriteFailuresCounter.Inc(1)
+		log.Error("Error in getting memory DB copy", "err", err, "blockNumber", blockNumber)
+		return false
+	}
+	snapshot := &orderbook.Snapshot{
+		Data:                memoryDBCopy,
+		AcceptedBlockNumber: new(big.Int).SetUint64(blockNumber),
+		AcceptedBlockHash:   blockHash,
+	}
+	logsToRevert := lop.getLogsAfterBlock(blockNumber)
+
+	lop.shutdownWg.Add(1)
+	go executeFuncAndRecoverPanic(func() {
+		defer lop.shutdownWg.Done()
+		defer lop.compactionRunning.Store(false)
+
+		if err := lop.compactBaseSnapshot(snapshot, logsToRevert); err != nil {
+			orderbook.SnapshotWriteFailuresCounter.Inc(1)
+			log.Error("Error in compacting memory DB snapshot", "err", err, "blockNumber", blockNumber)
+		}
+	}, orderbook.SaveSnapshotPanicMessage, orderbook.SaveSnapshotPanicsCounter)
+	return true
+}
+
+// getLogsAfterBlock returns the logs of the blocks from the current head down to the block after blockNumber, marked as removed
+func (lop *limitOrderProcesser) getLogsAfterBlock(blockNumber uint64) []*types.Log {
+	logs := []*types.Log{}
+	for header := lop.blockChain.CurrentBlock(); header != nil && header.Number.Uint64() > blockNumber; header = lop.blockChain.GetHeaderByHash(header.ParentHash) {
+		for _, event := range types.FlattenLogs(lop.blockChain.GetLogs(header.Hash(), header.Number.Uint64())) {
+			removed := *event
+			removed.Removed = true
+			logs = append(logs, &removed)
+		}
+	}
+	return logs
+}
+
+// compactBaseSnapshot reverts the events of the blocks after the accepted block of the snapshot, which were applied to the live memory DB
+// before it was copied, and replaces the base snapshot with it. The WAL entries till the accepted block are dropped.
+func (lop *limitOrderProcesser) compactBaseSnapshot(snapshot *orderbook.Snapshot, logsToRevert []*types.Log) error {
+	start := time.Now()
+	if len(logsToRevert) > 0 {
+		cep := orderbook.NewContractEventsProcessor(snapshot.Data, lop.configService.GetSignedOrderbookContract())
+		cep.ProcessEvents(logsToRevert)
+	}
+	if err := lop.writeBaseSnapshot(snapshot); err != nil {
+		return err
+	}
+	log.Info("Compacted memory DB snapshot", "blockNumber", snapshot.AcceptedBlockNumber, "revertedLogs", len(logsToRevert), "duration", time.Since(start))
+	return nil
+}
+
+// writeBaseSnapshot atomically replaces the base snapshot file and drops the WAL entries that it covers
+func (lop *limitOrderProcesser) writeBaseSnapshot(snapshot *orderbook.Snapshot) error {
+	snapshotBytes, err := orderbook.EncodeSnapshot(snapshot)
+	if err != nil {
+		return fmt.Errorf("Error in encoding snapshot: err=%v", err)
+	}
+
+	tmpFilePath := lop.snapshotFilePath + ".tmp"
+	if err := os.WriteFile(tmpFilePath, snapshotBytes, 0644); err != nil {
+		return fmt.Errorf("Error in writing to snapshot file: err=%v", err)
+	}
+	if err := os.Rename(tmpFilePath, lop.snapshotFilePath); err != nil {
+		return fmt.Errorf("Error in renaming snapshot file: err=%v", err)
+	}
+
+	if err := lop.wal.truncate(snapshot.AcceptedBlockNumber.Uint64()); err != nil {
+		// stale entries are skipped while replaying, they will be deleted after the next snapshot
+		log.Error("Error in truncating WAL", "err", err, "blockNumber", snapshot.AcceptedBlockNumber)
+	}
+	log.Info("Saved memory DB snapshot successfully", "accepted block", snapshot.AcceptedBlockNumber, "accepted block hash", snapshot.AcceptedBlockHash)
+	return nil
+}
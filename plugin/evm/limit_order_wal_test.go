@@ -0,0 +1,137 @@
+package evm
+
+import (
+	"encoding/gob"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/database/memdb"
+	"github.com/ava-labs/subnet-evm/core/types"
+	"github.com/ava-labs/subnet-evm/plugin/evm/orderbook"
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryDBWAL(t *testing.T) {
+	require := require.New(t)
+	wal := newMemoryDBWAL(memdb.New())
+
+	for _, blockNumber := range []uint64{3, 5, 256, 7} {
+		require.NoError(wal.append(&walEntry{
+			BlockNumber:    blockNumber,
+			BlockHash:      common.BigToHash(common.Big1),
+			BlockTimestamp: blockNumber * 2,
+			Logs: []*types.Log{{
+				Address:     common.HexToAddress("0x0300000000000000000000000000000000000000"),
+				Topics:      []common.Hash{common.HexToHash("0x01")},
+				Data:        []byte{1, 2, 3},
+				BlockNumber: blockNumber,
+			}},
+		}))
+	}
+
+	entries, err := wal.entriesAfter(0)
+	require.NoError(err)
+	require.Len(entries, 4)
+	for i, blockNumber := range []uint64{3, 5, 7, 256} {
+		require.Equal(blockNumber, entries[i].BlockNumber)
+		require.Equal(blockNumber*2, entries[i].BlockTimestamp)
+		require.Equal(blockNumber, entries[i].Logs[0].BlockNumber)
+		require.Equal([]byte{1, 2, 3}, entries[i].Logs[0].Data)
+	}
+
+	entries, err = wal.entriesAfter(5)
+	require.NoError(err)
+	require.Len(entries, 2)
+	require.Equal(uint64(7), entries[0].BlockNumber)
+
+	require.NoError(wal.truncate(7))
+	entries, err = wal.entriesAfter(0)
+	require.NoError(err)
+	require.Len(entries, 1)
+	require.Equal(uint64(256), entries[0].BlockNumber)
+}
+
+func TestCompactBaseSnapshot(t *testing.T) {
+	require := require.New(t)
+	gob.Register(&hu.SignedOrder{})
+	trader1 := common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+	trader2 := common.HexToAddress("0x3C44CdDdB6a900fa2b585dd299e03d12FA4293BC")
+	signedOrder := func(trader common.Address, salt int64) *orderbook.Order {
+		rawOrder := &hu.SignedOrder{
+			LimitOrder: hu.LimitOrder{
+				BaseOrder: hu.BaseOrder{
+					AmmIndex:          big.NewInt(0),
+					Trader:            trader,
+					BaseAssetQuantity: big.NewInt(10),
+					Price:             big.NewInt(20),
+					Salt:              big.NewInt(salt),
+				},
+				PostOnly: true,
+			},
+			OrderType: uint8(hu.Signed),
+			ExpireAt:  big.NewInt(time.Now().Unix() + 3600),
+		}
+		return &orderbook.Order{
+			Id:                      common.BigToHash(big.NewInt(salt)),
+			Market:                  0,
+			PositionType:            orderbook.LONG,
+			Trader:                  trader,
+			BaseAssetQuantity:       rawOrder.BaseAssetQuantity,
+			FilledBaseAssetQuantity: big.NewInt(0),
+			Price:                   rawOrder.Price,
+			Salt:                    rawOrder.Salt,
+			BlockNumber:             big.NewInt(0),
+			OrderType:               orderbook.Signed,
+			RawOrder:                rawOrder,
+		}
+	}
+
+	configService := orderbook.NewMockConfigService()
+	configService.On("GetMinAllowableMargin").Return(big.NewInt(1e5))
+	db := orderbook.NewInMemoryDatabase(configService)
+	lop := &limitOrderProcesser{
+		memoryDb:         db,
+		snapshotFilePath: filepath.Join(t.TempDir(), "snapshot"),
+		wal:              newMemoryDBWAL(memdb.New()),
+	}
+	cancelledOrder, keptOrder, newOrder := signedOrder(trader1, 1), signedOrder(trader2, 2), signedOrder(trader2, 3)
+	db.AddSignedOrder(cancelledOrder, big.NewInt(0))
+	db.AddSignedOrder(keptOrder, big.NewInt(0))
+	require.NoError(lop.saveBaseSnapshot(common.HexToHash("0x05"), 5))
+
+	// off-chain changes after the base snapshot
+	_, err := db.CancelAllSignedOrders(&hu.CancelAllOrders{Trader: trader1, AmmIndex: big.NewInt(0), Nonce: big.NewInt(1)})
+	require.NoError(err)
+	db.AddSignedOrder(newOrder, big.NewInt(0))
+	require.NoError(lop.wal.append(&walEntry{BlockNumber: 6, BlockHash: common.HexToHash("0x06")}))
+
+	memoryDBCopy, err := db.GetOrderBookDataCopy()
+	require.NoError(err)
+	require.NoError(lop.compactBaseSnapshot(&orderbook.Snapshot{
+		Data:                memoryDBCopy,
+		AcceptedBlockNumber: big.NewInt(10),
+		AcceptedBlockHash:   common.HexToHash("0x0a"),
+	}, nil))
+
+	// restart
+	snapshotBytes, err := os.ReadFile(lop.snapshotFilePath)
+	require.NoError(err)
+	snapshot, header, err := orderbook.DecodeSnapshot(snapshotBytes)
+	require.NoError(err)
+	require.Equal(uint64(10), header.AcceptedBlockNumber)
+	restored := orderbook.NewInMemoryDatabase(configService)
+	require.NoError(restored.LoadFromSnapshot(*snapshot))
+	require.Nil(restored.GetOrderById(cancelledOrder.Id))
+	require.NotNil(restored.GetOrderById(keptOrder.Id))
+	require.NotNil(restored.GetOrderById(newOrder.Id))
+	require.Len(restored.GetCancelAllMessages(), 1)
+
+	entries, err := lop.wal.entriesAfter(0)
+	require.NoError(err)
+	require.Len(entries, 0)
+}
@@ -49,6 +49,15 @@ func init() {
 		c.RegisterType(BlockSignatureRequest{}),
 		c.RegisterType(SignatureResponse{}),
 
+		// registered after the existing types so that their type IDs don't change
+		c.RegisterType(SignedOrderAmendmentsGossip{}),
+		c.RegisterType(CancelAllOrdersGossip{}),
+		c.RegisterType(HeartbeatsGossip{}),
+		c.RegisterType(SignedOrdersRequest{}),
+		c.RegisterType(SignedOrdersResponse{}),
+		c.RegisterType(EncodedSignedOrdersGossip{}),
+		c.RegisterType(TriggerOrdersGossip{}),
+
 		Codec.RegisterCodec(Version, c),
 	)
 
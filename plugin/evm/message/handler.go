@@ -20,6 +20,11 @@ var (
 // GossipHandler handles incoming gossip messages
 type GossipHandler interface {
 	HandleSignedOrders(nodeID ids.NodeID, msg SignedOrdersGossip) error
+	HandleEncodedSignedOrders(nodeID ids.NodeID, msg EncodedSignedOrdersGossip) error
+	HandleSignedOrderAmendments(nodeID ids.NodeID, msg SignedOrderAmendmentsGossip) error
+	HandleCancelAllOrders(nodeID ids.NodeID, msg CancelAllOrdersGossip) error
+	HandleHeartbeats(nodeID ids.NodeID, msg HeartbeatsGossip) error
+	HandleTriggerOrders(nodeID ids.NodeID, msg TriggerOrdersGossip) error
 	HandleEthTxs(nodeID ids.NodeID, msg EthTxsGossip) error
 }
 
@@ -35,6 +40,31 @@ func (NoopMempoolGossipHandler) HandleSignedOrders(nodeID ids.NodeID, _ SignedOr
 	return nil
 }
 
+func (NoopMempoolGossipHandler) HandleEncodedSignedOrders(nodeID ids.NodeID, _ EncodedSignedOrdersGossip) error {
+	log.Debug("dropping unexpected EncodedSignedOrdersGossip message", "peerID", nodeID)
+	return nil
+}
+
+func (NoopMempoolGossipHandler) HandleSignedOrderAmendments(nodeID ids.NodeID, _ SignedOrderAmendmentsGossip) error {
+	log.Debug("dropping unexpected SignedOrderAmendmentsGossip message", "peerID", nodeID)
+	return nil
+}
+
+func (NoopMempoolGossipHandler) HandleCancelAllOrders(nodeID ids.NodeID, _ CancelAllOrdersGossip) error {
+	log.Debug("dropping unexpected CancelAllOrdersGossip message", "peerID", nodeID)
+	return nil
+}
+
+func (NoopMempoolGossipHandler) HandleHeartbeats(nodeID ids.NodeID, _ HeartbeatsGossip) error {
+	log.Debug("dropping unexpected HeartbeatsGossip message", "peerID", nodeID)
+	return nil
+}
+
+func (NoopMempoolGossipHandler) HandleTriggerOrders(nodeID ids.NodeID, _ TriggerOrdersGossip) error {
+	log.Debug("dropping unexpected TriggerOrdersGossip message", "peerID", nodeID)
+	return nil
+}
+
 // RequestHandler interface handles incoming requests from peers
 // Must have methods in format of handleType(context.Context, ids.NodeID, uint32, request Type) error
 // so that the Request object of relevant Type can invoke its respective handle method
@@ -46,6 +76,7 @@ type RequestHandler interface {
 	HandleCodeRequest(ctx context.Context, nodeID ids.NodeID, requestID uint32, codeRequest CodeRequest) ([]byte, error)
 	HandleMessageSignatureRequest(ctx context.Context, nodeID ids.NodeID, requestID uint32, signatureRequest MessageSignatureRequest) ([]byte, error)
 	HandleBlockSignatureRequest(ctx context.Context, nodeID ids.NodeID, requestID uint32, signatureRequest BlockSignatureRequest) ([]byte, error)
+	HandleSignedOrdersRequest(ctx context.Context, nodeID ids.NodeID, requestID uint32, signedOrdersRequest SignedOrdersRequest) ([]byte, error)
 }
 
 // ResponseHandler handles response for a sent request
@@ -79,6 +110,10 @@ func (NoopRequestHandler) HandleBlockSignatureRequest(ctx context.Context, nodeI
 	return nil, nil
 }
 
+func (NoopRequestHandler) HandleSignedOrdersRequest(ctx context.Context, nodeID ids.NodeID, requestID uint32, signedOrdersRequest SignedOrdersRequest) ([]byte, error) {
+	return nil, nil
+}
+
 // CrossChainRequestHandler interface handles incoming requests from another chain
 type CrossChainRequestHandler interface {
 	HandleEthCallRequest(ctx context.Context, requestingchainID ids.ID, requestID uint32, ethCallRequest EthCallRequest) ([]byte, error)
@@ -12,8 +12,13 @@ import (
 )
 
 type CounterHandler struct {
-	Orders int
-	EthTxs int
+	Orders        int
+	EncodedOrders int
+	Amendments    int
+	Cancels       int
+	Heartbeats    int
+	TriggerOrders int
+	EthTxs        int
 }
 
 func (h *CounterHandler) HandleEthTxs(ids.NodeID, EthTxsGossip) error {
@@ -26,6 +31,31 @@ func (h *CounterHandler) HandleSignedOrders(ids.NodeID, SignedOrdersGossip) erro
 	return nil
 }
 
+func (h *CounterHandler) HandleEncodedSignedOrders(ids.NodeID, EncodedSignedOrdersGossip) error {
+	h.EncodedOrders++
+	return nil
+}
+
+func (h *CounterHandler) HandleSignedOrderAmendments(ids.NodeID, SignedOrderAmendmentsGossip) error {
+	h.Amendments++
+	return nil
+}
+
+func (h *CounterHandler) HandleCancelAllOrders(ids.NodeID, CancelAllOrdersGossip) error {
+	h.Cancels++
+	return nil
+}
+
+func (h *CounterHandler) HandleHeartbeats(ids.NodeID, HeartbeatsGossip) error {
+	h.Heartbeats++
+	return nil
+}
+
+func (h *CounterHandler) HandleTriggerOrders(ids.NodeID, TriggerOrdersGossip) error {
+	h.TriggerOrders++
+	return nil
+}
+
 func TestHandleEthTxs(t *testing.T) {
 	assert := assert.New(t)
 
@@ -37,6 +67,61 @@ func TestHandleEthTxs(t *testing.T) {
 	assert.Equal(1, handler.EthTxs)
 }
 
+func TestHandleEncodedSignedOrders(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := CounterHandler{}
+	msg := EncodedSignedOrdersGossip{}
+
+	err := msg.Handle(&handler, ids.EmptyNodeID)
+	assert.NoError(err)
+	assert.Equal(1, handler.EncodedOrders)
+}
+
+func TestHandleSignedOrderAmendments(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := CounterHandler{}
+	msg := SignedOrderAmendmentsGossip{}
+
+	err := msg.Handle(&handler, ids.EmptyNodeID)
+	assert.NoError(err)
+	assert.Equal(1, handler.Amendments)
+}
+
+func TestHandleCancelAllOrders(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := CounterHandler{}
+	msg := CancelAllOrdersGossip{}
+
+	err := msg.Handle(&handler, ids.EmptyNodeID)
+	assert.NoError(err)
+	assert.Equal(1, handler.Cancels)
+}
+
+func TestHandleHeartbeats(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := CounterHandler{}
+	msg := HeartbeatsGossip{}
+
+	err := msg.Handle(&handler, ids.EmptyNodeID)
+	assert.NoError(err)
+	assert.Equal(1, handler.Heartbeats)
+}
+
+func TestHandleTriggerOrders(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := CounterHandler{}
+	msg := TriggerOrdersGossip{}
+
+	err := msg.Handle(&handler, ids.EmptyNodeID)
+	assert.NoError(err)
+	assert.Equal(1, handler.TriggerOrders)
+}
+
 func TestNoopHandler(t *testing.T) {
 	assert := assert.New(t)
 
@@ -143,7 +143,8 @@ type mockHandler struct {
 	handleBlockRequestCalled,
 	handleCodeRequestCalled,
 	handleMessageSignatureCalled,
-	handleBlockSignatureCalled bool
+	handleBlockSignatureCalled,
+	handleSignedOrdersCalled bool
 }
 
 func (m *mockHandler) HandleStateTrieLeafsRequest(context.Context, ids.NodeID, uint32, LeafsRequest) ([]byte, error) {
@@ -169,6 +170,10 @@ func (m *mockHandler) HandleBlockSignatureRequest(ctx context.Context, nodeID id
 	m.handleBlockSignatureCalled = true
 	return nil, nil
 }
+func (m *mockHandler) HandleSignedOrdersRequest(ctx context.Context, nodeID ids.NodeID, requestID uint32, signedOrdersRequest SignedOrdersRequest) ([]byte, error) {
+	m.handleSignedOrdersCalled = true
+	return nil, nil
+}
 
 func (m *mockHandler) reset() {
 	m.handleStateTrieCalled = false
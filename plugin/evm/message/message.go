@@ -19,6 +19,10 @@ const (
 	// this size, however. Max inbound message size is enforced by the codec
 	// (512KB).
 	EthMsgSoftCapSize = 64 * units.KiB
+
+	// SignedOrdersEncodingV1 is the version of the encoding of the orders in EncodedSignedOrdersGossip and SignedOrdersResponse
+	// where each order is ABI encoded with its signature, the same encoding that order_placeSignedOrders accepts
+	SignedOrdersEncodingV1 uint8 = 1
 )
 
 var (
@@ -39,10 +43,35 @@ type EthTxsGossip struct {
 	Txs []byte `serialize:"true"`
 }
 
+// SignedOrdersGossip is only handled for the nodes that don't send EncodedSignedOrdersGossip yet, Orders is a gob blob
 type SignedOrdersGossip struct {
 	Orders []byte `serialize:"true"`
 }
 
+// EncodedSignedOrdersGossip carries signed orders that are each encoded on their own with the deterministic encoding
+// identified by Version, so that they can be decoded by non-Go clients and skipped by the hash of their encoding before decoding
+type EncodedSignedOrdersGossip struct {
+	Version uint8    `serialize:"true"`
+	Orders  [][]byte `serialize:"true"`
+}
+
+type SignedOrderAmendmentsGossip struct {
+	Amendments []byte `serialize:"true"`
+}
+
+type CancelAllOrdersGossip struct {
+	Cancels []byte `serialize:"true"`
+}
+
+type HeartbeatsGossip struct {
+	Heartbeats []byte `serialize:"true"`
+}
+
+// TriggerOrdersGossip carries trigger orders that are each ABI encoded with their signature, the same encoding that order_placeTriggerOrders accepts
+type TriggerOrdersGossip struct {
+	Orders [][]byte `serialize:"true"`
+}
+
 func (msg EthTxsGossip) Handle(handler GossipHandler, nodeID ids.NodeID) error {
 	return handler.HandleEthTxs(nodeID, msg)
 }
@@ -59,6 +88,46 @@ func (msg SignedOrdersGossip) String() string {
 	return fmt.Sprintf("SignedOrdersGossip(BytesLen=%d)", len(msg.Orders))
 }
 
+func (msg EncodedSignedOrdersGossip) Handle(handler GossipHandler, nodeID ids.NodeID) error {
+	return handler.HandleEncodedSignedOrders(nodeID, msg)
+}
+
+func (msg EncodedSignedOrdersGossip) String() string {
+	return fmt.Sprintf("EncodedSignedOrdersGossip(Version=%d, Len=%d)", msg.Version, len(msg.Orders))
+}
+
+func (msg SignedOrderAmendmentsGossip) Handle(handler GossipHandler, nodeID ids.NodeID) error {
+	return handler.HandleSignedOrderAmendments(nodeID, msg)
+}
+
+func (msg SignedOrderAmendmentsGossip) String() string {
+	return fmt.Sprintf("SignedOrderAmendmentsGossip(BytesLen=%d)", len(msg.Amendments))
+}
+
+func (msg CancelAllOrdersGossip) Handle(handler GossipHandler, nodeID ids.NodeID) error {
+	return handler.HandleCancelAllOrders(nodeID, msg)
+}
+
+func (msg CancelAllOrdersGossip) String() string {
+	return fmt.Sprintf("CancelAllOrdersGossip(BytesLen=%d)", len(msg.Cancels))
+}
+
+func (msg HeartbeatsGossip) Handle(handler GossipHandler, nodeID ids.NodeID) error {
+	return handler.HandleHeartbeats(nodeID, msg)
+}
+
+func (msg HeartbeatsGossip) String() string {
+	return fmt.Sprintf("HeartbeatsGossip(BytesLen=%d)", len(msg.Heartbeats))
+}
+
+func (msg TriggerOrdersGossip) Handle(handler GossipHandler, nodeID ids.NodeID) error {
+	return handler.HandleTriggerOrders(nodeID, msg)
+}
+
+func (msg TriggerOrdersGossip) String() string {
+	return fmt.Sprintf("TriggerOrdersGossip(Len=%d)", len(msg.Orders))
+}
+
 func ParseGossipMessage(codec codec.Manager, bytes []byte) (GossipMessage, error) {
 	var msg GossipMessage
 	version, err := codec.Unmarshal(bytes, &msg)
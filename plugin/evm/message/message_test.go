@@ -36,6 +36,24 @@ func TestMarshalEthTxs(t *testing.T) {
 	assert.Equal(msg, parsedMsg.Txs)
 }
 
+func TestMarshalEncodedSignedOrders(t *testing.T) {
+	assert := assert.New(t)
+
+	builtMsg := EncodedSignedOrdersGossip{
+		Version: SignedOrdersEncodingV1,
+		Orders:  [][]byte{[]byte("order1"), []byte("order2")},
+	}
+	builtMsgBytes, err := BuildGossipMessage(Codec, builtMsg)
+	assert.NoError(err)
+
+	parsedMsgIntf, err := ParseGossipMessage(Codec, builtMsgBytes)
+	assert.NoError(err)
+
+	parsedMsg, ok := parsedMsgIntf.(EncodedSignedOrdersGossip)
+	assert.True(ok)
+	assert.Equal(builtMsg, parsedMsg)
+}
+
 func TestEthTxsTooLarge(t *testing.T) {
 	assert := assert.New(t)
 
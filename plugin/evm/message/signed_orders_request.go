@@ -0,0 +1,35 @@
+package message
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/ids"
+)
+
+var (
+	_ Request = SignedOrdersRequest{}
+)
+
+// SignedOrdersRequest asks a peer for the live signed orders of its makerbook that the requester doesn't have.
+// Filter is a bloom filter of the ids of the requester's signed orders, hashed with Salt (see avalanchego's utils/bloom).
+// The salt is random per request, so that a false positive of one request is unlikely to repeat in the next one.
+type SignedOrdersRequest struct {
+	Filter []byte `serialize:"true"`
+	Salt   []byte `serialize:"true"`
+}
+
+func (r SignedOrdersRequest) String() string {
+	return fmt.Sprintf("SignedOrdersRequest(FilterLen=%d)", len(r.Filter))
+}
+
+func (r SignedOrdersRequest) Handle(ctx context.Context, nodeID ids.NodeID, requestID uint32, handler RequestHandler) ([]byte, error) {
+	return handler.HandleSignedOrdersRequest(ctx, nodeID, requestID, r)
+}
+
+// SignedOrdersResponse is a response to a SignedOrdersRequest
+// Orders are encoded like EncodedSignedOrdersGossip.Orders
+type SignedOrdersResponse struct {
+	Version uint8    `serialize:"true"`
+	Orders  [][]byte `serialize:"true"`
+}
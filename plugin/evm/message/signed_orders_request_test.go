@@ -0,0 +1,32 @@
+package message
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignedOrdersRequest(t *testing.T) {
+	request := SignedOrdersRequest{Filter: []byte{1, 2, 3}, Salt: []byte{4, 5}}
+	requestBytes, err := RequestToBytes(Codec, request)
+	assert.NoError(t, err)
+
+	parsed, err := BytesToRequest(Codec, requestBytes)
+	assert.NoError(t, err)
+	assert.Equal(t, request, parsed)
+
+	handler := &mockHandler{}
+	_, err = parsed.Handle(context.Background(), ids.GenerateTestNodeID(), 1, handler)
+	assert.NoError(t, err)
+	assert.True(t, handler.handleSignedOrdersCalled)
+
+	response := SignedOrdersResponse{Version: SignedOrdersEncodingV1, Orders: [][]byte{{6, 7}}}
+	responseBytes, err := Codec.Marshal(Version, response)
+	assert.NoError(t, err)
+	var r SignedOrdersResponse
+	_, err = Codec.Unmarshal(responseBytes, &r)
+	assert.NoError(t, err)
+	assert.Equal(t, response, r)
+}
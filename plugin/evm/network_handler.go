@@ -10,6 +10,7 @@ import (
 	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/subnet-evm/metrics"
 	"github.com/ava-labs/subnet-evm/plugin/evm/message"
+	"github.com/ava-labs/subnet-evm/plugin/evm/orderbook"
 	syncHandlers "github.com/ava-labs/subnet-evm/sync/handlers"
 	syncStats "github.com/ava-labs/subnet-evm/sync/handlers/stats"
 	"github.com/ava-labs/subnet-evm/trie"
@@ -25,6 +26,7 @@ type networkHandler struct {
 	blockRequestHandler          *syncHandlers.BlockRequestHandler
 	codeRequestHandler           *syncHandlers.CodeRequestHandler
 	signatureRequestHandler      *warpHandlers.SignatureRequestHandler
+	signedOrdersRequestHandler   *signedOrdersRequestHandler
 }
 
 // newNetworkHandler constructs the handler for serving network requests.
@@ -33,6 +35,8 @@ func newNetworkHandler(
 	diskDB ethdb.KeyValueReader,
 	evmTrieDB *trie.Database,
 	warpBackend warp.Backend,
+	orderbookDB orderbook.LimitOrderDatabase,
+	orderGossipLimiter *orderGossipLimiter,
 	networkCodec codec.Manager,
 ) message.RequestHandler {
 	syncStats := syncStats.NewHandlerStats(metrics.Enabled)
@@ -41,6 +45,7 @@ func newNetworkHandler(
 		blockRequestHandler:          syncHandlers.NewBlockRequestHandler(provider, networkCodec, syncStats),
 		codeRequestHandler:           syncHandlers.NewCodeRequestHandler(diskDB, networkCodec, syncStats),
 		signatureRequestHandler:      warpHandlers.NewSignatureRequestHandler(warpBackend, networkCodec),
+		signedOrdersRequestHandler:   newSignedOrdersRequestHandler(orderbookDB, orderGossipLimiter, networkCodec),
 	}
 }
 
@@ -63,3 +68,7 @@ func (n networkHandler) HandleMessageSignatureRequest(ctx context.Context, nodeI
 func (n networkHandler) HandleBlockSignatureRequest(ctx context.Context, nodeID ids.NodeID, requestID uint32, blockSignatureRequest message.BlockSignatureRequest) ([]byte, error) {
 	return n.signatureRequestHandler.OnBlockSignatureRequest(ctx, nodeID, requestID, blockSignatureRequest)
 }
+
+func (n networkHandler) HandleSignedOrdersRequest(ctx context.Context, nodeID ids.NodeID, requestID uint32, signedOrdersRequest message.SignedOrdersRequest) ([]byte, error) {
+	return n.signedOrdersRequestHandler.OnSignedOrdersRequest(ctx, nodeID, requestID, signedOrdersRequest)
+}
@@ -11,6 +11,8 @@ import (
 
 	"github.com/ava-labs/subnet-evm/plugin/evm/orderbook"
 	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 )
 
 type OrderAPI struct {
@@ -43,8 +45,127 @@ func (api *OrderAPI) PlaceSignedOrders(ctx context.Context, input string) (Place
 		return PlaceSignedOrdersResponse{}, err
 	}
 
+	// the orders that decode are placed in one batch, response keeps the order of the input
+	response := make([]PlaceOrderResponse, len(rawOrders))
+	orders := []*hu.SignedOrder{}
+	orderIndexes := []int{}
+	for i, rawOrder := range rawOrders {
+		testData, err := hex.DecodeString(strings.TrimPrefix(rawOrder, "0x"))
+		if err != nil {
+			response[i].Error = err.Error()
+			continue
+		}
+		order, err := hu.DecodeSignedOrder(testData)
+		if err != nil {
+			response[i].Error = err.Error()
+			continue
+		}
+		orders = append(orders, order)
+		orderIndexes = append(orderIndexes, i)
+	}
+
 	ordersToGossip := []*hu.SignedOrder{}
+	orderIds, _, errs := api.tradingAPI.PlaceOrders(orders)
+	for j, order := range orders {
+		orderResponse := &response[orderIndexes[j]]
+		orderResponse.OrderId = orderIds[j].String()
+		if errs[j] != nil {
+			orderResponse.Error = errs[j].Error()
+			continue
+		}
+		orderResponse.Success = true
+		ordersToGossip = append(ordersToGossip, order)
+	}
+
+	api.vm.orderGossiper.GossipSignedOrders(ordersToGossip)
+
+	return PlaceSignedOrdersResponse{Orders: response}, nil
+}
+
+// AmendSignedOrder replaces a signed order in the makerbook by a new abi encoded signed order from the same trader. amendedOrder is the abi encoded
+// order that is replaced, and cancelSig is the signature of the hu.CancelOrder message for it, which the validators record on chain so that
+// the amended order can't be filled anymore. The amendment is gossiped to the other nodes.
+func (api *OrderAPI) AmendSignedOrder(ctx context.Context, amendedOrder string, cancelSig hexutil.Bytes, input string) (PlaceOrderResponse, error) {
+	amendedOrderData, err := hex.DecodeString(strings.TrimPrefix(amendedOrder, "0x"))
+	if err != nil {
+		return PlaceOrderResponse{}, err
+	}
+	original, err := hu.DecodeSignedOrder(amendedOrderData)
+	if err != nil {
+		return PlaceOrderResponse{}, err
+	}
+	orderData, err := hex.DecodeString(strings.TrimPrefix(input, "0x"))
+	if err != nil {
+		return PlaceOrderResponse{}, err
+	}
+	order, err := hu.DecodeSignedOrder(orderData)
+	if err != nil {
+		return PlaceOrderResponse{}, err
+	}
+	cancelOrder := &hu.CancelOrder{Order: original, Sig: cancelSig}
+
+	response := PlaceOrderResponse{Success: false}
+	newOrderId, _, err := api.tradingAPI.AmendOrder(cancelOrder, order)
+	response.OrderId = newOrderId.String()
+	if err != nil {
+		response.Error = err.Error()
+		return response, nil
+	}
+	response.Success = true
+
+	amendedOrderId, _ := original.Hash()
+	api.vm.orderGossiper.GossipSignedOrderAmendments([]*SignedOrderAmendment{{AmendedOrderId: amendedOrderId, Order: order, Cancel: cancelOrder}})
+
+	return response, nil
+}
+
+type CancelAllSignedOrdersResponse struct {
+	CancelledOrderIds []common.Hash `json:"cancelledOrderIds"`
+}
+
+// CancelAllSignedOrders removes the trader's signed orders from the makerbook without a transaction, in a market or in all markets.
+// The signed message is gossiped to the other nodes and recorded with the juror, and its nonce makes them reject older orders, see hu.CancelAllOrders.
+func (api *OrderAPI) CancelAllSignedOrders(ctx context.Context, msg hu.CancelAllOrders) (CancelAllSignedOrdersResponse, error) {
+	cancelledOrderIds, err := api.tradingAPI.CancelAllOrders(&msg)
+	if err != nil {
+		return CancelAllSignedOrdersResponse{}, err
+	}
+
+	api.vm.orderGossiper.GossipCancelAllOrders([]*hu.CancelAllOrders{&msg})
+
+	return CancelAllSignedOrdersResponse{CancelledOrderIds: cancelledOrderIds}, nil
+}
+
+type HeartbeatResponse struct {
+	Deadline uint64 `json:"deadline"`
+}
+
+// Heartbeat registers or keeps alive the dead man's switch of a trader. The heartbeat is gossiped, and if a node doesn't receive a newer
+// heartbeat before the deadline, it cancels the signed orders of the trader that it received before the deadline.
+// A heartbeat with a timeout of 0 unregisters the switch.
+func (api *OrderAPI) Heartbeat(ctx context.Context, msg hu.Heartbeat) (HeartbeatResponse, error) {
+	if err := api.tradingAPI.Heartbeat(&msg); err != nil {
+		return HeartbeatResponse{}, err
+	}
+	api.vm.orderGossiper.GossipHeartbeats([]*hu.Heartbeat{&msg})
+	if msg.Timeout == 0 {
+		return HeartbeatResponse{}, nil
+	}
+	return HeartbeatResponse{Deadline: msg.Deadline()}, nil
+}
+
+// PlaceTriggerOrders places stop loss and take profit orders. The accepted orders are gossiped, and every node keeps them off the order book
+// till they are triggered.
+func (api *OrderAPI) PlaceTriggerOrders(ctx context.Context, input string) (PlaceSignedOrdersResponse, error) {
+	// input is a json encoded array of abi encoded trigger orders
+	var rawOrders []string
+	err := json.Unmarshal([]byte(input), &rawOrders)
+	if err != nil {
+		return PlaceSignedOrdersResponse{}, err
+	}
+
 	response := []PlaceOrderResponse{}
+	ordersToGossip := []*hu.TriggerOrder{}
 	for _, rawOrder := range rawOrders {
 		orderResponse := PlaceOrderResponse{Success: false}
 		testData, err := hex.DecodeString(strings.TrimPrefix(rawOrder, "0x"))
@@ -53,14 +174,14 @@ func (api *OrderAPI) PlaceSignedOrders(ctx context.Context, input string) (Place
 			response = append(response, orderResponse)
 			continue
 		}
-		order, err := hu.DecodeSignedOrder(testData)
+		order, err := hu.DecodeTriggerOrder(testData)
 		if err != nil {
 			orderResponse.Error = err.Error()
 			response = append(response, orderResponse)
 			continue
 		}
 
-		orderId, _, err := api.tradingAPI.PlaceOrder(order)
+		orderId, err := api.tradingAPI.PlaceTriggerOrder(order)
 		orderResponse.OrderId = orderId.String()
 		if err != nil {
 			orderResponse.Error = err.Error()
@@ -72,7 +193,9 @@ func (api *OrderAPI) PlaceSignedOrders(ctx context.Context, input string) (Place
 		ordersToGossip = append(ordersToGossip, order)
 	}
 
-	api.vm.orderGossiper.GossipSignedOrders(ordersToGossip)
+	if len(ordersToGossip) > 0 {
+		api.vm.orderGossiper.GossipTriggerOrders(ordersToGossip)
+	}
 
 	return PlaceSignedOrdersResponse{Orders: response}, nil
 }
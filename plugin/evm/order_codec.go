@@ -0,0 +1,44 @@
+package evm
+
+import (
+	"fmt"
+
+	"github.com/ava-labs/subnet-evm/plugin/evm/message"
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+)
+
+// encodeSignedOrders encodes signed orders with message.SignedOrdersEncodingV1, for message.EncodedSignedOrdersGossip and message.SignedOrdersResponse
+func encodeSignedOrders(orders []*hu.SignedOrder) ([][]byte, error) {
+	encodedOrders := make([][]byte, 0, len(orders))
+	for _, order := range orders {
+		encodedOrder, err := order.EncodeToABIWithoutType()
+		if err != nil {
+			return nil, err
+		}
+		encodedOrders = append(encodedOrders, encodedOrder)
+	}
+	return encodedOrders, nil
+}
+
+// decodeSignedOrder decodes an order encoded with the given version of the signed orders encoding
+func decodeSignedOrder(version uint8, encodedOrder []byte) (*hu.SignedOrder, error) {
+	switch version {
+	case message.SignedOrdersEncodingV1:
+		return hu.DecodeSignedOrder(encodedOrder)
+	default:
+		return nil, fmt.Errorf("unsupported signed orders encoding version %d", version)
+	}
+}
+
+// encodeTriggerOrders ABI encodes trigger orders with their signature, for message.TriggerOrdersGossip
+func encodeTriggerOrders(orders []*hu.TriggerOrder) ([][]byte, error) {
+	encodedOrders := make([][]byte, 0, len(orders))
+	for _, order := range orders {
+		encodedOrder, err := order.EncodeToABIWithoutType()
+		if err != nil {
+			return nil, err
+		}
+		encodedOrders = append(encodedOrders, encodedOrder)
+	}
+	return encodedOrders, nil
+}
@@ -0,0 +1,57 @@
+package evm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ava-labs/subnet-evm/plugin/evm/message"
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignedOrdersEncoding(t *testing.T) {
+	order := &hu.SignedOrder{
+		LimitOrder: hu.LimitOrder{
+			BaseOrder: hu.BaseOrder{
+				AmmIndex:          big.NewInt(1),
+				Trader:            common.HexToAddress("0x22Bb736b64A0b4D4081E103f83bccF864F0404aa"),
+				BaseAssetQuantity: big.NewInt(-5e18),
+				Price:             big.NewInt(10e6),
+				Salt:              big.NewInt(42),
+				ReduceOnly:        true,
+			},
+			PostOnly: true,
+		},
+		OrderType: uint8(hu.Signed),
+		ExpireAt:  big.NewInt(1e10),
+		Sig:       common.FromHex("0x3027b2cdeb0d3a1e50d1e4d3bd3b1e4d5b0c3a9fce01cf5b1a9d4f8a7b1b9f2d6e9b8e0a8d2f1c7f8d5e7a1b3c9d2e4f6a8b0c2d4e6f8a0b2c4d6e8f0a2b4c61b"),
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		encodedOrders, err := encodeSignedOrders([]*hu.SignedOrder{order})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(encodedOrders))
+
+		decodedOrder, err := decodeSignedOrder(message.SignedOrdersEncodingV1, encodedOrders[0])
+		assert.Nil(t, err)
+		assert.Equal(t, order, decodedOrder)
+
+		// the encoding is deterministic, so that it can be used to dedup gossiped orders
+		reEncodedOrders, err := encodeSignedOrders([]*hu.SignedOrder{decodedOrder})
+		assert.Nil(t, err)
+		assert.Equal(t, encodedOrders, reEncodedOrders)
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		encodedOrders, err := encodeSignedOrders([]*hu.SignedOrder{order})
+		assert.Nil(t, err)
+		_, err = decodeSignedOrder(message.SignedOrdersEncodingV1+1, encodedOrders[0])
+		assert.NotNil(t, err)
+	})
+
+	t.Run("malformed order", func(t *testing.T) {
+		_, err := decodeSignedOrder(message.SignedOrdersEncodingV1, []byte{1, 2, 3})
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,127 @@
+package evm
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/avalanchego/cache"
+	"github.com/ava-labs/avalanchego/ids"
+	"golang.org/x/time/rate"
+)
+
+// maxOrderGossipLimiterPeers is the number of peers whose order gossip budget is tracked, the least recently seen peers are forgotten
+const maxOrderGossipLimiterPeers = 1024
+
+type orderGossipPeer struct {
+	limiter *rate.Limiter
+	// number of invalid orders received since invalidWindowStart
+	invalidOrders      int
+	invalidWindowStart time.Time
+	mutedUntil         time.Time
+}
+
+// orderGossipLimiter bounds the signed orders that are processed for each peer, so that a peer can't make the node spend
+// signature recoveries and margin checks on a flood of orders. Every peer has a token bucket of orders, and a peer that sent more
+// than maxInvalidOrders invalid orders within invalidOrdersWindow is muted for muteDuration.
+type orderGossipLimiter struct {
+	mu    sync.Mutex
+	peers *cache.LRU[ids.NodeID, *orderGossipPeer]
+
+	// orders per second, rate.Inf disables the token buckets
+	ordersRate          rate.Limit
+	ordersBurst         int
+	maxInvalidOrders    int
+	invalidOrdersWindow time.Duration
+	muteDuration        time.Duration
+}
+
+func newOrderGossipLimiter(ordersRate float64, ordersBurst int, maxInvalidOrders int, invalidOrdersWindow time.Duration, muteDuration time.Duration) *orderGossipLimiter {
+	limit := rate.Limit(ordersRate)
+	if ordersRate <= 0 {
+		limit = rate.Inf
+	}
+	return &orderGossipLimiter{
+		peers:               &cache.LRU[ids.NodeID, *orderGossipPeer]{Size: maxOrderGossipLimiterPeers},
+		ordersRate:          limit,
+		ordersBurst:         ordersBurst,
+		maxInvalidOrders:    maxInvalidOrders,
+		invalidOrdersWindow: invalidOrdersWindow,
+		muteDuration:        muteDuration,
+	}
+}
+
+func (l *orderGossipLimiter) getPeer(nodeID ids.NodeID, now time.Time) *orderGossipPeer {
+	peer, ok := l.peers.Get(nodeID)
+	if !ok {
+		peer = &orderGossipPeer{
+			limiter:            rate.NewLimiter(l.ordersRate, l.ordersBurst),
+			invalidWindowStart: now,
+		}
+		l.peers.Put(nodeID, peer)
+	}
+	return peer
+}
+
+// allow returns how many of the numOrders orders gossiped by the peer can be processed, the rest should be dropped.
+// Returns 0 while the peer is muted
+func (l *orderGossipLimiter) allow(nodeID ids.NodeID, numOrders int, now time.Time) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	peer := l.getPeer(nodeID, now)
+	if now.Before(peer.mutedUntil) {
+		return 0
+	}
+	if l.ordersRate == rate.Inf {
+		return numOrders
+	}
+	allowed := min(numOrders, int(peer.limiter.TokensAt(now)))
+	if allowed <= 0 || !peer.limiter.AllowN(now, allowed) {
+		return 0
+	}
+	return allowed
+}
+
+// available returns how many orders the peer can send now without using its budget, 0 while the peer is muted.
+// It's used to drop messages that have to be decoded before their orders can be counted
+func (l *orderGossipLimiter) available(nodeID ids.NodeID, now time.Time) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	peer := l.getPeer(nodeID, now)
+	if now.Before(peer.mutedUntil) {
+		return 0
+	}
+	if l.ordersRate == rate.Inf {
+		return math.MaxInt
+	}
+	return int(peer.limiter.TokensAt(now))
+}
+
+// reportInvalid records numOrders invalid orders gossiped by the peer.
+// Returns true if the peer went over maxInvalidOrders and got muted
+func (l *orderGossipLimiter) reportInvalid(nodeID ids.NodeID, numOrders int, now time.Time) bool {
+	if numOrders == 0 || l.maxInvalidOrders <= 0 {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	peer := l.getPeer(nodeID, now)
+	if now.Before(peer.mutedUntil) {
+		return false
+	}
+	if now.Sub(peer.invalidWindowStart) > l.invalidOrdersWindow {
+		peer.invalidOrders = 0
+		peer.invalidWindowStart = now
+	}
+	peer.invalidOrders += numOrders
+	if peer.invalidOrders <= l.maxInvalidOrders {
+		return false
+	}
+	peer.mutedUntil = now.Add(l.muteDuration)
+	peer.invalidOrders = 0
+	peer.invalidWindowStart = peer.mutedUntil
+	return true
+}
@@ -0,0 +1,86 @@
+package evm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderGossipLimiter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	peer1 := ids.GenerateTestNodeID()
+	peer2 := ids.GenerateTestNodeID()
+
+	t.Run("token bucket per peer", func(t *testing.T) {
+		limiter := newOrderGossipLimiter(10, 20, 0, time.Minute, time.Minute)
+		assert.Equal(t, 15, limiter.allow(peer1, 15, now))
+		// only 5 tokens left
+		assert.Equal(t, 5, limiter.allow(peer1, 15, now))
+		assert.Equal(t, 0, limiter.allow(peer1, 1, now))
+		// other peers have their own budget
+		assert.Equal(t, 20, limiter.allow(peer2, 30, now))
+
+		// refilled at 10 orders per second
+		assert.Equal(t, 10, limiter.allow(peer1, 15, now.Add(time.Second)))
+	})
+
+	t.Run("rate 0 disables the limit", func(t *testing.T) {
+		limiter := newOrderGossipLimiter(0, 0, 0, time.Minute, time.Minute)
+		assert.Equal(t, 10_000, limiter.allow(peer1, 10_000, now))
+		assert.Equal(t, 10_000, limiter.allow(peer1, 10_000, now))
+	})
+
+	t.Run("peer is muted after too many invalid orders", func(t *testing.T) {
+		limiter := newOrderGossipLimiter(0, 0, 10, time.Minute, 5*time.Minute)
+		assert.False(t, limiter.reportInvalid(peer1, 10, now))
+		assert.Equal(t, 1, limiter.allow(peer1, 1, now))
+
+		assert.True(t, limiter.reportInvalid(peer1, 1, now.Add(time.Second)))
+		assert.Equal(t, 0, limiter.allow(peer1, 1, now.Add(time.Second)))
+		assert.Equal(t, 0, limiter.allow(peer1, 1, now.Add(5*time.Minute)))
+		assert.Equal(t, 1, limiter.allow(peer2, 1, now.Add(time.Second)))
+
+		// unmuted after the mute duration, with a new window
+		unmuted := now.Add(time.Second + 5*time.Minute)
+		assert.Equal(t, 1, limiter.allow(peer1, 1, unmuted))
+		assert.False(t, limiter.reportInvalid(peer1, 10, unmuted))
+	})
+
+	t.Run("invalid orders are counted within the window", func(t *testing.T) {
+		limiter := newOrderGossipLimiter(0, 0, 10, time.Minute, 5*time.Minute)
+		assert.False(t, limiter.reportInvalid(peer1, 8, now))
+		assert.False(t, limiter.reportInvalid(peer1, 8, now.Add(2*time.Minute)))
+		assert.True(t, limiter.reportInvalid(peer1, 3, now.Add(2*time.Minute)))
+	})
+
+	t.Run("max invalid 0 disables muting", func(t *testing.T) {
+		limiter := newOrderGossipLimiter(0, 0, 0, time.Minute, 5*time.Minute)
+		assert.False(t, limiter.reportInvalid(peer1, 1000, now))
+		assert.Equal(t, 1, limiter.allow(peer1, 1, now))
+	})
+
+	t.Run("available doesn't use the budget", func(t *testing.T) {
+		limiter := newOrderGossipLimiter(10, 20, 10, time.Minute, 5*time.Minute)
+		assert.Equal(t, 20, limiter.available(peer1, now))
+		assert.Equal(t, 20, limiter.available(peer1, now))
+		assert.Equal(t, 15, limiter.allow(peer1, 15, now))
+		assert.Equal(t, 5, limiter.available(peer1, now))
+
+		assert.True(t, limiter.reportInvalid(peer1, 11, now))
+		assert.Equal(t, 0, limiter.available(peer1, now))
+	})
+}
+
+func TestIsInvalidGossipedOrder(t *testing.T) {
+	_, signatureErr := hu.ECRecover(make([]byte, 32), make([]byte, 10))
+	for _, err := range []error{signatureErr, hu.ErrNoTradingAuthority, hu.ErrNotPostOnly, hu.ErrInvalidTriggerKind} {
+		assert.True(t, isInvalidGossipedOrder(err), err)
+	}
+	// honest peers relay these when their state lags
+	for _, err := range []error{hu.ErrOrderExpired, hu.ErrOrderAlreadyExists, hu.ErrOrderCancelled, hu.ErrCancelledByNonce, hu.ErrHeartbeatExpired, hu.ErrInsufficientMargin, hu.ErrInvalidMarket} {
+		assert.False(t, isInvalidGossipedOrder(err), err)
+	}
+}
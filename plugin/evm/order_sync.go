@@ -0,0 +1,182 @@
+package evm
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/ava-labs/avalanchego/codec"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/bloom"
+	"github.com/ava-labs/subnet-evm/plugin/evm/message"
+	"github.com/ava-labs/subnet-evm/plugin/evm/orderbook"
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// maxSignedOrdersSyncResponseSize caps the signed orders in a response, so that it stays well within the max message size.
+	// The orders that don't fit are sent in response to the next requests
+	maxSignedOrdersSyncResponseSize = 1000
+	// signedOrdersSyncMinFilterElements is the minimum number of orders the bloom filter of a request is sized for
+	signedOrdersSyncMinFilterElements = 1000
+	// signedOrdersSyncFalsePositiveProbability is the probability that an order the requester is missing is not sent to it
+	signedOrdersSyncFalsePositiveProbability = 0.001
+	signedOrdersSyncSaltLen                  = 32
+	signedOrdersSyncRequestTimeout           = 10 * time.Second
+)
+
+// signedOrdersRequestHandler serves message.SignedOrdersRequest with the signed orders of the node's makerbook
+type signedOrdersRequestHandler struct {
+	db    orderbook.LimitOrderDatabase
+	codec codec.Manager
+	// per peer budget of signed orders, shared with order gossip. A request is charged for the orders it may be sent
+	limiter *orderGossipLimiter
+}
+
+func newSignedOrdersRequestHandler(db orderbook.LimitOrderDatabase, limiter *orderGossipLimiter, codec codec.Manager) *signedOrdersRequestHandler {
+	return &signedOrdersRequestHandler{
+		db:      db,
+		codec:   codec,
+		limiter: limiter,
+	}
+}
+
+// OnSignedOrdersRequest responds with the live signed orders whose ids are not in the bloom filter of the request.
+// The response is capped at the orders the peer's budget allows, and the request is dropped before the makerbook is read
+// if the peer is over its budget or muted. Never returns an error, an invalid request is dropped
+func (h *signedOrdersRequestHandler) OnSignedOrdersRequest(ctx context.Context, nodeID ids.NodeID, requestID uint32, request message.SignedOrdersRequest) ([]byte, error) {
+	maxOrders := h.limiter.allow(nodeID, maxSignedOrdersSyncResponseSize, time.Now())
+	if maxOrders == 0 {
+		log.Debug("dropping SignedOrdersRequest from peer over its order budget", "nodeID", nodeID, "requestID", requestID)
+		return nil, nil
+	}
+	if len(request.Salt) > signedOrdersSyncSaltLen {
+		log.Debug("dropping SignedOrdersRequest with invalid salt", "nodeID", nodeID, "requestID", requestID)
+		return nil, nil
+	}
+	filter, err := bloom.Parse(request.Filter)
+	if err != nil {
+		log.Debug("dropping SignedOrdersRequest with invalid filter", "nodeID", nodeID, "requestID", requestID, "err", err)
+		return nil, nil
+	}
+
+	orders := []*hu.SignedOrder{}
+	for _, order := range h.db.GetSignedOrders() {
+		if bloom.Contains(filter, order.Id[:], request.Salt) {
+			continue
+		}
+		signedOrder, ok := order.RawOrder.(*hu.SignedOrder)
+		if !ok {
+			continue
+		}
+		orders = append(orders, signedOrder)
+		if len(orders) == maxOrders {
+			break
+		}
+	}
+
+	encodedOrders, err := encodeSignedOrders(orders)
+	if err != nil {
+		log.Error("could not encode signed orders, dropping request", "nodeID", nodeID, "requestID", requestID, "err", err)
+		return nil, nil
+	}
+	response := message.SignedOrdersResponse{Version: message.SignedOrdersEncodingV1, Orders: encodedOrders}
+	responseBytes, err := h.codec.Marshal(message.Version, &response)
+	if err != nil {
+		log.Error("could not marshal SignedOrdersResponse, dropping request", "nodeID", nodeID, "requestID", requestID, "err", err)
+		return nil, nil
+	}
+	log.Debug("served SignedOrdersRequest", "nodeID", nodeID, "requestID", requestID, "len(orders)", len(orders))
+	return responseBytes, nil
+}
+
+// syncSignedOrders pulls the live signed orders that the node is missing from a peer, and places them through the trading API.
+// Returns the number of orders that were new to the node
+func (vm *VM) syncSignedOrders(ctx context.Context) (int, error) {
+	orders := vm.limitOrderProcesser.GetMemoryDB().GetSignedOrders()
+	numHashes, numEntries := bloom.OptimalParameters(max(len(orders), signedOrdersSyncMinFilterElements), signedOrdersSyncFalsePositiveProbability)
+	filter, err := bloom.New(numHashes, numEntries)
+	if err != nil {
+		return 0, err
+	}
+	salt := make([]byte, signedOrdersSyncSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return 0, err
+	}
+	for _, order := range orders {
+		bloom.Add(filter, order.Id[:], salt)
+	}
+
+	requestBytes, err := message.RequestToBytes(vm.networkCodec, message.SignedOrdersRequest{Filter: filter.Marshal(), Salt: salt})
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, signedOrdersSyncRequestTimeout)
+	defer cancel()
+	responseBytes, nodeID, err := vm.client.SendAppRequestAny(ctx, nil, requestBytes)
+	if err != nil {
+		return 0, err
+	}
+	if len(responseBytes) == 0 {
+		// the peer doesn't serve signed orders
+		return 0, nil
+	}
+	var response message.SignedOrdersResponse
+	if _, err := vm.networkCodec.Unmarshal(responseBytes, &response); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal SignedOrdersResponse from %s: %w", nodeID, err)
+	}
+	if len(response.Orders) > maxSignedOrdersSyncResponseSize {
+		return 0, fmt.Errorf("too many signed orders from %s: %d", nodeID, len(response.Orders))
+	}
+	signedOrders := make([]*hu.SignedOrder, 0, len(response.Orders))
+	for _, encodedOrder := range response.Orders {
+		order, err := decodeSignedOrder(response.Version, encodedOrder)
+		if err != nil {
+			return 0, fmt.Errorf("failed to decode signed orders from %s: %w", nodeID, err)
+		}
+		signedOrders = append(signedOrders, order)
+	}
+
+	tradingAPI := vm.limitOrderProcesser.GetTradingAPI()
+	numNew := 0
+	_, _, errs := tradingAPI.PlaceOrders(signedOrders)
+	for _, err := range errs {
+		if err == nil {
+			numNew++
+		} else if err != hu.ErrOrderAlreadyExists {
+			log.Debug("syncSignedOrders - rejected signed order", "nodeID", nodeID, "err", err)
+		}
+	}
+	log.Info("syncSignedOrders", "nodeID", nodeID, "received", len(signedOrders), "new", numNew)
+	return numNew, nil
+}
+
+// awaitSignedOrdersSync syncs the signed orders once the node starts matching, and then every vm.config.OrderSyncFrequency,
+// so that a node that restarted, joined late or missed gossip has the same makerbook as its peers
+func (vm *VM) awaitSignedOrdersSync(ctx context.Context) {
+	frequency := vm.config.OrderSyncFrequency.Duration
+	if frequency == 0 {
+		return
+	}
+	vm.shutdownWg.Add(1)
+	go executeFuncAndRecoverPanic(func() {
+		defer vm.shutdownWg.Done()
+
+		ticker := time.NewTicker(frequency)
+		defer ticker.Stop()
+		for {
+			if _, err := vm.syncSignedOrders(ctx); err != nil {
+				log.Warn("failed to sync signed orders", "err", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			case <-vm.shutdownChan:
+				return
+			}
+		}
+	}, orderbook.SyncSignedOrdersPanicMessage, orderbook.SyncSignedOrdersPanicsCounter)
+}
@@ -0,0 +1,117 @@
+package evm
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/bloom"
+	"github.com/ava-labs/subnet-evm/plugin/evm/message"
+	"github.com/ava-labs/subnet-evm/plugin/evm/orderbook"
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignedOrdersRequestHandler(t *testing.T) {
+	db := orderbook.NewInMemoryDatabase(orderbook.NewMockConfigService())
+	trader := common.HexToAddress("0x22Bb736b64A0b4D4081E103f83bccF864F0404aa")
+	orders := []*orderbook.Order{}
+	for i := int64(1); i <= 3; i++ {
+		signedOrder := &hu.SignedOrder{
+			LimitOrder: hu.LimitOrder{
+				BaseOrder: hu.BaseOrder{
+					AmmIndex:          big.NewInt(0),
+					Trader:            trader,
+					BaseAssetQuantity: big.NewInt(1e18),
+					Price:             big.NewInt(10e6),
+					Salt:              big.NewInt(i),
+				},
+			},
+			OrderType: uint8(orderbook.Signed),
+			ExpireAt:  big.NewInt(1e10),
+		}
+		order := &orderbook.Order{
+			Id:                      common.BigToHash(big.NewInt(i)),
+			Market:                  orderbook.Market(0),
+			PositionType:            orderbook.LONG,
+			Trader:                  trader,
+			BaseAssetQuantity:       signedOrder.BaseAssetQuantity,
+			FilledBaseAssetQuantity: big.NewInt(0),
+			Price:                   signedOrder.Price,
+			Salt:                    signedOrder.Salt,
+			BlockNumber:             big.NewInt(0),
+			OrderType:               orderbook.Signed,
+			RawOrder:                signedOrder,
+		}
+		db.AddSignedOrder(order, big.NewInt(0))
+		orders = append(orders, order)
+	}
+	handler := newSignedOrdersRequestHandler(db, newOrderGossipLimiter(0, 0, 0, 0, 0), message.Codec)
+
+	getResponse := func(t *testing.T, request message.SignedOrdersRequest) []*hu.SignedOrder {
+		responseBytes, err := handler.OnSignedOrdersRequest(context.Background(), ids.EmptyNodeID, 1, request)
+		assert.Nil(t, err)
+		var response message.SignedOrdersResponse
+		_, err = message.Codec.Unmarshal(responseBytes, &response)
+		assert.Nil(t, err)
+		assert.Equal(t, message.SignedOrdersEncodingV1, response.Version)
+		signedOrders := []*hu.SignedOrder{}
+		for _, encodedOrder := range response.Orders {
+			order, err := decodeSignedOrder(response.Version, encodedOrder)
+			assert.Nil(t, err)
+			signedOrders = append(signedOrders, order)
+		}
+		return signedOrders
+	}
+
+	t.Run("orders in the filter are not sent", func(t *testing.T) {
+		salt := []byte{1, 2, 3}
+		numHashes, numEntries := bloom.OptimalParameters(signedOrdersSyncMinFilterElements, signedOrdersSyncFalsePositiveProbability)
+		filter, err := bloom.New(numHashes, numEntries)
+		assert.Nil(t, err)
+		bloom.Add(filter, orders[0].Id[:], salt)
+		bloom.Add(filter, orders[2].Id[:], salt)
+
+		signedOrders := getResponse(t, message.SignedOrdersRequest{Filter: filter.Marshal(), Salt: salt})
+		assert.Equal(t, 1, len(signedOrders))
+		assert.Equal(t, big.NewInt(2), signedOrders[0].Salt)
+	})
+
+	t.Run("empty filter gets all orders", func(t *testing.T) {
+		filter, err := bloom.New(1, 1)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(getResponse(t, message.SignedOrdersRequest{Filter: filter.Marshal()})))
+	})
+
+	t.Run("invalid request is dropped", func(t *testing.T) {
+		responseBytes, err := handler.OnSignedOrdersRequest(context.Background(), ids.EmptyNodeID, 1, message.SignedOrdersRequest{Filter: []byte{1}})
+		assert.Nil(t, err)
+		assert.Nil(t, responseBytes)
+	})
+
+	t.Run("requests are limited by the order budget of the peer", func(t *testing.T) {
+		// 2 orders with no refill
+		limitedHandler := newSignedOrdersRequestHandler(db, newOrderGossipLimiter(1e-9, 2, 0, 0, 0), message.Codec)
+		filter, err := bloom.New(1, 1)
+		assert.Nil(t, err)
+		request := message.SignedOrdersRequest{Filter: filter.Marshal()}
+
+		responseBytes, err := limitedHandler.OnSignedOrdersRequest(context.Background(), ids.EmptyNodeID, 1, request)
+		assert.Nil(t, err)
+		var response message.SignedOrdersResponse
+		_, err = message.Codec.Unmarshal(responseBytes, &response)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(response.Orders))
+
+		responseBytes, err = limitedHandler.OnSignedOrdersRequest(context.Background(), ids.EmptyNodeID, 2, request)
+		assert.Nil(t, err)
+		assert.Nil(t, responseBytes)
+
+		// other peers have their own budget
+		responseBytes, err = limitedHandler.OnSignedOrdersRequest(context.Background(), ids.GenerateTestNodeID(), 3, request)
+		assert.Nil(t, err)
+		assert.NotNil(t, responseBytes)
+	})
+}
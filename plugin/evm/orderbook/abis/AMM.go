@@ -0,0 +1,59 @@
+package abis
+
+// AMMAbi only has the funding functions of the AMM contract that the trading APIs read, the full ABI is in tests/orderbook/abi/AMM.json
+var AMMAbi = []byte(`{"abi": [
+    {
+      "inputs": [],
+      "name": "fundingPeriod",
+      "outputs": [
+        {
+          "internalType": "uint256",
+          "name": "",
+          "type": "uint256"
+        }
+      ],
+      "stateMutability": "view",
+      "type": "function"
+    },
+    {
+      "inputs": [],
+      "name": "maxFundingRate",
+      "outputs": [
+        {
+          "internalType": "int256",
+          "name": "",
+          "type": "int256"
+        }
+      ],
+      "stateMutability": "view",
+      "type": "function"
+    },
+    {
+      "inputs": [],
+      "name": "piData",
+      "outputs": [
+        {
+          "internalType": "int256",
+          "name": "piTwap",
+          "type": "int256"
+        },
+        {
+          "internalType": "uint256",
+          "name": "accTime",
+          "type": "uint256"
+        },
+        {
+          "internalType": "int256",
+          "name": "piLast",
+          "type": "int256"
+        },
+        {
+          "internalType": "uint256",
+          "name": "lastTS",
+          "type": "uint256"
+        }
+      ],
+      "stateMutability": "view",
+      "type": "function"
+    }
+]}`)
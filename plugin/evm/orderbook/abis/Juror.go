@@ -0,0 +1,31 @@
+package abis
+
+// JurorAbi only has the functions of the juror precompile that the validators call, the full ABI is in precompile/contracts/jurorv2/contract.abi
+var JurorAbi = []byte(`{"abi": [
+    {
+      "inputs": [
+        {
+          "internalType": "bytes[]",
+          "name": "data",
+          "type": "bytes[]"
+        }
+      ],
+      "name": "cancelAllSignedOrders",
+      "outputs": [],
+      "stateMutability": "nonpayable",
+      "type": "function"
+    },
+    {
+      "inputs": [
+        {
+          "internalType": "bytes[]",
+          "name": "data",
+          "type": "bytes[]"
+        }
+      ],
+      "name": "cancelSignedOrders",
+      "outputs": [],
+      "stateMutability": "nonpayable",
+      "type": "function"
+    }
+]}`)
@@ -2,6 +2,7 @@ package orderbook
 
 import (
 	"math/big"
+	"time"
 
 	"github.com/ava-labs/subnet-evm/core"
 	"github.com/ava-labs/subnet-evm/core/state"
@@ -32,11 +33,15 @@ type IConfigService interface {
 
 	GetSignedOrderStatus(orderHash common.Hash) int64
 	IsTradingAuthority(trader, signer common.Address) bool
+	IsSignedOrderCancelled(orderHash common.Hash) bool
+	GetCancelNonce(trader common.Address, ammIndex *big.Int) *big.Int
 	GetSignedOrderbookContract() common.Address
 
 	GetMarketAddressFromMarketID(marketId int64) common.Address
 	GetImpactMarginNotional(ammAddress common.Address) *big.Int
 	GetReduceOnlyAmounts(trader common.Address) []*big.Int
+	GetMatchingMode(market Market) hu.MatchingMode
+	GetSelfTradePreventionMode(market Market) hu.SelfTradePreventionMode
 
 	IsSettledAll() bool
 }
@@ -149,6 +154,14 @@ func (cs *ConfigService) IsTradingAuthority(trader, signer common.Address) bool
 	return bibliophile.IsTradingAuthority(cs.getStateAtCurrentBlock(), trader, signer)
 }
 
+func (cs *ConfigService) IsSignedOrderCancelled(orderHash common.Hash) bool {
+	return bibliophile.IsSignedOrderCancelled(cs.getStateAtCurrentBlock(), orderHash)
+}
+
+func (cs *ConfigService) GetCancelNonce(trader common.Address, ammIndex *big.Int) *big.Int {
+	return bibliophile.GetCancelNonce(cs.getStateAtCurrentBlock(), trader, ammIndex)
+}
+
 func (cs *ConfigService) GetSignedOrderbookContract() common.Address {
 	return bibliophile.GetSignedOrderBookAddress(cs.getStateAtCurrentBlock())
 }
@@ -165,6 +178,14 @@ func (cs *ConfigService) GetReduceOnlyAmounts(trader common.Address) []*big.Int
 	return bibliophile.GetReduceOnlyAmounts(cs.getStateAtCurrentBlock(), trader)
 }
 
+func (cs *ConfigService) GetMatchingMode(market Market) hu.MatchingMode {
+	return bibliophile.GetMatchingMode(cs.getStateAtCurrentBlock(), int64(market), uint64(time.Now().Unix()))
+}
+
+func (cs *ConfigService) GetSelfTradePreventionMode(market Market) hu.SelfTradePreventionMode {
+	return bibliophile.GetSelfTradePreventionMode(cs.getStateAtCurrentBlock(), int64(market))
+}
+
 func (cs *ConfigService) IsSettledAll() bool {
 	return bibliophile.IsSettledAll(cs.getStateAtCurrentBlock())
 }
@@ -99,11 +99,12 @@ func (cep *ContractEventsProcessor) ProcessEvents(logs []*types.Log) {
 		return rebirthLogs[i].BlockNumber < rebirthLogs[j].BlockNumber
 	})
 
+	counterparties := cep.getMatchCounterparties(rebirthLogs)
 	logs = append(deletedLogs, rebirthLogs...)
 	for _, event := range logs {
 		switch event.Address {
 		case OrderBookContractAddress:
-			cep.handleOrderBookEvent(event)
+			cep.handleOrderBookEvent(event, counterparties)
 		case LimitOrderBookContractAddress:
 			cep.handleLimitOrderBookEvent(event)
 		case IOCOrderBookContractAddress:
@@ -136,7 +137,45 @@ func (cep *ContractEventsProcessor) ProcessAcceptedEvents(logs []*types.Log, inB
 	}
 }
 
-func (cep *ContractEventsProcessor) handleOrderBookEvent(event *types.Log) {
+// getMatchCounterparties pairs the OrderMatched events of two orders matched with each other from their payload, see pairOrderMatches,
+// and returns the order id of the counterparty for each event. OrderMatched of a liquidation has no counterparty order, the other side is the liquidated position.
+// The orders of a batch auction are filled against the whole auction, so they have no counterparty either
+func (cep *ContractEventsProcessor) getMatchCounterparties(logs []*types.Log) map[*types.Log]common.Hash {
+	batchAuctionTxs := map[common.Hash]struct{}{}
+	for _, event := range logs {
+		if event.Address == OrderBookContractAddress && len(event.Topics) > 0 && event.Topics[0] == cep.orderBookABI.Events["BatchAuctionCleared"].ID {
+			batchAuctionTxs[event.TxHash] = struct{}{}
+		}
+	}
+
+	events := []*orderMatchedEvent{}
+	for _, event := range logs {
+		if event.Address != OrderBookContractAddress || len(event.Topics) < 3 || event.Topics[0] != cep.orderBookABI.Events["OrderMatched"].ID {
+			continue
+		}
+		if _, ok := batchAuctionTxs[event.TxHash]; ok {
+			continue
+		}
+		args := map[string]interface{}{}
+		if err := cep.orderBookABI.UnpackIntoMap(args, "OrderMatched", event.Data); err != nil {
+			continue
+		}
+		matchedEvent := newOrderMatchedEvent(event, args)
+		if order := cep.database.GetOrderById(matchedEvent.orderHash); order != nil {
+			matchedEvent.side = order.BaseAssetQuantity.Sign()
+		}
+		events = append(events, matchedEvent)
+	}
+
+	counterparties := map[*types.Log]common.Hash{}
+	for _, pair := range pairOrderMatches(events) {
+		counterparties[pair[0].log] = pair[1].orderHash
+		counterparties[pair[1].log] = pair[0].orderHash
+	}
+	return counterparties
+}
+
+func (cep *ContractEventsProcessor) handleOrderBookEvent(event *types.Log, counterparties map[*types.Log]common.Hash) {
 	removed := event.Removed
 	args := map[string]interface{}{}
 	switch event.Topics[0] {
@@ -153,12 +192,25 @@ func (cep *ContractEventsProcessor) handleOrderBookEvent(event *types.Log) {
 		fillAmount := args["fillAmount"].(*big.Int)
 		if !removed {
 			log.Info("OrderMatched", "orderId", orderId.String(), "trader", trader.String(), "args", args, "number", event.BlockNumber)
-			cep.database.UpdateFilledBaseAssetQuantity(fillAmount, orderId, event.BlockNumber)
+			fill := Lifecycle{BlockNumber: event.BlockNumber, FillPrice: args["price"].(*big.Int), TxHash: &event.TxHash}
+			if counterparty, ok := counterparties[event]; ok {
+				fill.CounterpartyOrderId = &counterparty
+			}
+			cep.database.UpdateOrderFill(fillAmount, orderId, fill)
 		} else {
 			fillAmount.Neg(fillAmount)
 			log.Info("OrderMatched removed", "orderId", orderId.String(), "trader", trader.String(), "args", args, "number", event.BlockNumber)
 			cep.database.UpdateFilledBaseAssetQuantity(fillAmount, orderId, event.BlockNumber)
 		}
+	// event BatchAuctionCleared(uint256 indexed ammIndex, uint256 clearingPrice, uint256 volume, uint256 numOrders, uint256 timestamp);
+	// the fills of the auction are applied from the OrderMatched event of each order
+	case cep.orderBookABI.Events["BatchAuctionCleared"].ID:
+		err := cep.orderBookABI.UnpackIntoMap(args, "BatchAuctionCleared", event.Data)
+		if err != nil {
+			log.Error("error in orderBookAbi.UnpackIntoMap", "method", "BatchAuctionCleared", "err", err)
+			return
+		}
+		log.Info("BatchAuctionCleared", "ammIndex", event.Topics[1].Big(), "args", args, "number", event.BlockNumber, "removed", removed)
 	// OrderMatchingError(bytes32 indexed orderHash, string err);
 	case cep.orderBookABI.Events["OrderMatchingError"].ID:
 		err := cep.orderBookABI.UnpackIntoMap(args, "OrderMatchingError", event.Data)
@@ -170,13 +222,13 @@ func (cep *ContractEventsProcessor) handleOrderBookEvent(event *types.Log) {
 		errorString := args["err"].(string)
 		if !removed {
 			log.Info("OrderMatchingError", "args", args, "orderId", orderId.String(), "TxHash", event.TxHash, "number", event.BlockNumber)
-			if err := cep.database.SetOrderStatus(orderId, Execution_Failed, errorString, event.BlockNumber); err != nil {
+			if err := cep.database.UpdateOrderLifecycle(orderId, Lifecycle{BlockNumber: event.BlockNumber, Status: Execution_Failed, Info: errorString, Reason: ReasonMatchingError, TxHash: &event.TxHash}); err != nil {
 				log.Error("error in SetOrderStatus", "method", "OrderMatchingError", "err", err)
 				return
 			}
 		} else {
 			log.Info("OrderMatchingError removed", "args", args, "orderId", orderId.String(), "number", event.BlockNumber)
-			if err := cep.database.RevertLastStatus(orderId); err != nil {
+			if err := cep.database.RevertLastStatus(orderId, event.BlockNumber); err != nil {
 				log.Error("error in SetOrderStatus", "method", "OrderMatchingError", "removed", true, "err", err)
 				return
 			}
@@ -264,13 +316,17 @@ func (cep *ContractEventsProcessor) handleLimitOrderBookEvent(event *types.Log)
 		if !removed {
 			timestamp := args["timestamp"].(*big.Int)
 			log.Info("LimitOrder/OrderCancelAccepted", "args", args, "orderId", orderId.String(), "number", event.BlockNumber, "timestamp", timestamp)
-			if err := cep.database.SetOrderStatus(orderId, Cancelled, "", event.BlockNumber); err != nil {
+			reason := ReasonCancelledByTrader
+			if isAutoCancelled, _ := args["isAutoCancelled"].(bool); isAutoCancelled {
+				reason = autoCancelReason(cep.database.GetOrderById(orderId))
+			}
+			if err := cep.database.UpdateOrderLifecycle(orderId, Lifecycle{BlockNumber: event.BlockNumber, Status: Cancelled, Reason: reason, TxHash: &event.TxHash}); err != nil {
 				log.Error("error in SetOrderStatus", "method", "OrderCancelAccepted", "err", err)
 				return
 			}
 		} else {
 			log.Info("LimitOrder/OrderCancelAccepted removed", "args", args, "orderId", orderId.String(), "number", event.BlockNumber)
-			if err := cep.database.RevertLastStatus(orderId); err != nil {
+			if err := cep.database.RevertLastStatus(orderId, event.BlockNumber); err != nil {
 				log.Error("error in SetOrderStatus", "method", "OrderCancelAccepted", "removed", true, "err", err)
 				return
 			}
@@ -362,13 +418,13 @@ func (cep *ContractEventsProcessor) handleSignedOrderBookEvent(event *types.Log)
 		if !removed {
 			timestamp := args["timestamp"].(*big.Int)
 			log.Info("SignedOrder/OrderCancelAccepted", "args", args, "orderId", orderId.String(), "number", event.BlockNumber, "timestamp", timestamp)
-			if err := cep.database.SetOrderStatus(orderId, Cancelled, "", event.BlockNumber); err != nil {
+			if err := cep.database.UpdateOrderLifecycle(orderId, Lifecycle{BlockNumber: event.BlockNumber, Status: Cancelled, Reason: ReasonCancelledByTrader, TxHash: &event.TxHash}); err != nil {
 				log.Error("error in SetOrderStatus", "method", "OrderCancelAccepted", "err", err)
 				return
 			}
 		} else {
 			log.Info("SignedOrder/OrderCancelAccepted removed", "args", args, "orderId", orderId.String(), "number", event.BlockNumber)
-			if err := cep.database.RevertLastStatus(orderId); err != nil {
+			if err := cep.database.RevertLastStatus(orderId, event.BlockNumber); err != nil {
 				log.Error("error in SetOrderStatus", "method", "OrderCancelAccepted", "removed", true, "err", err)
 				return
 			}
@@ -688,6 +744,60 @@ func (cep *ContractEventsProcessor) PushToTraderFeed(events []*types.Log, blockS
 				orderId = event.Topics[2]
 				trader = getAddressFromTopicHash(event.Topics[1])
 			}
+
+		case MarginAccountContractAddress:
+			// amounts are not scaled because they are in the decimals of the collateral
+			switch event.Topics[0] {
+			case cep.marginAccountABI.Events["MarginAdded"].ID:
+				err := cep.marginAccountABI.UnpackIntoMap(args, "MarginAdded", event.Data)
+				if err != nil {
+					log.Error("error in marginAccountABI.UnpackIntoMap", "method", "MarginAdded", "err", err)
+					continue
+				}
+				eventName = "MarginAdded"
+				trader = getAddressFromTopicHash(event.Topics[1])
+				args["collateral"] = event.Topics[2].Big().Int64()
+
+			case cep.marginAccountABI.Events["MarginRemoved"].ID:
+				err := cep.marginAccountABI.UnpackIntoMap(args, "MarginRemoved", event.Data)
+				if err != nil {
+					log.Error("error in marginAccountABI.UnpackIntoMap", "method", "MarginRemoved", "err", err)
+					continue
+				}
+				eventName = "MarginRemoved"
+				trader = getAddressFromTopicHash(event.Topics[1])
+				args["collateral"] = event.Topics[2].Big().Int64()
+
+			case cep.marginAccountABI.Events["MarginReserved"].ID:
+				err := cep.marginAccountABI.UnpackIntoMap(args, "MarginReserved", event.Data)
+				if err != nil {
+					log.Error("error in marginAccountABI.UnpackIntoMap", "method", "MarginReserved", "err", err)
+					continue
+				}
+				eventName = "MarginReserved"
+				trader = getAddressFromTopicHash(event.Topics[1])
+
+			case cep.marginAccountABI.Events["MarginReleased"].ID:
+				err := cep.marginAccountABI.UnpackIntoMap(args, "MarginReleased", event.Data)
+				if err != nil {
+					log.Error("error in marginAccountABI.UnpackIntoMap", "method", "MarginReleased", "err", err)
+					continue
+				}
+				eventName = "MarginReleased"
+				trader = getAddressFromTopicHash(event.Topics[1])
+
+			case cep.marginAccountABI.Events["PnLRealized"].ID:
+				err := cep.marginAccountABI.UnpackIntoMap(args, "PnLRealized", event.Data)
+				if err != nil {
+					log.Error("error in marginAccountABI.UnpackIntoMap", "method", "PnLRealized", "err", err)
+					continue
+				}
+				eventName = "PnLRealized"
+				trader = getAddressFromTopicHash(event.Topics[1])
+
+			default:
+				continue
+			}
 		}
 
 		timestamp := args["timestamp"]
@@ -781,3 +781,31 @@ func getIdFromOrder(order Order) common.Hash {
 func getIdFromLimitOrder(order LimitOrder) common.Hash {
 	return crypto.Keccak256Hash([]byte(order.Trader.String() + order.Salt.String()))
 }
+
+func TestPushToTraderFeed(t *testing.T) {
+	cep := newcep(t, getDatabase())
+	marginAccountABI := getABIfromJson(abis.MarginAccountAbi)
+	trader := common.HexToAddress(userAddress)
+
+	traderFeedCh := make(chan TraderEvent, 1)
+	subscription := traderFeed.Subscribe(traderFeedCh)
+	defer subscription.Unsubscribe()
+
+	event := getEventFromABI(marginAccountABI, "MarginAdded")
+	data, err := event.Inputs.NonIndexed().Pack(big.NewInt(100e6), timestamp)
+	assert.Nil(t, err)
+	marginAddedLog := getEventLog(MarginAccountContractAddress, []common.Hash{event.ID, trader.Hash(), common.BigToHash(big.NewInt(1))}, data, 5)
+	go cep.PushToTraderFeed([]*types.Log{marginAddedLog}, ConfirmationLevelAccepted)
+
+	select {
+	case traderEvent := <-traderFeedCh:
+		assert.Equal(t, "MarginAdded", traderEvent.EventName)
+		assert.Equal(t, trader, traderEvent.Trader)
+		assert.Equal(t, ConfirmationLevelAccepted, traderEvent.BlockStatus)
+		assert.Equal(t, big.NewInt(100e6), traderEvent.Args["amount"])
+		assert.Equal(t, int64(1), traderEvent.Args["collateral"])
+		assert.Equal(t, timestamp, traderEvent.Timestamp)
+	case <-time.After(time.Second):
+		t.Fatal("MarginAdded was not pushed to the trader feed")
+	}
+}
@@ -0,0 +1,110 @@
+package orderbook
+
+import (
+	"math/big"
+	"sync"
+)
+
+// depthUpdatesBufferSize is the number of depth updates that can be queued for a subscriber.
+// A subscriber that falls behind by more than this is dropped, and has to resubscribe to get a fresh snapshot.
+const depthUpdatesBufferSize = 1024
+
+// DepthUpdate is the new total unfilled quantity at a price level of a market, after an order book mutation.
+// UpdateID is incremented by 1 for every update of a market, so that a gap means an update was missed.
+type DepthUpdate struct {
+	Market       Market
+	UpdateID     uint64
+	PositionType PositionType
+	Price        *big.Int
+	Quantity     *big.Int // 0 when the price level is empty
+}
+
+// DepthSnapshot is the depth of a market as of LastUpdateID. The depth updates after it start at LastUpdateID+1
+type DepthSnapshot struct {
+	Depth        *MarketDepth
+	LastUpdateID uint64
+}
+
+// DepthSubscription receives the depth updates of a market. Updates is closed if the subscriber falls behind or unsubscribes.
+type DepthSubscription struct {
+	Updates <-chan DepthUpdate
+
+	updates chan DepthUpdate
+	market  Market
+	feed    *depthFeed
+}
+
+func (sub *DepthSubscription) Unsubscribe() {
+	sub.feed.unsubscribe(sub)
+}
+
+// depthFeed sequences and fans out the depth updates of the memory db.
+// Updates are published while holding the db lock, so sending to a subscriber never blocks
+type depthFeed struct {
+	mu          sync.Mutex
+	lastUpdate  map[Market]uint64
+	subscribers map[Market]map[*DepthSubscription]struct{}
+}
+
+func newDepthFeed() *depthFeed {
+	return &depthFeed{
+		lastUpdate:  map[Market]uint64{},
+		subscribers: map[Market]map[*DepthSubscription]struct{}{},
+	}
+}
+
+func (feed *depthFeed) lastUpdateID(market Market) uint64 {
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+	return feed.lastUpdate[market]
+}
+
+func (feed *depthFeed) subscribe(market Market) *DepthSubscription {
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+
+	updates := make(chan DepthUpdate, depthUpdatesBufferSize)
+	sub := &DepthSubscription{Updates: updates, updates: updates, market: market, feed: feed}
+	if feed.subscribers[market] == nil {
+		feed.subscribers[market] = map[*DepthSubscription]struct{}{}
+	}
+	feed.subscribers[market][sub] = struct{}{}
+	return sub
+}
+
+func (feed *depthFeed) unsubscribe(sub *DepthSubscription) {
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+	feed.removeWithoutLock(sub)
+}
+
+func (feed *depthFeed) removeWithoutLock(sub *DepthSubscription) {
+	if _, ok := feed.subscribers[sub.market][sub]; !ok {
+		return
+	}
+	delete(feed.subscribers[sub.market], sub)
+	close(sub.updates)
+}
+
+func (feed *depthFeed) publish(market Market, positionType PositionType, price, quantity *big.Int) {
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+
+	feed.lastUpdate[market]++
+	update := DepthUpdate{
+		Market:       market,
+		UpdateID:     feed.lastUpdate[market],
+		PositionType: positionType,
+		Price:        new(big.Int).Set(price),
+		Quantity:     quantity,
+	}
+	for sub := range feed.subscribers[market] {
+		select {
+		case sub.updates <- update:
+		default:
+			// the subscriber would miss this update, so drop it instead
+			depthSubscribersDroppedCounter.Inc(1)
+			feed.removeWithoutLock(sub)
+		}
+	}
+}
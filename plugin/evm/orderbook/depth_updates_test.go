@@ -0,0 +1,104 @@
+package orderbook
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDepthUpdates(t *testing.T) {
+	t.Run("every mutation of the order book is a depth update", func(t *testing.T) {
+		db := getDatabase()
+		order1 := createLimitOrder(LONG, userAddress, big.NewInt(5), big.NewInt(100), Placed, big.NewInt(1), big.NewInt(1))
+		db.Add(&order1)
+
+		snapshot, sub := db.SubscribeDepthUpdates(market)
+		defer sub.Unsubscribe()
+		assert.Equal(t, uint64(1), snapshot.LastUpdateID)
+		assert.Equal(t, map[string]string{"100": "5"}, snapshot.Depth.Longs)
+
+		order2 := createLimitOrder(LONG, userAddress, big.NewInt(3), big.NewInt(100), Placed, big.NewInt(2), big.NewInt(2))
+		db.Add(&order2)
+		db.UpdateFilledBaseAssetQuantity(big.NewInt(2), order1.Id, 3)
+		db.Delete(order2.Id)
+		db.Delete(order1.Id)
+
+		expected := []string{"8", "6", "3", "0"}
+		for i, quantity := range expected {
+			update := <-sub.Updates
+			assert.Equal(t, snapshot.LastUpdateID+uint64(i)+1, update.UpdateID)
+			assert.Equal(t, LONG, update.PositionType)
+			assert.Equal(t, big.NewInt(100), update.Price)
+			assert.Equal(t, quantity, update.Quantity.String())
+		}
+		assert.Equal(t, 0, len(sub.Updates))
+		assert.Equal(t, snapshot.LastUpdateID+uint64(len(expected)), db.GetDepthSnapshot(market).LastUpdateID)
+	})
+	t.Run("orders that are not placed are not part of the depth", func(t *testing.T) {
+		db := getDatabase()
+		order := createLimitOrder(SHORT, userAddress, big.NewInt(-5), big.NewInt(100), Placed, big.NewInt(1), big.NewInt(1))
+		db.Add(&order)
+		_, sub := db.SubscribeDepthUpdates(market)
+		defer sub.Unsubscribe()
+
+		assert.Nil(t, db.SetOrderStatus(order.Id, Cancelled, "", 2))
+		update := <-sub.Updates
+		assert.Equal(t, SHORT, update.PositionType)
+		assert.Equal(t, 0, update.Quantity.Sign())
+		assert.Equal(t, 0, len(db.GetDepthSnapshot(market).Depth.Shorts))
+
+		assert.Nil(t, db.RevertLastStatus(order.Id, 2))
+		update = <-sub.Updates
+		assert.Equal(t, big.NewInt(-5), update.Quantity)
+	})
+	t.Run("subscriber that falls behind is dropped", func(t *testing.T) {
+		db := getDatabase()
+		_, sub := db.SubscribeDepthUpdates(market)
+		for i := 0; i <= depthUpdatesBufferSize; i++ {
+			order := createLimitOrder(LONG, userAddress, big.NewInt(1), big.NewInt(100), Placed, big.NewInt(1), big.NewInt(int64(i)))
+			db.Add(&order)
+		}
+		received := 0
+		for range sub.Updates {
+			received++
+		}
+		assert.Equal(t, depthUpdatesBufferSize, received)
+		sub.Unsubscribe() // no-op
+	})
+}
+
+func TestStreamDepthUpdates(t *testing.T) {
+	db := getDatabase()
+	order1 := createLimitOrder(LONG, userAddress, big.NewInt(5), big.NewInt(100), Placed, big.NewInt(1), big.NewInt(1))
+	db.Add(&order1)
+
+	closed := make(chan interface{})
+	notifications := make(chan *MarketDepth, 10)
+	snapshots := make(chan bool, 10)
+	done := make(chan struct{})
+	go func() {
+		streamDepthUpdates(db, market, 0, closed, func(depth *MarketDepth, isSnapshot bool) {
+			notifications <- depth
+			snapshots <- isSnapshot
+		})
+		close(done)
+	}()
+
+	snapshot := <-notifications
+	assert.True(t, <-snapshots)
+	assert.Equal(t, uint64(1), snapshot.LastUpdateID)
+	assert.Equal(t, map[string]string{"100": "5"}, snapshot.Longs)
+
+	order2 := createLimitOrder(SHORT, userAddress, big.NewInt(-2), big.NewInt(101), Placed, big.NewInt(2), big.NewInt(2))
+	db.Add(&order2)
+	update := <-notifications
+	assert.False(t, <-snapshots)
+	assert.Equal(t, uint64(2), update.FirstUpdateID)
+	assert.Equal(t, uint64(2), update.LastUpdateID)
+	assert.Equal(t, map[string]string{"101": "-2"}, update.Shorts)
+	assert.Equal(t, 0, len(update.Longs))
+
+	close(closed)
+	<-done
+}
@@ -8,4 +8,6 @@ const (
 	RunSanitaryPipelinePanicMessage       = "panic while running sanitary pipeline"
 	MakerBookFileWriteChannelPanicMessage = "panic while sending to makerbook file write channel"
 	SaveSnapshotPanicMessage              = "panic while saving snapshot"
+	StateAuditPanicMessage                = "panic while auditing memory DB state"
+	SyncSignedOrdersPanicMessage          = "panic while syncing signed orders"
 )
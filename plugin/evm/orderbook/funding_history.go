@@ -0,0 +1,238 @@
+package orderbook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/ava-labs/subnet-evm/core/types"
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ava-labs/subnet-evm/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const secondsPerDay = 24 * 60 * 60
+
+var ErrFundingHistoryIncomplete = errors.New("funding history is incomplete, the trade indexer started after the position was opened")
+
+// FundingRate is a funding settled for a market. Longs pay PremiumFraction per unit of their position to shorts, or receive it if negative
+type FundingRate struct {
+	Market                    Market      `json:"market"`
+	PremiumFraction           *big.Int    `json:"premiumFraction"`
+	UnderlyingPrice           *big.Int    `json:"underlyingPrice"`
+	CumulativePremiumFraction *big.Int    `json:"cumulativePremiumFraction"`
+	FundingRate               *big.Int    `json:"fundingRate"` // PremiumFraction / UnderlyingPrice, 6 decimals
+	NextFundingTime           uint64      `json:"nextFundingTime"`
+	BlockNumber               uint64      `json:"blockNumber"`
+	TxHash                    common.Hash `json:"txHash"`
+	LogIndex                  uint        `json:"logIndex"`
+	Timestamp                 uint64      `json:"timestamp"`
+}
+
+// FundingPayment is the funding for the position of a trader in a settled funding. Payment is positive when the trader pays
+type FundingPayment struct {
+	Market          Market   `json:"market"`
+	Size            *big.Int `json:"size"`
+	PremiumFraction *big.Int `json:"premiumFraction"`
+	Payment         *big.Int `json:"payment"`
+	BlockNumber     uint64   `json:"blockNumber"`
+	Timestamp       uint64   `json:"timestamp"`
+}
+
+type FundingRatesResponse struct {
+	FundingRates []*FundingRate `json:"fundingRates"`
+	NextCursor   string         `json:"nextCursor,omitempty"`
+}
+
+type FundingPaymentsResponse struct {
+	Payments []*FundingPayment `json:"payments"`
+	// funding that is not yet settled for the position, from the cumulative premium fraction since the position's LastPremiumFraction
+	PendingFunding *big.Int `json:"pendingFunding"`
+	NextCursor     string   `json:"nextCursor,omitempty"`
+}
+
+// PredictedFunding is the funding that would be settled for a market if the order book and the oracle price did not change till NextFundingTime
+type PredictedFunding struct {
+	Market                   Market   `json:"market"`
+	ImpactBid                *big.Int `json:"impactBid"`
+	ImpactAsk                *big.Int `json:"impactAsk"`
+	UnderlyingPrice          *big.Int `json:"underlyingPrice"`
+	PredictedPremiumIndex    *big.Int `json:"predictedPremiumIndex"` // TWAP of the premium index till NextFundingTime, capped at MaxFundingRate, 6 decimals
+	PredictedPremiumFraction *big.Int `json:"predictedPremiumFraction"`
+	PredictedFundingRate     *big.Int `json:"predictedFundingRate"` // 6 decimals
+	NextFundingTime          uint64   `json:"nextFundingTime"`
+}
+
+// FundingParams are the funding parameters of a market and the premium index samples of the current funding period, as read from its AMM contract
+type FundingParams struct {
+	FundingPeriod  uint64   // seconds
+	MaxFundingRate *big.Int // cap of the premium index, 6 decimals
+	// the premium index is sampled with samplePI. PITwap is the TWAP of the samples over PIAccTime seconds, and PILast is the last sample, taken at PILastTimestamp
+	PITwap          *big.Int
+	PIAccTime       uint64
+	PILast          *big.Int
+	PILastTimestamp uint64
+}
+
+func newFundingRate(event *types.Log, args map[string]interface{}) *FundingRate {
+	premiumFraction := args["premiumFraction"].(*big.Int)
+	underlyingPrice := args["underlyingPrice"].(*big.Int)
+	return &FundingRate{
+		Market:                    Market(int(event.Topics[1].Big().Int64())),
+		PremiumFraction:           premiumFraction,
+		UnderlyingPrice:           underlyingPrice,
+		CumulativePremiumFraction: args["cumulativePremiumFraction"].(*big.Int),
+		FundingRate:               fundingRate(premiumFraction, underlyingPrice),
+		NextFundingTime:           args["nextFundingTime"].(*big.Int).Uint64(),
+		BlockNumber:               event.BlockNumber,
+		TxHash:                    event.TxHash,
+		LogIndex:                  event.Index,
+		Timestamp:                 args["timestamp"].(*big.Int).Uint64(),
+	}
+}
+
+func fundingRate(premiumFraction, underlyingPrice *big.Int) *big.Int {
+	if underlyingPrice.Sign() == 0 {
+		return big.NewInt(0)
+	}
+	return hu.Div(hu.Mul1e6(premiumFraction), underlyingPrice)
+}
+
+// getPredictedFunding predicts the funding the same way as the AMM contract settles it. The TWAP of the premium index samples in params is
+// extended with the last sample till now, and with the premium index of the current impact prices till nextFundingTime.
+// A side without enough liquidity has an impact price of 0 and doesn't add to the premium. The TWAP is capped at params.MaxFundingRate,
+// and the premium fraction is the TWAP scaled to the funding period, premiumIndex * underlyingPrice * fundingPeriod / (1e6 * 1 day)
+func getPredictedFunding(market Market, params FundingParams, impactBid, impactAsk, underlyingPrice *big.Int, now, nextFundingTime uint64) PredictedFunding {
+	premium := big.NewInt(0)
+	if impactBid.Sign() > 0 && impactBid.Cmp(underlyingPrice) > 0 {
+		premium.Add(premium, hu.Sub(impactBid, underlyingPrice))
+	}
+	if impactAsk.Sign() > 0 && impactAsk.Cmp(underlyingPrice) < 0 {
+		premium.Sub(premium, hu.Sub(underlyingPrice, impactAsk))
+	}
+	premiumIndex := fundingRate(premium, underlyingPrice)
+
+	weightedSum := new(big.Int).Mul(params.PITwap, new(big.Int).SetUint64(params.PIAccTime))
+	totalTime := params.PIAccTime
+	if params.PILastTimestamp > 0 && now > params.PILastTimestamp {
+		weightedSum.Add(weightedSum, new(big.Int).Mul(params.PILast, new(big.Int).SetUint64(now-params.PILastTimestamp)))
+		totalTime += now - params.PILastTimestamp
+	}
+	if nextFundingTime > now {
+		weightedSum.Add(weightedSum, new(big.Int).Mul(premiumIndex, new(big.Int).SetUint64(nextFundingTime-now)))
+		totalTime += nextFundingTime - now
+	}
+	if totalTime > 0 {
+		premiumIndex = hu.Div(weightedSum, new(big.Int).SetUint64(totalTime))
+	}
+	if maxFundingRate := params.MaxFundingRate; maxFundingRate != nil && maxFundingRate.Sign() > 0 {
+		premiumIndex = utils.BigIntMin(utils.BigIntMax(premiumIndex, new(big.Int).Neg(maxFundingRate)), maxFundingRate)
+	}
+
+	premiumFraction := hu.Div(hu.Mul(hu.Mul(premiumIndex, underlyingPrice), new(big.Int).SetUint64(params.FundingPeriod)), hu.Mul1e6(big.NewInt(secondsPerDay)))
+	return PredictedFunding{
+		Market:                   market,
+		ImpactBid:                impactBid,
+		ImpactAsk:                impactAsk,
+		UnderlyingPrice:          underlyingPrice,
+		PredictedPremiumIndex:    premiumIndex,
+		PredictedPremiumFraction: premiumFraction,
+		PredictedFundingRate:     fundingRate(premiumFraction, underlyingPrice),
+		NextFundingTime:          nextFundingTime,
+	}
+}
+
+// GetFundingRates returns the fundings settled for a market with a timestamp in [from, to], oldest first. to = 0 means no upper bound
+func (indexer *TradeIndexer) GetFundingRates(market Market, from, to uint64, page *PaginationArgs) (FundingRatesResponse, error) {
+	response := FundingRatesResponse{FundingRates: []*FundingRate{}}
+	nextCursor, err := indexer.iterate(marketFundingRatesPrefix(market), from, to, pageLimit(page), pageCursor(page), func(value []byte) error {
+		fundingRate := &FundingRate{}
+		if err := json.Unmarshal(value, fundingRate); err != nil {
+			return fmt.Errorf("error in decoding funding rate: err=%v", err)
+		}
+		response.FundingRates = append(response.FundingRates, fundingRate)
+		return nil
+	})
+	response.NextCursor = nextCursor
+	return response, err
+}
+
+// GetFundingPayments returns the funding for the position of a trader in every funding of a market with a timestamp in [from, to].
+// The size of the position at a funding is the current size minus the fills of the trader after it, which is only known for the fundings in the
+// indexed range. It returns ErrFundingHistoryIncomplete if a funding in [from, to] is before the indexed range and the position was open
+// when the range started, because the fills and fundings before it are missing. PendingFunding of the response is not set
+func (indexer *TradeIndexer) GetFundingPayments(trader common.Address, market Market, size *big.Int, from, to uint64, page *PaginationArgs) (FundingPaymentsResponse, error) {
+	response := FundingPaymentsResponse{Payments: []*FundingPayment{}}
+	indexedRange, err := indexer.GetIndexedRange()
+	if err != nil {
+		return response, err
+	}
+	if indexedRange == nil {
+		return response, ErrFundingHistoryIncomplete
+	}
+
+	fills := []*Fill{}
+	_, err = indexer.iterate(traderFillsPrefix(trader), indexedRange.FromTimestamp, 0, math.MaxInt, "", func(value []byte) error {
+		fill := &Fill{}
+		if err := json.Unmarshal(value, fill); err != nil {
+			return fmt.Errorf("error in decoding fill: err=%v", err)
+		}
+		if fill.Market == market && fill.BlockNumber >= indexedRange.FromBlock {
+			fills = append(fills, fill)
+		}
+		return nil
+	})
+	if err != nil {
+		return response, err
+	}
+
+	// sizeAfter is the size of the position after all the fills before the funding, starting with the size when the indexed range started
+	sizeAfter := big.NewInt(0)
+	if size != nil {
+		sizeAfter.Set(size)
+	}
+	for _, fill := range fills {
+		sizeAfter.Sub(sizeAfter, fill.BaseAsset)
+	}
+	if sizeAfter.Sign() != 0 && from < indexedRange.FromTimestamp {
+		return response, fmt.Errorf("%w, query from timestamp %d", ErrFundingHistoryIncomplete, indexedRange.FromTimestamp)
+	}
+
+	fundingRates, err := indexer.GetFundingRates(market, max(from, indexedRange.FromTimestamp), to, page)
+	if err != nil {
+		return response, err
+	}
+	response.NextCursor = fundingRates.NextCursor
+	i := 0
+	for _, fundingRate := range fundingRates.FundingRates {
+		if fundingRate.BlockNumber < indexedRange.FromBlock {
+			// settled in the same second as the start of the indexed range, but in an earlier block
+			continue
+		}
+		for ; i < len(fills) && isBefore(fills[i], fundingRate); i++ {
+			sizeAfter.Add(sizeAfter, fills[i].BaseAsset)
+		}
+		response.Payments = append(response.Payments, &FundingPayment{
+			Market:          market,
+			Size:            new(big.Int).Set(sizeAfter),
+			PremiumFraction: fundingRate.PremiumFraction,
+			Payment:         hu.Div1e18(hu.Mul(fundingRate.PremiumFraction, sizeAfter)),
+			BlockNumber:     fundingRate.BlockNumber,
+			Timestamp:       fundingRate.Timestamp,
+		})
+	}
+	return response, nil
+}
+
+// isBefore compares the position of the events the same way as their keys
+func isBefore(fill *Fill, fundingRate *FundingRate) bool {
+	if fill.Timestamp != fundingRate.Timestamp {
+		return fill.Timestamp < fundingRate.Timestamp
+	}
+	if fill.BlockNumber != fundingRate.BlockNumber {
+		return fill.BlockNumber < fundingRate.BlockNumber
+	}
+	return fill.LogIndex < fundingRate.LogIndex
+}
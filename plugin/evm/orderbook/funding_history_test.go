@@ -0,0 +1,150 @@
+package orderbook
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/database/memdb"
+	"github.com/ava-labs/subnet-evm/core/types"
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFundingHistory(t *testing.T) {
+	indexer := NewTradeIndexer(memdb.New())
+	trader := common.HexToAddress(userAddress)
+
+	fundingRateUpdated := func(premiumFraction, cumulativePremiumFraction int64, timestamp uint64) *types.Log {
+		event := getEventFromABI(indexer.clearingHouseABI, "FundingRateUpdated")
+		data, err := event.Inputs.NonIndexed().Pack(big.NewInt(premiumFraction), hu.Mul1e6(big.NewInt(100)), big.NewInt(cumulativePremiumFraction), new(big.Int).SetUint64(timestamp+3600), new(big.Int).SetUint64(timestamp), new(big.Int).SetUint64(timestamp))
+		assert.Nil(t, err)
+		return indexerEventLog(ClearingHouseContractAddress, []common.Hash{event.ID, common.BigToHash(big.NewInt(int64(market)))}, data, timestamp, common.BigToHash(new(big.Int).SetUint64(timestamp)), 0)
+	}
+
+	assert.Nil(t, indexer.StartIndexing(1, 0))
+	// position is 2 at the first funding, 5 at the second and 3 at the third
+	indexer.IndexAcceptedLogs([]*types.Log{fundingRateUpdated(1000, 1000, 3600)})
+	indexer.IndexAcceptedLogs([]*types.Log{fundingRateUpdated(-500, 500, 7200)})
+	indexer.IndexAcceptedLogs([]*types.Log{fundingRateUpdated(2000, 2500, 10800)})
+	err := indexer.write([]*Fill{
+		{Market: market, Trader: trader, BaseAsset: hu.Mul1e18(big.NewInt(2)), Timestamp: 3000, BlockNumber: 3000},
+		{Market: market, Trader: trader, BaseAsset: hu.Mul1e18(big.NewInt(3)), Timestamp: 3600, BlockNumber: 3600, LogIndex: 1},
+		{Market: market, Trader: trader, BaseAsset: hu.Mul1e18(big.NewInt(-2)), Timestamp: 9000, BlockNumber: 9000},
+		{Market: Market(1), Trader: trader, BaseAsset: hu.Mul1e18(big.NewInt(7)), Timestamp: 9000, BlockNumber: 9000, LogIndex: 1},
+	}, nil, nil, 9000)
+	assert.Nil(t, err)
+
+	t.Run("funding rates", func(t *testing.T) {
+		response, err := indexer.GetFundingRates(market, 0, 0, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(response.FundingRates))
+		assert.Equal(t, big.NewInt(-500), response.FundingRates[1].PremiumFraction)
+		assert.Equal(t, big.NewInt(500), response.FundingRates[1].CumulativePremiumFraction)
+		assert.Equal(t, uint64(10800), response.FundingRates[1].NextFundingTime)
+		assert.Equal(t, big.NewInt(-5), response.FundingRates[1].FundingRate) // -500 / 100e6 with 6 decimals
+
+		response, err = indexer.GetFundingRates(market, 3601, 7200, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(response.FundingRates))
+		assert.Equal(t, uint64(7200), response.FundingRates[0].Timestamp)
+	})
+	t.Run("funding payments", func(t *testing.T) {
+		response, err := indexer.GetFundingPayments(trader, market, hu.Mul1e18(big.NewInt(3)), 0, 0, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(response.Payments))
+		expectedSizes := []int64{2, 5, 3}
+		expectedPayments := []int64{2000, -2500, 6000}
+		for i := range expectedSizes {
+			assert.Equal(t, hu.Mul1e18(big.NewInt(expectedSizes[i])), response.Payments[i].Size)
+			assert.Equal(t, big.NewInt(expectedPayments[i]), response.Payments[i].Payment)
+		}
+
+		response, err = indexer.GetFundingPayments(trader, market, hu.Mul1e18(big.NewInt(3)), 7000, 0, &PaginationArgs{Limit: 1})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(response.Payments))
+		assert.Equal(t, hu.Mul1e18(big.NewInt(5)), response.Payments[0].Size)
+		assert.NotEqual(t, "", response.NextCursor)
+
+		response, err = indexer.GetFundingPayments(trader, Market(1), hu.Mul1e18(big.NewInt(7)), 0, 0, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(response.Payments))
+	})
+	t.Run("indexer started after the position was opened", func(t *testing.T) {
+		indexedRange, err := indexer.GetIndexedRange()
+		assert.Nil(t, err)
+		assert.Equal(t, IndexedRange{FromBlock: 1, FromTimestamp: 0, ToBlock: 10800}, *indexedRange)
+
+		// the blocks after 10800 were not indexed, so the range starts again
+		assert.Nil(t, indexer.StartIndexing(20000, 20000))
+		_, err = indexer.GetFundingPayments(trader, market, hu.Mul1e18(big.NewInt(3)), 0, 0, nil)
+		assert.ErrorIs(t, err, ErrFundingHistoryIncomplete)
+
+		response, err := indexer.GetFundingPayments(trader, market, hu.Mul1e18(big.NewInt(3)), 20000, 0, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(response.Payments))
+
+		// a position opened after the range started doesn't have fundings before it
+		response, err = indexer.GetFundingPayments(trader, market, big.NewInt(0), 0, 0, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(response.Payments))
+
+		// indexing from a block in the range keeps it
+		assert.Nil(t, indexer.StartIndexing(20001, 20001))
+		indexedRange, err = indexer.GetIndexedRange()
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(20000), indexedRange.FromBlock)
+	})
+}
+
+func TestGetPredictedFunding(t *testing.T) {
+	underlyingPrice := hu.Mul1e6(big.NewInt(100))
+	// one funding a day and no premium index samples yet
+	params := FundingParams{FundingPeriod: secondsPerDay, PITwap: big.NewInt(0), PILast: big.NewInt(0)}
+	t.Run("impact bid above the oracle price", func(t *testing.T) {
+		predicted := getPredictedFunding(market, params, hu.Mul1e6(big.NewInt(101)), hu.Mul1e6(big.NewInt(102)), underlyingPrice, 0, 3600)
+		assert.Equal(t, big.NewInt(10000), predicted.PredictedPremiumIndex)
+		assert.Equal(t, hu.Mul1e6(big.NewInt(1)), predicted.PredictedPremiumFraction)
+		assert.Equal(t, big.NewInt(10000), predicted.PredictedFundingRate) // 1%
+		assert.Equal(t, uint64(3600), predicted.NextFundingTime)
+	})
+	t.Run("impact ask below the oracle price", func(t *testing.T) {
+		predicted := getPredictedFunding(market, params, hu.Mul1e6(big.NewInt(97)), hu.Mul1e6(big.NewInt(98)), underlyingPrice, 0, 3600)
+		assert.Equal(t, hu.Mul1e6(big.NewInt(-2)), predicted.PredictedPremiumFraction)
+		assert.Equal(t, big.NewInt(-20000), predicted.PredictedFundingRate)
+	})
+	t.Run("oracle price between the impact prices", func(t *testing.T) {
+		predicted := getPredictedFunding(market, params, hu.Mul1e6(big.NewInt(99)), hu.Mul1e6(big.NewInt(101)), underlyingPrice, 0, 3600)
+		assert.Equal(t, 0, predicted.PredictedPremiumFraction.Sign())
+	})
+	t.Run("side without liquidity", func(t *testing.T) {
+		predicted := getPredictedFunding(market, params, hu.Mul1e6(big.NewInt(101)), big.NewInt(0), underlyingPrice, 0, 3600)
+		assert.Equal(t, hu.Mul1e6(big.NewInt(1)), predicted.PredictedPremiumFraction)
+	})
+	t.Run("premium fraction is scaled to the funding period", func(t *testing.T) {
+		hourly := params
+		hourly.FundingPeriod = 3600
+		predicted := getPredictedFunding(market, hourly, hu.Mul1e6(big.NewInt(101)), hu.Mul1e6(big.NewInt(102)), underlyingPrice, 0, 3600)
+		assert.Equal(t, big.NewInt(10000), predicted.PredictedPremiumIndex)
+		assert.Equal(t, big.NewInt(41666), predicted.PredictedPremiumFraction) // 1e6 / 24
+		assert.Equal(t, big.NewInt(416), predicted.PredictedFundingRate)
+	})
+	t.Run("premium index is capped at the max funding rate", func(t *testing.T) {
+		capped := params
+		capped.MaxFundingRate = big.NewInt(5000)
+		predicted := getPredictedFunding(market, capped, hu.Mul1e6(big.NewInt(101)), hu.Mul1e6(big.NewInt(102)), underlyingPrice, 0, 3600)
+		assert.Equal(t, big.NewInt(5000), predicted.PredictedPremiumIndex)
+		predicted = getPredictedFunding(market, capped, hu.Mul1e6(big.NewInt(97)), hu.Mul1e6(big.NewInt(98)), underlyingPrice, 0, 3600)
+		assert.Equal(t, big.NewInt(-5000), predicted.PredictedPremiumIndex)
+		assert.Equal(t, big.NewInt(-500000), predicted.PredictedPremiumFraction)
+	})
+	t.Run("TWAP of the premium index samples", func(t *testing.T) {
+		sampled := params
+		// -2% for 1800s, then a sample of 0 at 1800 that counts till now, and the current 1% till the next funding
+		sampled.PITwap, sampled.PIAccTime = big.NewInt(-20000), 1800
+		sampled.PILast, sampled.PILastTimestamp = big.NewInt(0), 1800
+		predicted := getPredictedFunding(market, sampled, hu.Mul1e6(big.NewInt(101)), hu.Mul1e6(big.NewInt(102)), underlyingPrice, 2700, 3600)
+		assert.Equal(t, big.NewInt(-7500), predicted.PredictedPremiumIndex)
+		assert.Equal(t, big.NewInt(-750000), predicted.PredictedPremiumFraction)
+	})
+}
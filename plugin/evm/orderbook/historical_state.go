@@ -0,0 +1,62 @@
+package orderbook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ava-labs/subnet-evm/core/state"
+	"github.com/ava-labs/subnet-evm/eth"
+	"github.com/ava-labs/subnet-evm/precompile/contract"
+	"github.com/ava-labs/subnet-evm/precompile/contracts/bibliophile"
+	"github.com/ava-labs/subnet-evm/rpc"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var ErrHistoricalStateUnavailable = errors.New("state is not available for the block, it may have been pruned")
+
+// getStateAtBlock returns the chain state after a block, to answer account queries for the block instead of the memory DB
+func getStateAtBlock(ctx context.Context, backend *eth.EthAPIBackend, blockNumber rpc.BlockNumber) (*state.StateDB, error) {
+	stateDB, header, err := backend.StateAndHeaderByNumber(ctx, blockNumber)
+	if header == nil {
+		if err == nil {
+			err = errors.New("header not found")
+		}
+		return nil, fmt.Errorf("block %d not found: %w", blockNumber.Int64(), err)
+	}
+	if err != nil || stateDB == nil {
+		return nil, fmt.Errorf("%w: block=%d, err=%v", ErrHistoricalStateUnavailable, header.Number.Uint64(), err)
+	}
+	return stateDB, nil
+}
+
+// getTraderFromState builds a trader the same way as the memory DB from the contract storage.
+// The liquidation threshold is computed from the current size, the memory DB doesn't raise it after a partial liquidation
+func getTraderFromState(stateDB contract.StateDB, configService IConfigService, address common.Address) *Trader {
+	trader := &Trader{
+		Positions: map[Market]*Position{},
+		Margin: Margin{
+			Deposited: map[Collateral]*big.Int{},
+			Reserved:  bibliophile.GetReservedMargin(stateDB, address),
+		},
+	}
+	for i, margin := range bibliophile.GetMargins(stateDB, address) {
+		trader.Margin.Deposited[Collateral(i)] = margin
+	}
+	for i, marketAddress := range bibliophile.GetMarketsIncludingSettled(stateDB) {
+		market := Market(i)
+		position := bibliophile.GetPosition(stateDB, marketAddress, &address)
+		if position.Size.Sign() == 0 {
+			continue
+		}
+		lastPremiumFraction := bibliophile.GetLastPremiumFraction(stateDB, marketAddress, &address)
+		trader.Positions[market] = &Position{
+			Position:             *position,
+			LastPremiumFraction:  lastPremiumFraction,
+			UnrealisedFunding:    calcPendingFunding(bibliophile.GetCumulativePremiumFraction(stateDB, marketAddress), lastPremiumFraction, position.Size),
+			LiquidationThreshold: getLiquidationThreshold(configService.getMaxLiquidationRatio(market), configService.getMinSizeRequirement(market), position.Size),
+		}
+	}
+	return trader
+}
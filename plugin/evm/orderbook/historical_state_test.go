@@ -0,0 +1,38 @@
+package orderbook
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ava-labs/subnet-evm/core/rawdb"
+	"github.com/ava-labs/subnet-evm/core/state"
+	"github.com/ava-labs/subnet-evm/precompile/contracts/bibliophile"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTraderFromState(t *testing.T) {
+	stateDB, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	assert.Nil(t, err)
+	trader := common.HexToAddress(userAddress)
+	marginAccount := common.HexToAddress(bibliophile.MARGIN_ACCOUNT_GENESIS_ADDRESS)
+
+	// 1 collateral with a margin of 50 and 10 reserved
+	stateDB.SetState(marginAccount, common.BigToHash(big.NewInt(bibliophile.SUPPORTED_COLLATERAL_SLOT)), common.BigToHash(big.NewInt(1)))
+	marginSlot := crypto.Keccak256(append(common.LeftPadBytes(nil, 32), common.LeftPadBytes(big.NewInt(bibliophile.MARGIN_MAPPING_SLOT).Bytes(), 32)...))
+	marginSlot = crypto.Keccak256(append(common.LeftPadBytes(trader.Bytes(), 32), marginSlot...))
+	stateDB.SetState(marginAccount, common.BytesToHash(marginSlot), common.BigToHash(big.NewInt(50e6)))
+	reservedMarginSlot := crypto.Keccak256(append(common.LeftPadBytes(trader.Bytes(), 32), common.LeftPadBytes(big.NewInt(bibliophile.RESERVED_MARGIN_SLOT).Bytes(), 32)...))
+	stateDB.SetState(marginAccount, common.BytesToHash(reservedMarginSlot), common.BigToHash(big.NewInt(10e6)))
+
+	traderInfo := getTraderFromState(stateDB, getDatabase().configService, trader)
+	assert.Equal(t, 1, len(traderInfo.Margin.Deposited))
+	assert.Equal(t, big.NewInt(50e6), traderInfo.Margin.Deposited[HUSD])
+	assert.Equal(t, big.NewInt(10e6), traderInfo.Margin.Reserved)
+	assert.Equal(t, 0, len(traderInfo.Positions))
+
+	other := getTraderFromState(stateDB, getDatabase().configService, common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8"))
+	assert.Equal(t, 0, other.Margin.Deposited[HUSD].Sign())
+	assert.Equal(t, 0, other.Margin.Reserved.Sign())
+}
@@ -0,0 +1,138 @@
+package hubbleutils
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+
+	"github.com/ava-labs/subnet-evm/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// AuctionOrder is an order that takes part in a batch auction
+type AuctionOrder struct {
+	OrderHash common.Hash
+	Price     *big.Int
+	// Size is the unfilled base asset quantity of the order, it is positive for both long and short orders
+	Size *big.Int
+	// BlockPlaced decides the time priority of orders at the same price. Signed orders use 0 and trigger orders use math.MaxUint64,
+	// same as the juror
+	BlockPlaced *big.Int
+}
+
+// BatchAuctionResult is the outcome of a batch auction. LongFills and ShortFills are aligned with the orders passed to ClearBatchAuction,
+// the fills are positive and an order that isn't matched has a fill of 0
+type BatchAuctionResult struct {
+	ClearingPrice *big.Int
+	Volume        *big.Int
+	LongFills     []*big.Int
+	ShortFills    []*big.Int
+}
+
+// ClearBatchAuction matches the crossing long and short orders of a market at a single clearing price that maximizes the matched volume.
+//
+// Orders are ranked by price, then by BlockPlaced, then by OrderHash, and the best long and short orders are matched against each other
+// for as long as they cross. Every matched long order is priced at or above the clearing price and every matched short order at or below it,
+// so all of them trade at the same price. Of the prices that clear the matched volume, the one closest to the oracle price is picked,
+// rounded down to a multiple of tickSize and kept within the price bounds of the market.
+// Long orders below the lower bound and short orders above the upper bound can't be filled at any allowed price, and are not matched.
+//
+// Returns false if no orders cross. The result only depends on its inputs, so the matching engine and the juror arrive at the same fills.
+func ClearBatchAuction(longs, shorts []AuctionOrder, oraclePrice, lowerBound, upperBound, tickSize *big.Int) (*BatchAuctionResult, bool) {
+	result := &BatchAuctionResult{
+		Volume:     big.NewInt(0),
+		LongFills:  make([]*big.Int, len(longs)),
+		ShortFills: make([]*big.Int, len(shorts)),
+	}
+	for i := range result.LongFills {
+		result.LongFills[i] = big.NewInt(0)
+	}
+	for i := range result.ShortFills {
+		result.ShortFills[i] = big.NewInt(0)
+	}
+
+	longIndexes := filterAuctionOrders(longs, RankAuctionOrders(longs, true), func(order AuctionOrder) bool { return order.Price.Cmp(lowerBound) >= 0 })
+	shortIndexes := filterAuctionOrders(shorts, RankAuctionOrders(shorts, false), func(order AuctionOrder) bool { return order.Price.Cmp(upperBound) <= 0 })
+
+	var marginalLongPrice, marginalShortPrice *big.Int
+	i, j := 0, 0
+	longRemaining, shortRemaining := big.NewInt(0), big.NewInt(0)
+	for i < len(longIndexes) && j < len(shortIndexes) {
+		long, short := longs[longIndexes[i]], shorts[shortIndexes[j]]
+		if long.Price.Cmp(short.Price) < 0 {
+			break
+		}
+		if longRemaining.Sign() == 0 {
+			longRemaining.Set(long.Size)
+		}
+		if shortRemaining.Sign() == 0 {
+			shortRemaining.Set(short.Size)
+		}
+		fillAmount := utils.BigIntMin(longRemaining, shortRemaining)
+		result.LongFills[longIndexes[i]].Add(result.LongFills[longIndexes[i]], fillAmount)
+		result.ShortFills[shortIndexes[j]].Add(result.ShortFills[shortIndexes[j]], fillAmount)
+		result.Volume.Add(result.Volume, fillAmount)
+		marginalLongPrice, marginalShortPrice = long.Price, short.Price
+
+		longRemaining.Sub(longRemaining, fillAmount)
+		shortRemaining.Sub(shortRemaining, fillAmount)
+		if longRemaining.Sign() == 0 {
+			i++
+		}
+		if shortRemaining.Sign() == 0 {
+			j++
+		}
+	}
+	if result.Volume.Sign() == 0 {
+		return nil, false
+	}
+
+	// the clearing price has to be between the marginal prices, within the price bounds and a multiple of the tick size
+	minPrice := roundUpToMultiple(utils.BigIntMax(marginalShortPrice, lowerBound), tickSize)
+	maxPrice := RoundOff(utils.BigIntMin(marginalLongPrice, upperBound), tickSize)
+	if minPrice.Cmp(maxPrice) > 0 {
+		// the bounds are narrower than the tick size
+		return nil, false
+	}
+	result.ClearingPrice = utils.BigIntMin(utils.BigIntMax(RoundOff(oraclePrice, tickSize), minPrice), maxPrice)
+	return result, true
+}
+
+// RankAuctionOrders returns the indexes of the orders with a non zero size, from the highest priority in the auction to the lowest
+func RankAuctionOrders(orders []AuctionOrder, isLong bool) []int {
+	indexes := []int{}
+	for i, order := range orders {
+		if order.Size.Sign() > 0 {
+			indexes = append(indexes, i)
+		}
+	}
+	sort.SliceStable(indexes, func(a, b int) bool {
+		orderA, orderB := orders[indexes[a]], orders[indexes[b]]
+		if priceDiff := orderA.Price.Cmp(orderB.Price); priceDiff != 0 {
+			return (priceDiff > 0) == isLong
+		}
+		if blockDiff := orderA.BlockPlaced.Cmp(orderB.BlockPlaced); blockDiff != 0 {
+			return blockDiff < 0
+		}
+		return bytes.Compare(orderA.OrderHash[:], orderB.OrderHash[:]) < 0
+	})
+	return indexes
+}
+
+func filterAuctionOrders(orders []AuctionOrder, indexes []int, filter func(AuctionOrder) bool) []int {
+	filteredIndexes := indexes[:0]
+	for _, i := range indexes {
+		if filter(orders[i]) {
+			filteredIndexes = append(filteredIndexes, i)
+		}
+	}
+	return filteredIndexes
+}
+
+func roundUpToMultiple(a, b *big.Int) *big.Int {
+	rounded := RoundOff(a, b)
+	if rounded.Cmp(a) < 0 {
+		rounded.Add(rounded, b)
+	}
+	return rounded
+}
@@ -0,0 +1,96 @@
+package hubbleutils
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClearBatchAuction(t *testing.T) {
+	tickSize := big.NewInt(10)
+	lowerBound, upperBound := big.NewInt(50), big.NewInt(150)
+	auctionOrder := func(id byte, price, size, blockPlaced int64) AuctionOrder {
+		return AuctionOrder{OrderHash: common.Hash{id}, Price: big.NewInt(price), Size: big.NewInt(size), BlockPlaced: big.NewInt(blockPlaced)}
+	}
+	assertFills := func(t *testing.T, expected []int64, fills []*big.Int) {
+		assert.Equal(t, len(expected), len(fills))
+		for i := range expected {
+			assert.Equal(t, big.NewInt(expected[i]), fills[i], "fill %d", i)
+		}
+	}
+
+	t.Run("no crossing orders", func(t *testing.T) {
+		longs := []AuctionOrder{auctionOrder(1, 90, 5, 1)}
+		shorts := []AuctionOrder{auctionOrder(2, 100, 5, 1)}
+		_, ok := ClearBatchAuction(longs, shorts, big.NewInt(95), lowerBound, upperBound, tickSize)
+		assert.False(t, ok)
+		_, ok = ClearBatchAuction(longs, nil, big.NewInt(95), lowerBound, upperBound, tickSize)
+		assert.False(t, ok)
+	})
+
+	t.Run("matches the max volume at a single price", func(t *testing.T) {
+		longs := []AuctionOrder{auctionOrder(1, 100, 3, 1), auctionOrder(2, 120, 2, 1), auctionOrder(3, 80, 4, 1)}
+		shorts := []AuctionOrder{auctionOrder(4, 90, 4, 1), auctionOrder(5, 70, 2, 1), auctionOrder(6, 130, 5, 1)}
+		result, ok := ClearBatchAuction(longs, shorts, big.NewInt(95), lowerBound, upperBound, tickSize)
+		assert.True(t, ok)
+		assert.Equal(t, big.NewInt(5), result.Volume)
+		assertFills(t, []int64{3, 2, 0}, result.LongFills)
+		assertFills(t, []int64{3, 2, 0}, result.ShortFills)
+		// marginal prices are 100 (long) and 90 (short), the oracle price is rounded down to the tick size
+		assert.Equal(t, big.NewInt(90), result.ClearingPrice)
+	})
+
+	t.Run("clearing price is clamped to the marginal prices", func(t *testing.T) {
+		longs := []AuctionOrder{auctionOrder(1, 110, 2, 1)}
+		shorts := []AuctionOrder{auctionOrder(2, 100, 2, 1)}
+		result, ok := ClearBatchAuction(longs, shorts, big.NewInt(140), lowerBound, upperBound, tickSize)
+		assert.True(t, ok)
+		assert.Equal(t, big.NewInt(110), result.ClearingPrice)
+
+		result, ok = ClearBatchAuction(longs, shorts, big.NewInt(60), lowerBound, upperBound, tickSize)
+		assert.True(t, ok)
+		assert.Equal(t, big.NewInt(100), result.ClearingPrice)
+	})
+
+	t.Run("clearing price is kept within the price bounds", func(t *testing.T) {
+		longs := []AuctionOrder{auctionOrder(1, 200, 2, 1)}
+		shorts := []AuctionOrder{auctionOrder(2, 140, 2, 1)}
+		result, ok := ClearBatchAuction(longs, shorts, big.NewInt(300), lowerBound, big.NewInt(155), tickSize)
+		assert.True(t, ok)
+		assert.Equal(t, big.NewInt(150), result.ClearingPrice)
+
+		longs = []AuctionOrder{auctionOrder(1, 60, 2, 1)}
+		shorts = []AuctionOrder{auctionOrder(2, 10, 2, 1)}
+		result, ok = ClearBatchAuction(longs, shorts, big.NewInt(0), big.NewInt(45), upperBound, tickSize)
+		assert.True(t, ok)
+		assert.Equal(t, big.NewInt(50), result.ClearingPrice)
+	})
+
+	t.Run("orders that can't be filled within the price bounds are not matched", func(t *testing.T) {
+		longs := []AuctionOrder{auctionOrder(1, 40, 2, 1), auctionOrder(2, 100, 2, 1)}
+		shorts := []AuctionOrder{auctionOrder(3, 30, 2, 1), auctionOrder(4, 160, 2, 1)}
+		result, ok := ClearBatchAuction(longs, shorts, big.NewInt(100), lowerBound, upperBound, tickSize)
+		assert.True(t, ok)
+		assertFills(t, []int64{0, 2}, result.LongFills)
+		assertFills(t, []int64{2, 0}, result.ShortFills)
+	})
+
+	t.Run("ties are broken by block placed and then by order hash", func(t *testing.T) {
+		longs := []AuctionOrder{auctionOrder(3, 100, 2, 5), auctionOrder(2, 100, 2, 5), auctionOrder(1, 100, 2, 6)}
+		shorts := []AuctionOrder{auctionOrder(4, 100, 3, 1)}
+		result, ok := ClearBatchAuction(longs, shorts, big.NewInt(100), lowerBound, upperBound, tickSize)
+		assert.True(t, ok)
+		assert.Equal(t, big.NewInt(3), result.Volume)
+		assertFills(t, []int64{1, 2, 0}, result.LongFills)
+		assertFills(t, []int64{3}, result.ShortFills)
+	})
+
+	t.Run("bounds narrower than the tick size", func(t *testing.T) {
+		longs := []AuctionOrder{auctionOrder(1, 200, 2, 1)}
+		shorts := []AuctionOrder{auctionOrder(2, 10, 2, 1)}
+		_, ok := ClearBatchAuction(longs, shorts, big.NewInt(100), big.NewInt(101), big.NewInt(109), tickSize)
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,97 @@
+package hubbleutils
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ava-labs/subnet-evm/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// AllMarkets is the AmmIndex of a cancel all message for the orders in every market
+var AllMarkets = big.NewInt(-1)
+
+// CancelAllOrders is a message signed by a trader or its trading authority that cancels all the trader's signed orders
+// in a market, or in all markets if AmmIndex is AllMarkets.
+// Orders with a salt <= Nonce can't be placed or matched after it, so a client that uses a timestamp as the salt of its orders
+// can keep placing new orders. The nonce of a market can only increase.
+// The message is gossiped to the makerbooks and the validators record it with the juror's cancelAllSignedOrders, which rejects the orders on chain.
+type CancelAllOrders struct {
+	Trader   common.Address `json:"trader"`
+	AmmIndex *big.Int       `json:"ammIndex"`
+	Nonce    *big.Int       `json:"nonce"`
+	Sig      hexutil.Bytes  `json:"sig"`
+}
+
+func (c *CancelAllOrders) Hash() (hash common.Hash, err error) {
+	if VerifyingContract == "" || ChainId == 0 {
+		return common.Hash{}, fmt.Errorf("ChainId or VerifyingContract not set")
+	}
+	message := map[string]interface{}{
+		"trader":   c.Trader.String(),
+		"ammIndex": c.AmmIndex.String(),
+		"nonce":    c.Nonce.String(),
+	}
+	domain := apitypes.TypedDataDomain{
+		Name:              "Hubble",
+		Version:           "2.0",
+		ChainId:           math.NewHexOrDecimal256(ChainId),
+		VerifyingContract: VerifyingContract,
+	}
+	typedData := apitypes.TypedData{
+		Types:       Eip712OrderTypes,
+		PrimaryType: "CancelAllOrders",
+		Domain:      domain,
+		Message:     message,
+	}
+	return EncodeForSigning(typedData)
+}
+
+func (c *CancelAllOrders) EncodeToABI() ([]byte, error) {
+	return cancelAllOrdersArguments().Pack(c.Trader, c.AmmIndex, c.Nonce, []byte(c.Sig))
+}
+
+func DecodeCancelAllOrders(data []byte) (*CancelAllOrders, error) {
+	decodedValues, err := cancelAllOrdersArguments().Unpack(data)
+	if err != nil {
+		return nil, err
+	}
+	return &CancelAllOrders{
+		Trader:   decodedValues[0].(common.Address),
+		AmmIndex: decodedValues[1].(*big.Int),
+		Nonce:    decodedValues[2].(*big.Int),
+		Sig:      decodedValues[3].([]byte),
+	}, nil
+}
+
+func cancelAllOrdersArguments() abi.Arguments {
+	addressTy, _ := abi.NewType("address", "address", nil)
+	int256Ty, _ := abi.NewType("int256", "int256", nil)
+	uint256Ty, _ := abi.NewType("uint256", "uint256", nil)
+	bytesTy, _ := abi.NewType("bytes", "bytes", nil)
+	return abi.Arguments{{Type: addressTy}, {Type: int256Ty}, {Type: uint256Ty}, {Type: bytesTy}}
+}
+
+func (c *CancelAllOrders) IsAllMarkets() bool {
+	return c.AmmIndex.Cmp(AllMarkets) == 0
+}
+
+// ValidateCancelAllOrders checks the market and nonce of the message and returns the address that signed it.
+// The caller checks that the signer is the trader or its trading authority, and that the nonce is greater than the last one
+func ValidateCancelAllOrders(c *CancelAllOrders, activeMarketsCount int64) (signer common.Address, err error) {
+	if c.AmmIndex == nil || c.AmmIndex.Cmp(AllMarkets) < 0 || c.AmmIndex.Cmp(big.NewInt(activeMarketsCount)) >= 0 {
+		return signer, ErrInvalidMarket
+	}
+	if c.Nonce == nil || c.Nonce.Sign() <= 0 {
+		return signer, errors.New("invalid nonce")
+	}
+	hash, err := c.Hash()
+	if err != nil {
+		return signer, err
+	}
+	return ECRecover(hash.Bytes(), c.Sig)
+}
@@ -0,0 +1,101 @@
+package hubbleutils
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCancelAllOrders(t *testing.T) {
+	SetChainIdAndVerifyingSignedOrdersContract(321123, "0x4c5859f0F772848b2D91F1D83E2Fe57935348029")
+	key, err := crypto.GenerateKey()
+	assert.Nil(t, err)
+	trader := crypto.PubkeyToAddress(key.PublicKey)
+
+	sign := func(c *CancelAllOrders) {
+		hash, err := c.Hash()
+		assert.Nil(t, err)
+		sig, err := crypto.Sign(hash.Bytes(), key)
+		assert.Nil(t, err)
+		sig[crypto.RecoveryIDOffset] += 27
+		c.Sig = sig
+	}
+
+	t.Run("market and all markets messages return the signer", func(t *testing.T) {
+		for _, ammIndex := range []*big.Int{big.NewInt(1), AllMarkets} {
+			c := &CancelAllOrders{Trader: trader, AmmIndex: ammIndex, Nonce: big.NewInt(1700000000000)}
+			sign(c)
+			signer, err := ValidateCancelAllOrders(c, 2)
+			assert.Nil(t, err)
+			assert.Equal(t, trader, signer)
+		}
+	})
+
+	t.Run("signature is bound to the nonce", func(t *testing.T) {
+		c := &CancelAllOrders{Trader: trader, AmmIndex: big.NewInt(0), Nonce: big.NewInt(5)}
+		sign(c)
+		c.Nonce = big.NewInt(6)
+		signer, err := ValidateCancelAllOrders(c, 2)
+		assert.Nil(t, err)
+		assert.NotEqual(t, trader, signer)
+	})
+
+	t.Run("invalid market", func(t *testing.T) {
+		for _, ammIndex := range []*big.Int{big.NewInt(2), big.NewInt(-2), nil} {
+			c := &CancelAllOrders{Trader: trader, AmmIndex: ammIndex, Nonce: big.NewInt(5)}
+			_, err := ValidateCancelAllOrders(c, 2)
+			assert.Equal(t, ErrInvalidMarket, err)
+		}
+	})
+
+	t.Run("invalid nonce", func(t *testing.T) {
+		c := &CancelAllOrders{Trader: trader, AmmIndex: big.NewInt(0), Nonce: big.NewInt(0)}
+		_, err := ValidateCancelAllOrders(c, 2)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestValidateSignedOrderCancelNonce(t *testing.T) {
+	SetChainIdAndVerifyingSignedOrdersContract(321123, "0x4c5859f0F772848b2D91F1D83E2Fe57935348029")
+	key, err := crypto.GenerateKey()
+	assert.Nil(t, err)
+	order := &SignedOrder{
+		LimitOrder: LimitOrder{
+			BaseOrder: BaseOrder{
+				AmmIndex:          big.NewInt(0),
+				Trader:            crypto.PubkeyToAddress(key.PublicKey),
+				BaseAssetQuantity: big.NewInt(3e18),
+				Price:             big.NewInt(1000000000),
+				Salt:              big.NewInt(1688994806105),
+			},
+			PostOnly: true,
+		},
+		OrderType: uint8(Signed),
+		ExpireAt:  big.NewInt(1688994854),
+	}
+	hash, err := order.Hash()
+	assert.Nil(t, err)
+	order.Sig, err = crypto.Sign(hash.Bytes(), key)
+	assert.Nil(t, err)
+	order.Sig[crypto.RecoveryIDOffset] += 27
+
+	getFields := func(cancelNonce *big.Int) SignedOrderValidationFields {
+		return SignedOrderValidationFields{
+			OrderHash:          hash,
+			Now:                1688994800,
+			ActiveMarketsCount: 1,
+			MinSize:            big.NewInt(1e18),
+			PriceMultiplier:    big.NewInt(1),
+			Status:             int64(Invalid),
+			CancelNonce:        cancelNonce,
+		}
+	}
+	_, _, err = ValidateSignedOrder(order, getFields(nil))
+	assert.Nil(t, err)
+	_, _, err = ValidateSignedOrder(order, getFields(big.NewInt(1688994806104)))
+	assert.Nil(t, err)
+	_, _, err = ValidateSignedOrder(order, getFields(big.NewInt(1688994806105)))
+	assert.Equal(t, ErrCancelledByNonce, err)
+}
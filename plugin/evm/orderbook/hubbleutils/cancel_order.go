@@ -0,0 +1,87 @@
+package hubbleutils
+
+import (
+	"fmt"
+
+	"github.com/ava-labs/subnet-evm/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// CancelOrder is an off-chain message signed by a trader or its trading authority that cancels one of the trader's signed orders.
+// It carries the order itself, so that the juror can check that the signer owns the order without having seen it before.
+// The validators record the message on chain with the juror's cancelSignedOrders, after which the juror rejects the order.
+// Amending a signed order cancels the original order with this message.
+type CancelOrder struct {
+	Order *SignedOrder  `json:"order"`
+	Sig   hexutil.Bytes `json:"sig"`
+}
+
+func (c *CancelOrder) Hash() (hash common.Hash, err error) {
+	if VerifyingContract == "" || ChainId == 0 {
+		return common.Hash{}, fmt.Errorf("ChainId or VerifyingContract not set")
+	}
+	orderHash, err := c.Order.Hash()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	message := map[string]interface{}{
+		"orderHash": orderHash.Hex(),
+	}
+	domain := apitypes.TypedDataDomain{
+		Name:              "Hubble",
+		Version:           "2.0",
+		ChainId:           math.NewHexOrDecimal256(ChainId),
+		VerifyingContract: VerifyingContract,
+	}
+	typedData := apitypes.TypedData{
+		Types:       Eip712OrderTypes,
+		PrimaryType: "CancelOrder",
+		Domain:      domain,
+		Message:     message,
+	}
+	return EncodeForSigning(typedData)
+}
+
+// EncodeToABI encodes the message as the juror's cancelSignedOrders expects it
+func (c *CancelOrder) EncodeToABI() ([]byte, error) {
+	encodedOrder, err := c.Order.EncodeToABIWithoutType()
+	if err != nil {
+		return nil, err
+	}
+	bytesTy, _ := abi.NewType("bytes", "bytes", nil)
+	return abi.Arguments{{Type: bytesTy}, {Type: bytesTy}}.Pack(encodedOrder, []byte(c.Sig))
+}
+
+func DecodeCancelOrder(data []byte) (*CancelOrder, error) {
+	bytesTy, _ := abi.NewType("bytes", "bytes", nil)
+	decodedValues, err := abi.Arguments{{Type: bytesTy}, {Type: bytesTy}}.Unpack(data)
+	if err != nil {
+		return nil, err
+	}
+	order, err := DecodeSignedOrder(decodedValues[0].([]byte))
+	if err != nil {
+		return nil, err
+	}
+	return &CancelOrder{Order: order, Sig: decodedValues[1].([]byte)}, nil
+}
+
+// ValidateCancelOrder returns the hash of the cancelled order and the address that signed the message.
+// The caller checks that the signer is the trader of the order or its trading authority
+func ValidateCancelOrder(c *CancelOrder) (orderHash common.Hash, signer common.Address, err error) {
+	if c.Order == nil || OrderType(c.Order.OrderType) != Signed {
+		return orderHash, signer, ErrNotSignedOrder
+	}
+	orderHash, err = c.Order.Hash()
+	if err != nil {
+		return orderHash, signer, err
+	}
+	hash, err := c.Hash()
+	if err != nil {
+		return orderHash, signer, err
+	}
+	signer, err = ECRecover(hash.Bytes(), c.Sig)
+	return orderHash, signer, err
+}
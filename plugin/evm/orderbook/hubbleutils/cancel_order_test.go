@@ -0,0 +1,89 @@
+package hubbleutils
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCancelOrder(t *testing.T) {
+	SetChainIdAndVerifyingSignedOrdersContract(321123, "0x4c5859f0F772848b2D91F1D83E2Fe57935348029")
+	key, err := crypto.GenerateKey()
+	assert.Nil(t, err)
+	trader := crypto.PubkeyToAddress(key.PublicKey)
+	newOrder := func(salt int64) *SignedOrder {
+		return &SignedOrder{
+			LimitOrder: LimitOrder{
+				BaseOrder: BaseOrder{
+					AmmIndex:          big.NewInt(0),
+					Trader:            trader,
+					BaseAssetQuantity: big.NewInt(3e18),
+					Price:             big.NewInt(1000000000),
+					Salt:              big.NewInt(salt),
+				},
+				PostOnly: true,
+			},
+			OrderType: uint8(Signed),
+			ExpireAt:  big.NewInt(1688994854),
+			Sig:       []byte{1},
+		}
+	}
+	sign := func(c *CancelOrder) {
+		hash, err := c.Hash()
+		assert.Nil(t, err)
+		sig, err := crypto.Sign(hash.Bytes(), key)
+		assert.Nil(t, err)
+		sig[crypto.RecoveryIDOffset] += 27
+		c.Sig = sig
+	}
+
+	t.Run("returns the order hash and the signer", func(t *testing.T) {
+		c := &CancelOrder{Order: newOrder(1)}
+		sign(c)
+		orderHash, signer, err := ValidateCancelOrder(c)
+		assert.Nil(t, err)
+		assert.Equal(t, trader, signer)
+		expectedHash, _ := c.Order.Hash()
+		assert.Equal(t, expectedHash, orderHash)
+	})
+
+	t.Run("signature is bound to the order", func(t *testing.T) {
+		c := &CancelOrder{Order: newOrder(1)}
+		sign(c)
+		c.Order = newOrder(2)
+		_, signer, err := ValidateCancelOrder(c)
+		assert.Nil(t, err)
+		assert.NotEqual(t, trader, signer)
+	})
+
+	t.Run("not a signed order", func(t *testing.T) {
+		c := &CancelOrder{Order: newOrder(1)}
+		c.Order.OrderType = uint8(Limit)
+		_, _, err := ValidateCancelOrder(c)
+		assert.Equal(t, ErrNotSignedOrder, err)
+	})
+
+	t.Run("abi encoding", func(t *testing.T) {
+		c := &CancelOrder{Order: newOrder(1)}
+		sign(c)
+		data, err := c.EncodeToABI()
+		assert.Nil(t, err)
+		decoded, err := DecodeCancelOrder(data)
+		assert.Nil(t, err)
+		assert.Equal(t, c.Sig, decoded.Sig)
+		expectedHash, _ := c.Order.Hash()
+		orderHash, signer, err := ValidateCancelOrder(decoded)
+		assert.Nil(t, err)
+		assert.Equal(t, expectedHash, orderHash)
+		assert.Equal(t, trader, signer)
+	})
+
+	t.Run("cancelled orders are rejected", func(t *testing.T) {
+		fields := SignedOrderValidationFields{ActiveMarketsCount: 1, Status: int64(Invalid)}
+		assert.Nil(t, ValidateSignedOrderState(newOrder(1), fields))
+		fields.Cancelled = true
+		assert.Equal(t, ErrOrderCancelled, ValidateSignedOrderState(newOrder(1), fields))
+	})
+}
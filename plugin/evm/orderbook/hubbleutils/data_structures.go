@@ -65,10 +65,93 @@ const (
 	Limit OrderType = iota
 	IOC
 	Signed
+	Trigger
 )
 
 func (o OrderType) String() string {
-	return [...]string{"limit", "ioc", "signed"}[o]
+	return [...]string{"limit", "ioc", "signed", "trigger"}[o]
+}
+
+// TriggerKind decides the direction in which the oracle price has to move for a trigger order to become active
+type TriggerKind uint8
+
+const (
+	// StopLoss triggers when the price moves against the position that the order closes, i.e. a sell order triggers
+	// when the price falls to the trigger price and a buy order when the price rises to it
+	StopLoss TriggerKind = iota
+	// TakeProfit triggers when the price moves in favour of the position that the order closes
+	TakeProfit
+)
+
+func (k TriggerKind) String() string {
+	switch k {
+	case StopLoss:
+		return "stop_loss"
+	case TakeProfit:
+		return "take_profit"
+	}
+	return "unknown"
+}
+
+// MatchingMode decides how an incoming order is allocated among the resting orders at the best price level
+type MatchingMode uint8
+
+const (
+	// FIFO fills resting orders in the order they were placed
+	FIFO MatchingMode = iota
+	// ProRata fills resting orders in proportion to their unfilled size, the rounding remainder is allocated FIFO
+	ProRata
+	// TopOfBookPriority fills the first order at the level completely, then allocates the rest pro-rata
+	TopOfBookPriority
+	// BatchAuction collects the crossing orders of every block and fills them at a single clearing price, see ClearBatchAuction.
+	// Orders can't be filled pair by pair, so a validator can't front run an order by matching it ahead of the others
+	BatchAuction
+)
+
+func (m MatchingMode) String() string {
+	switch m {
+	case FIFO:
+		return "fifo"
+	case ProRata:
+		return "pro_rata"
+	case TopOfBookPriority:
+		return "top_of_book_priority"
+	case BatchAuction:
+		return "batch_auction"
+	}
+	return "unknown"
+}
+
+// SelfTradePreventionMode decides what happens when a taker would match a resting order of the same trader,
+// or of a trader that shares a trading authority with it
+type SelfTradePreventionMode uint8
+
+const (
+	NoSelfTradePrevention SelfTradePreventionMode = iota
+	// CancelNewest cancels the taker order
+	CancelNewest
+	// CancelOldest cancels the resting order
+	CancelOldest
+	// CancelBoth cancels both the orders
+	CancelBoth
+	// DecrementAndCancel reduces both the orders by the smaller unfilled quantity, which cancels the smaller order
+	DecrementAndCancel
+)
+
+func (m SelfTradePreventionMode) String() string {
+	switch m {
+	case NoSelfTradePrevention:
+		return "none"
+	case CancelNewest:
+		return "cancel_newest"
+	case CancelOldest:
+		return "cancel_oldest"
+	case CancelBoth:
+		return "cancel_both"
+	case DecrementAndCancel:
+		return "decrement_and_cancel"
+	}
+	return "unknown"
 }
 
 type BaseOrder struct {
@@ -293,5 +376,20 @@ func getOrderType(orderType string) (abi.Type, error) {
 			{Name: "postOnly", Type: "bool"},
 		})
 	}
+	if orderType == "trigger" {
+		return abi.NewType("tuple", "", []abi.ArgumentMarshaling{
+			{Name: "orderType", Type: "uint8"},
+			{Name: "expireAt", Type: "uint256"},
+			{Name: "ammIndex", Type: "uint256"},
+			{Name: "trader", Type: "address"},
+			{Name: "baseAssetQuantity", Type: "int256"},
+			{Name: "price", Type: "uint256"},
+			{Name: "salt", Type: "uint256"},
+			{Name: "reduceOnly", Type: "bool"},
+			{Name: "postOnly", Type: "bool"},
+			{Name: "triggerPrice", Type: "uint256"},
+			{Name: "triggerKind", Type: "uint8"},
+		})
+	}
 	return abi.Type{}, fmt.Errorf("invalid order type")
 }
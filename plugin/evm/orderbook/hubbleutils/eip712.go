@@ -80,4 +80,98 @@ var Eip712OrderTypes = apitypes.Types{
 			Type: "bool",
 		},
 	},
+	"TriggerOrder": {
+		{
+			Name: "orderType",
+			Type: "uint8",
+		},
+		{
+			Name: "expireAt",
+			Type: "uint256",
+		},
+		{
+			Name: "ammIndex",
+			Type: "uint256",
+		},
+		{
+			Name: "trader",
+			Type: "address",
+		},
+		{
+			Name: "baseAssetQuantity",
+			Type: "int256",
+		},
+		{
+			Name: "price",
+			Type: "uint256",
+		},
+		{
+			Name: "salt",
+			Type: "uint256",
+		},
+		{
+			Name: "reduceOnly",
+			Type: "bool",
+		},
+		{
+			Name: "postOnly",
+			Type: "bool",
+		},
+		{
+			Name: "triggerPrice",
+			Type: "uint256",
+		},
+		{
+			Name: "triggerKind",
+			Type: "uint8",
+		},
+	},
+	"CancelAllOrders": {
+		{
+			Name: "trader",
+			Type: "address",
+		},
+		{
+			Name: "ammIndex",
+			Type: "int256",
+		},
+		{
+			Name: "nonce",
+			Type: "uint256",
+		},
+	},
+	"CancelOrder": {
+		{
+			Name: "orderHash",
+			Type: "bytes32",
+		},
+	},
+	"StreamAuth": {
+		{
+			Name: "trader",
+			Type: "address",
+		},
+		{
+			Name: "expireAt",
+			Type: "uint256",
+		},
+		{
+			Name: "nonce",
+			Type: "bytes32",
+		},
+	},
+	"Heartbeat": {
+		{
+			Name: "trader",
+			Type: "address",
+		},
+		{
+			Name: "timeout",
+			Type: "uint256",
+		},
+		{
+			Name: "timestamp",
+			Type: "uint256",
+		},
+	},
 }
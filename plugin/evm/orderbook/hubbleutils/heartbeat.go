@@ -0,0 +1,74 @@
+package hubbleutils
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+const (
+	// MinHeartbeatTimeout and MaxHeartbeatTimeout bound the timeout, in seconds, a trader can register with a heartbeat
+	MinHeartbeatTimeout = 5
+	MaxHeartbeatTimeout = 24 * 60 * 60
+	// MaxHeartbeatClockSkew is how far, in seconds, the timestamp of a heartbeat can be from the time of the node
+	MaxHeartbeatClockSkew = 30
+)
+
+// Heartbeat is signed by a trader or its trading authority to keep its dead man's switch alive. Heartbeats are gossiped, and if a node
+// doesn't receive a newer heartbeat within Timeout seconds of Timestamp, it cancels the signed orders of the trader it received before that.
+// A heartbeat with a Timeout of 0 unregisters the switch.
+type Heartbeat struct {
+	Trader    common.Address `json:"trader"`
+	Timeout   uint64         `json:"timeout"`
+	Timestamp uint64         `json:"timestamp"`
+	Sig       hexutil.Bytes  `json:"sig"`
+}
+
+func (h *Heartbeat) Hash() (hash common.Hash, err error) {
+	if VerifyingContract == "" || ChainId == 0 {
+		return common.Hash{}, fmt.Errorf("ChainId or VerifyingContract not set")
+	}
+	message := map[string]interface{}{
+		"trader":    h.Trader.String(),
+		"timeout":   strconv.FormatUint(h.Timeout, 10),
+		"timestamp": strconv.FormatUint(h.Timestamp, 10),
+	}
+	domain := apitypes.TypedDataDomain{
+		Name:              "Hubble",
+		Version:           "2.0",
+		ChainId:           math.NewHexOrDecimal256(ChainId),
+		VerifyingContract: VerifyingContract,
+	}
+	typedData := apitypes.TypedData{
+		Types:       Eip712OrderTypes,
+		PrimaryType: "Heartbeat",
+		Domain:      domain,
+		Message:     message,
+	}
+	return EncodeForSigning(typedData)
+}
+
+// Deadline is the time after which the trader's signed orders are cancelled if there is no newer heartbeat
+func (h *Heartbeat) Deadline() uint64 {
+	return h.Timestamp + h.Timeout
+}
+
+// ValidateHeartbeat checks the timeout and timestamp of a heartbeat sent to the node and returns the address that signed it.
+// The caller checks that the signer is the trader or its trading authority, and that the heartbeat is newer than the last one
+func ValidateHeartbeat(h *Heartbeat, now uint64) (signer common.Address, err error) {
+	if h.Timeout != 0 && (h.Timeout < MinHeartbeatTimeout || h.Timeout > MaxHeartbeatTimeout) {
+		return signer, ErrInvalidHeartbeatTimeout
+	}
+	if h.Timestamp > now+MaxHeartbeatClockSkew || h.Timestamp+MaxHeartbeatClockSkew < now {
+		return signer, ErrHeartbeatClockSkew
+	}
+	hash, err := h.Hash()
+	if err != nil {
+		return signer, err
+	}
+	return ECRecover(hash.Bytes(), h.Sig)
+}
@@ -0,0 +1,60 @@
+package hubbleutils
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateHeartbeat(t *testing.T) {
+	SetChainIdAndVerifyingSignedOrdersContract(321123, "0x4c5859f0F772848b2D91F1D83E2Fe57935348029")
+	key, err := crypto.GenerateKey()
+	assert.Nil(t, err)
+	trader := crypto.PubkeyToAddress(key.PublicKey)
+	now := uint64(1700000000)
+
+	sign := func(h *Heartbeat) {
+		hash, err := h.Hash()
+		assert.Nil(t, err)
+		sig, err := crypto.Sign(hash.Bytes(), key)
+		assert.Nil(t, err)
+		sig[crypto.RecoveryIDOffset] += 27
+		h.Sig = sig
+	}
+
+	t.Run("valid heartbeat returns the signer", func(t *testing.T) {
+		for _, timeout := range []uint64{0, MinHeartbeatTimeout, MaxHeartbeatTimeout} {
+			h := &Heartbeat{Trader: trader, Timeout: timeout, Timestamp: now}
+			sign(h)
+			signer, err := ValidateHeartbeat(h, now)
+			assert.Nil(t, err)
+			assert.Equal(t, trader, signer)
+		}
+	})
+
+	t.Run("signature is bound to the timeout", func(t *testing.T) {
+		h := &Heartbeat{Trader: trader, Timeout: 10, Timestamp: now}
+		sign(h)
+		h.Timeout = 20
+		signer, err := ValidateHeartbeat(h, now)
+		assert.Nil(t, err)
+		assert.NotEqual(t, trader, signer)
+	})
+
+	t.Run("invalid timeout", func(t *testing.T) {
+		for _, timeout := range []uint64{MinHeartbeatTimeout - 1, MaxHeartbeatTimeout + 1} {
+			h := &Heartbeat{Trader: trader, Timeout: timeout, Timestamp: now}
+			_, err := ValidateHeartbeat(h, now)
+			assert.Equal(t, ErrInvalidHeartbeatTimeout, err)
+		}
+	})
+
+	t.Run("timestamp too far from now", func(t *testing.T) {
+		for _, timestamp := range []uint64{now - MaxHeartbeatClockSkew - 1, now + MaxHeartbeatClockSkew + 1} {
+			h := &Heartbeat{Trader: trader, Timeout: 10, Timestamp: timestamp}
+			_, err := ValidateHeartbeat(h, now)
+			assert.Equal(t, ErrHeartbeatClockSkew, err)
+		}
+	})
+}
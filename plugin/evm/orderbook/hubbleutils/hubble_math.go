@@ -1,6 +1,7 @@
 package hubbleutils
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
 
@@ -76,21 +77,36 @@ func Unscale(a *big.Int, decimals uint8) *big.Int {
 	return Div(a, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))
 }
 
+// SignatureError is returned by ECRecover when the signature is malformed or the public key can't be recovered.
+// It keeps the message of the underlying error, which is part of the juror's output
+type SignatureError struct {
+	Err error
+}
+
+func (e *SignatureError) Error() string { return e.Err.Error() }
+func (e *SignatureError) Unwrap() error { return e.Err }
+
+// IsSignatureError returns true if err is or wraps a SignatureError
+func IsSignatureError(err error) bool {
+	var signatureErr *SignatureError
+	return errors.As(err, &signatureErr)
+}
+
 func ECRecover(data, sign hexutil.Bytes) (common.Address, error) {
 	sig := make([]byte, len(sign))
 	copy(sig, sign)
 
 	if len(sig) != crypto.SignatureLength {
-		return common.Address{}, fmt.Errorf("signature must be %d bytes long", crypto.SignatureLength)
+		return common.Address{}, &SignatureError{fmt.Errorf("signature must be %d bytes long", crypto.SignatureLength)}
 	}
 	if sig[crypto.RecoveryIDOffset] != 27 && sig[crypto.RecoveryIDOffset] != 28 {
-		return common.Address{}, fmt.Errorf("invalid Ethereum signature (V is not 27 or 28)")
+		return common.Address{}, &SignatureError{fmt.Errorf("invalid Ethereum signature (V is not 27 or 28)")}
 	}
 	sig[crypto.RecoveryIDOffset] -= 27 // Transform yellow paper V from 27/28 to 0/1
 
 	rpk, err := crypto.Ecrecover(data, sig)
 	if err != nil {
-		return common.Address{}, err
+		return common.Address{}, &SignatureError{err}
 	}
 	return common.BytesToAddress(common.LeftPadBytes(crypto.Keccak256(rpk[1:])[12:], 32)), nil
 }
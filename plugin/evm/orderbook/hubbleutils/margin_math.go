@@ -125,6 +125,80 @@ func getOptimalPnl(hState *HubbleState, position *Position, margin *big.Int, mar
 	return notionalPosition, unrealizedPnl
 }
 
+// GetLiquidationPrice returns the oracle price of market at which the margin fraction of the trader falls to the maintenance margin, with every other price unchanged.
+// The uPnL of the position at price p is size * p / 1e18 + c, where c is -openNotional for longs and openNotional for shorts, so for
+// MF(p) = 1e6 * (margin + otherPnl + size * p / 1e18 + c) / (otherNotional + |size| * p / 1e18) = maintenanceMargin, p is the solution of a linear equation.
+// Margins of every collateral are weighted with their current prices, and the other positions are valued the same way as GetMarginFraction.
+// Returns 0 if there is no such positive price, or if the market is settled or not active, because its oracle price doesn't affect the margin fraction then.
+func GetLiquidationPrice(hState *HubbleState, userState *UserState, market Market) *big.Int {
+	position := userState.Positions[market]
+	if position == nil || position.Size == nil || position.Size.Sign() == 0 || !isActiveMarket(hState, market) {
+		return big.NewInt(0)
+	}
+	if settlementPrice := hState.SettlementPrices[market]; settlementPrice != nil && settlementPrice.Sign() != 0 {
+		return big.NewInt(0)
+	}
+
+	margin := Sub(GetNormalizedMargin(hState.Assets, userState.Margins), userState.PendingFunding)
+	otherNotional, otherPnl := big.NewInt(0), big.NewInt(0)
+	for _, m := range hState.ActiveMarkets {
+		if m == market {
+			continue
+		}
+		notional, pnl := getOptimalPnl(hState, userState.Positions[m], margin, m, Maintenance_Margin)
+		otherNotional.Add(otherNotional, notional)
+		otherPnl.Add(otherPnl, pnl)
+	}
+
+	c := new(big.Int).Set(position.OpenNotional)
+	if position.Size.Sign() > 0 {
+		c.Neg(c)
+	}
+	a := Add(Add(margin, otherPnl), c)
+	numerator := Mul1e18(Sub(Mul(hState.MaintenanceMargin, otherNotional), Mul1e6(a)))
+	denominator := Sub(Mul1e6(position.Size), Mul(hState.MaintenanceMargin, Abs(position.Size)))
+	if denominator.Sign() == 0 {
+		return big.NewInt(0)
+	}
+	price := Div(numerator, denominator)
+	if price.Sign() <= 0 {
+		return big.NewInt(0)
+	}
+	return price
+}
+
+// GetLiquidationPrices returns the liquidation price of every position, solved one at a time with GetLiquidationPrice
+func GetLiquidationPrices(hState *HubbleState, userState *UserState) map[Market]*big.Int {
+	liquidationPrices := map[Market]*big.Int{}
+	for market := range userState.Positions {
+		liquidationPrices[market] = GetLiquidationPrice(hState, userState, market)
+	}
+	return liquidationPrices
+}
+
+// GetDistanceToLiquidation is the fraction of the margin (including unrealized pnl) of a trader that can be lost across all positions before the
+// margin fraction falls to the maintenance margin, with 6 decimals. It is 1e6 without positions and <= 0 when the trader can be liquidated
+func GetDistanceToLiquidation(hState *HubbleState, userState *UserState) *big.Int {
+	notionalPosition, margin := GetNotionalPositionAndMargin(hState, userState, Maintenance_Margin)
+	if notionalPosition.Sign() == 0 {
+		return big.NewInt(1e6)
+	}
+	if margin.Sign() <= 0 {
+		return big.NewInt(0)
+	}
+	buffer := Sub(margin, Div1e6(Mul(notionalPosition, hState.MaintenanceMargin)))
+	return Div(Mul1e6(buffer), margin)
+}
+
+func isActiveMarket(hState *HubbleState, market Market) bool {
+	for _, m := range hState.ActiveMarkets {
+		if m == market {
+			return true
+		}
+	}
+	return false
+}
+
 func GetPositionMetadata(price *big.Int, openNotional *big.Int, size *big.Int, margin *big.Int) (notionalPosition *big.Int, unrealisedPnl *big.Int, marginFraction *big.Int) {
 	notionalPosition = GetNotionalPosition(price, size)
 	uPnL := new(big.Int)
@@ -238,3 +238,102 @@ func TestGetTotalNotionalPositionAndUnrealizedPnl(t *testing.T) {
 	assert.Equal(t, expectedNotionalPosition, notionalPosition)
 	assert.Equal(t, expectedUPnL, uPnL)
 }
+
+func TestGetLiquidationPrice(t *testing.T) {
+	hState := &HubbleState{
+		Assets:             _hState.Assets,
+		OraclePrices:       map[Market]*big.Int{0: _hState.OraclePrices[0], 1: _hState.OraclePrices[1]},
+		ActiveMarkets:      _hState.ActiveMarkets,
+		MinAllowableMargin: _hState.MinAllowableMargin,
+		MaintenanceMargin:  _hState.MaintenanceMargin,
+		UpgradeVersion:     V2,
+	}
+	for _, market := range hState.ActiveMarkets {
+		t.Run(fmt.Sprintf("market %d", market), func(t *testing.T) {
+			liquidationPrice := GetLiquidationPrice(hState, userState, market)
+			assert.Equal(t, 1, liquidationPrice.Sign())
+
+			oraclePrice := hState.OraclePrices[market]
+			defer func() { hState.OraclePrices[market] = oraclePrice }()
+			hState.OraclePrices[market] = liquidationPrice
+			marginFraction := GetMarginFraction(hState, userState)
+			assert.True(t, Abs(Sub(marginFraction, hState.MaintenanceMargin)).Cmp(big.NewInt(1)) <= 0, "marginFraction %s", marginFraction)
+		})
+	}
+	t.Run("long position is liquidated when the price falls, short position when it rises", func(t *testing.T) {
+		assert.True(t, GetLiquidationPrice(hState, userState, 0).Cmp(hState.OraclePrices[0]) < 0)
+		assert.True(t, GetLiquidationPrice(hState, userState, 1).Cmp(hState.OraclePrices[1]) > 0)
+	})
+	t.Run("no position", func(t *testing.T) {
+		assert.Equal(t, big.NewInt(0), GetLiquidationPrice(hState, &UserState{Positions: map[Market]*Position{}, Margins: userState.Margins, PendingFunding: big.NewInt(0)}, 0))
+	})
+	t.Run("long position that can't be liquidated", func(t *testing.T) {
+		state := &UserState{
+			Positions:      map[Market]*Position{0: {Size: Scale(big.NewInt(1), 16), OpenNotional: big.NewInt(15 * 1e6)}},
+			Margins:        []*big.Int{big.NewInt(1000 * 1e6), big.NewInt(0)},
+			PendingFunding: big.NewInt(0),
+		}
+		assert.Equal(t, big.NewInt(0), GetLiquidationPrice(hState, state, 0))
+	})
+}
+
+func TestGetLiquidationPrices(t *testing.T) {
+	hState := &HubbleState{
+		Assets:             _hState.Assets,
+		OraclePrices:       map[Market]*big.Int{0: _hState.OraclePrices[0], 1: _hState.OraclePrices[1]},
+		ActiveMarkets:      _hState.ActiveMarkets,
+		MinAllowableMargin: _hState.MinAllowableMargin,
+		MaintenanceMargin:  _hState.MaintenanceMargin,
+		SettlementPrices:   map[Market]*big.Int{},
+		UpgradeVersion:     V2,
+	}
+	liquidationPrices := GetLiquidationPrices(hState, userState)
+	assert.Equal(t, 2, len(liquidationPrices))
+	for market, liquidationPrice := range liquidationPrices {
+		assert.Equal(t, GetLiquidationPrice(hState, userState, market), liquidationPrice)
+	}
+
+	t.Run("settled market", func(t *testing.T) {
+		hState.SettlementPrices[1] = _hState.OraclePrices[1]
+		defer delete(hState.SettlementPrices, 1)
+		assert.Equal(t, big.NewInt(0), GetLiquidationPrice(hState, userState, 1))
+	})
+	t.Run("market that is not active", func(t *testing.T) {
+		state := &UserState{
+			Positions:      map[Market]*Position{2: {Size: Scale(big.NewInt(1), 18), OpenNotional: big.NewInt(10 * 1e6)}},
+			Margins:        userState.Margins,
+			PendingFunding: big.NewInt(0),
+		}
+		assert.Equal(t, big.NewInt(0), GetLiquidationPrice(hState, state, 2))
+	})
+}
+
+func TestGetDistanceToLiquidation(t *testing.T) {
+	hState := &HubbleState{
+		Assets:             _hState.Assets,
+		OraclePrices:       map[Market]*big.Int{0: _hState.OraclePrices[0], 1: _hState.OraclePrices[1]},
+		ActiveMarkets:      _hState.ActiveMarkets,
+		MinAllowableMargin: _hState.MinAllowableMargin,
+		MaintenanceMargin:  _hState.MaintenanceMargin,
+		UpgradeVersion:     V2,
+	}
+	t.Run("no position", func(t *testing.T) {
+		state := &UserState{Positions: map[Market]*Position{}, Margins: userState.Margins, PendingFunding: big.NewInt(0)}
+		assert.Equal(t, big.NewInt(1e6), GetDistanceToLiquidation(hState, state))
+	})
+	t.Run("matches the margin fraction", func(t *testing.T) {
+		distance := GetDistanceToLiquidation(hState, userState)
+		marginFraction := GetMarginFraction(hState, userState)
+		assert.Equal(t, marginFraction.Cmp(hState.MaintenanceMargin), distance.Sign())
+		// margin * (1 - distance) / notional = maintenance margin
+		notionalPosition, margin := GetNotionalPositionAndMargin(hState, userState, Maintenance_Margin)
+		remaining := Div1e6(Mul(margin, Sub(big.NewInt(1e6), distance)))
+		assert.True(t, Abs(Sub(Div(Mul1e6(remaining), notionalPosition), hState.MaintenanceMargin)).Cmp(big.NewInt(1)) <= 0)
+	})
+	t.Run("at the liquidation price of a position", func(t *testing.T) {
+		oraclePrice := hState.OraclePrices[0]
+		defer func() { hState.OraclePrices[0] = oraclePrice }()
+		hState.OraclePrices[0] = GetLiquidationPrice(hState, userState, 0)
+		assert.True(t, Abs(GetDistanceToLiquidation(hState, userState)).Cmp(big.NewInt(10)) <= 0)
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -148,3 +149,66 @@ func assertLimitOrderEquality(t *testing.T, expected, actual BaseOrder) {
 	assert.Equal(t, expected.Salt, actual.Salt)
 	assert.Equal(t, expected.ReduceOnly, actual.ReduceOnly)
 }
+
+func TestValidateReduceOnlySignedOrder(t *testing.T) {
+	SetChainIdAndVerifyingSignedOrdersContract(321123, "0x4c5859f0F772848b2D91F1D83E2Fe57935348029")
+	key, err := crypto.GenerateKey()
+	assert.Nil(t, err)
+
+	// closes 3 of a long position
+	order := &SignedOrder{
+		LimitOrder: LimitOrder{
+			BaseOrder: BaseOrder{
+				AmmIndex:          big.NewInt(0),
+				Trader:            crypto.PubkeyToAddress(key.PublicKey),
+				BaseAssetQuantity: big.NewInt(-3e18),
+				Price:             big.NewInt(1000000000),
+				Salt:              big.NewInt(1688994806105),
+				ReduceOnly:        true,
+			},
+			PostOnly: true,
+		},
+		OrderType: uint8(Signed),
+		ExpireAt:  big.NewInt(1688994854),
+	}
+	hash, err := order.Hash()
+	assert.Nil(t, err)
+	order.Sig, err = crypto.Sign(hash.Bytes(), key)
+	assert.Nil(t, err)
+	order.Sig[crypto.RecoveryIDOffset] += 27
+
+	getFields := func(positionSize, reduceOnlyAmount *big.Int) SignedOrderValidationFields {
+		return SignedOrderValidationFields{
+			OrderHash:          hash,
+			Now:                1688994800,
+			ActiveMarketsCount: 1,
+			MinSize:            big.NewInt(1e18),
+			PriceMultiplier:    big.NewInt(1),
+			Status:             int64(Invalid),
+			PositionSize:       positionSize,
+			ReduceOnlyAmount:   reduceOnlyAmount,
+		}
+	}
+
+	t.Run("reduces the position", func(t *testing.T) {
+		_, _, err := ValidateSignedOrder(order, getFields(big.NewInt(5e18), big.NewInt(-2e18)))
+		assert.Nil(t, err)
+		// sum of reduce only orders is not checked while matching
+		_, _, err = ValidateSignedOrder(order, getFields(big.NewInt(5e18), nil))
+		assert.Nil(t, err)
+	})
+	t.Run("no position or position in the same direction", func(t *testing.T) {
+		_, _, err := ValidateSignedOrder(order, getFields(big.NewInt(0), big.NewInt(0)))
+		assert.Equal(t, ErrNotReducingPosition, err)
+		_, _, err = ValidateSignedOrder(order, getFields(big.NewInt(-5e18), big.NewInt(0)))
+		assert.Equal(t, ErrNotReducingPosition, err)
+	})
+	t.Run("reduce only checks are skipped without the position", func(t *testing.T) {
+		_, _, err := ValidateSignedOrder(order, getFields(nil, nil))
+		assert.Nil(t, err)
+	})
+	t.Run("sum of reduce only orders exceeds the position", func(t *testing.T) {
+		_, _, err := ValidateSignedOrder(order, getFields(big.NewInt(5e18), big.NewInt(-3e18)))
+		assert.Equal(t, ErrNetReduceOnlyAmount, err)
+	})
+}
@@ -0,0 +1,63 @@
+package hubbleutils
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// MaxStreamAuthValidity is how far in the future, in seconds, the expiry of a stream auth challenge can be
+const MaxStreamAuthValidity = 5 * 60
+
+// StreamAuth is a time bound challenge signed by a trader or its trading authority to subscribe to the private updates of the trader.
+// Nonce is issued by the node that serves the subscription, so that the signature can only be used once and only on that node
+type StreamAuth struct {
+	Trader   common.Address `json:"trader"`
+	ExpireAt *big.Int       `json:"expireAt"`
+	Nonce    common.Hash    `json:"nonce"`
+	Sig      hexutil.Bytes  `json:"sig"`
+}
+
+func (a *StreamAuth) Hash() (hash common.Hash, err error) {
+	if VerifyingContract == "" || ChainId == 0 {
+		return common.Hash{}, fmt.Errorf("ChainId or VerifyingContract not set")
+	}
+	message := map[string]interface{}{
+		"trader":   a.Trader.String(),
+		"expireAt": a.ExpireAt.String(),
+		"nonce":    a.Nonce.Hex(),
+	}
+	domain := apitypes.TypedDataDomain{
+		Name:              "Hubble",
+		Version:           "2.0",
+		ChainId:           math.NewHexOrDecimal256(ChainId),
+		VerifyingContract: VerifyingContract,
+	}
+	typedData := apitypes.TypedData{
+		Types:       Eip712OrderTypes,
+		PrimaryType: "StreamAuth",
+		Domain:      domain,
+		Message:     message,
+	}
+	return EncodeForSigning(typedData)
+}
+
+// ValidateStreamAuth checks the expiry of the challenge and returns the address that signed it.
+// The caller checks that the signer is the trader or its trading authority
+func ValidateStreamAuth(auth *StreamAuth, now uint64) (signer common.Address, err error) {
+	if auth.ExpireAt == nil || auth.ExpireAt.Sign() <= 0 || auth.ExpireAt.Uint64() < now {
+		return signer, ErrStreamAuthExpired
+	}
+	if !auth.ExpireAt.IsUint64() || auth.ExpireAt.Uint64() > now+MaxStreamAuthValidity {
+		return signer, ErrStreamAuthTooLong
+	}
+	hash, err := auth.Hash()
+	if err != nil {
+		return signer, err
+	}
+	return ECRecover(hash.Bytes(), auth.Sig)
+}
@@ -0,0 +1,73 @@
+package hubbleutils
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateStreamAuth(t *testing.T) {
+	SetChainIdAndVerifyingSignedOrdersContract(321123, "0x4c5859f0F772848b2D91F1D83E2Fe57935348029")
+	key, err := crypto.GenerateKey()
+	assert.Nil(t, err)
+	trader := crypto.PubkeyToAddress(key.PublicKey)
+	now := uint64(1700000000)
+
+	sign := func(auth *StreamAuth) {
+		hash, err := auth.Hash()
+		assert.Nil(t, err)
+		sig, err := crypto.Sign(hash.Bytes(), key)
+		assert.Nil(t, err)
+		sig[crypto.RecoveryIDOffset] += 27
+		auth.Sig = sig
+	}
+
+	t.Run("valid challenge returns the signer", func(t *testing.T) {
+		auth := &StreamAuth{Trader: trader, ExpireAt: new(big.Int).SetUint64(now + 60)}
+		sign(auth)
+		signer, err := ValidateStreamAuth(auth, now)
+		assert.Nil(t, err)
+		assert.Equal(t, trader, signer)
+	})
+
+	t.Run("signature is bound to the trader", func(t *testing.T) {
+		auth := &StreamAuth{Trader: trader, ExpireAt: new(big.Int).SetUint64(now + 60)}
+		sign(auth)
+		auth.Trader[0] ^= 1
+		signer, err := ValidateStreamAuth(auth, now)
+		assert.Nil(t, err)
+		assert.NotEqual(t, trader, signer)
+	})
+
+	t.Run("signature is bound to the nonce", func(t *testing.T) {
+		auth := &StreamAuth{Trader: trader, ExpireAt: new(big.Int).SetUint64(now + 60), Nonce: common.Hash{1}}
+		sign(auth)
+		auth.Nonce = common.Hash{2}
+		signer, err := ValidateStreamAuth(auth, now)
+		assert.Nil(t, err)
+		assert.NotEqual(t, trader, signer)
+	})
+
+	t.Run("expired challenge", func(t *testing.T) {
+		auth := &StreamAuth{Trader: trader, ExpireAt: new(big.Int).SetUint64(now - 1)}
+		sign(auth)
+		_, err := ValidateStreamAuth(auth, now)
+		assert.Equal(t, ErrStreamAuthExpired, err)
+	})
+
+	t.Run("expiry too far in the future", func(t *testing.T) {
+		auth := &StreamAuth{Trader: trader, ExpireAt: new(big.Int).SetUint64(now + MaxStreamAuthValidity + 1)}
+		sign(auth)
+		_, err := ValidateStreamAuth(auth, now)
+		assert.Equal(t, ErrStreamAuthTooLong, err)
+	})
+
+	t.Run("invalid signature", func(t *testing.T) {
+		auth := &StreamAuth{Trader: trader, ExpireAt: new(big.Int).SetUint64(now + 60), Sig: []byte{1, 2, 3}}
+		_, err := ValidateStreamAuth(auth, now)
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,149 @@
+package hubbleutils
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/ava-labs/subnet-evm/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// TriggerOrder is a signed stop loss or take profit order. It stays dormant off the order book till the oracle price
+// reaches TriggerPrice, and is then matched as a taker order at its limit price.
+type TriggerOrder struct {
+	LimitOrder
+	OrderType    uint8    `json:"orderType"`
+	ExpireAt     *big.Int `json:"expireAt"`
+	TriggerPrice *big.Int `json:"triggerPrice"`
+	TriggerKind  uint8    `json:"triggerKind"`
+	Sig          []byte   `json:"sig"`
+}
+
+func (order *TriggerOrder) EncodeToABIWithoutType() ([]byte, error) {
+	triggerOrderType, err := getOrderType("trigger")
+	if err != nil {
+		return nil, err
+	}
+	bytesTy, _ := abi.NewType("bytes", "bytes", nil)
+	encodedOrder, err := abi.Arguments{{Type: triggerOrderType}, {Type: bytesTy}}.Pack(order, order.Sig)
+	if err != nil {
+		return nil, err
+	}
+	return encodedOrder, nil
+}
+
+func (order *TriggerOrder) EncodeToABI() ([]byte, error) {
+	encodedTriggerOrder, err := order.EncodeToABIWithoutType()
+	if err != nil {
+		return nil, fmt.Errorf("failed getting abi type: %w", err)
+	}
+
+	uint8Ty, _ := abi.NewType("uint8", "uint8", nil)
+	bytesTy, _ := abi.NewType("bytes", "bytes", nil)
+
+	encodedOrder, err := abi.Arguments{{Type: uint8Ty}, {Type: bytesTy}}.Pack(uint8(Trigger), encodedTriggerOrder)
+	if err != nil {
+		return nil, fmt.Errorf("order encoding failed: %w", err)
+	}
+
+	return encodedOrder, nil
+}
+
+func DecodeTriggerOrder(encodedOrder []byte) (*TriggerOrder, error) {
+	triggerOrderType, err := getOrderType("trigger")
+	if err != nil {
+		return nil, fmt.Errorf("failed getting abi type: %w", err)
+	}
+	bytesTy, _ := abi.NewType("bytes", "bytes", nil)
+	decodedValues, err := abi.Arguments{{Type: triggerOrderType}, {Type: bytesTy}}.Unpack(encodedOrder)
+	if err != nil {
+		return nil, err
+	}
+	triggerOrder := &TriggerOrder{
+		Sig: decodedValues[1].([]byte),
+	}
+	triggerOrder.DecodeFromRawOrder(decodedValues[0])
+	return triggerOrder, nil
+}
+
+func (order *TriggerOrder) DecodeFromRawOrder(rawOrder interface{}) {
+	marshalledOrder, _ := json.Marshal(rawOrder)
+	err := json.Unmarshal(marshalledOrder, &order)
+	if err != nil {
+		log.Error("err in DecodeFromRawOrder", "err", err, "rawOrder", rawOrder)
+	}
+}
+
+func (o *TriggerOrder) String() string {
+	return fmt.Sprintf(
+		"Order: %s, OrderType: %d, ExpireAt: %d, TriggerPrice: %d, TriggerKind: %s, Sig: %s",
+		o.LimitOrder.String(),
+		o.OrderType,
+		o.ExpireAt,
+		o.TriggerPrice,
+		TriggerKind(o.TriggerKind),
+		hex.EncodeToString(o.Sig),
+	)
+}
+
+func (o *TriggerOrder) Map() map[string]interface{} {
+	orderMap := o.LimitOrder.Map()
+	orderMap["orderType"] = o.OrderType
+	orderMap["expireAt"] = o.ExpireAt
+	orderMap["triggerPrice"] = o.TriggerPrice
+	orderMap["triggerKind"] = TriggerKind(o.TriggerKind).String()
+	return orderMap
+}
+
+func (o *TriggerOrder) Hash() (hash common.Hash, err error) {
+	if VerifyingContract == "" || ChainId == 0 {
+		return common.Hash{}, fmt.Errorf("ChainId or VerifyingContract not set")
+	}
+	message := map[string]interface{}{
+		"orderType":         strconv.FormatUint(uint64(o.OrderType), 10),
+		"expireAt":          o.ExpireAt.String(),
+		"ammIndex":          o.AmmIndex.String(),
+		"trader":            o.Trader.String(),
+		"baseAssetQuantity": o.BaseAssetQuantity.String(),
+		"price":             o.Price.String(),
+		"salt":              o.Salt.String(),
+		"reduceOnly":        o.ReduceOnly,
+		"postOnly":          o.PostOnly,
+		"triggerPrice":      o.TriggerPrice.String(),
+		"triggerKind":       strconv.FormatUint(uint64(o.TriggerKind), 10),
+	}
+	domain := apitypes.TypedDataDomain{
+		Name:              "Hubble",
+		Version:           "2.0",
+		ChainId:           math.NewHexOrDecimal256(ChainId),
+		VerifyingContract: VerifyingContract,
+	}
+	typedData := apitypes.TypedData{
+		Types:       Eip712OrderTypes,
+		PrimaryType: "TriggerOrder",
+		Domain:      domain,
+		Message:     message,
+	}
+	return EncodeForSigning(typedData)
+}
+
+// IsTriggered returns true if the order can be matched at the given oracle price
+func (o *TriggerOrder) IsTriggered(oraclePrice *big.Int) bool {
+	if oraclePrice == nil || o.TriggerPrice == nil {
+		return false
+	}
+	isLong := o.BaseAssetQuantity.Sign() > 0
+	if TriggerKind(o.TriggerKind) == TakeProfit {
+		isLong = !isLong
+	}
+	if isLong {
+		return oraclePrice.Cmp(o.TriggerPrice) >= 0
+	}
+	return oraclePrice.Cmp(o.TriggerPrice) <= 0
+}
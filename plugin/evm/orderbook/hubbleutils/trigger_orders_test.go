@@ -0,0 +1,149 @@
+package hubbleutils
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTriggerOrder(t *testing.T, baseAssetQuantity int64, triggerKind TriggerKind) *TriggerOrder {
+	SetChainIdAndVerifyingSignedOrdersContract(321123, "0x4c5859f0F772848b2D91F1D83E2Fe57935348029")
+	key, err := crypto.GenerateKey()
+	assert.Nil(t, err)
+
+	order := &TriggerOrder{
+		LimitOrder: LimitOrder{
+			BaseOrder: BaseOrder{
+				AmmIndex:          big.NewInt(0),
+				Trader:            crypto.PubkeyToAddress(key.PublicKey),
+				BaseAssetQuantity: new(big.Int).Mul(big.NewInt(baseAssetQuantity), big.NewInt(1e18)),
+				Price:             big.NewInt(1000000000),
+				Salt:              big.NewInt(1688994806105),
+				ReduceOnly:        true,
+			},
+		},
+		OrderType:    uint8(Trigger),
+		ExpireAt:     big.NewInt(1688994854),
+		TriggerPrice: big.NewInt(990000000),
+		TriggerKind:  uint8(triggerKind),
+	}
+	hash, err := order.Hash()
+	assert.Nil(t, err)
+	order.Sig, err = crypto.Sign(hash.Bytes(), key)
+	assert.Nil(t, err)
+	order.Sig[crypto.RecoveryIDOffset] += 27
+	return order
+}
+
+func TestTriggerOrderEncoding(t *testing.T) {
+	order := newTriggerOrder(t, -5, StopLoss)
+
+	encodedOrder, err := order.EncodeToABI()
+	assert.Nil(t, err)
+	decodeStep, err := DecodeTypeAndEncodedOrder(encodedOrder)
+	assert.Nil(t, err)
+	assert.Equal(t, Trigger, decodeStep.OrderType)
+
+	decoded, err := DecodeTriggerOrder(decodeStep.EncodedOrder)
+	assert.Nil(t, err)
+	assertLimitOrderEquality(t, order.BaseOrder, decoded.BaseOrder)
+	assert.Equal(t, order.OrderType, decoded.OrderType)
+	assert.Equal(t, order.ExpireAt.Int64(), decoded.ExpireAt.Int64())
+	assert.Equal(t, order.TriggerPrice.Int64(), decoded.TriggerPrice.Int64())
+	assert.Equal(t, order.TriggerKind, decoded.TriggerKind)
+	assert.Equal(t, order.Sig, decoded.Sig)
+
+	hash, err := order.Hash()
+	assert.Nil(t, err)
+	decodedHash, err := decoded.Hash()
+	assert.Nil(t, err)
+	assert.Equal(t, hash, decodedHash)
+
+	// trigger price and kind are part of the signed message
+	decoded.TriggerPrice = big.NewInt(980000000)
+	decodedHash, err = decoded.Hash()
+	assert.Nil(t, err)
+	assert.NotEqual(t, hash, decodedHash)
+}
+
+func TestValidateTriggerOrder(t *testing.T) {
+	getFields := func(order *TriggerOrder) SignedOrderValidationFields {
+		hash, _ := order.Hash()
+		return SignedOrderValidationFields{
+			OrderHash:          hash,
+			Now:                1688994800,
+			ActiveMarketsCount: 1,
+			MinSize:            big.NewInt(1e18),
+			PriceMultiplier:    big.NewInt(1),
+			Status:             int64(Invalid),
+		}
+	}
+
+	t.Run("valid order", func(t *testing.T) {
+		order := newTriggerOrder(t, -5, StopLoss)
+		trader, signer, err := ValidateTriggerOrder(order, getFields(order))
+		assert.Nil(t, err)
+		assert.Equal(t, order.Trader, trader)
+		assert.Equal(t, order.Trader, signer)
+	})
+	t.Run("post only", func(t *testing.T) {
+		order := newTriggerOrder(t, -5, StopLoss)
+		order.PostOnly = true
+		_, _, err := ValidateTriggerOrder(order, getFields(order))
+		assert.Equal(t, ErrPostOnlyTriggerOrder, err)
+	})
+	t.Run("invalid trigger price", func(t *testing.T) {
+		order := newTriggerOrder(t, -5, StopLoss)
+		order.TriggerPrice = big.NewInt(0)
+		_, _, err := ValidateTriggerOrder(order, getFields(order))
+		assert.Equal(t, ErrInvalidTriggerPrice, err)
+	})
+	t.Run("invalid trigger kind", func(t *testing.T) {
+		order := newTriggerOrder(t, -5, StopLoss)
+		order.TriggerKind = 2
+		_, _, err := ValidateTriggerOrder(order, getFields(order))
+		assert.Equal(t, ErrInvalidTriggerKind, err)
+	})
+	t.Run("checks shared with signed orders", func(t *testing.T) {
+		order := newTriggerOrder(t, -5, StopLoss)
+		fields := getFields(order)
+		fields.Now = order.ExpireAt.Uint64() + 1
+		_, _, err := ValidateTriggerOrder(order, fields)
+		assert.Equal(t, ErrOrderExpired, err)
+
+		fields = getFields(order)
+		fields.CancelNonce = order.Salt
+		_, _, err = ValidateTriggerOrder(order, fields)
+		assert.Equal(t, ErrCancelledByNonce, err)
+	})
+}
+
+func TestIsTriggered(t *testing.T) {
+	above, at, below := big.NewInt(1000000000), big.NewInt(990000000), big.NewInt(980000000)
+
+	// stop loss of a long position
+	order := newTriggerOrder(t, -5, StopLoss)
+	assert.False(t, order.IsTriggered(above))
+	assert.True(t, order.IsTriggered(at))
+	assert.True(t, order.IsTriggered(below))
+
+	// stop loss of a short position
+	order = newTriggerOrder(t, 5, StopLoss)
+	assert.True(t, order.IsTriggered(above))
+	assert.True(t, order.IsTriggered(at))
+	assert.False(t, order.IsTriggered(below))
+
+	// take profit of a long position
+	order = newTriggerOrder(t, -5, TakeProfit)
+	assert.True(t, order.IsTriggered(above))
+	assert.False(t, order.IsTriggered(below))
+
+	// take profit of a short position
+	order = newTriggerOrder(t, 5, TakeProfit)
+	assert.False(t, order.IsTriggered(above))
+	assert.True(t, order.IsTriggered(below))
+
+	assert.False(t, order.IsTriggered(nil))
+}
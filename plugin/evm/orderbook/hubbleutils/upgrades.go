@@ -0,0 +1,48 @@
+package hubbleutils
+
+import "math"
+
+// TriggerOrdersActivationTime is the block timestamp from which the juror validates trigger orders and the nodes accept and activate them.
+// Trigger orders are filled and cancelled through the signed order book, so this is only scheduled together with the contract upgrade
+// that executes order type Trigger. It is a var so that tests can activate trigger orders.
+var TriggerOrdersActivationTime uint64 = math.MaxUint64 // not scheduled
+
+func IsTriggerOrdersActive(timestamp uint64) bool {
+	return timestamp >= TriggerOrdersActivationTime
+}
+
+// ReduceOnlySignedOrdersActivationTime is the block timestamp from which reduce only signed orders are accepted by the nodes, and the
+// juror checks that they are in the opposite direction of the position. Before it, the juror only checks the fill against the position (M2).
+// It is a var so that tests can activate reduce only signed orders.
+var ReduceOnlySignedOrdersActivationTime uint64 = math.MaxUint64 // not scheduled
+
+func IsReduceOnlySignedOrdersActive(timestamp uint64) bool {
+	return timestamp >= ReduceOnlySignedOrdersActivationTime
+}
+
+// SignedOrderCancellationsActivationTime is the block timestamp from which the juror records CancelOrder messages with cancelSignedOrders
+// and rejects the cancelled orders, and the nodes accept amendments of signed orders. Before it, an amended order would stay valid on chain.
+// It is a var so that tests can activate cancellations.
+var SignedOrderCancellationsActivationTime uint64 = math.MaxUint64 // not scheduled
+
+func IsSignedOrderCancellationsActive(timestamp uint64) bool {
+	return timestamp >= SignedOrderCancellationsActivationTime
+}
+
+// EncodedSignedOrdersGossipActivationTime is the unix time from which the nodes gossip signed orders only as EncodedSignedOrdersGossip.
+// Before it, they also gossip them as the legacy gob encoded SignedOrdersGossip, so that nodes that haven't upgraded keep receiving
+// signed orders during the rollout. It is a var so that tests can activate it.
+var EncodedSignedOrdersGossipActivationTime uint64 = math.MaxUint64 // not scheduled
+
+func IsEncodedSignedOrdersGossipActive(timestamp uint64) bool {
+	return timestamp >= EncodedSignedOrdersGossipActivationTime
+}
+
+// BatchAuctionActivationTime is the block timestamp from which markets can match in the BatchAuction matching mode and the juror validates
+// batch auctions. Before it, a market set to BatchAuction matches with FIFO. Batch auctions are executed by the OrderBook's executeBatchAuction,
+// so this is only scheduled together with the contract upgrade that implements it. It is a var so that tests can activate batch auctions.
+var BatchAuctionActivationTime uint64 = math.MaxUint64 // not scheduled
+
+func IsBatchAuctionActive(timestamp uint64) bool {
+	return timestamp >= BatchAuctionActivationTime
+}
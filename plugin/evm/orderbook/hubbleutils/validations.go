@@ -15,22 +15,52 @@ type SignedOrderValidationFields struct {
 	MinSize            *big.Int
 	PriceMultiplier    *big.Int
 	Status             int64
+	// PositionSize is the trader's position in the market. The reduce only checks are skipped when it is nil, see ReduceOnlySignedOrdersActivationTime
+	PositionSize *big.Int
+	// ReduceOnlyAmount is the sum of the trader's other active reduce only orders in the market. P3 is skipped when it is nil
+	ReduceOnlyAmount *big.Int
+	// CancelNonce is the nonce of the trader's last signed cancel all message for the market. P6 is skipped when it is nil
+	CancelNonce *big.Int
+	// HeartbeatExpired is set if the trader's dead man's switch was triggered and there was no heartbeat since (P7)
+	HeartbeatExpired bool
+	// Cancelled is set if the order was cancelled by a CancelOrder message. (9.)
+	Cancelled bool
 }
 
 var (
-	ErrNotSignedOrder        = errors.New("not signed order")
-	ErrInvalidPrice          = errors.New("invalid price")
-	ErrOrderExpired          = errors.New("order expired")
-	ErrBaseAssetQuantityZero = errors.New("baseAssetQuantity is zero")
-	ErrNotPostOnly           = errors.New("not post only")
-	ErrInvalidMarket         = errors.New("invalid market")
-	ErrNotMultiple           = errors.New("not multiple")
-	ErrPricePrecision        = errors.New("invalid price precision")
-	ErrOrderAlreadyExists    = errors.New("order already exists")
-	ErrCrossingMarket        = errors.New("crossing market")
-	ErrNoTradingAuthority    = errors.New("no trading authority")
-	ErrInsufficientMargin    = errors.New("insufficient margin")
-	ErrNoReferrer            = errors.New("no referrer")
+	ErrNotSignedOrder          = errors.New("not signed order")
+	ErrInvalidPrice            = errors.New("invalid price")
+	ErrOrderExpired            = errors.New("order expired")
+	ErrBaseAssetQuantityZero   = errors.New("baseAssetQuantity is zero")
+	ErrNotPostOnly             = errors.New("not post only")
+	ErrInvalidMarket           = errors.New("invalid market")
+	ErrNotMultiple             = errors.New("not multiple")
+	ErrPricePrecision          = errors.New("invalid price precision")
+	ErrOrderAlreadyExists      = errors.New("order already exists")
+	ErrCrossingMarket          = errors.New("crossing market")
+	ErrNoTradingAuthority      = errors.New("no trading authority")
+	ErrInsufficientMargin      = errors.New("insufficient margin")
+	ErrNoReferrer              = errors.New("no referrer")
+	ErrNotTriggerOrder         = errors.New("not trigger order")
+	ErrPostOnlyTriggerOrder    = errors.New("trigger order can't be post only")
+	ErrInvalidTriggerPrice     = errors.New("invalid trigger price")
+	ErrInvalidTriggerKind      = errors.New("invalid trigger kind")
+	ErrNotTriggered            = errors.New("trigger price not reached")
+	ErrTriggerOrdersNotActive  = errors.New("trigger orders are not active yet")
+	ErrNotReducingPosition     = errors.New("reduce only order doesn't reduce the position")
+	ErrNetReduceOnlyAmount     = errors.New("net reduce only amount exceeds the position size")
+	ErrReduceOnlyNotSupported  = errors.New("reduce only orders via makerbook are not supported yet")
+	ErrStreamAuthExpired       = errors.New("stream auth expired")
+	ErrStreamAuthTooLong       = errors.New("stream auth expiry is too far in the future")
+	ErrCancelledByNonce        = errors.New("order was cancelled by a cancel all message")
+	ErrStaleCancelNonce        = errors.New("cancel nonce should be greater than the last cancel nonce")
+	ErrInvalidHeartbeatTimeout = errors.New("invalid heartbeat timeout")
+	ErrHeartbeatClockSkew      = errors.New("heartbeat timestamp is too far from the node time")
+	ErrStaleHeartbeat          = errors.New("heartbeat is not newer than the last heartbeat")
+	ErrHeartbeatExpired        = errors.New("heartbeat of the trader expired, send a heartbeat to place orders")
+	ErrOrderCancelled          = errors.New("order was cancelled by a cancel order message")
+	ErrCancellationsNotActive  = errors.New("signed order cancellations are not active yet")
+	ErrBatchAuctionNotActive   = errors.New("batch auctions are not active yet")
 )
 
 // Common Checks
@@ -42,6 +72,7 @@ var (
 // 6. signer is valid trading authority
 // 7. market is valid
 // 8. order is not already filled or cancelled
+// 9. order was not cancelled by a CancelOrder message
 
 // Place Order Checks
 // P1. Order is not already in memdb (placed)
@@ -49,6 +80,8 @@ var (
 // P3. Sum of all reduce only orders should not exceed the total position size (not in state, simply compared to other active orders) and/or opposite direction validations
 // P4. Post only order shouldn't cross the market
 // P5. HasReferrer
+// P6. Order salt is greater than the nonce of the last cancel all message. The juror checks the nonces recorded by cancelAllSignedOrders, the order book also the messages that aren't recorded yet
+// P7. Dead man's switch of the trader is not triggered (off-chain only, heartbeats are not in the chain state)
 
 // Matching Order Checks
 // M1. order is not being overfilled
@@ -56,57 +89,145 @@ var (
 // M3. HasReferrer
 // M4. Not both post only orders are being matched
 
+// IsSelfTrade returns true if the orders belong to the same trader, or to traders that share a trading authority.
+// The signer of an order is the address that signed a signed order, and the trader for orders placed on chain.
+// It is used by both the matching engine and the juror, so that they always agree on what a self trade is.
+func IsSelfTrade(trader0, signer0, trader1, signer1 common.Address, isTradingAuthority func(trader, senderOrSigner common.Address) bool) bool {
+	if trader0 == trader1 || signer0 == signer1 {
+		return true
+	}
+	return isTradingAuthority(trader0, signer1) || isTradingAuthority(trader1, signer0)
+}
+
 func ValidateSignedOrder(order *SignedOrder, fields SignedOrderValidationFields) (trader, signer common.Address, err error) {
-	if OrderType(order.OrderType) != Signed { // 1.
-		err = ErrNotSignedOrder
+	signer, err = ValidateSignedOrderStateless(order, fields)
+	if err != nil {
 		return trader, signer, err
 	}
+	trader = order.Trader
+	return trader, signer, ValidateSignedOrderState(order, fields)
+}
 
-	if order.ExpireAt.Uint64() < fields.Now { // 2.
-		err = ErrOrderExpired
-		return trader, signer, err
+// ValidateSignedOrderStateless runs the checks of ValidateSignedOrder that only depend on the order, fields.OrderHash, fields.Now and the
+// market's fields.MinSize and fields.PriceMultiplier, and recovers the signer. It's safe to call concurrently
+func ValidateSignedOrderStateless(order *SignedOrder, fields SignedOrderValidationFields) (signer common.Address, err error) {
+	if OrderType(order.OrderType) != Signed { // 1.
+		return signer, ErrNotSignedOrder
 	}
 
+	var postOnlyErr error
 	if !order.PostOnly { // 3.
-		err = ErrNotPostOnly
-		return trader, signer, err
+		postOnlyErr = ErrNotPostOnly
+	}
+	if err := validateOrderStateless(&order.LimitOrder, order.ExpireAt, postOnlyErr, fields); err != nil {
+		return signer, err
+	}
+
+	return ECRecover(fields.OrderHash.Bytes(), order.Sig[:])
+}
+
+// validateOrderStateless runs the checks 2. to 5. that signed and trigger orders share. postOnlyErr is the result of check 3., which depends on the order type
+func validateOrderStateless(order *LimitOrder, expireAt *big.Int, postOnlyErr error, fields SignedOrderValidationFields) error {
+	if expireAt.Uint64() < fields.Now { // 2.
+		return ErrOrderExpired
+	}
+
+	if postOnlyErr != nil { // 3.
+		return postOnlyErr
 	}
 
 	// 4.
 	if order.BaseAssetQuantity.Sign() == 0 {
-		err = ErrBaseAssetQuantityZero
-		return trader, signer, err
+		return ErrBaseAssetQuantityZero
 	}
 	if new(big.Int).Mod(order.BaseAssetQuantity, fields.MinSize).Sign() != 0 {
-		err = ErrNotMultiple
-		return trader, signer, err
+		return ErrNotMultiple
 	}
 
 	if order.Price.Sign() != 1 { // 5.
-		err = ErrInvalidPrice
-		return trader, signer, err
+		return ErrInvalidPrice
 	}
 	if Mod(order.Price, fields.PriceMultiplier).Sign() != 0 {
-		err = ErrPricePrecision
-		return trader, signer, err
+		return ErrPricePrecision
 	}
+	return nil
+}
 
-	signer, err = ECRecover(fields.OrderHash.Bytes(), order.Sig[:])
-	// fmt.Println("signer", signer)
-	if err != nil {
-		return trader, signer, err
-	}
-	trader = order.Trader
+// ValidateSignedOrderState runs the rest of the checks of ValidateSignedOrder, the ones that depend on the state of the chain and the makerbook
+func ValidateSignedOrderState(order *SignedOrder, fields SignedOrderValidationFields) error {
+	return validateOrderState(&order.LimitOrder, fields)
+}
 
+// validateOrderState runs the checks that depend on the state of the chain and the makerbook, for signed and trigger orders.
+// The checks of the fields that are not set are skipped
+func validateOrderState(order *LimitOrder, fields SignedOrderValidationFields) error {
 	// assumes all markets are active and in sequential order
 	if order.AmmIndex.Int64() >= fields.ActiveMarketsCount { // 7.
-		err = ErrInvalidMarket
-		return trader, signer, err
+		return ErrInvalidMarket
 	}
 
 	if OrderStatus(fields.Status) != Invalid { // 8.
-		err = ErrOrderAlreadyExists
+		return ErrOrderAlreadyExists
+	}
+
+	if fields.Cancelled { // 9.
+		return ErrOrderCancelled
+	}
+
+	if fields.CancelNonce != nil && order.Salt.Cmp(fields.CancelNonce) <= 0 { // P6.
+		return ErrCancelledByNonce
+	}
+
+	if fields.HeartbeatExpired { // P7.
+		return ErrHeartbeatExpired
+	}
+
+	if order.ReduceOnly && fields.PositionSize != nil {
+		return validateReduceOnlyAmount(order.BaseAssetQuantity, fields)
+	}
+	return nil
+}
+
+// validateReduceOnlyAmount checks that a reduce only order is in the opposite direction of the position (M2) and, when
+// fields.ReduceOnlyAmount is set, that together with the other reduce only orders it doesn't exceed the position (P3)
+func validateReduceOnlyAmount(baseAssetQuantity *big.Int, fields SignedOrderValidationFields) error {
+	if fields.PositionSize.Sign() == 0 || fields.PositionSize.Sign() == baseAssetQuantity.Sign() {
+		return ErrNotReducingPosition
+	}
+	if fields.ReduceOnlyAmount != nil {
+		netAmount := Add(fields.ReduceOnlyAmount, baseAssetQuantity)
+		if netAmount.CmpAbs(fields.PositionSize) > 0 {
+			return ErrNetReduceOnlyAmount
+		}
+	}
+	return nil
+}
+
+// ValidateTriggerOrder runs the common checks on a trigger order. Trigger orders are matched as taker orders once triggered,
+// so unlike signed orders they can't be post only. Whether the order has been triggered is checked by the caller against the oracle price.
+func ValidateTriggerOrder(order *TriggerOrder, fields SignedOrderValidationFields) (trader, signer common.Address, err error) {
+	if OrderType(order.OrderType) != Trigger { // 1.
+		return trader, signer, ErrNotTriggerOrder
+	}
+
+	var postOnlyErr error
+	if order.PostOnly { // 3.
+		postOnlyErr = ErrPostOnlyTriggerOrder
+	}
+	if err := validateOrderStateless(&order.LimitOrder, order.ExpireAt, postOnlyErr, fields); err != nil {
 		return trader, signer, err
 	}
-	return trader, signer, nil
+	if order.TriggerPrice == nil || order.TriggerPrice.Sign() != 1 {
+		return trader, signer, ErrInvalidTriggerPrice
+	}
+	if TriggerKind(order.TriggerKind) != StopLoss && TriggerKind(order.TriggerKind) != TakeProfit {
+		return trader, signer, ErrInvalidTriggerKind
+	}
+
+	signer, err = ECRecover(fields.OrderHash.Bytes(), order.Sig[:])
+	if err != nil {
+		return trader, signer, err
+	}
+	trader = order.Trader
+	return trader, signer, validateOrderState(&order.LimitOrder, fields)
 }
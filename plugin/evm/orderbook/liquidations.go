@@ -33,6 +33,17 @@ func calcMarginFraction(trader *Trader, hState *hu.HubbleState) *big.Int {
 	return hu.GetMarginFraction(hState, userState)
 }
 
+// getLiquidationPrices returns the liquidation price of every position of the trader and its distance to liquidation, see hu.GetDistanceToLiquidation
+func getLiquidationPrices(trader *Trader, hState *hu.HubbleState) (map[Market]*big.Int, *big.Int) {
+	userState := &hu.UserState{
+		Positions:      translatePositions(trader.Positions),
+		Margins:        getMargins(trader, len(hState.Assets)),
+		PendingFunding: getTotalFunding(trader, hState.ActiveMarkets),
+		ReservedMargin: big.NewInt(0),
+	}
+	return hu.GetLiquidationPrices(hState, userState), hu.GetDistanceToLiquidation(hState, userState)
+}
+
 func sortLiquidableSliceByMarginFraction(positions []LiquidablePosition) []LiquidablePosition {
 	sort.SliceStable(positions, func(i, j int) bool {
 		return positions[i].MarginFraction.Cmp(positions[j].MarginFraction) == -1
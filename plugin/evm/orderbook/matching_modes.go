@@ -0,0 +1,261 @@
+package orderbook
+
+import (
+	"math"
+	"math/big"
+
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ava-labs/subnet-evm/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// runAllocationMatchingEngine matches the book one price level at a time. The first order of the side that arrived later is the taker,
+// and its quantity is split among all the resting orders at the best price level of the other side by allocateFill.
+// Every allocation is a regular pairwise fill, so the juror validates it the same way as a FIFO fill.
+func (pipeline *MatchingPipeline) runAllocationMatchingEngine(lotp LimitOrderTxProcessor, longOrders []Order, shortOrders []Order, marginMap map[common.Address]*big.Int, minAllowableMargin, takerFee, upperBound, minSize *big.Int, topOfBookPriority bool) {
+	if len(longOrders) == 0 {
+		return
+	}
+	selfTradeChecker := newSelfTradeChecker(pipeline.db, pipeline.configService, longOrders[0].Market)
+	for len(longOrders) > 0 && len(shortOrders) > 0 && longOrders[0].Price.Cmp(shortOrders[0].Price) >= 0 {
+		longIsMaker := isLongOrderMaker(longOrders[0], shortOrders[0])
+		makers, takers := shortOrders, longOrders
+		if longIsMaker {
+			makers, takers = longOrders, shortOrders
+		}
+
+		levelLength := 1
+		for levelLength < len(makers) && makers[levelLength].Price.Cmp(makers[0].Price) == 0 {
+			levelLength++
+		}
+		// cancelled orders are removed below, and the level is allocated again in the next iteration
+		selfTradePrevented := selfTradeChecker.preventSelfTradeInLevel(&takers[0], makers[:levelLength], longIsMaker)
+
+		filled := false
+		if !selfTradePrevented {
+			sizes := make([]*big.Int, levelLength)
+			for i := 0; i < levelLength; i++ {
+				sizes[i] = new(big.Int).Abs(makers[i].GetUnFilledBaseAssetQuantity())
+			}
+			allocations := allocateFill(new(big.Int).Abs(takers[0].GetUnFilledBaseAssetQuantity()), sizes, minSize, topOfBookPriority)
+
+			for i, fillAmount := range allocations {
+				if fillAmount.Sign() == 0 {
+					continue
+				}
+				longOrder, shortOrder := &takers[0], &makers[i]
+				if longIsMaker {
+					longOrder, shortOrder = &makers[i], &takers[0]
+				}
+				if err := canFillOrders(*longOrder, *shortOrder, fillAmount, marginMap, minAllowableMargin, takerFee, upperBound); err != nil {
+					log.Error("orders not matcheable", "longOrder", longOrder, "shortOrder", shortOrder, "fillAmount", fillAmount, "err", err)
+					continue
+				}
+				*longOrder, *shortOrder = ExecuteMatchedOrders(lotp, *longOrder, *shortOrder, fillAmount)
+				filled = true
+			}
+		}
+
+		remainingMakers := makers[:0:0]
+		for i := range makers {
+			if i >= levelLength || makers[i].GetUnFilledBaseAssetQuantity().Sign() != 0 {
+				remainingMakers = append(remainingMakers, makers[i])
+			}
+		}
+		// a taker that can't be filled against the best level is skipped, same as an unmatcheable long order in the FIFO engine
+		if (!filled && !selfTradePrevented) || takers[0].GetUnFilledBaseAssetQuantity().Sign() == 0 {
+			takers = takers[1:]
+		}
+
+		if longIsMaker {
+			longOrders, shortOrders = remainingMakers, takers
+		} else {
+			longOrders, shortOrders = takers, remainingMakers
+		}
+	}
+}
+
+// isLongOrderMaker decides which order was resting in the book, the same way the juror determines the fill price
+func isLongOrderMaker(longOrder, shortOrder Order) bool {
+	switch compareBlockPlaced(longOrder, shortOrder) {
+	case -1:
+		return true
+	case 1:
+		return false
+	}
+	// in the same block, long order is the taker unless the short order is an IOC order
+	return shortOrder.OrderType == IOC
+}
+
+// allocateFill splits quantity among the resting orders of a price level, given their unfilled sizes in time priority.
+// With topOfBookPriority, the first order is filled before the rest are allocated. The remaining quantity is split in proportion
+// to the unfilled sizes, rounded down to multiples of minSize, and the leftover from rounding is allocated in time priority.
+func allocateFill(quantity *big.Int, sizes []*big.Int, minSize *big.Int, topOfBookPriority bool) []*big.Int {
+	allocations := make([]*big.Int, len(sizes))
+	for i := range allocations {
+		allocations[i] = big.NewInt(0)
+	}
+	remaining := new(big.Int).Set(quantity)
+	if len(sizes) == 0 || remaining.Sign() <= 0 {
+		return allocations
+	}
+
+	if topOfBookPriority {
+		allocations[0] = utils.BigIntMin(remaining, sizes[0])
+		remaining.Sub(remaining, allocations[0])
+	}
+
+	totalSize := big.NewInt(0)
+	for i, size := range sizes {
+		totalSize.Add(totalSize, new(big.Int).Sub(size, allocations[i]))
+	}
+	if remaining.Sign() > 0 && totalSize.Sign() > 0 {
+		// the allocations are computed from the quantity left before this loop, so the order of the sizes doesn't matter
+		toAllocate := utils.BigIntMin(remaining, totalSize)
+		for i, size := range sizes {
+			share := new(big.Int).Sub(size, allocations[i])
+			share.Mul(share, toAllocate)
+			share.Div(share, totalSize)
+			if minSize != nil && minSize.Sign() > 0 {
+				share.Sub(share, new(big.Int).Mod(share, minSize))
+			}
+			allocations[i].Add(allocations[i], share)
+			remaining.Sub(remaining, share)
+		}
+	}
+
+	for i, size := range sizes {
+		if remaining.Sign() == 0 {
+			break
+		}
+		extra := utils.BigIntMin(remaining, new(big.Int).Sub(size, allocations[i]))
+		allocations[i].Add(allocations[i], extra)
+		remaining.Sub(remaining, extra)
+	}
+	return allocations
+}
+
+// maxBatchAuctionOrdersPerSide caps the orders of each side in a batch auction, so that the auction is filled in a single tx.
+// change this if the tx gas limit (1.5m) is changed
+const maxBatchAuctionOrdersPerSide = 5
+
+// runBatchAuction fills the crossing orders of a market in the BatchAuction matching mode at a single clearing price, in a single tx.
+// The orders are cleared by hu.ClearBatchAuction, and only the matched orders are sent, because the juror clears the auction again from the submitted orders.
+// An order is left out of the auction if its trader can't afford to fill it completely, so that the fills don't depend on the outcome of the auction.
+// All the orders trade at the same price, so self trade prevention is applied between all the crossing orders before they are selected.
+// The matching mode is only returned after hu.BatchAuctionActivationTime, which needs the executeBatchAuction function of the OrderBook contract.
+func (pipeline *MatchingPipeline) runBatchAuction(lotp LimitOrderTxProcessor, market Market, longOrders []Order, shortOrders []Order, marginMap map[common.Address]*big.Int, minAllowableMargin, takerFee, oraclePrice *big.Int) {
+	if len(longOrders) == 0 || len(shortOrders) == 0 {
+		return
+	}
+	upperBound, lowerBound := pipeline.configService.GetAcceptableBounds(market)
+	newSelfTradeChecker(pipeline.db, pipeline.configService, market).preventSelfTradesInAuction(longOrders, shortOrders)
+	longs, longMargins := selectAuctionOrders(longOrders, true, marginMap, minAllowableMargin, takerFee, upperBound, lowerBound)
+	shorts, shortMargins := selectAuctionOrders(shortOrders, false, marginMap, minAllowableMargin, takerFee, upperBound, lowerBound)
+
+	result, ok := hu.ClearBatchAuction(toAuctionOrders(longs), toAuctionOrders(shorts), oraclePrice, lowerBound, upperBound, pipeline.configService.GetPriceMultiplier(market))
+	if !ok {
+		// nothing is filled, so all the reserved margin is released below
+		result = &hu.BatchAuctionResult{LongFills: zeroFills(len(longs)), ShortFills: zeroFills(len(shorts))}
+	}
+
+	matchedOrders, fillAmounts := []*Order{}, []*big.Int{}
+	collect := func(selected []*Order, fills []*big.Int, reservedMargins []*big.Int, isLong bool) {
+		for i, order := range selected {
+			// release the margin reserved for the part of the order that isn't filled
+			usedMargin, _ := isExecutable(order, fills[i], minAllowableMargin, takerFee, upperBound, reservedMargins[i])
+			marginMap[order.Trader].Add(marginMap[order.Trader], new(big.Int).Sub(reservedMargins[i], usedMargin))
+			if fills[i].Sign() == 0 {
+				continue
+			}
+			fillAmount := fills[i]
+			if !isLong {
+				fillAmount = new(big.Int).Neg(fillAmount)
+			}
+			matchedOrders = append(matchedOrders, order)
+			fillAmounts = append(fillAmounts, fillAmount)
+		}
+	}
+	collect(longs, result.LongFills, longMargins, true)
+	collect(shorts, result.ShortFills, shortMargins, false)
+	if !ok {
+		return
+	}
+
+	orders := make([]Order, len(matchedOrders))
+	for i, order := range matchedOrders {
+		orders[i] = *order
+	}
+	log.Info("batch auction", "market", market, "clearingPrice", result.ClearingPrice, "volume", result.Volume, "numOrders", len(orders))
+	if err := lotp.ExecuteBatchAuctionTx(orders, fillAmounts); err != nil {
+		return
+	}
+	for i, order := range matchedOrders {
+		order.FilledBaseAssetQuantity = big.NewInt(0).Add(order.FilledBaseAssetQuantity, fillAmounts[i])
+	}
+}
+
+// selectAuctionOrders returns the orders of a side that take part in the auction, in the priority of the auction, with the margin reserved for each of them.
+// Orders that can't be filled within the price bounds are left out, same as in hu.ClearBatchAuction
+func selectAuctionOrders(orders []Order, isLong bool, marginMap map[common.Address]*big.Int, minAllowableMargin, takerFee, upperBound, lowerBound *big.Int) ([]*Order, []*big.Int) {
+	selected, reservedMargins := []*Order{}, []*big.Int{}
+	auctionOrders := make([]hu.AuctionOrder, len(orders))
+	for i := range orders {
+		auctionOrders[i] = toAuctionOrder(&orders[i])
+	}
+	for _, i := range hu.RankAuctionOrders(auctionOrders, isLong) {
+		if len(selected) == maxBatchAuctionOrdersPerSide {
+			break
+		}
+		order := &orders[i]
+		if auctionOrders[i].Size.Sign() == 0 || isLong && order.Price.Cmp(lowerBound) < 0 || !isLong && order.Price.Cmp(upperBound) > 0 {
+			continue
+		}
+		if marginMap[order.Trader] == nil {
+			marginMap[order.Trader] = big.NewInt(0)
+		}
+		requiredMargin, err := isExecutable(order, auctionOrders[i].Size, minAllowableMargin, takerFee, upperBound, marginMap[order.Trader])
+		if err != nil {
+			log.Error("order is not executable", "order", order, "err", err)
+			continue
+		}
+		marginMap[order.Trader].Sub(marginMap[order.Trader], requiredMargin)
+		selected = append(selected, order)
+		reservedMargins = append(reservedMargins, requiredMargin)
+	}
+	return selected, reservedMargins
+}
+
+func zeroFills(n int) []*big.Int {
+	fills := make([]*big.Int, n)
+	for i := range fills {
+		fills[i] = big.NewInt(0)
+	}
+	return fills
+}
+
+func toAuctionOrders(orders []*Order) []hu.AuctionOrder {
+	auctionOrders := make([]hu.AuctionOrder, len(orders))
+	for i, order := range orders {
+		auctionOrders[i] = toAuctionOrder(order)
+	}
+	return auctionOrders
+}
+
+// toAuctionOrder uses the same block placed as the juror, signed orders always rest in the book and trigger orders are treated as placed after every other order
+func toAuctionOrder(order *Order) hu.AuctionOrder {
+	blockPlaced := order.BlockNumber
+	switch order.OrderType {
+	case Signed:
+		blockPlaced = big.NewInt(0)
+	case Trigger:
+		blockPlaced = new(big.Int).SetUint64(math.MaxUint64)
+	}
+	return hu.AuctionOrder{
+		OrderHash:   order.Id,
+		Price:       order.Price,
+		Size:        new(big.Int).Abs(order.GetUnFilledBaseAssetQuantity()),
+		BlockPlaced: blockPlaced,
+	}
+}
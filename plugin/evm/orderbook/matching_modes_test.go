@@ -0,0 +1,217 @@
+package orderbook
+
+import (
+	"math/big"
+	"testing"
+
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func bigInts(values ...int64) []*big.Int {
+	result := make([]*big.Int, len(values))
+	for i, value := range values {
+		result[i] = big.NewInt(value)
+	}
+	return result
+}
+
+func TestAllocateFill(t *testing.T) {
+	t.Run("quantity is split in proportion to the sizes", func(t *testing.T) {
+		allocations := allocateFill(big.NewInt(20), bigInts(10, 30), big.NewInt(1), false)
+		assert.Equal(t, bigInts(5, 15), allocations)
+	})
+	t.Run("allocations are rounded down to min size and the leftover is filled in time priority", func(t *testing.T) {
+		allocations := allocateFill(big.NewInt(10), bigInts(10, 10, 10), big.NewInt(2), false)
+		assert.Equal(t, bigInts(6, 2, 2), allocations)
+	})
+	t.Run("top of book order is filled first", func(t *testing.T) {
+		allocations := allocateFill(big.NewInt(20), bigInts(10, 30), big.NewInt(1), true)
+		assert.Equal(t, bigInts(10, 10), allocations)
+
+		allocations = allocateFill(big.NewInt(5), bigInts(10, 30), big.NewInt(1), true)
+		assert.Equal(t, bigInts(5, 0), allocations)
+	})
+	t.Run("quantity larger than the level fills every order", func(t *testing.T) {
+		allocations := allocateFill(big.NewInt(100), bigInts(10, 30), big.NewInt(1), false)
+		assert.Equal(t, bigInts(10, 30), allocations)
+	})
+}
+
+func TestRunAllocationMatchingEngine(t *testing.T) {
+	minAllowableMargin := big.NewInt(1e6)
+	takerFee := big.NewInt(1e6)
+	upperBound := big.NewInt(22)
+	marginMap := func() map[common.Address]*big.Int {
+		return map[common.Address]*big.Int{
+			common.HexToAddress(userAddress): big.NewInt(0), // limit orders don't need any available margin
+		}
+	}
+	getFillAmounts := func(lotp *MockLimitOrderTxProcessor) map[common.Hash]*big.Int {
+		fills := map[common.Hash]*big.Int{}
+		for _, call := range lotp.Calls {
+			longOrder := call.Arguments.Get(0).(Order)
+			fills[longOrder.Id] = call.Arguments.Get(2).(*big.Int)
+		}
+		return fills
+	}
+
+	t.Run("pro-rata splits the taker among the resting orders at the best price", func(t *testing.T) {
+		_, lotp, pipeline, _, _ := setupDependencies(t)
+		longOrder1 := createLimitOrder(LONG, userAddress, big.NewInt(10), big.NewInt(20), Placed, big.NewInt(2), big.NewInt(1))
+		longOrder2 := createLimitOrder(LONG, userAddress, big.NewInt(30), big.NewInt(20), Placed, big.NewInt(2), big.NewInt(2))
+		longOrder3 := createLimitOrder(LONG, userAddress, big.NewInt(30), big.NewInt(19), Placed, big.NewInt(1), big.NewInt(3))
+		shortOrder := createLimitOrder(SHORT, userAddress, big.NewInt(-20), big.NewInt(19), Placed, big.NewInt(3), big.NewInt(4))
+		lotp.On("ExecuteMatchedOrdersTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+		pipeline.runAllocationMatchingEngine(lotp, []Order{longOrder1, longOrder2, longOrder3}, []Order{shortOrder}, marginMap(), minAllowableMargin, takerFee, upperBound, big.NewInt(1), false)
+
+		fills := getFillAmounts(lotp)
+		assert.Equal(t, 2, len(fills))
+		assert.Equal(t, big.NewInt(5), fills[longOrder1.Id])
+		assert.Equal(t, big.NewInt(15), fills[longOrder2.Id])
+	})
+	t.Run("taker moves to the next level once the best level is exhausted", func(t *testing.T) {
+		_, lotp, pipeline, _, _ := setupDependencies(t)
+		longOrder1 := createLimitOrder(LONG, userAddress, big.NewInt(10), big.NewInt(20), Placed, big.NewInt(2), big.NewInt(1))
+		longOrder2 := createLimitOrder(LONG, userAddress, big.NewInt(30), big.NewInt(19), Placed, big.NewInt(2), big.NewInt(2))
+		shortOrder := createLimitOrder(SHORT, userAddress, big.NewInt(-20), big.NewInt(19), Placed, big.NewInt(3), big.NewInt(3))
+		lotp.On("ExecuteMatchedOrdersTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+		pipeline.runAllocationMatchingEngine(lotp, []Order{longOrder1, longOrder2}, []Order{shortOrder}, marginMap(), minAllowableMargin, takerFee, upperBound, big.NewInt(1), true)
+
+		fills := getFillAmounts(lotp)
+		assert.Equal(t, big.NewInt(10), fills[longOrder1.Id])
+		assert.Equal(t, big.NewInt(10), fills[longOrder2.Id])
+	})
+	t.Run("orders that don't cross are not matched", func(t *testing.T) {
+		_, lotp, pipeline, _, _ := setupDependencies(t)
+		longOrder := createLimitOrder(LONG, userAddress, big.NewInt(10), big.NewInt(19), Placed, big.NewInt(2), big.NewInt(1))
+		shortOrder := createLimitOrder(SHORT, userAddress, big.NewInt(-10), big.NewInt(20), Placed, big.NewInt(3), big.NewInt(2))
+
+		pipeline.runAllocationMatchingEngine(lotp, []Order{longOrder}, []Order{shortOrder}, marginMap(), minAllowableMargin, takerFee, upperBound, big.NewInt(1), false)
+		lotp.AssertNotCalled(t, "ExecuteMatchedOrdersTx", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestRunMatchingEngineForMarket(t *testing.T) {
+	upperBound, lowerBound := big.NewInt(22), big.NewInt(18)
+	hState := &hu.HubbleState{MinAllowableMargin: big.NewInt(1e6), TakerFee: big.NewInt(1e6)}
+	marginMap := func() map[common.Address]*big.Int {
+		return map[common.Address]*big.Int{
+			common.HexToAddress(userAddress): big.NewInt(0), // limit orders don't need any available margin
+		}
+	}
+	getOrders := func() (Order, Order, *Orders) {
+		longOrder1 := createLimitOrder(LONG, userAddress, big.NewInt(10), big.NewInt(20), Placed, big.NewInt(2), big.NewInt(1))
+		longOrder2 := createLimitOrder(LONG, userAddress, big.NewInt(30), big.NewInt(20), Placed, big.NewInt(2), big.NewInt(2))
+		shortOrder := createLimitOrder(SHORT, userAddress, big.NewInt(-20), big.NewInt(19), Placed, big.NewInt(3), big.NewInt(3))
+		return longOrder1, longOrder2, &Orders{longOrders: []Order{longOrder1, longOrder2}, shortOrders: []Order{shortOrder}}
+	}
+	getFillAmounts := func(lotp *MockLimitOrderTxProcessor) map[common.Hash]*big.Int {
+		fills := map[common.Hash]*big.Int{}
+		for _, call := range lotp.Calls {
+			longOrder := call.Arguments.Get(0).(Order)
+			fills[longOrder.Id] = call.Arguments.Get(2).(*big.Int)
+		}
+		return fills
+	}
+
+	t.Run("pro-rata market splits the taker among the resting orders", func(t *testing.T) {
+		_, lotp, pipeline, _, cs := setupDependencies(t)
+		cs.On("GetAcceptableBounds").Return(upperBound, lowerBound)
+		cs.On("GetMatchingMode", market).Return(hu.ProRata)
+		lotp.On("ExecuteMatchedOrdersTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		longOrder1, longOrder2, orders := getOrders()
+
+		pipeline.runMatchingEngineForMarket(market, orders, marginMap(), hState, true)
+
+		fills := getFillAmounts(lotp)
+		assert.Equal(t, big.NewInt(5), fills[longOrder1.Id])
+		assert.Equal(t, big.NewInt(15), fills[longOrder2.Id])
+	})
+	t.Run("FIFO market fills the resting orders in time priority", func(t *testing.T) {
+		_, lotp, pipeline, _, cs := setupDependencies(t)
+		cs.On("GetAcceptableBounds").Return(upperBound, lowerBound)
+		cs.On("GetMatchingMode", market).Return(hu.FIFO)
+		lotp.On("ExecuteMatchedOrdersTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		longOrder1, longOrder2, orders := getOrders()
+
+		pipeline.runMatchingEngineForMarket(market, orders, marginMap(), hState, true)
+
+		fills := getFillAmounts(lotp)
+		assert.Equal(t, big.NewInt(10), fills[longOrder1.Id])
+		assert.Equal(t, big.NewInt(10), fills[longOrder2.Id])
+	})
+	t.Run("batch auction market is only matched when batch auctions are run", func(t *testing.T) {
+		_, lotp, pipeline, _, cs := setupDependencies(t)
+		cs.On("GetAcceptableBounds").Return(upperBound, lowerBound)
+		cs.On("GetMatchingMode", market).Return(hu.BatchAuction)
+		_, _, orders := getOrders()
+
+		pipeline.runMatchingEngineForMarket(market, orders, marginMap(), hState, false)
+
+		lotp.AssertNotCalled(t, "ExecuteBatchAuctionTx", mock.Anything, mock.Anything)
+		lotp.AssertNotCalled(t, "ExecuteMatchedOrdersTx", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestRunBatchAuction(t *testing.T) {
+	minAllowableMargin := big.NewInt(1e6)
+	takerFee := big.NewInt(1e6)
+	upperBound, lowerBound := big.NewInt(30e6), big.NewInt(10e6)
+	oraclePrice := big.NewInt(20_500_000)
+	marginMap := func() map[common.Address]*big.Int {
+		return map[common.Address]*big.Int{
+			common.HexToAddress(userAddress): big.NewInt(0), // limit orders don't need any available margin
+		}
+	}
+
+	t.Run("crossing orders are filled in a single tx", func(t *testing.T) {
+		_, lotp, pipeline, _, cs := setupDependencies(t)
+		cs.On("GetAcceptableBounds").Return(upperBound, lowerBound)
+		longOrder1 := createLimitOrder(LONG, userAddress, big.NewInt(10), big.NewInt(22e6), Placed, big.NewInt(2), big.NewInt(1))
+		longOrder2 := createLimitOrder(LONG, userAddress, big.NewInt(10), big.NewInt(20e6), Placed, big.NewInt(3), big.NewInt(2))
+		longOrder3 := createLimitOrder(LONG, userAddress, big.NewInt(10), big.NewInt(18e6), Placed, big.NewInt(3), big.NewInt(3))
+		shortOrder1 := createLimitOrder(SHORT, userAddress, big.NewInt(-15), big.NewInt(19e6), Placed, big.NewInt(1), big.NewInt(4))
+		shortOrder2 := createLimitOrder(SHORT, userAddress, big.NewInt(-10), big.NewInt(21e6), Placed, big.NewInt(1), big.NewInt(5))
+		lotp.On("ExecuteBatchAuctionTx", mock.Anything, mock.Anything).Return(nil)
+
+		longOrders := []Order{longOrder1, longOrder2, longOrder3}
+		pipeline.runBatchAuction(lotp, market, longOrders, []Order{shortOrder1, shortOrder2}, marginMap(), minAllowableMargin, takerFee, oraclePrice)
+
+		lotp.AssertNumberOfCalls(t, "ExecuteBatchAuctionTx", 1)
+		orders := lotp.Calls[0].Arguments.Get(0).([]Order)
+		assert.Equal(t, []common.Hash{longOrder1.Id, longOrder2.Id, shortOrder1.Id}, []common.Hash{orders[0].Id, orders[1].Id, orders[2].Id})
+		assert.Equal(t, bigInts(10, 5, -15), lotp.Calls[0].Arguments.Get(1).([]*big.Int))
+		assert.Equal(t, big.NewInt(5), longOrders[1].FilledBaseAssetQuantity)
+		assert.Equal(t, big.NewInt(0), longOrder2.FilledBaseAssetQuantity)
+	})
+	t.Run("orders that the trader can't afford to fill completely are left out", func(t *testing.T) {
+		_, lotp, pipeline, _, cs := setupDependencies(t)
+		cs.On("GetAcceptableBounds").Return(upperBound, lowerBound)
+		// the trader of the signed order has no available margin
+		signedOrder := createSignedOrder(LONG, "0x710bf5f942331874dcbc7783319123679033b63b", big.NewInt(5e18), big.NewInt(22e6), big.NewInt(1), false)
+		longOrder := createLimitOrder(LONG, userAddress, big.NewInt(10), big.NewInt(20e6), Placed, big.NewInt(3), big.NewInt(2))
+		shortOrder := createLimitOrder(SHORT, userAddress, big.NewInt(-10), big.NewInt(19e6), Placed, big.NewInt(1), big.NewInt(3))
+		lotp.On("ExecuteBatchAuctionTx", mock.Anything, mock.Anything).Return(nil)
+
+		pipeline.runBatchAuction(lotp, market, []Order{signedOrder, longOrder}, []Order{shortOrder}, marginMap(), minAllowableMargin, takerFee, oraclePrice)
+
+		orders := lotp.Calls[0].Arguments.Get(0).([]Order)
+		assert.Equal(t, 2, len(orders))
+		assert.Equal(t, longOrder.Id, orders[0].Id)
+		assert.Equal(t, bigInts(10, -10), lotp.Calls[0].Arguments.Get(1).([]*big.Int))
+	})
+	t.Run("orders that don't cross are not matched", func(t *testing.T) {
+		_, lotp, pipeline, _, cs := setupDependencies(t)
+		cs.On("GetAcceptableBounds").Return(upperBound, lowerBound)
+		longOrder := createLimitOrder(LONG, userAddress, big.NewInt(10), big.NewInt(19e6), Placed, big.NewInt(2), big.NewInt(1))
+		shortOrder := createLimitOrder(SHORT, userAddress, big.NewInt(-10), big.NewInt(20e6), Placed, big.NewInt(3), big.NewInt(2))
+
+		pipeline.runBatchAuction(lotp, market, []Order{longOrder}, []Order{shortOrder}, marginMap(), minAllowableMargin, takerFee, oraclePrice)
+		lotp.AssertNotCalled(t, "ExecuteBatchAuctionTx", mock.Anything, mock.Anything)
+	})
+}
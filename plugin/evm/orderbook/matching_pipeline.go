@@ -18,6 +18,9 @@ const (
 	// ticker frequency for calling signalTxsReady
 	matchingTickerDuration = 5 * time.Second
 	sanitaryTickerDuration = 1 * time.Second
+
+	// keeps a cancelSignedOrders tx within the gas limit of the validator txs
+	maxCancelSignedOrdersPerTx = 32
 )
 
 type MatchingPipeline struct {
@@ -55,8 +58,11 @@ func NewTemporaryMatchingPipeline(
 	}
 }
 
+// RunSanitization removes the orders that can no longer be matched
 func (pipeline *MatchingPipeline) RunSanitization() {
 	pipeline.db.RemoveExpiredSignedOrders()
+	pipeline.db.RemoveStaleReduceOnlySignedOrders()
+	pipeline.db.CancelOrdersOfExpiredHeartbeats()
 }
 
 func (pipeline *MatchingPipeline) Run(blockNumber *big.Int) bool {
@@ -76,6 +82,10 @@ func (pipeline *MatchingPipeline) Run(blockNumber *big.Int) bool {
 	// start fresh and purge all local transactions
 	pipeline.lotp.PurgeOrderBookTxs()
 
+	// the cancellations go before the matches, so that the amended orders can't be matched anymore
+	pipeline.recordSignedOrderCancellations()
+	pipeline.recordCancelAllMessages()
+
 	if isFundingPaymentTime(pipeline.db.GetNextFundingTime()) {
 		log.Info("MatchingPipeline:isFundingPaymentTime")
 		err := executeFundingPayment(pipeline.lotp)
@@ -96,6 +106,14 @@ func (pipeline *MatchingPipeline) Run(blockNumber *big.Int) bool {
 	// fetch various hubble market params and run the matching engine
 	hState := GetHubbleState(pipeline.configService)
 
+	// move the stop loss and take profit orders that have been triggered at the current oracle prices into the order book
+	if hu.IsTriggerOrdersActive(uint64(time.Now().Unix())) {
+		if triggeredOrders := pipeline.db.ActivateTriggeredOrders(hState.OraclePrices, blockNumber.Uint64()); len(triggeredOrders) > 0 {
+			log.Info("MatchingPipeline:triggered orders", "num", len(triggeredOrders))
+			triggerOrdersActivatedCounter.Inc(int64(len(triggeredOrders)))
+		}
+	}
+
 	// build trader map
 	liquidablePositions, ordersToCancel, marginMap := pipeline.db.GetNaughtyTraders(hState)
 	cancellableOrderIds := pipeline.cancelLimitOrders(ordersToCancel)
@@ -106,8 +124,7 @@ func (pipeline *MatchingPipeline) Run(blockNumber *big.Int) bool {
 	pipeline.runLiquidations(liquidablePositions, orderMap, hState.OraclePrices, marginMap)
 	for _, market := range markets {
 		// @todo should we prioritize matching in any particular market?
-		upperBound, _ := pipeline.configService.GetAcceptableBounds(market)
-		pipeline.runMatchingEngine(pipeline.lotp, orderMap[market].longOrders, orderMap[market].shortOrders, marginMap, hState.MinAllowableMargin, hState.TakerFee, upperBound)
+		pipeline.runMatchingEngineForMarket(market, orderMap[market], marginMap, hState, true)
 	}
 
 	orderBookTxsCount := pipeline.lotp.GetOrderBookTxsCount()
@@ -134,6 +151,10 @@ func (pipeline *MatchingPipeline) GetOrderMatchingTransactions(blockNumber *big.
 	// start fresh and purge all local transactions
 	pipeline.lotp.PurgeOrderBookTxs()
 
+	// the cancellations go before the matches, so that the amended orders can't be matched anymore
+	pipeline.recordSignedOrderCancellations()
+	pipeline.recordCancelAllMessages()
+
 	// fetch various hubble market params and run the matching engine
 	hState := GetHubbleState(pipeline.configService)
 	hState.OraclePrices = hu.ArrayToMap(pipeline.configService.GetUnderlyingPrices())
@@ -152,8 +173,8 @@ func (pipeline *MatchingPipeline) GetOrderMatchingTransactions(blockNumber *big.
 	}
 	for _, market := range markets {
 		orders := pipeline.fetchOrders(market, hState.OraclePrices[market], map[common.Hash]struct{}{}, blockNumber)
-		upperBound, _ := pipeline.configService.GetAcceptableBounds(market)
-		pipeline.runMatchingEngine(pipeline.lotp, orders.longOrders, orders.shortOrders, marginMap, hState.MinAllowableMargin, hState.TakerFee, upperBound)
+		// the juror accepts one batch auction of a market in a block, it is cleared by Run
+		pipeline.runMatchingEngineForMarket(market, orders, marginMap, hState, false)
 	}
 
 	orderbookTxs := pipeline.lotp.GetOrderBookTxs()
@@ -161,6 +182,69 @@ func (pipeline *MatchingPipeline) GetOrderMatchingTransactions(blockNumber *big.
 	return orderbookTxs
 }
 
+// recordSignedOrderCancellations submits the cancel messages of amended signed orders that the juror hasn't recorded yet.
+// The memdb keeps the messages till the amended orders expire, so a message is submitted again till it is recorded
+func (pipeline *MatchingPipeline) recordSignedOrderCancellations() {
+	if !hu.IsSignedOrderCancellationsActive(uint64(time.Now().Unix())) {
+		return
+	}
+	cancelOrders := []*hu.CancelOrder{}
+	for orderId, cancelOrder := range pipeline.db.GetSignedOrderCancellations() {
+		if !pipeline.configService.IsSignedOrderCancelled(orderId) {
+			cancelOrders = append(cancelOrders, cancelOrder)
+		}
+		if len(cancelOrders) == maxCancelSignedOrdersPerTx {
+			break
+		}
+	}
+	if len(cancelOrders) == 0 {
+		return
+	}
+	if err := pipeline.lotp.ExecuteCancelSignedOrdersTx(cancelOrders); err != nil {
+		log.Error("recordSignedOrderCancellations - ExecuteCancelSignedOrdersTx failed", "err", err)
+	}
+}
+
+// recordCancelAllMessages submits the cancel all messages with a nonce that the juror hasn't recorded yet
+func (pipeline *MatchingPipeline) recordCancelAllMessages() {
+	if !hu.IsSignedOrderCancellationsActive(uint64(time.Now().Unix())) {
+		return
+	}
+	cancels := []*hu.CancelAllOrders{}
+	for _, cancel := range pipeline.db.GetCancelAllMessages() {
+		if cancel.Nonce.Cmp(pipeline.configService.GetCancelNonce(cancel.Trader, cancel.AmmIndex)) > 0 {
+			cancels = append(cancels, cancel)
+		}
+		if len(cancels) == maxCancelSignedOrdersPerTx {
+			break
+		}
+	}
+	if len(cancels) == 0 {
+		return
+	}
+	if err := pipeline.lotp.ExecuteCancelAllSignedOrdersTx(cancels); err != nil {
+		log.Error("recordCancelAllMessages - ExecuteCancelAllSignedOrdersTx failed", "err", err)
+	}
+}
+
+// runMatchingEngineForMarket matches the orders of a market with the matching mode that is set for the market in the juror config.
+// The orders of a BatchAuction market are only matched if runBatchAuctions is set
+func (pipeline *MatchingPipeline) runMatchingEngineForMarket(market Market, orders *Orders, marginMap map[common.Address]*big.Int, hState *hu.HubbleState, runBatchAuctions bool) {
+	upperBound, _ := pipeline.configService.GetAcceptableBounds(market)
+	switch mode := pipeline.configService.GetMatchingMode(market); mode {
+	case hu.ProRata, hu.TopOfBookPriority:
+		minSize := pipeline.configService.getMinSizeRequirement(market)
+		pipeline.runAllocationMatchingEngine(pipeline.lotp, orders.longOrders, orders.shortOrders, marginMap, hState.MinAllowableMargin, hState.TakerFee, upperBound, minSize, mode == hu.TopOfBookPriority)
+	case hu.BatchAuction:
+		if !runBatchAuctions {
+			return
+		}
+		pipeline.runBatchAuction(pipeline.lotp, market, orders.longOrders, orders.shortOrders, marginMap, hState.MinAllowableMargin, hState.TakerFee, hState.OraclePrices[market])
+	default:
+		pipeline.runMatchingEngine(pipeline.lotp, orders.longOrders, orders.shortOrders, marginMap, hState.MinAllowableMargin, hState.TakerFee, upperBound)
+	}
+}
+
 type Orders struct {
 	longOrders  []Order
 	shortOrders []Order
@@ -218,7 +302,7 @@ func (pipeline *MatchingPipeline) fetchOrders(market Market, underlyingPrice *bi
 		upperBoundforShorts = utils.BigIntMin(utils.BigIntMax(longOrders[0].Price, upperBoundforShorts), oracleUpperBound)
 	}
 	shortOrders := removeOrdersWithIds(pipeline.db.GetShortOrders(market, upperBoundforShorts, blockNumber), cancellableOrderIds)
-	return &Orders{longOrders, shortOrders}
+	return &Orders{removeUntriggeredOrders(longOrders, underlyingPrice), removeUntriggeredOrders(shortOrders, underlyingPrice)}
 }
 
 func (pipeline *MatchingPipeline) runLiquidations(liquidablePositions []LiquidablePosition, orderMap map[Market]*Orders, underlyingPrices map[Market]*big.Int, marginMap map[common.Address]*big.Int) {
@@ -246,9 +330,15 @@ func (pipeline *MatchingPipeline) runLiquidations(liquidablePositions []Liquidab
 
 	minAllowableMargin := pipeline.configService.GetMinAllowableMargin()
 	takerFee := pipeline.configService.GetTakerFee()
+	selfTradeCheckers := make([]*selfTradeChecker, len(markets))
+	for _, market := range markets {
+		selfTradeCheckers[market] = newSelfTradeChecker(pipeline.db, pipeline.configService, market)
+	}
 	for _, liquidable := range liquidablePositions {
 		market := liquidable.Market
 		numOrdersExhausted := 0
+		// orders of the liquidated trader's group that were skipped but can still be matched with other orders
+		skippedOrders := []Order{}
 		switch liquidable.PositionType {
 		case LONG:
 			for _, order := range orderMap[market].longOrders {
@@ -256,6 +346,13 @@ func (pipeline *MatchingPipeline) runLiquidations(liquidablePositions []Liquidab
 					// further orders are not not eligible to liquidate with
 					break
 				}
+				if selfTradeCheckers[market].isSelfLiquidation(liquidable.Address, &order) {
+					numOrdersExhausted++
+					if !selfTradeCheckers[market].preventSelfLiquidation(liquidable.Address, &order, liquidable.GetUnfilledSize()) {
+						skippedOrders = append(skippedOrders, order)
+					}
+					continue
+				}
 				fillAmount := utils.BigIntMinAbs(liquidable.GetUnfilledSize(), order.GetUnFilledBaseAssetQuantity())
 				if marginMap[order.Trader] == nil {
 					// compatibility with existing tests
@@ -278,13 +375,20 @@ func (pipeline *MatchingPipeline) runLiquidations(liquidablePositions []Liquidab
 					break // partial/full liquidation for this position slated for this run is complete
 				}
 			}
-			orderMap[market].longOrders = orderMap[market].longOrders[numOrdersExhausted:]
+			orderMap[market].longOrders = append(skippedOrders, orderMap[market].longOrders[numOrdersExhausted:]...)
 		case SHORT:
 			for _, order := range orderMap[market].shortOrders {
 				if order.Price.Cmp(liquidationBounds[market].Upperbound) == 1 {
 					// further orders are not not eligible to liquidate with
 					break
 				}
+				if selfTradeCheckers[market].isSelfLiquidation(liquidable.Address, &order) {
+					numOrdersExhausted++
+					if !selfTradeCheckers[market].preventSelfLiquidation(liquidable.Address, &order, liquidable.GetUnfilledSize()) {
+						skippedOrders = append(skippedOrders, order)
+					}
+					continue
+				}
 				fillAmount := utils.BigIntMinAbs(liquidable.GetUnfilledSize(), order.GetUnFilledBaseAssetQuantity())
 				if marginMap[order.Trader] == nil {
 					marginMap[order.Trader] = big.NewInt(0)
@@ -306,7 +410,7 @@ func (pipeline *MatchingPipeline) runLiquidations(liquidablePositions []Liquidab
 					break // partial/full liquidation for this position slated for this run is complete
 				}
 			}
-			orderMap[market].shortOrders = orderMap[market].shortOrders[numOrdersExhausted:]
+			orderMap[market].shortOrders = append(skippedOrders, orderMap[market].shortOrders[numOrdersExhausted:]...)
 		}
 		if liquidable.GetUnfilledSize().Sign() != 0 {
 			unquenchedLiquidationsCounter.Inc(1)
@@ -316,6 +420,10 @@ func (pipeline *MatchingPipeline) runLiquidations(liquidablePositions []Liquidab
 }
 
 func (pipeline *MatchingPipeline) runMatchingEngine(lotp LimitOrderTxProcessor, longOrders []Order, shortOrders []Order, marginMap map[common.Address]*big.Int, minAllowableMargin, takerFee, upperBound *big.Int) {
+	if len(longOrders) == 0 {
+		return
+	}
+	selfTradeChecker := newSelfTradeChecker(pipeline.db, pipeline.configService, longOrders[0].Market)
 	for i := 0; i < len(longOrders); i++ {
 		// if there are no short orders or if the price of the first long order is < the price of the first short order, then we can stop matching
 		if len(shortOrders) == 0 || longOrders[i].Price.Cmp(shortOrders[0].Price) == -1 {
@@ -323,6 +431,16 @@ func (pipeline *MatchingPipeline) runMatchingEngine(lotp LimitOrderTxProcessor,
 		}
 		numOrdersExhausted := 0
 		for j := 0; j < len(shortOrders); j++ {
+			if longOrders[i].Price.Cmp(shortOrders[j].Price) >= 0 && selfTradeChecker.isSelfTrade(&longOrders[i], &shortOrders[j]) {
+				selfTradeChecker.preventSelfTrade(&longOrders[i], &shortOrders[j])
+				if shortOrders[j].GetUnFilledBaseAssetQuantity().Sign() == 0 {
+					numOrdersExhausted++
+				}
+				if longOrders[i].GetUnFilledBaseAssetQuantity().Sign() == 0 {
+					break
+				}
+				continue
+			}
 			fillAmount, err := areMatchingOrders(longOrders[i], shortOrders[j], marginMap, minAllowableMargin, takerFee, upperBound)
 			if err != nil {
 				log.Error("orders not matcheable", "longOrder", longOrders[i], "shortOrder", shortOrders[i], "err", err)
@@ -341,31 +459,39 @@ func (pipeline *MatchingPipeline) runMatchingEngine(lotp LimitOrderTxProcessor,
 }
 
 func areMatchingOrders(longOrder, shortOrder Order, marginMap map[common.Address]*big.Int, minAllowableMargin, takerFee, upperBound *big.Int) (*big.Int, error) {
+	fillAmount := utils.BigIntMinAbs(longOrder.GetUnFilledBaseAssetQuantity(), shortOrder.GetUnFilledBaseAssetQuantity())
+	if err := canFillOrders(longOrder, shortOrder, fillAmount, marginMap, minAllowableMargin, takerFee, upperBound); err != nil {
+		return nil, err
+	}
+	return fillAmount, nil
+}
+
+// canFillOrders checks that fillAmount can be matched between the two orders and reserves the margin for it in marginMap
+func canFillOrders(longOrder, shortOrder Order, fillAmount *big.Int, marginMap map[common.Address]*big.Int, minAllowableMargin, takerFee, upperBound *big.Int) error {
 	if longOrder.Price.Cmp(shortOrder.Price) == -1 {
-		return nil, fmt.Errorf("long order price %s is less than short order price %s", longOrder.Price, shortOrder.Price)
+		return fmt.Errorf("long order price %s is less than short order price %s", longOrder.Price, shortOrder.Price)
 	}
-	blockDiff := longOrder.BlockNumber.Cmp(shortOrder.BlockNumber)
+	blockDiff := compareBlockPlaced(longOrder, shortOrder)
 	if blockDiff == -1 && (longOrder.OrderType == IOC || shortOrder.isPostOnly()) ||
 		blockDiff == 1 && (shortOrder.OrderType == IOC || longOrder.isPostOnly()) {
-		return nil, fmt.Errorf("resting order semantics mismatch")
+		return fmt.Errorf("resting order semantics mismatch")
 	}
-	fillAmount := utils.BigIntMinAbs(longOrder.GetUnFilledBaseAssetQuantity(), shortOrder.GetUnFilledBaseAssetQuantity())
 	if fillAmount.Sign() == 0 {
-		return nil, fmt.Errorf("no fill amount")
+		return fmt.Errorf("no fill amount")
 	}
 
 	longMargin, err := isExecutable(&longOrder, fillAmount, minAllowableMargin, takerFee, upperBound, marginMap[longOrder.Trader])
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	shortMargin, err := isExecutable(&shortOrder, fillAmount, minAllowableMargin, takerFee, upperBound, marginMap[shortOrder.Trader])
 	if err != nil {
-		return nil, err
+		return err
 	}
 	marginMap[longOrder.Trader].Sub(marginMap[longOrder.Trader], longMargin)
 	marginMap[shortOrder.Trader].Sub(marginMap[shortOrder.Trader], shortMargin)
-	return fillAmount, nil
+	return nil
 }
 
 func isExecutable(order *Order, fillAmount, minAllowableMargin, takerFee, upperBound, availableMargin *big.Int) (*big.Int, error) {
@@ -379,6 +505,10 @@ func isExecutable(order *Order, fillAmount, minAllowableMargin, takerFee, upperB
 	if order.OrderType == Signed {
 		requiredMargin = getRequiredMargin(order, fillAmount, minAllowableMargin, big.NewInt(0) /* signed orders are always maker */, upperBound)
 	}
+	if order.OrderType == Trigger {
+		// no margin is reserved for trigger orders, and they are always taker once triggered
+		requiredMargin = getRequiredMargin(order, fillAmount, minAllowableMargin, takerFee, upperBound)
+	}
 	if requiredMargin.Cmp(availableMargin) > 0 {
 		return nil, fmt.Errorf("insufficient margin. trader %s, required: %s, available: %s", order.Trader, requiredMargin, availableMargin)
 	}
@@ -405,11 +535,11 @@ func matchLongAndShortOrder(lotp LimitOrderTxProcessor, longOrder, shortOrder Or
 	if longOrder.Price.Cmp(shortOrder.Price) == -1 || fillAmount.Sign() == 0 {
 		return longOrder, shortOrder, false
 	}
-	if longOrder.BlockNumber.Cmp(shortOrder.BlockNumber) > 0 && longOrder.isPostOnly() {
+	if compareBlockPlaced(longOrder, shortOrder) > 0 && longOrder.isPostOnly() {
 		log.Warn("post only long order matched with a resting order", "longOrder", longOrder, "shortOrder", shortOrder)
 		return longOrder, shortOrder, false
 	}
-	if shortOrder.BlockNumber.Cmp(longOrder.BlockNumber) > 0 && shortOrder.isPostOnly() {
+	if compareBlockPlaced(shortOrder, longOrder) > 0 && shortOrder.isPostOnly() {
 		log.Warn("post only short order matched with a resting order", "longOrder", longOrder, "shortOrder", shortOrder)
 		return longOrder, shortOrder, false
 	}
@@ -445,6 +575,34 @@ func executeFundingPayment(lotp LimitOrderTxProcessor) error {
 	return lotp.ExecuteFundingPaymentTx()
 }
 
+// compareBlockPlaced compares the blocks in which the orders were placed, the same way as the juror does.
+// The juror can't know when a trigger order was triggered, so triggered orders are treated as placed after every other order
+func compareBlockPlaced(order0, order1 Order) int {
+	if order0.OrderType == Trigger || order1.OrderType == Trigger {
+		if order0.OrderType == order1.OrderType {
+			return 0
+		}
+		if order0.OrderType == Trigger {
+			return 1
+		}
+		return -1
+	}
+	return order0.BlockNumber.Cmp(order1.BlockNumber)
+}
+
+// removeUntriggeredOrders removes the trigger orders whose trigger price is not reached at the current oracle price.
+// A trigger order stays in the order book once it is triggered, but the juror only allows it to be matched while the oracle price is past its trigger price
+func removeUntriggeredOrders(orders []Order, oraclePrice *big.Int) []Order {
+	filteredOrders := orders[:0:0]
+	for _, order := range orders {
+		if order.OrderType == Trigger && !order.RawOrder.(*hu.TriggerOrder).IsTriggered(oraclePrice) {
+			continue
+		}
+		filteredOrders = append(filteredOrders, order)
+	}
+	return filteredOrders
+}
+
 func removeOrdersWithIds(orders []Order, orderIds map[common.Hash]struct{}) []Order {
 	var filteredOrders []Order
 	for _, order := range orders {
@@ -12,23 +12,34 @@ import (
 
 	"github.com/ava-labs/subnet-evm/metrics"
 	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ava-labs/subnet-evm/precompile/contract"
 	"github.com/ava-labs/subnet-evm/utils"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/log"
 )
 
 type InMemoryDatabase struct {
-	mu                        *sync.RWMutex              `json:"-"`
-	Orders                    map[common.Hash]*Order     `json:"order_map"` // ID => order
-	LongOrders                map[Market][]*Order        `json:"long_orders"`
-	ShortOrders               map[Market][]*Order        `json:"short_orders"`
-	TraderMap                 map[common.Address]*Trader `json:"trader_map"` // address => trader info
-	NextFundingTime           uint64                     `json:"next_funding_time"`
-	LastPrice                 map[Market]*big.Int        `json:"last_price"`
-	CumulativePremiumFraction map[Market]*big.Int        `json:"cumulative_last_premium_fraction"`
-	NextSamplePITime          uint64                     `json:"next_sample_pi_time"`
-	SamplePIAttemptedTime     uint64                     `json:"sample_pi_attempted_time"`
-	configService             IConfigService             `json:"-"`
+	mu                        *sync.RWMutex                               `json:"-"`
+	Orders                    map[common.Hash]*Order                      `json:"order_map"` // ID => order
+	longOrders                map[Market]*orderBookSide                   `json:"-"`         // not serialized; rebuilt from Orders
+	shortOrders               map[Market]*orderBookSide                   `json:"-"`
+	traderOrders              map[common.Address]map[common.Hash]struct{} `json:"-"`          // trader => order ids; rebuilt from Orders
+	TraderMap                 map[common.Address]*Trader                  `json:"trader_map"` // address => trader info
+	NextFundingTime           uint64                                      `json:"next_funding_time"`
+	LastPrice                 map[Market]*big.Int                         `json:"last_price"`
+	CumulativePremiumFraction map[Market]*big.Int                         `json:"cumulative_last_premium_fraction"`
+	NextSamplePITime          uint64                                      `json:"next_sample_pi_time"`
+	SamplePIAttemptedTime     uint64                                      `json:"sample_pi_attempted_time"`
+	TriggerOrders             map[common.Hash]*Order                      `json:"trigger_orders"`             // ID => trigger order that hasn't been triggered yet; not part of the order book
+	CancelNonces              map[common.Address]*CancelNonce             `json:"cancel_nonces"`              // trader => nonces of the trader's last signed cancel all messages
+	Heartbeats                map[common.Address]*TraderHeartbeat         `json:"heartbeats"`                 // trader => last heartbeat of the trader's dead man's switch
+	SignedOrderCancellations  map[common.Hash]*hu.CancelOrder             `json:"signed_order_cancellations"` // ID => cancel message of a signed order that was amended, till the order expires
+	CancelledSignedOrders     map[common.Hash]uint64                      `json:"cancelled_signed_orders"`    // ID => expireAt of a signed order cancelled without a transaction, till the order expires
+	positionChangedTraders    map[common.Address]struct{}                 `json:"-"`                          // traders whose position changed since the last RemoveStaleReduceOnlySignedOrders
+	removedOrders             []Order                                     `json:"-"`                          // orders removed for good since the last PopRemovedOrders, to be archived
+	acceptedBlockNumber       uint64                                      `json:"-"`
+	depthFeed                 *depthFeed                                  `json:"-"`
+	configService             IConfigService                              `json:"-"`
 }
 
 func NewInMemoryDatabase(configService IConfigService) *InMemoryDatabase {
@@ -37,8 +48,16 @@ func NewInMemoryDatabase(configService IConfigService) *InMemoryDatabase {
 
 	return &InMemoryDatabase{
 		Orders:                    map[common.Hash]*Order{},
-		LongOrders:                map[Market][]*Order{},
-		ShortOrders:               map[Market][]*Order{},
+		longOrders:                map[Market]*orderBookSide{},
+		shortOrders:               map[Market]*orderBookSide{},
+		traderOrders:              map[common.Address]map[common.Hash]struct{}{},
+		TriggerOrders:             map[common.Hash]*Order{},
+		CancelNonces:              map[common.Address]*CancelNonce{},
+		Heartbeats:                map[common.Address]*TraderHeartbeat{},
+		SignedOrderCancellations:  map[common.Hash]*hu.CancelOrder{},
+		CancelledSignedOrders:     map[common.Hash]uint64{},
+		positionChangedTraders:    map[common.Address]struct{}{},
+		depthFeed:                 newDepthFeed(),
 		TraderMap:                 traderMap,
 		LastPrice:                 lastPrice,
 		CumulativePremiumFraction: map[Market]*big.Int{},
@@ -82,15 +101,78 @@ const (
 type OrderType = hu.OrderType
 
 const (
-	Limit  = hu.Limit
-	IOC    = hu.IOC
-	Signed = hu.Signed
+	Limit   = hu.Limit
+	IOC     = hu.IOC
+	Signed  = hu.Signed
+	Trigger = hu.Trigger
 )
 
+// LifecycleReason is why an entry was added to the lifecycle of an order
+type LifecycleReason string
+
+const (
+	ReasonPartialFill        LifecycleReason = "PARTIAL_FILL"
+	ReasonFill               LifecycleReason = "FILL"
+	ReasonMatchingError      LifecycleReason = "MATCHING_ERROR"
+	ReasonCancelledByTrader  LifecycleReason = "CANCELLED_BY_TRADER"
+	ReasonInsufficientMargin LifecycleReason = "INSUFFICIENT_MARGIN" // cancelled by a validator, see determineOrdersToCancel
+	ReasonStaleReduceOnly    LifecycleReason = "STALE_REDUCE_ONLY"   // the order can no longer reduce the position
+	ReasonExpired            LifecycleReason = "EXPIRED"
+	ReasonAmended            LifecycleReason = "AMENDED"
+	ReasonTriggered          LifecycleReason = "TRIGGERED"
+	ReasonCancelAll          LifecycleReason = "CANCEL_ALL"        // cancelled by a signed cancel all message
+	ReasonHeartbeatExpired   LifecycleReason = "HEARTBEAT_EXPIRED" // cancelled by the trader's dead man's switch
+	ReasonSelfTrade          LifecycleReason = "SELF_TRADE"        // cancelled by the self trade prevention of the market
+)
+
+// CancelNonce holds the nonces of a trader's last signed cancel all messages, for all markets and per market
+type CancelNonce struct {
+	AllMarkets *big.Int              `json:"all_markets"`
+	Markets    map[Market]*big.Int   `json:"markets"`
+	Messages   []*hu.CancelAllOrders `json:"messages"` // the last message for every AmmIndex, to be recorded with the juror
+}
+
+// setMessage replaces the message with the same AmmIndex
+func (n *CancelNonce) setMessage(msg *hu.CancelAllOrders) {
+	for i, m := range n.Messages {
+		if m.AmmIndex.Cmp(msg.AmmIndex) == 0 {
+			n.Messages[i] = msg
+			return
+		}
+	}
+	n.Messages = append(n.Messages, msg)
+}
+
+// get returns the nonce that applies to the signed orders of a market, nil if no cancel all message applies to the market
+func (n *CancelNonce) get(market Market) *big.Int {
+	if n == nil {
+		return nil
+	}
+	nonce := n.AllMarkets
+	if marketNonce := n.Markets[market]; marketNonce != nil && (nonce == nil || marketNonce.Cmp(nonce) > 0) {
+		nonce = marketNonce
+	}
+	return nonce
+}
+
+// TraderHeartbeat is the last heartbeat of a trader's dead man's switch, see hu.Heartbeat
+type TraderHeartbeat struct {
+	Heartbeat hu.Heartbeat `json:"heartbeat"`
+	// Expired is set once the trader's signed orders were cancelled for missing the deadline of the heartbeat
+	Expired bool `json:"expired"`
+}
+
+// Lifecycle is a change in the status of an order, or a fill of the order. Partial fills don't change the status, which stays Placed.
+// FillAmount is unsigned, and CounterpartyOrderId is not set for liquidations because the liquidated trader has no order
 type Lifecycle struct {
-	BlockNumber uint64
-	Status      Status
-	Info        string
+	BlockNumber         uint64
+	Status              Status
+	Info                string
+	Reason              LifecycleReason `json:",omitempty"`
+	FillAmount          *big.Int        `json:",omitempty"`
+	FillPrice           *big.Int        `json:",omitempty"`
+	CounterpartyOrderId *common.Hash    `json:",omitempty"`
+	TxHash              *common.Hash    `json:",omitempty"`
 }
 
 type Order struct {
@@ -107,6 +189,7 @@ type Order struct {
 	BlockNumber             *big.Int      // block number order was placed on
 	RawOrder                ContractOrder `json:"-"`
 	OrderType               OrderType
+	PlacedAt                uint64 // unix time the order was added to this node's order book, set if it is 0
 }
 
 func (order *Order) MarshalJSON() ([]byte, error) {
@@ -146,6 +229,19 @@ func (order Order) getOrderStatus() Lifecycle {
 	return lifecycle[len(lifecycle)-1]
 }
 
+// removeLifecycle removes the last lifecycle entry added at blockNumber that is a fill if fill is true, or any other entry otherwise.
+// Removed events are reverted in reverse order, but entries of other kinds might have been added after the one being reverted
+func (order *Order) removeLifecycle(blockNumber uint64, fill bool) {
+	for i := len(order.LifecycleList) - 1; i >= 0; i-- {
+		lifecycle := order.LifecycleList[i]
+		isFill := lifecycle.Reason == ReasonFill || lifecycle.Reason == ReasonPartialFill
+		if lifecycle.BlockNumber == blockNumber && isFill == fill {
+			order.LifecycleList = append(order.LifecycleList[:i], order.LifecycleList[i+1:]...)
+			return
+		}
+	}
+}
+
 func (order Order) getExpireAt() *big.Int {
 	if order.OrderType == IOC {
 		return order.RawOrder.(*IOCOrder).ExpireAt
@@ -153,6 +249,9 @@ func (order Order) getExpireAt() *big.Int {
 	if order.OrderType == Signed {
 		return order.RawOrder.(*hu.SignedOrder).ExpireAt
 	}
+	if order.OrderType == Trigger {
+		return order.RawOrder.(*hu.TriggerOrder).ExpireAt
+	}
 	return big.NewInt(0)
 }
 
@@ -223,9 +322,12 @@ type Trader struct {
 type LimitOrderDatabase interface {
 	LoadFromSnapshot(snapshot Snapshot) error
 	GetAllOrders() []Order
+	GetSignedOrders() []Order
 	GetMarketOrders(market Market) []Order
 	Add(order *Order)
 	AddSignedOrder(order *Order, requiredMargin *big.Int)
+	AddTriggerOrder(order *Order)
+	ActivateTriggeredOrders(oraclePrices map[Market]*big.Int, blockNumber uint64) []Order
 	Delete(orderId common.Hash)
 	UpdateFilledBaseAssetQuantity(quantity *big.Int, orderId common.Hash, blockNumber uint64)
 	GetLongOrders(market Market, lowerbound *big.Int, blockNumber *big.Int) []Order
@@ -248,7 +350,10 @@ type LimitOrderDatabase interface {
 	GetOrderBookDataCopy() (*InMemoryDatabase, error)
 	Accept(acceptedBlockNumber uint64, blockTimestamp uint64)
 	SetOrderStatus(orderId common.Hash, status Status, info string, blockNumber uint64) error
-	RevertLastStatus(orderId common.Hash) error
+	UpdateOrderLifecycle(orderId common.Hash, lifecycle Lifecycle) error
+	UpdateOrderFill(quantity *big.Int, orderId common.Hash, fill Lifecycle)
+	PopRemovedOrders() []Order
+	RevertLastStatus(orderId common.Hash, blockNumber uint64) error
 	GetNaughtyTraders(hState *hu.HubbleState) ([]LiquidablePosition, map[common.Address][]Order, map[common.Address]*big.Int)
 	GetAllOpenOrdersForTrader(trader common.Address) []Order
 	GetOpenOrdersForTraderByType(trader common.Address, orderType OrderType) []Order
@@ -258,12 +363,24 @@ type LimitOrderDatabase interface {
 	GetOrderValidationFields(orderId common.Hash, order *hu.SignedOrder) OrderValidationFields
 	SampleImpactPrice() (impactBids, impactAsks, midPrices []*big.Int)
 	RemoveExpiredSignedOrders()
+	RemoveStaleReduceOnlySignedOrders()
+	AmendSignedOrder(amendedOrderId common.Hash, cancelOrder *hu.CancelOrder, order *Order, requiredMargin *big.Int) error
+	GetSignedOrderCancellations() map[common.Hash]*hu.CancelOrder
+	CancelAllSignedOrders(msg *hu.CancelAllOrders) ([]common.Hash, error)
+	GetCancelAllMessages() []*hu.CancelAllOrders
+	UpdateHeartbeat(heartbeat *hu.Heartbeat) error
+	CancelOrdersOfExpiredHeartbeats() []common.Hash
+	GetDepthSnapshot(market Market) DepthSnapshot
+	SubscribeDepthUpdates(market Market) (DepthSnapshot, *DepthSubscription)
 	GetMarginAvailableForMakerbook(trader common.Address, prices map[int]*big.Int) *big.Int
+	VerifyState(stateDB contract.StateDB, blockNumber uint64) *StateVerificationReport
+	CancelSelfTradeOrder(orderId common.Hash, mode hu.SelfTradePreventionMode) error
 }
 
 type Snapshot struct {
 	Data                *InMemoryDatabase
 	AcceptedBlockNumber *big.Int // data includes this block number too
+	AcceptedBlockHash   common.Hash
 }
 
 func (db *InMemoryDatabase) LoadFromSnapshot(snapshot Snapshot) error {
@@ -281,11 +398,46 @@ func (db *InMemoryDatabase) LoadFromSnapshot(snapshot Snapshot) error {
 	db.NextFundingTime = snapshot.Data.NextFundingTime
 	db.NextSamplePITime = snapshot.Data.NextSamplePITime
 	db.CumulativePremiumFraction = snapshot.Data.CumulativePremiumFraction
+	db.TriggerOrders = snapshot.Data.TriggerOrders
+	if db.TriggerOrders == nil {
+		// snapshots taken before trigger orders were supported
+		db.TriggerOrders = map[common.Hash]*Order{}
+	}
+	db.CancelNonces = snapshot.Data.CancelNonces
+	if db.CancelNonces == nil {
+		db.CancelNonces = map[common.Address]*CancelNonce{}
+	}
+	db.Heartbeats = snapshot.Data.Heartbeats
+	if db.Heartbeats == nil {
+		db.Heartbeats = map[common.Address]*TraderHeartbeat{}
+	}
+	db.SignedOrderCancellations = snapshot.Data.SignedOrderCancellations
+	if db.SignedOrderCancellations == nil {
+		db.SignedOrderCancellations = map[common.Hash]*hu.CancelOrder{}
+	}
+	db.CancelledSignedOrders = snapshot.Data.CancelledSignedOrders
+	if db.CancelledSignedOrders == nil {
+		db.CancelledSignedOrders = map[common.Hash]uint64{}
+	}
+
+	db.rebuildIndexes()
+	return nil
+}
 
+// caller is expected to acquire db.mu before calling this function
+func (db *InMemoryDatabase) rebuildIndexes() {
+	db.longOrders = map[Market]*orderBookSide{}
+	db.shortOrders = map[Market]*orderBookSide{}
+	db.traderOrders = map[common.Address]map[common.Hash]struct{}{}
 	for _, order := range db.Orders {
-		db.AddInSortedArray(order)
+		db.getOrderBookSide(order.Market, order.PositionType).add(order)
+		db.addToTraderIndex(order)
+	}
+	// positions might have changed while the node was down
+	db.positionChangedTraders = map[common.Address]struct{}{}
+	for trader := range db.traderOrders {
+		db.positionChangedTraders[trader] = struct{}{}
 	}
-	return nil
 }
 
 func (db *InMemoryDatabase) Accept(acceptedBlockNumber, blockTimestamp uint64) {
@@ -293,6 +445,7 @@ func (db *InMemoryDatabase) Accept(acceptedBlockNumber, blockTimestamp uint64) {
 	defer db.mu.Unlock()
 
 	log.Info("Accept", "acceptedBlockNumber", acceptedBlockNumber, "blockTimestamp", blockTimestamp)
+	db.acceptedBlockNumber = acceptedBlockNumber
 	// SUNSET: this will work with 0 markets
 	count := db.configService.GetActiveMarketsCount()
 	for m := int64(0); m < count; m++ {
@@ -301,16 +454,46 @@ func (db *InMemoryDatabase) Accept(acceptedBlockNumber, blockTimestamp uint64) {
 
 		for _, longOrder := range longOrders {
 			if shouldRemove(acceptedBlockNumber, blockTimestamp, longOrder) == REMOVE {
-				db.deleteOrderWithoutLock(longOrder.Id)
+				db.removeOrderWithoutLock(db.getOrderWithoutLock(longOrder.Id), nil)
 			}
 		}
 
 		for _, shortOrder := range shortOrders {
 			if shouldRemove(acceptedBlockNumber, blockTimestamp, shortOrder) == REMOVE {
-				db.deleteOrderWithoutLock(shortOrder.Id)
+				db.removeOrderWithoutLock(db.getOrderWithoutLock(shortOrder.Id), nil)
 			}
 		}
 	}
+
+	for _, order := range db.TriggerOrders {
+		// only cancelled trigger orders are removed here, expired ones are removed in RemoveExpiredSignedOrders
+		if shouldRemove(acceptedBlockNumber, blockTimestamp, *order) == REMOVE {
+			db.removeOrderWithoutLock(order, nil)
+		}
+	}
+}
+
+// removeOrderWithoutLock deletes an order for good, adding lifecycle to it if set, and keeps it to be archived
+// caller is expected to acquire db.mu before calling this function
+func (db *InMemoryDatabase) removeOrderWithoutLock(order *Order, lifecycle *Lifecycle) {
+	if order == nil {
+		return
+	}
+	db.deleteOrderWithoutLock(order.Id)
+	if lifecycle != nil {
+		order.LifecycleList = append(order.LifecycleList, *lifecycle)
+	}
+	db.removedOrders = append(db.removedOrders, deepCopyOrder(order))
+}
+
+// PopRemovedOrders returns the orders removed for good since the last call, with their complete lifecycle
+func (db *InMemoryDatabase) PopRemovedOrders() []Order {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	removedOrders := db.removedOrders
+	db.removedOrders = nil
+	return removedOrders
 }
 
 type OrderStatus uint8
@@ -332,9 +515,9 @@ func shouldRemove(acceptedBlockNumber, blockTimestamp uint64, order Order) Order
 		return KEEP
 	}
 
-	// do not remove expired signed order here. They should be removed from
+	// do not remove expired signed and trigger orders here. They should be removed from
 	// RemoveExpiredSignedOrders function only so that the appropriate Trader event is sent
-	if order.OrderType == Signed {
+	if order.OrderType == Signed || order.OrderType == Trigger {
 		return KEEP
 	}
 
@@ -351,57 +534,159 @@ func (db *InMemoryDatabase) RemoveExpiredSignedOrders() {
 	defer db.mu.Unlock()
 
 	now := time.Now().Unix()
+	expiredOrders := []*Order{}
 	for _, order := range db.Orders {
-		if order.OrderType == Signed && order.getExpireAt().Int64() <= now {
-			db.deleteOrderWithoutLock(order.Id)
-
-			// send TraderEvent for the expired order
-			go func(order_ *Order) {
-				traderEvent := TraderEvent{
-					Trader:      order_.Trader,
-					Removed:     false,
-					EventName:   "OrderExpired",
-					BlockStatus: ConfirmationLevelHead,
-					OrderId:     order_.Id,
-					OrderType:   order_.OrderType.String(),
-					Timestamp:   big.NewInt(now),
-				}
+		if (order.OrderType == Signed || order.OrderType == Trigger) && order.getExpireAt().Int64() <= now {
+			expiredOrders = append(expiredOrders, order)
+		}
+	}
+	for _, order := range db.TriggerOrders {
+		if order.getExpireAt().Int64() <= now {
+			expiredOrders = append(expiredOrders, order)
+		}
+	}
+	for _, order := range expiredOrders {
+		db.removeOrderWithoutLock(order, &Lifecycle{BlockNumber: db.acceptedBlockNumber, Status: Cancelled, Reason: ReasonExpired})
+		go sendOrderRemovedEvent(order, "OrderExpired", now)
+	}
+	for orderId, cancelOrder := range db.SignedOrderCancellations {
+		if cancelOrder.Order.ExpireAt.Int64() <= now {
+			delete(db.SignedOrderCancellations, orderId)
+		}
+	}
+	for orderId, expireAt := range db.CancelledSignedOrders {
+		if int64(expireAt) <= now {
+			delete(db.CancelledSignedOrders, orderId)
+		}
+	}
+}
+
+// cancelSignedOrderWithoutLock removes a signed order that was cancelled without a transaction, and keeps its ID till it expires
+// so that the order is rejected if a peer gossips or syncs it again
+func (db *InMemoryDatabase) cancelSignedOrderWithoutLock(order *Order, lifecycle *Lifecycle) {
+	if expireAt := order.getExpireAt(); expireAt != nil && expireAt.IsUint64() {
+		db.CancelledSignedOrders[order.Id] = expireAt.Uint64()
+	}
+	db.removeOrderWithoutLock(order, lifecycle)
+}
+
+// RemoveStaleReduceOnlySignedOrders removes the signed reduce only orders that can no longer reduce the trader's position.
+// Only the traders whose position changed since the last run are checked. The orders of a trader in a market are kept in their
+// matching priority while their cumulative unfilled size is within the position; the last one kept might be bigger than what's left
+// of the position, and is shrunk by getReduceOnlyOrderDisplay in matching.
+func (db *InMemoryDatabase) RemoveStaleReduceOnlySignedOrders() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	type traderMarket struct {
+		trader common.Address
+		market Market
+	}
+	reduceOnlyOrders := map[traderMarket][]*Order{}
+	for trader := range db.positionChangedTraders {
+		for orderId := range db.traderOrders[trader] {
+			order := db.Orders[orderId]
+			if order != nil && order.OrderType == Signed && order.ReduceOnly && order.getOrderStatus().Status == Placed {
+				key := traderMarket{order.Trader, order.Market}
+				reduceOnlyOrders[key] = append(reduceOnlyOrders[key], order)
+			}
+		}
+	}
+	db.positionChangedTraders = map[common.Address]struct{}{}
 
-				traderFeed.Send(traderEvent)
-				traderEvent.BlockStatus = ConfirmationLevelAccepted
-				traderFeed.Send(traderEvent)
-			}(order)
+	now := time.Now().Unix()
+	for key, orders := range reduceOnlyOrders {
+		positionSize := big.NewInt(0)
+		if db.TraderMap[key.trader] != nil && db.TraderMap[key.trader].Positions[key.market] != nil && db.TraderMap[key.trader].Positions[key.market].Size != nil {
+			positionSize = db.TraderMap[key.trader].Positions[key.market].Size
+		}
+		queuePositions := make(map[common.Hash]int, len(orders))
+		for _, order := range orders {
+			queuePositions[order.Id] = db.getOrderBookSide(order.Market, order.PositionType).queuePosition(order.Id)
+		}
+		sort.SliceStable(orders, func(i, j int) bool {
+			if orders[i].Price.Cmp(orders[j].Price) != 0 {
+				// best price first - highest bid or lowest ask
+				return (orders[i].Price.Cmp(orders[j].Price) > 0) == (orders[i].PositionType == LONG)
+			}
+			// then in the order they are matched at the same price
+			return queuePositions[orders[i].Id] < queuePositions[orders[j].Id]
+		})
 
+		reducedAmount := big.NewInt(0)
+		for _, order := range orders {
+			if positionSize.Sign() != 0 && positionSize.Sign() != order.BaseAssetQuantity.Sign() && reducedAmount.CmpAbs(positionSize) < 0 {
+				reducedAmount.Add(reducedAmount, order.GetUnFilledBaseAssetQuantity())
+				continue
+			}
+			log.Info("removing stale reduce only signed order", "orderId", order.Id.Hex(), "trader", key.trader.String(), "positionSize", positionSize)
+			db.removeOrderWithoutLock(order, &Lifecycle{BlockNumber: db.acceptedBlockNumber, Status: Cancelled, Reason: ReasonStaleReduceOnly})
+			go sendOrderRemovedEvent(order, "OrderCancelled", now)
 		}
 	}
 }
 
+// sendOrderRemovedEvent sends the trader event for an order that was removed from the memory db without a transaction,
+// both for head and accepted block
+func sendOrderRemovedEvent(order *Order, eventName string, timestamp int64) {
+	traderEvent := TraderEvent{
+		Trader:      order.Trader,
+		Removed:     false,
+		EventName:   eventName,
+		BlockStatus: ConfirmationLevelHead,
+		OrderId:     order.Id,
+		OrderType:   order.OrderType.String(),
+		Timestamp:   big.NewInt(timestamp),
+	}
+
+	traderFeed.Send(traderEvent)
+	traderEvent.BlockStatus = ConfirmationLevelAccepted
+	traderFeed.Send(traderEvent)
+}
+
 func (db *InMemoryDatabase) SetOrderStatus(orderId common.Hash, status Status, info string, blockNumber uint64) error {
+	return db.UpdateOrderLifecycle(orderId, Lifecycle{BlockNumber: blockNumber, Status: status, Info: info})
+}
+
+// UpdateOrderLifecycle adds lifecycle to an order. It is reverted with RevertLastStatus
+func (db *InMemoryDatabase) UpdateOrderLifecycle(orderId common.Hash, lifecycle Lifecycle) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	if db.Orders[orderId] == nil {
+	order := db.getOrderWithoutLock(orderId)
+	if order == nil {
 		return fmt.Errorf("invalid orderId %s", orderId.Hex())
 	}
-	db.Orders[orderId].LifecycleList = append(db.Orders[orderId].LifecycleList, Lifecycle{blockNumber, status, info})
+	order.LifecycleList = append(order.LifecycleList, lifecycle)
+	db.publishDepthUpdate(order)
 	return nil
 }
 
-func (db *InMemoryDatabase) RevertLastStatus(orderId common.Hash) error {
+// RevertLastStatus removes the last lifecycle entry that was added at blockNumber, other than a fill, when the event that added it
+// is removed in a reorg. Fills are reverted with UpdateOrderFill
+func (db *InMemoryDatabase) RevertLastStatus(orderId common.Hash, blockNumber uint64) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	if db.Orders[orderId] == nil {
+	order := db.getOrderWithoutLock(orderId)
+	if order == nil {
 		return fmt.Errorf("invalid orderId %s", orderId.Hex())
 	}
 
-	lifeCycleList := db.Orders[orderId].LifecycleList
-	if len(lifeCycleList) > 0 {
-		db.Orders[orderId].LifecycleList = lifeCycleList[:len(lifeCycleList)-1]
-	}
+	order.removeLifecycle(blockNumber, false)
+	db.publishDepthUpdate(order)
 	return nil
 }
 
+// getOrderWithoutLock returns an order from the order book or from the trigger orders that haven't been triggered yet
+// caller is expected to acquire db.mu before calling this function
+func (db *InMemoryDatabase) getOrderWithoutLock(orderId common.Hash) *Order {
+	if order := db.Orders[orderId]; order != nil {
+		return order
+	}
+	return db.TriggerOrders[orderId]
+}
+
 func (db *InMemoryDatabase) GetAllOrders() []Order {
 	db.mu.RLock() // only read lock required
 	defer db.mu.RUnlock()
@@ -413,18 +698,34 @@ func (db *InMemoryDatabase) GetAllOrders() []Order {
 	return allOrders
 }
 
+// GetSignedOrders returns the signed orders that can still be matched, e.g. to sync them to a peer
+func (db *InMemoryDatabase) GetSignedOrders() []Order {
+	db.mu.RLock() // only read lock required
+	defer db.mu.RUnlock()
+
+	signedOrders := []Order{}
+	for _, order := range db.Orders {
+		if order.OrderType == Signed && order.getOrderStatus().Status == Placed {
+			signedOrders = append(signedOrders, deepCopyOrder(order))
+		}
+	}
+	return signedOrders
+}
+
 func (db *InMemoryDatabase) GetMarketOrders(market Market) []Order {
 	db.mu.RLock() // only read lock required
 	defer db.mu.RUnlock()
 
 	allOrders := []Order{}
-	for _, order := range db.LongOrders[market] {
+	db.longOrders[market].ascend(func(order *Order) bool {
 		allOrders = append(allOrders, deepCopyOrder(order))
-	}
+		return true
+	})
 
-	for _, order := range db.ShortOrders[market] {
+	db.shortOrders[market].ascend(func(order *Order) bool {
 		allOrders = append(allOrders, deepCopyOrder(order))
-	}
+		return true
+	})
 
 	return allOrders
 }
@@ -452,68 +753,303 @@ func (db *InMemoryDatabase) AddSignedOrder(order *Order, requiredMargin *big.Int
 	db.updateVirtualReservedMargin(order.Trader, requiredMargin)
 }
 
-func (db *InMemoryDatabase) addOrderWithoutLock(order *Order) {
-	order.LifecycleList = append(order.LifecycleList, Lifecycle{order.BlockNumber.Uint64(), Placed, ""})
-	db.AddInSortedArray(order)
+// AmendSignedOrder replaces a signed order with order, and keeps the message that cancels the amended order so that it is rejected
+// from then on and can be recorded on chain, see GetSignedOrderCancellations. If the price is unchanged and the unfilled size isn't increased,
+// order takes the place of the amended order in the queue at its price level, otherwise it goes to the back of the queue.
+// The difference in reserved margin is applied in one step. The amended order might not have reached this node yet, then order is just added.
+func (db *InMemoryDatabase) AmendSignedOrder(amendedOrderId common.Hash, cancelOrder *hu.CancelOrder, order *Order, requiredMargin *big.Int) error {
+	if order.OrderType != Signed {
+		return fmt.Errorf("amendment is not a signed order")
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, amended := db.SignedOrderCancellations[amendedOrderId]; amended {
+		return ErrAmendedOrderNotFound
+	}
+	amendedOrder := db.Orders[amendedOrderId]
+	if amendedOrder != nil && (amendedOrder.OrderType != Signed || amendedOrder.getOrderStatus().Status != Placed) {
+		return ErrAmendedOrderNotFound
+	}
+	if db.Orders[order.Id] != nil {
+		return hu.ErrOrderAlreadyExists
+	}
+	log.Info("SignedOrder/OrderAmended", "amendedOrderId", amendedOrderId, "order", order)
+	db.SignedOrderCancellations[amendedOrderId] = cancelOrder
+
+	reservedMarginDelta := new(big.Int).Set(requiredMargin)
+	keepPriority := false
+	if amendedOrder != nil {
+		if !amendedOrder.ReduceOnly {
+			minAllowableMargin := db.configService.GetMinAllowableMargin()
+			reservedMargin := hu.GetRequiredMargin(amendedOrder.Price, hu.Abs(amendedOrder.GetUnFilledBaseAssetQuantity()), minAllowableMargin, big.NewInt(0))
+			reservedMarginDelta.Sub(reservedMarginDelta, reservedMargin)
+		}
+		keepPriority = amendedOrder.Market == order.Market && amendedOrder.PositionType == order.PositionType && amendedOrder.Price.Cmp(order.Price) == 0 &&
+			amendedOrder.GetUnFilledBaseAssetQuantity().CmpAbs(order.GetUnFilledBaseAssetQuantity()) >= 0
+		delete(db.Orders, amendedOrderId)
+		db.removeFromTraderIndex(amendedOrder.Trader, amendedOrderId)
+		side := db.getOrderBookSide(amendedOrder.Market, amendedOrder.PositionType)
+		if keepPriority {
+			keepPriority = side.replace(amendedOrderId, order)
+		} else {
+			side.remove(amendedOrderId)
+		}
+		db.publishDepthUpdate(amendedOrder)
+		amendedOrder.LifecycleList = append(amendedOrder.LifecycleList, Lifecycle{BlockNumber: order.BlockNumber.Uint64(), Status: Cancelled, Info: "amended by " + order.Id.Hex(), Reason: ReasonAmended})
+		db.removedOrders = append(db.removedOrders, deepCopyOrder(amendedOrder))
+	}
+	db.updateVirtualReservedMargin(order.Trader, reservedMarginDelta)
+
+	if order.PlacedAt == 0 {
+		order.PlacedAt = uint64(time.Now().Unix())
+	}
+	order.LifecycleList = append(order.LifecycleList, Lifecycle{BlockNumber: order.BlockNumber.Uint64(), Status: Placed, Info: "amends " + amendedOrderId.Hex()})
 	db.Orders[order.Id] = order
+	db.addToTraderIndex(order)
+	if !keepPriority {
+		db.AddInSortedArray(order)
+	}
+	return nil
 }
 
-// caller is expected to acquire db.mu before calling this function
-func (db *InMemoryDatabase) AddInSortedArray(order *Order) {
-	market := order.Market
+// GetSignedOrderCancellations returns the cancel messages of the amended signed orders that haven't expired, by the ID of the amended order
+func (db *InMemoryDatabase) GetSignedOrderCancellations() map[common.Hash]*hu.CancelOrder {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
 
-	var orders []*Order
-	var position int
-	if order.PositionType == LONG {
-		orders = db.LongOrders[market]
-		position = sort.Search(len(orders), func(i int) bool {
-			priceDiff := order.Price.Cmp(orders[i].Price)
-			if priceDiff == 1 {
-				return true
-			} else if priceDiff == 0 {
-				blockDiff := order.BlockNumber.Cmp(orders[i].BlockNumber)
-				if blockDiff == -1 { // order was placed before i
-					return true
-				} else if blockDiff == 0 { // order and i were placed in the same block
-					if order.OrderType == IOC {
-						// prioritize fulfilling IOC orders first, because they are short-lived
-						return true
-					}
-				}
-			}
-			return false
-		})
+	cancellations := make(map[common.Hash]*hu.CancelOrder, len(db.SignedOrderCancellations))
+	for orderId, cancelOrder := range db.SignedOrderCancellations {
+		cancellations[orderId] = cancelOrder
+	}
+	return cancellations
+}
+
+// CancelAllSignedOrders books the nonce of a signed cancel all message, and removes all the placed signed orders of the trader in the market
+// of the message, or in all markets. Signed orders with a salt <= nonce are rejected after this, see GetOrderValidationFields.
+// The message is kept to be recorded with the juror, see GetCancelAllMessages.
+// Returns hu.ErrStaleCancelNonce if the nonce is not greater than the last nonce that applies to the market(s), e.g. when the message is gossiped again
+func (db *InMemoryDatabase) CancelAllSignedOrders(msg *hu.CancelAllOrders) ([]common.Hash, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	trader, nonce := msg.Trader, msg.Nonce
+	var market *Market
+	if !msg.IsAllMarkets() {
+		m := Market(msg.AmmIndex.Int64())
+		market = &m
+	}
+	cancelNonce := db.CancelNonces[trader]
+	if cancelNonce == nil {
+		cancelNonce = &CancelNonce{Markets: map[Market]*big.Int{}}
+	}
+	if market == nil {
+		if cancelNonce.AllMarkets != nil && nonce.Cmp(cancelNonce.AllMarkets) <= 0 {
+			return nil, hu.ErrStaleCancelNonce
+		}
+		cancelNonce.AllMarkets = new(big.Int).Set(nonce)
 	} else {
-		orders = db.ShortOrders[market]
-		position = sort.Search(len(orders), func(i int) bool {
-			priceDiff := order.Price.Cmp(orders[i].Price)
-			if priceDiff == -1 {
-				return true
-			} else if priceDiff == 0 {
-				blockDiff := order.BlockNumber.Cmp(orders[i].BlockNumber)
-				if blockDiff == -1 { // order was placed before i
-					return true
-				} else if blockDiff == 0 { // order and i were placed in the same block
-					if order.OrderType == IOC {
-						// prioritize fulfilling IOC orders first, because they are short-lived
-						return true
-					}
-				}
+		if lastNonce := cancelNonce.get(*market); lastNonce != nil && nonce.Cmp(lastNonce) <= 0 {
+			return nil, hu.ErrStaleCancelNonce
+		}
+		cancelNonce.Markets[*market] = new(big.Int).Set(nonce)
+	}
+	cancelNonce.setMessage(msg)
+	db.CancelNonces[trader] = cancelNonce
+
+	now := time.Now().Unix()
+	cancelledOrderIds := []common.Hash{}
+	for orderId := range db.traderOrders[trader] {
+		order := db.Orders[orderId]
+		if order == nil || order.OrderType != Signed || order.getOrderStatus().Status != Placed || (market != nil && order.Market != *market) {
+			continue
+		}
+		db.cancelSignedOrderWithoutLock(order, &Lifecycle{BlockNumber: db.acceptedBlockNumber, Status: Cancelled, Reason: ReasonCancelAll, Info: "nonce " + nonce.String()})
+		cancelledOrderIds = append(cancelledOrderIds, orderId)
+		go sendOrderRemovedEvent(order, "OrderCancelAll", now)
+	}
+	log.Info("SignedOrder/CancelAll", "trader", trader, "market", market, "nonce", nonce, "cancelledOrders", len(cancelledOrderIds))
+	return cancelledOrderIds, nil
+}
+
+// GetCancelAllMessages returns the last cancel all message of every trader for every market and for all markets
+func (db *InMemoryDatabase) GetCancelAllMessages() []*hu.CancelAllOrders {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	messages := []*hu.CancelAllOrders{}
+	for _, cancelNonce := range db.CancelNonces {
+		messages = append(messages, cancelNonce.Messages...)
+	}
+	return messages
+}
+
+// UpdateHeartbeat sets the deadline of the trader's dead man's switch to the deadline of a heartbeat sent to this node or gossiped by
+// another node, or unregisters the switch if the timeout of the heartbeat is 0. The trader can place orders again after a heartbeat that
+// follows the switch being triggered.
+// Returns hu.ErrStaleHeartbeat if the heartbeat is not newer than the last heartbeat of the trader, e.g. when it is gossiped again
+func (db *InMemoryDatabase) UpdateHeartbeat(heartbeat *hu.Heartbeat) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if last := db.Heartbeats[heartbeat.Trader]; last != nil && heartbeat.Timestamp <= last.Heartbeat.Timestamp {
+		return hu.ErrStaleHeartbeat
+	}
+	if heartbeat.Timeout == 0 {
+		delete(db.Heartbeats, heartbeat.Trader)
+		return nil
+	}
+	db.Heartbeats[heartbeat.Trader] = &TraderHeartbeat{Heartbeat: *heartbeat}
+	return nil
+}
+
+// CancelOrdersOfExpiredHeartbeats triggers the dead man's switch of the traders whose last heartbeat is past its deadline. It cancels the
+// signed orders of the trader that this node received before the deadline. Orders received after it were accepted before the switch was
+// triggered and are kept, the trader might have sent a newer heartbeat that didn't reach this node yet.
+// Returns the cancelled orders
+func (db *InMemoryDatabase) CancelOrdersOfExpiredHeartbeats() []common.Hash {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	now := time.Now().Unix()
+	cancelledOrderIds := []common.Hash{}
+	for trader, traderHeartbeat := range db.Heartbeats {
+		deadline := traderHeartbeat.Heartbeat.Deadline()
+		if traderHeartbeat.Expired || deadline >= uint64(now) {
+			continue
+		}
+		traderHeartbeat.Expired = true
+		heartbeatExpiredCounter.Inc(1)
+
+		numCancelled := 0
+		for orderId := range db.traderOrders[trader] {
+			order := db.Orders[orderId]
+			if order == nil || order.OrderType != Signed || order.getOrderStatus().Status != Placed || order.PlacedAt > deadline {
+				continue
 			}
-			return false
-		})
+			db.cancelSignedOrderWithoutLock(order, &Lifecycle{BlockNumber: db.acceptedBlockNumber, Status: Cancelled, Reason: ReasonHeartbeatExpired, Info: fmt.Sprintf("deadline %d", deadline)})
+			cancelledOrderIds = append(cancelledOrderIds, orderId)
+			numCancelled++
+			go sendOrderRemovedEvent(order, "OrderHeartbeatExpired", now)
+		}
+		log.Info("SignedOrder/HeartbeatExpired", "trader", trader, "deadline", deadline, "cancelledOrders", numCancelled)
 	}
+	return cancelledOrderIds
+}
 
-	// Insert the order at the determined position
-	orders = append(orders, &Order{})            // Add an empty order to the end
-	copy(orders[position+1:], orders[position:]) // Shift orders to the right
-	orders[position] = order                     // Insert new Order at the right position
+// CancelSelfTradeOrder removes a signed or trigger order that self trade prevention cancelled in the matching engine.
+// Orders placed on chain can't be cancelled by a validator, so they are only left out of the matching run
+func (db *InMemoryDatabase) CancelSelfTradeOrder(orderId common.Hash, mode hu.SelfTradePreventionMode) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
 
-	if order.PositionType == LONG {
-		db.LongOrders[market] = orders
+	order := db.Orders[orderId]
+	if order == nil || order.getOrderStatus().Status != Placed {
+		return fmt.Errorf("invalid orderId %s", orderId.Hex())
+	}
+	if order.OrderType != Signed && order.OrderType != Trigger {
+		return fmt.Errorf("%s orders can't be cancelled by self trade prevention", order.OrderType)
+	}
+	lifecycle := &Lifecycle{BlockNumber: db.acceptedBlockNumber, Status: Cancelled, Reason: ReasonSelfTrade, Info: mode.String()}
+	if order.OrderType == Signed {
+		db.cancelSignedOrderWithoutLock(order, lifecycle)
 	} else {
-		db.ShortOrders[market] = orders
+		db.removeOrderWithoutLock(order, lifecycle)
+	}
+	go sendOrderRemovedEvent(order, "OrderSelfTradePrevented", time.Now().Unix())
+	return nil
+}
+
+// AddTriggerOrder stores a trigger order off the order book till ActivateTriggeredOrders finds that the oracle price has reached its trigger price.
+// No margin is reserved for a trigger order, it is checked when the order is matched like an IOC order.
+func (db *InMemoryDatabase) AddTriggerOrder(order *Order) {
+	if order.OrderType != Trigger {
+		log.Error("In AddTriggerOrder - order type is not Trigger", "order", order)
+		return
+	}
+	log.Info("TriggerOrder/OrderAccepted", "order", order)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	order.LifecycleList = append(order.LifecycleList, Lifecycle{BlockNumber: order.BlockNumber.Uint64(), Status: Placed})
+	db.TriggerOrders[order.Id] = order
+}
+
+// ActivateTriggeredOrders moves the trigger orders whose trigger price has been reached at the given oracle prices into the order book.
+// The block number of an order stays the block it was placed in, blockNumber is only recorded in its lifecycle. Triggered orders are matched
+// after the orders resting in the book because compareBlockPlaced treats them as takers, same as the juror.
+// Returns the activated orders
+func (db *InMemoryDatabase) ActivateTriggeredOrders(oraclePrices map[Market]*big.Int, blockNumber uint64) []Order {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	activatedOrders := []Order{}
+	for orderId, order := range db.TriggerOrders {
+		if order.getOrderStatus().Status != Placed || !order.RawOrder.(*hu.TriggerOrder).IsTriggered(oraclePrices[order.Market]) {
+			continue
+		}
+		delete(db.TriggerOrders, orderId)
+		order.LifecycleList = append(order.LifecycleList, Lifecycle{BlockNumber: blockNumber, Status: Placed, Info: "triggered", Reason: ReasonTriggered})
+		db.AddInSortedArray(order)
+		db.Orders[orderId] = order
+		db.addToTraderIndex(order)
+		activatedOrders = append(activatedOrders, deepCopyOrder(order))
+		log.Info("TriggerOrder/OrderTriggered", "order", order, "oraclePrice", oraclePrices[order.Market])
+	}
+	return activatedOrders
+}
+
+func (db *InMemoryDatabase) addOrderWithoutLock(order *Order) {
+	if order.PlacedAt == 0 {
+		order.PlacedAt = uint64(time.Now().Unix())
+	}
+	order.LifecycleList = append(order.LifecycleList, Lifecycle{BlockNumber: order.BlockNumber.Uint64(), Status: Placed})
+	db.AddInSortedArray(order)
+	db.Orders[order.Id] = order
+	db.addToTraderIndex(order)
+}
+
+// caller is expected to acquire db.mu before calling this function
+func (db *InMemoryDatabase) addToTraderIndex(order *Order) {
+	orderIds, ok := db.traderOrders[order.Trader]
+	if !ok {
+		orderIds = map[common.Hash]struct{}{}
+		db.traderOrders[order.Trader] = orderIds
+	}
+	orderIds[order.Id] = struct{}{}
+}
+
+// caller is expected to acquire db.mu before calling this function
+func (db *InMemoryDatabase) removeFromTraderIndex(trader common.Address, orderId common.Hash) {
+	orderIds, ok := db.traderOrders[trader]
+	if !ok {
+		return
+	}
+	delete(orderIds, orderId)
+	if len(orderIds) == 0 {
+		delete(db.traderOrders, trader)
+	}
+}
+
+// caller is expected to acquire db.mu before calling this function
+func (db *InMemoryDatabase) AddInSortedArray(order *Order) {
+	db.getOrderBookSide(order.Market, order.PositionType).add(order)
+	db.publishDepthUpdate(order)
+}
+
+// caller is expected to acquire db.mu before calling this function
+func (db *InMemoryDatabase) getOrderBookSide(market Market, positionType PositionType) *orderBookSide {
+	sides := db.shortOrders
+	if positionType == LONG {
+		sides = db.longOrders
+	}
+	side, ok := sides[market]
+	if !ok {
+		side = newOrderBookSide(positionType)
+		sides[market] = side
 	}
+	return side
 }
 
 func (db *InMemoryDatabase) Delete(orderId common.Hash) {
@@ -524,6 +1060,11 @@ func (db *InMemoryDatabase) Delete(orderId common.Hash) {
 }
 
 func (db *InMemoryDatabase) deleteOrderWithoutLock(orderId common.Hash) {
+	if _, ok := db.TriggerOrders[orderId]; ok {
+		delete(db.TriggerOrders, orderId)
+		return
+	}
+
 	order := db.Orders[orderId]
 	if order == nil {
 		log.Error("In Delete - orderId does not exist in the db.Orders", "orderId", orderId.Hex())
@@ -531,30 +1072,14 @@ func (db *InMemoryDatabase) deleteOrderWithoutLock(orderId common.Hash) {
 		return
 	}
 
-	market := order.Market
-	if order.PositionType == LONG {
-		orders := db.LongOrders[market]
-		idx := getOrderIdx(orders, orderId)
-		if idx == -1 {
-			log.Error("In Delete - orderId does not exist in the db.LongOrders", "orderId", orderId.Hex())
-			deleteOrderIdNotFoundCounter.Inc(1)
-		} else {
-			orders = append(orders[:idx], orders[idx+1:]...)
-			db.LongOrders[market] = orders
-		}
-	} else {
-		orders := db.ShortOrders[market]
-		idx := getOrderIdx(orders, orderId)
-		if idx == -1 {
-			log.Error("In Delete - orderId does not exist in the db.ShortOrders", "orderId", orderId.Hex())
-			deleteOrderIdNotFoundCounter.Inc(1)
-		} else {
-			orders = append(orders[:idx], orders[idx+1:]...)
-			db.ShortOrders[market] = orders
-		}
+	if !db.getOrderBookSide(order.Market, order.PositionType).remove(orderId) {
+		log.Error("In Delete - orderId does not exist in the order book", "orderId", orderId.Hex(), "positionType", order.PositionType)
+		deleteOrderIdNotFoundCounter.Inc(1)
 	}
 
 	delete(db.Orders, orderId)
+	db.removeFromTraderIndex(order.Trader, orderId)
+	db.publishDepthUpdate(order)
 
 	if order.OrderType == Signed && !order.ReduceOnly {
 		minAllowableMargin := db.configService.GetMinAllowableMargin()
@@ -564,6 +1089,12 @@ func (db *InMemoryDatabase) deleteOrderWithoutLock(orderId common.Hash) {
 }
 
 func (db *InMemoryDatabase) UpdateFilledBaseAssetQuantity(quantity *big.Int, orderId common.Hash, blockNumber uint64) {
+	db.UpdateOrderFill(quantity, orderId, Lifecycle{BlockNumber: blockNumber})
+}
+
+// UpdateOrderFill adds quantity to the filled quantity of an order and a fill to its lifecycle with the price, counterparty and tx hash of fill.
+// A negative quantity reverts the last fill at the block number of fill when its event is removed in a reorg
+func (db *InMemoryDatabase) UpdateOrderFill(quantity *big.Int, orderId common.Hash, fill Lifecycle) {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
@@ -580,14 +1111,20 @@ func (db *InMemoryDatabase) UpdateFilledBaseAssetQuantity(quantity *big.Int, ord
 		order.FilledBaseAssetQuantity.Sub(order.FilledBaseAssetQuantity, quantity) // filled = filled - quantity
 	}
 
-	if order.BaseAssetQuantity.Cmp(order.FilledBaseAssetQuantity) == 0 {
-		order.LifecycleList = append(order.LifecycleList, Lifecycle{blockNumber, FulFilled, ""})
+	if quantity.Sign() > 0 {
+		fill.Status, fill.Reason = Placed, ReasonPartialFill
+		if order.BaseAssetQuantity.Cmp(order.FilledBaseAssetQuantity) == 0 {
+			fill.Status, fill.Reason = FulFilled, ReasonFill
+		}
+		fill.FillAmount = new(big.Int).Set(quantity)
+		order.LifecycleList = append(order.LifecycleList, fill)
 	}
 
-	if quantity.Cmp(big.NewInt(0)) == -1 && order.getOrderStatus().Status == FulFilled {
-		// handling reorgs
-		order.LifecycleList = order.LifecycleList[:len(order.LifecycleList)-1]
+	if quantity.Sign() < 0 {
+		// handling reorgs; removed events are processed in reverse order, so the last fill of the block is the one being removed
+		order.removeLifecycle(fill.BlockNumber, true)
 	}
+	db.publishDepthUpdate(order)
 
 	// only update margin if the order is not reduce-only
 	if order.OrderType == Signed && !order.ReduceOnly {
@@ -649,12 +1186,10 @@ func (db *InMemoryDatabase) GetLongOrders(market Market, lowerbound *big.Int, bl
 func (db *InMemoryDatabase) getLongOrdersWithoutLock(market Market, lowerbound *big.Int, blockNumber *big.Int, shouldClean bool) []Order {
 	var longOrders []Order
 
-	marketOrders := db.LongOrders[market]
-	// log.Info("getLongOrdersWithoutLock", "marketOrders", marketOrders, "lowerbound", lowerbound, "blockNumber", blockNumber)
-	for _, order := range marketOrders {
+	db.longOrders[market].ascend(func(order *Order) bool {
 		if lowerbound != nil && order.Price.Cmp(lowerbound) < 0 {
 			// because the long orders are sorted in descending order of price, there is no point in checking further
-			break
+			return false
 		}
 
 		if shouldClean {
@@ -664,7 +1199,8 @@ func (db *InMemoryDatabase) getLongOrdersWithoutLock(market Market, lowerbound *
 		} else {
 			longOrders = append(longOrders, deepCopyOrder(order))
 		}
-	}
+		return true
+	})
 	return longOrders
 }
 
@@ -677,12 +1213,10 @@ func (db *InMemoryDatabase) GetShortOrders(market Market, upperbound *big.Int, b
 func (db *InMemoryDatabase) getShortOrdersWithoutLock(market Market, upperbound *big.Int, blockNumber *big.Int, shouldClean bool) []Order {
 	var shortOrders []Order
 
-	marketOrders := db.ShortOrders[market]
-
-	for _, order := range marketOrders {
+	db.shortOrders[market].ascend(func(order *Order) bool {
 		if upperbound != nil && order.Price.Cmp(upperbound) > 0 {
 			// short orders are sorted in ascending order of price
-			break
+			return false
 		}
 		if shouldClean {
 			if _order := db.getCleanOrder(order, blockNumber); _order != nil {
@@ -691,7 +1225,8 @@ func (db *InMemoryDatabase) getShortOrdersWithoutLock(market Market, upperbound
 		} else {
 			shortOrders = append(shortOrders, deepCopyOrder(order))
 		}
-	}
+		return true
+	})
 	return shortOrders
 }
 
@@ -799,6 +1334,7 @@ func (db *InMemoryDatabase) UpdatePosition(trader common.Address, market Market,
 
 	db.TraderMap[trader].Positions[market].Size = size
 	db.TraderMap[trader].Positions[market].OpenNotional = openNotional
+	db.positionChangedTraders[trader] = struct{}{}
 
 	if !isLiquidation {
 		db.TraderMap[trader].Positions[market].LiquidationThreshold = getLiquidationThreshold(db.configService.getMaxLiquidationRatio(market), db.configService.getMinSizeRequirement(market), size)
@@ -907,6 +1443,23 @@ func (db *InMemoryDatabase) GetAllTraders() map[common.Address]Trader {
 	return traderMap
 }
 
+// VerifyState compares the traders and orders with the contract storage in stateDB. The read lock is held for the whole pass,
+// so that the memory DB isn't updated in the middle of it
+func (db *InMemoryDatabase) VerifyState(stateDB contract.StateDB, blockNumber uint64) *StateVerificationReport {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	traders := make(map[common.Address]Trader, len(db.TraderMap))
+	for address, trader := range db.TraderMap {
+		traders[address] = *trader
+	}
+	orders := make([]Order, 0, len(db.Orders))
+	for _, order := range db.Orders {
+		orders = append(orders, *order)
+	}
+	return verifyState(stateDB, blockNumber, traders, orders)
+}
+
 func (db *InMemoryDatabase) GetOpenOrdersForTraderByType(trader common.Address, orderType OrderType) []Order {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
@@ -925,7 +1478,7 @@ func (db *InMemoryDatabase) GetOrderById(orderId common.Hash) *Order {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
-	order := db.Orders[orderId]
+	order := db.getOrderWithoutLock(orderId)
 	if order == nil {
 		return nil
 	}
@@ -1101,10 +1654,19 @@ func (db *InMemoryDatabase) determineOrdersToCancel(addr common.Address, trader
 	return foundCancellableOrders
 }
 
+// autoCancelReason is the reason determineOrdersToCancel chose an order that was cancelled by a validator
+func autoCancelReason(order *Order) LifecycleReason {
+	if order != nil && order.ReduceOnly {
+		return ReasonStaleReduceOnly
+	}
+	return ReasonInsufficientMargin
+}
+
 func (db *InMemoryDatabase) getTraderOrders(trader common.Address, orderType OrderType) []Order {
 	traderOrders := []Order{}
-	for _, order := range db.Orders {
-		if order.Trader == trader && order.OrderType == orderType {
+	for orderId := range db.traderOrders[trader] {
+		order := db.Orders[orderId]
+		if order != nil && order.OrderType == orderType {
 			traderOrders = append(traderOrders, deepCopyOrder(order))
 		}
 	}
@@ -1113,8 +1675,8 @@ func (db *InMemoryDatabase) getTraderOrders(trader common.Address, orderType Ord
 
 func (db *InMemoryDatabase) getAllTraderOrders(trader common.Address) []Order {
 	traderOrders := []Order{}
-	for _, order := range db.Orders {
-		if order.Trader == trader {
+	for orderId := range db.traderOrders[trader] {
+		if order := db.Orders[orderId]; order != nil {
 			traderOrders = append(traderOrders, deepCopyOrder(order))
 		}
 	}
@@ -1148,11 +1710,85 @@ func (db *InMemoryDatabase) getReduceOnlyOrderDisplay(order *Order) *Order {
 	}
 }
 
+// GetOrderBookData returns a copy of the memory DB that is taken under one read lock, so that it can be marshalled while the DB is updated.
+// The orders are added to the book of the copy in the priority order of the DB
 func (db *InMemoryDatabase) GetOrderBookData() InMemoryDatabase {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
-	return *db
+	memoryDBCopy := NewInMemoryDatabase(db.configService)
+	memoryDBCopy.NextFundingTime = db.NextFundingTime
+	memoryDBCopy.NextSamplePITime = db.NextSamplePITime
+	memoryDBCopy.SamplePIAttemptedTime = db.SamplePIAttemptedTime
+	for orderId, order := range db.Orders {
+		orderCopy := deepCopyOrder(order)
+		memoryDBCopy.Orders[orderId] = &orderCopy
+	}
+	for _, sides := range []map[Market]*orderBookSide{db.longOrders, db.shortOrders} {
+		for _, side := range sides {
+			for _, order := range side.orders() {
+				orderCopy := memoryDBCopy.Orders[order.Id]
+				memoryDBCopy.getOrderBookSide(order.Market, order.PositionType).add(orderCopy)
+				memoryDBCopy.addToTraderIndex(orderCopy)
+			}
+		}
+	}
+	for orderId, order := range db.TriggerOrders {
+		orderCopy := deepCopyOrder(order)
+		memoryDBCopy.TriggerOrders[orderId] = &orderCopy
+	}
+	for addr, trader := range db.TraderMap {
+		memoryDBCopy.TraderMap[addr] = deepCopyTrader(trader)
+	}
+	for market, lastPrice := range db.LastPrice {
+		memoryDBCopy.LastPrice[market] = new(big.Int).Set(lastPrice)
+	}
+	for market, cumulativePremiumFraction := range db.CumulativePremiumFraction {
+		memoryDBCopy.CumulativePremiumFraction[market] = new(big.Int).Set(cumulativePremiumFraction)
+	}
+	for trader, cancelNonce := range db.CancelNonces {
+		nonceCopy := &CancelNonce{AllMarkets: cancelNonce.AllMarkets, Markets: map[Market]*big.Int{}, Messages: append([]*hu.CancelAllOrders{}, cancelNonce.Messages...)}
+		for market, nonce := range cancelNonce.Markets {
+			nonceCopy.Markets[market] = nonce
+		}
+		memoryDBCopy.CancelNonces[trader] = nonceCopy
+	}
+	for trader, heartbeat := range db.Heartbeats {
+		heartbeatCopy := *heartbeat
+		memoryDBCopy.Heartbeats[trader] = &heartbeatCopy
+	}
+	for orderId, cancelOrder := range db.SignedOrderCancellations {
+		// cancel messages are not modified after they are added
+		memoryDBCopy.SignedOrderCancellations[orderId] = cancelOrder
+	}
+	for orderId, expireAt := range db.CancelledSignedOrders {
+		memoryDBCopy.CancelledSignedOrders[orderId] = expireAt
+	}
+	return *memoryDBCopy
+}
+
+// MarshalJSON includes both sides of the book as lists of orders sorted by matching priority.
+// It doesn't take the lock of the DB, so it must only be called on a copy, see GetOrderBookData
+func (db InMemoryDatabase) MarshalJSON() ([]byte, error) {
+	longOrders := map[Market][]*Order{}
+	for market, side := range db.longOrders {
+		longOrders[market] = side.orders()
+	}
+	shortOrders := map[Market][]*Order{}
+	for market, side := range db.shortOrders {
+		shortOrders[market] = side.orders()
+	}
+
+	type inMemoryDatabase InMemoryDatabase // to not recurse into this method
+	return json.Marshal(&struct {
+		*inMemoryDatabase
+		LongOrders  map[Market][]*Order `json:"long_orders"`
+		ShortOrders map[Market][]*Order `json:"short_orders"`
+	}{
+		inMemoryDatabase: (*inMemoryDatabase)(&db),
+		LongOrders:       longOrders,
+		ShortOrders:      shortOrders,
+	})
 }
 
 func (db *InMemoryDatabase) GetOrderBookDataCopy() (*InMemoryDatabase, error) {
@@ -1174,6 +1810,23 @@ func (db *InMemoryDatabase) GetOrderBookDataCopy() (*InMemoryDatabase, error) {
 
 	memoryDBCopy.mu = &sync.RWMutex{}
 	memoryDBCopy.configService = db.configService
+	if memoryDBCopy.TriggerOrders == nil {
+		// gob doesn't encode empty maps
+		memoryDBCopy.TriggerOrders = map[common.Hash]*Order{}
+	}
+	if memoryDBCopy.CancelNonces == nil {
+		memoryDBCopy.CancelNonces = map[common.Address]*CancelNonce{}
+	}
+	if memoryDBCopy.Heartbeats == nil {
+		memoryDBCopy.Heartbeats = map[common.Address]*TraderHeartbeat{}
+	}
+	if memoryDBCopy.SignedOrderCancellations == nil {
+		memoryDBCopy.SignedOrderCancellations = map[common.Hash]*hu.CancelOrder{}
+	}
+	if memoryDBCopy.CancelledSignedOrders == nil {
+		memoryDBCopy.CancelledSignedOrders = map[common.Hash]uint64{}
+	}
+	memoryDBCopy.rebuildIndexes()
 	return memoryDBCopy, nil
 }
 
@@ -1212,21 +1865,21 @@ func getAvailableMargin(trader *Trader, hState *hu.HubbleState) *big.Int {
 
 // deepCopyOrder deep copies the LimitOrder struct
 func deepCopyOrder(order *Order) Order {
-	lifecycleList := &order.LifecycleList
 	return Order{
 		Id:                      order.Id,
 		Market:                  order.Market,
 		PositionType:            order.PositionType,
 		Trader:                  order.Trader,
-		BaseAssetQuantity:       big.NewInt(0).Set(order.BaseAssetQuantity),
-		FilledBaseAssetQuantity: big.NewInt(0).Set(order.FilledBaseAssetQuantity),
-		Salt:                    big.NewInt(0).Set(order.Salt),
-		Price:                   big.NewInt(0).Set(order.Price),
+		BaseAssetQuantity:       copyBigInt(order.BaseAssetQuantity),
+		FilledBaseAssetQuantity: copyBigInt(order.FilledBaseAssetQuantity),
+		Salt:                    copyBigInt(order.Salt),
+		Price:                   copyBigInt(order.Price),
 		ReduceOnly:              order.ReduceOnly,
-		LifecycleList:           *lifecycleList,
-		BlockNumber:             big.NewInt(0).Set(order.BlockNumber),
+		LifecycleList:           append([]Lifecycle{}, order.LifecycleList...),
+		BlockNumber:             copyBigInt(order.BlockNumber),
 		RawOrder:                order.RawOrder,
 		OrderType:               order.OrderType,
+		PlacedAt:                order.PlacedAt,
 	}
 }
 
@@ -1235,21 +1888,22 @@ func deepCopyTrader(order *Trader) *Trader {
 	for market, position := range order.Positions {
 		positions[market] = &Position{
 			Position: hu.Position{
-				OpenNotional: big.NewInt(0).Set(position.OpenNotional),
-				Size:         big.NewInt(0).Set(position.Size),
+				OpenNotional: copyBigInt(position.OpenNotional),
+				Size:         copyBigInt(position.Size),
 			},
-			UnrealisedFunding:    big.NewInt(0).Set(position.UnrealisedFunding),
-			LastPremiumFraction:  big.NewInt(0).Set(position.LastPremiumFraction),
-			LiquidationThreshold: big.NewInt(0).Set(position.LiquidationThreshold),
+			UnrealisedFunding:    copyBigInt(position.UnrealisedFunding),
+			LastPremiumFraction:  copyBigInt(position.LastPremiumFraction),
+			LiquidationThreshold: copyBigInt(position.LiquidationThreshold),
 		}
 	}
 
 	margin := Margin{
-		Reserved:  big.NewInt(0).Set(order.Margin.Reserved),
-		Deposited: map[Collateral]*big.Int{},
+		Reserved:        copyBigInt(order.Margin.Reserved),
+		VirtualReserved: copyBigInt(order.Margin.VirtualReserved),
+		Deposited:       map[Collateral]*big.Int{},
 	}
 	for collateral, amount := range order.Margin.Deposited {
-		margin.Deposited[collateral] = big.NewInt(0).Set(amount)
+		margin.Deposited[collateral] = copyBigInt(amount)
 	}
 	return &Trader{
 		Positions: positions,
@@ -1257,18 +1911,63 @@ func deepCopyTrader(order *Trader) *Trader {
 	}
 }
 
-func getOrderIdx(orders []*Order, orderId common.Hash) int {
-	for i, order := range orders {
-		if order.Id == orderId {
-			return i
-		}
+// copyBigInt returns a copy of n, or nil if n is nil
+func copyBigInt(n *big.Int) *big.Int {
+	if n == nil {
+		return nil
+	}
+	return new(big.Int).Set(n)
+}
+
+// publishDepthUpdate sends the new quantity at the price level of order to the depth subscribers.
+// It is called after every mutation of an order in the order book; caller is expected to acquire db.mu before calling this function
+func (db *InMemoryDatabase) publishDepthUpdate(order *Order) {
+	if db.depthFeed == nil || (order.OrderType == Trigger && db.TriggerOrders[order.Id] != nil) {
+		// copies of the db don't have subscribers, and dormant trigger orders are not part of the order book
+		return
+	}
+	sides := db.shortOrders
+	if order.PositionType == LONG {
+		sides = db.longOrders
+	}
+	db.depthFeed.publish(order.Market, order.PositionType, order.Price, sides[order.Market].quantity(order.Price))
+}
+
+func (db *InMemoryDatabase) GetDepthSnapshot(market Market) DepthSnapshot {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.getDepthSnapshotWithoutLock(market)
+}
+
+// SubscribeDepthUpdates returns the depth of market and a subscription to the updates that follow it, without missing any in between
+func (db *InMemoryDatabase) SubscribeDepthUpdates(market Market) (DepthSnapshot, *DepthSubscription) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.getDepthSnapshotWithoutLock(market), db.depthFeed.subscribe(market)
+}
+
+func (db *InMemoryDatabase) getDepthSnapshotWithoutLock(market Market) DepthSnapshot {
+	var lastUpdateID uint64
+	if db.depthFeed != nil {
+		lastUpdateID = db.depthFeed.lastUpdateID(market)
+	}
+	return DepthSnapshot{
+		Depth: &MarketDepth{
+			Market: market,
+			Longs:  db.longOrders[market].depth(),
+			Shorts: db.shortOrders[market].depth(),
+		},
+		LastUpdateID: lastUpdateID,
 	}
-	return -1
 }
 
 type OrderValidationFields struct {
 	Exists                bool
+	Cancelled             bool // the order was amended or cancelled without a transaction, see SignedOrderCancellations and CancelledSignedOrders
 	PosSize               *big.Int
+	ReduceOnlyAmount      *big.Int // sum of unfilled signed reduce only orders of the trader in the market
+	CancelNonce           *big.Int // nonce of the trader's last signed cancel all message for the market, nil if there was none
+	HeartbeatExpired      bool     // the trader's dead man's switch was triggered on this node and there was no heartbeat since
 	AsksHead              *big.Int
 	BidsHead              *big.Int
 	ShouldTriggerMatching bool
@@ -1281,6 +1980,13 @@ func (db *InMemoryDatabase) GetOrderValidationFields(orderId common.Hash, order
 	if db.Orders[orderId] != nil {
 		return OrderValidationFields{Exists: true}
 	}
+	// an amended or cancelled order can't be placed again
+	if _, cancelled := db.SignedOrderCancellations[orderId]; cancelled {
+		return OrderValidationFields{Cancelled: true}
+	}
+	if _, cancelled := db.CancelledSignedOrders[orderId]; cancelled {
+		return OrderValidationFields{Cancelled: true}
+	}
 
 	// trader data
 	trader := order.Trader
@@ -1289,6 +1995,13 @@ func (db *InMemoryDatabase) GetOrderValidationFields(orderId common.Hash, order
 	if db.TraderMap[trader] != nil && db.TraderMap[trader].Positions[marketId] != nil && db.TraderMap[trader].Positions[marketId].Size != nil {
 		posSize = db.TraderMap[trader].Positions[marketId].Size
 	}
+	reduceOnlyAmount := big.NewInt(0)
+	for _, _order := range db.getTraderOrders(trader, Signed) {
+		if _order.ReduceOnly && _order.Market == Market(marketId) {
+			reduceOnlyAmount.Add(reduceOnlyAmount, _order.GetUnFilledBaseAssetQuantity())
+		}
+	}
+	heartbeat := db.Heartbeats[trader]
 
 	// market data
 	// allow some grace to market orders to be filled and accept post-only orders that might fill them
@@ -1296,32 +2009,37 @@ func (db *InMemoryDatabase) GetOrderValidationFields(orderId common.Hash, order
 	isLongOrder := order.BaseAssetQuantity.Sign() > 0
 	shouldTriggerMatching := false
 	asksHead := big.NewInt(0)
-	if isLongOrder && len(db.ShortOrders[marketId]) > 0 {
-		for _, _order := range db.ShortOrders[marketId] {
-			if _order.OrderType != IOC {
+	if isLongOrder {
+		db.shortOrders[marketId].ascend(func(_order *Order) bool {
+			if _order.OrderType != IOC && _order.OrderType != Trigger {
 				asksHead = _order.Price
-				break
+				return false
 			} else if _order.Price.Cmp(order.Price) <= 0 {
 				shouldTriggerMatching = true
 			}
-		}
+			return true
+		})
 	}
 
 	bidsHead := big.NewInt(0)
-	if !isLongOrder && len(db.LongOrders[marketId]) > 0 {
-		for _, _order := range db.LongOrders[marketId] {
-			if _order.OrderType != IOC {
+	if !isLongOrder {
+		db.longOrders[marketId].ascend(func(_order *Order) bool {
+			if _order.OrderType != IOC && _order.OrderType != Trigger {
 				bidsHead = _order.Price
-				break
+				return false
 			} else if _order.Price.Cmp(order.Price) >= 0 {
 				shouldTriggerMatching = true
 			}
-		}
+			return true
+		})
 	}
 
 	return OrderValidationFields{
 		Exists:                false,
 		PosSize:               posSize,
+		ReduceOnlyAmount:      reduceOnlyAmount,
+		CancelNonce:           db.CancelNonces[trader].get(Market(marketId)),
+		HeartbeatExpired:      heartbeat != nil && heartbeat.Expired,
 		AsksHead:              asksHead,
 		BidsHead:              bidsHead,
 		ShouldTriggerMatching: shouldTriggerMatching,
@@ -1,6 +1,7 @@
 package orderbook
 
 import (
+	"encoding/json"
 	"math/big"
 	"math/rand"
 	"testing"
@@ -36,48 +37,48 @@ func TestAddSequence(t *testing.T) {
 		db.Add(&order1)
 
 		assert.Equal(t, 1, len(db.Orders))
-		assert.Equal(t, 1, len(db.LongOrders[market]))
-		assert.Equal(t, db.LongOrders[market][0].Id, order1.Id)
+		assert.Equal(t, 1, db.longOrders[market].len())
+		assert.Equal(t, db.longOrders[market].orders()[0].Id, order1.Id)
 
 		order2 := createLimitOrder(LONG, userAddress, baseAssetQuantity, big.NewInt(21), status, big.NewInt(2), big.NewInt(2))
 		db.Add(&order2)
 
 		assert.Equal(t, 2, len(db.Orders))
-		assert.Equal(t, 2, len(db.LongOrders[market]))
-		assert.Equal(t, db.LongOrders[market][0].Id, order2.Id)
-		assert.Equal(t, db.LongOrders[market][1].Id, order1.Id)
+		assert.Equal(t, 2, db.longOrders[market].len())
+		assert.Equal(t, db.longOrders[market].orders()[0].Id, order2.Id)
+		assert.Equal(t, db.longOrders[market].orders()[1].Id, order1.Id)
 
 		order3 := createLimitOrder(LONG, userAddress, baseAssetQuantity, big.NewInt(19), status, big.NewInt(2), big.NewInt(3))
 		db.Add(&order3)
 
 		assert.Equal(t, 3, len(db.Orders))
-		assert.Equal(t, 3, len(db.LongOrders[market]))
-		assert.Equal(t, db.LongOrders[market][0].Id, order2.Id)
-		assert.Equal(t, db.LongOrders[market][1].Id, order1.Id)
-		assert.Equal(t, db.LongOrders[market][2].Id, order3.Id)
+		assert.Equal(t, 3, db.longOrders[market].len())
+		assert.Equal(t, db.longOrders[market].orders()[0].Id, order2.Id)
+		assert.Equal(t, db.longOrders[market].orders()[1].Id, order1.Id)
+		assert.Equal(t, db.longOrders[market].orders()[2].Id, order3.Id)
 
 		// block number
 		order4 := createLimitOrder(LONG, userAddress, baseAssetQuantity, big.NewInt(20), status, big.NewInt(3), big.NewInt(4))
 		db.Add(&order4)
 
 		assert.Equal(t, 4, len(db.Orders))
-		assert.Equal(t, 4, len(db.LongOrders[market]))
-		assert.Equal(t, db.LongOrders[market][0].Id, order2.Id)
-		assert.Equal(t, db.LongOrders[market][1].Id, order1.Id)
-		assert.Equal(t, db.LongOrders[market][2].Id, order4.Id)
-		assert.Equal(t, db.LongOrders[market][3].Id, order3.Id)
+		assert.Equal(t, 4, db.longOrders[market].len())
+		assert.Equal(t, db.longOrders[market].orders()[0].Id, order2.Id)
+		assert.Equal(t, db.longOrders[market].orders()[1].Id, order1.Id)
+		assert.Equal(t, db.longOrders[market].orders()[2].Id, order4.Id)
+		assert.Equal(t, db.longOrders[market].orders()[3].Id, order3.Id)
 
 		// ioc order
 		order5 := createIOCOrder(LONG, userAddress, baseAssetQuantity, big.NewInt(20), status, big.NewInt(2), big.NewInt(5), big.NewInt(2))
 		db.Add(&order5)
 
 		assert.Equal(t, 5, len(db.Orders))
-		assert.Equal(t, 5, len(db.LongOrders[market]))
-		assert.Equal(t, db.LongOrders[market][0].Id, order2.Id)
-		assert.Equal(t, db.LongOrders[market][1].Id, order5.Id)
-		assert.Equal(t, db.LongOrders[market][2].Id, order1.Id)
-		assert.Equal(t, db.LongOrders[market][3].Id, order4.Id)
-		assert.Equal(t, db.LongOrders[market][4].Id, order3.Id)
+		assert.Equal(t, 5, db.longOrders[market].len())
+		assert.Equal(t, db.longOrders[market].orders()[0].Id, order2.Id)
+		assert.Equal(t, db.longOrders[market].orders()[1].Id, order5.Id)
+		assert.Equal(t, db.longOrders[market].orders()[2].Id, order1.Id)
+		assert.Equal(t, db.longOrders[market].orders()[3].Id, order4.Id)
+		assert.Equal(t, db.longOrders[market].orders()[4].Id, order3.Id)
 	})
 
 	t.Run("Short orders", func(t *testing.T) {
@@ -86,48 +87,48 @@ func TestAddSequence(t *testing.T) {
 		db.Add(&order1)
 
 		assert.Equal(t, 6, len(db.Orders))
-		assert.Equal(t, 1, len(db.ShortOrders[market]))
-		assert.Equal(t, db.ShortOrders[market][0].Id, order1.Id)
+		assert.Equal(t, 1, db.shortOrders[market].len())
+		assert.Equal(t, db.shortOrders[market].orders()[0].Id, order1.Id)
 
 		order2 := createLimitOrder(SHORT, userAddress, baseAssetQuantity, big.NewInt(19), status, big.NewInt(2), big.NewInt(7))
 		db.Add(&order2)
 
 		assert.Equal(t, 7, len(db.Orders))
-		assert.Equal(t, 2, len(db.ShortOrders[market]))
-		assert.Equal(t, db.ShortOrders[market][0].Id, order2.Id)
-		assert.Equal(t, db.ShortOrders[market][1].Id, order1.Id)
+		assert.Equal(t, 2, db.shortOrders[market].len())
+		assert.Equal(t, db.shortOrders[market].orders()[0].Id, order2.Id)
+		assert.Equal(t, db.shortOrders[market].orders()[1].Id, order1.Id)
 
 		order3 := createLimitOrder(SHORT, userAddress, baseAssetQuantity, big.NewInt(21), status, big.NewInt(2), big.NewInt(8))
 		db.Add(&order3)
 
 		assert.Equal(t, 8, len(db.Orders))
-		assert.Equal(t, 3, len(db.ShortOrders[market]))
-		assert.Equal(t, db.ShortOrders[market][0].Id, order2.Id)
-		assert.Equal(t, db.ShortOrders[market][1].Id, order1.Id)
-		assert.Equal(t, db.ShortOrders[market][2].Id, order3.Id)
+		assert.Equal(t, 3, db.shortOrders[market].len())
+		assert.Equal(t, db.shortOrders[market].orders()[0].Id, order2.Id)
+		assert.Equal(t, db.shortOrders[market].orders()[1].Id, order1.Id)
+		assert.Equal(t, db.shortOrders[market].orders()[2].Id, order3.Id)
 
 		// block number
 		order4 := createLimitOrder(SHORT, userAddress, baseAssetQuantity, big.NewInt(20), status, big.NewInt(3), big.NewInt(9))
 		db.Add(&order4)
 
 		assert.Equal(t, 9, len(db.Orders))
-		assert.Equal(t, 4, len(db.ShortOrders[market]))
-		assert.Equal(t, db.ShortOrders[market][0].Id, order2.Id)
-		assert.Equal(t, db.ShortOrders[market][1].Id, order1.Id)
-		assert.Equal(t, db.ShortOrders[market][2].Id, order4.Id)
-		assert.Equal(t, db.ShortOrders[market][3].Id, order3.Id)
+		assert.Equal(t, 4, db.shortOrders[market].len())
+		assert.Equal(t, db.shortOrders[market].orders()[0].Id, order2.Id)
+		assert.Equal(t, db.shortOrders[market].orders()[1].Id, order1.Id)
+		assert.Equal(t, db.shortOrders[market].orders()[2].Id, order4.Id)
+		assert.Equal(t, db.shortOrders[market].orders()[3].Id, order3.Id)
 
 		// ioc order
 		order5 := createIOCOrder(SHORT, userAddress, baseAssetQuantity, big.NewInt(20), status, big.NewInt(2), big.NewInt(10), big.NewInt(2))
 		db.Add(&order5)
 
 		assert.Equal(t, 10, len(db.Orders))
-		assert.Equal(t, 5, len(db.ShortOrders[market]))
-		assert.Equal(t, db.ShortOrders[market][0].Id, order2.Id)
-		assert.Equal(t, db.ShortOrders[market][1].Id, order5.Id)
-		assert.Equal(t, db.ShortOrders[market][2].Id, order1.Id)
-		assert.Equal(t, db.ShortOrders[market][3].Id, order4.Id)
-		assert.Equal(t, db.ShortOrders[market][4].Id, order3.Id)
+		assert.Equal(t, 5, db.shortOrders[market].len())
+		assert.Equal(t, db.shortOrders[market].orders()[0].Id, order2.Id)
+		assert.Equal(t, db.shortOrders[market].orders()[1].Id, order5.Id)
+		assert.Equal(t, db.shortOrders[market].orders()[2].Id, order1.Id)
+		assert.Equal(t, db.shortOrders[market].orders()[3].Id, order4.Id)
+		assert.Equal(t, db.shortOrders[market].orders()[4].Id, order3.Id)
 	})
 }
 
@@ -327,38 +328,112 @@ func TestDeleteOrders(t *testing.T) {
 	db.Add(&order6)
 
 	assert.Equal(t, 6, len(db.Orders))
-	assert.Equal(t, 3, len(db.ShortOrders[market]))
-	assert.Equal(t, 3, len(db.LongOrders[market]))
+	assert.Equal(t, 3, db.shortOrders[market].len())
+	assert.Equal(t, 3, db.longOrders[market].len())
 
 	db.Delete(order1.Id)
 	assert.Equal(t, 5, len(db.Orders))
-	assert.Equal(t, 2, len(db.ShortOrders[market]))
-	assert.Equal(t, 3, len(db.LongOrders[market]))
-	assert.Equal(t, -1, getOrderIdx(db.ShortOrders[market], order1.Id))
+	assert.Equal(t, 2, db.shortOrders[market].len())
+	assert.Equal(t, 3, db.longOrders[market].len())
+	assert.False(t, db.shortOrders[market].has(order1.Id))
 	assert.Nil(t, db.Orders[order1.Id])
 
 	db.Delete(order5.Id)
 	assert.Equal(t, 4, len(db.Orders))
-	assert.Equal(t, 2, len(db.ShortOrders[market]))
-	assert.Equal(t, 2, len(db.LongOrders[market]))
-	assert.Equal(t, -1, getOrderIdx(db.LongOrders[market], order5.Id))
+	assert.Equal(t, 2, db.shortOrders[market].len())
+	assert.Equal(t, 2, db.longOrders[market].len())
+	assert.False(t, db.longOrders[market].has(order5.Id))
 	assert.Nil(t, db.Orders[order5.Id])
 
 	db.Delete(order3.Id)
 	assert.Equal(t, 3, len(db.Orders))
-	assert.Equal(t, 1, len(db.ShortOrders[market]))
-	assert.Equal(t, 2, len(db.LongOrders[market]))
-	assert.Equal(t, -1, getOrderIdx(db.ShortOrders[market], order3.Id))
+	assert.Equal(t, 1, db.shortOrders[market].len())
+	assert.Equal(t, 2, db.longOrders[market].len())
+	assert.False(t, db.shortOrders[market].has(order3.Id))
 	assert.Nil(t, db.Orders[order3.Id])
 
 	db.Delete(order2.Id)
 	assert.Equal(t, 2, len(db.Orders))
-	assert.Equal(t, 0, len(db.ShortOrders[market]))
-	assert.Equal(t, 2, len(db.LongOrders[market]))
-	assert.Equal(t, -1, getOrderIdx(db.ShortOrders[market], order2.Id))
+	assert.Equal(t, 0, db.shortOrders[market].len())
+	assert.Equal(t, 2, db.longOrders[market].len())
+	assert.False(t, db.shortOrders[market].has(order2.Id))
 	assert.Nil(t, db.Orders[order2.Id])
 }
 
+func TestTraderOrders(t *testing.T) {
+	db := getDatabase()
+	otherTrader := "0x710bf5F942331874dcBC7783319123679033b63b"
+
+	order1 := createLimitOrder(LONG, userAddress, big.NewInt(10), big.NewInt(20), status, big.NewInt(2), big.NewInt(1))
+	order2 := createIOCOrder(SHORT, userAddress, big.NewInt(-10), big.NewInt(21), status, big.NewInt(2), big.NewInt(2), big.NewInt(5))
+	order3 := createLimitOrder(LONG, otherTrader, big.NewInt(10), big.NewInt(20), status, big.NewInt(2), big.NewInt(3))
+	db.Add(&order1)
+	db.Add(&order2)
+	db.Add(&order3)
+
+	assert.Equal(t, 2, len(db.GetAllOpenOrdersForTrader(trader)))
+	assert.Equal(t, 1, len(db.GetAllOpenOrdersForTrader(common.HexToAddress(otherTrader))))
+
+	limitOrders := db.GetOpenOrdersForTraderByType(trader, Limit)
+	assert.Equal(t, 1, len(limitOrders))
+	assert.Equal(t, order1.Id, limitOrders[0].Id)
+
+	db.UpdateFilledBaseAssetQuantity(big.NewInt(5), order1.Id, 3)
+	assert.Equal(t, 2, len(db.GetAllOpenOrdersForTrader(trader)))
+
+	db.Delete(order1.Id)
+	assert.Equal(t, 0, len(db.GetOpenOrdersForTraderByType(trader, Limit)))
+	assert.Equal(t, 1, len(db.GetAllOpenOrdersForTrader(trader)))
+
+	db.Delete(order2.Id)
+	assert.Equal(t, 0, len(db.GetAllOpenOrdersForTrader(trader)))
+	assert.NotContains(t, db.traderOrders, trader)
+}
+
+func TestGetOrderBookDataMarshalJSON(t *testing.T) {
+	db := getDatabase()
+	longOrder := createLimitOrder(LONG, userAddress, big.NewInt(10), big.NewInt(20), status, big.NewInt(2), big.NewInt(1))
+	shortOrder := createLimitOrder(SHORT, userAddress, big.NewInt(-10), big.NewInt(21), status, big.NewInt(2), big.NewInt(2))
+	db.Add(&longOrder)
+	db.Add(&shortOrder)
+
+	data := db.GetOrderBookData()
+	assert.Equal(t, 2, len(data.GetAllOpenOrdersForTrader(trader)))
+	// the copy is marshalled without the lock of the DB, so a writer doesn't block it
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	marshalled, err := json.Marshal(data)
+	assert.Nil(t, err)
+
+	var decoded struct {
+		OrderMap    map[common.Hash]json.RawMessage `json:"order_map"`
+		LongOrders  map[Market][]json.RawMessage    `json:"long_orders"`
+		ShortOrders map[Market][]json.RawMessage    `json:"short_orders"`
+	}
+	assert.Nil(t, json.Unmarshal(marshalled, &decoded))
+	assert.Equal(t, 2, len(decoded.OrderMap))
+	assert.Equal(t, 1, len(decoded.LongOrders[market]))
+	assert.Equal(t, 1, len(decoded.ShortOrders[market]))
+}
+
+func TestCancelSelfTradeOrder(t *testing.T) {
+	db := getDatabase()
+	signedOrder := createSignedOrder(LONG, userAddress, big.NewInt(10), big.NewInt(20), big.NewInt(1), false)
+	db.AddSignedOrder(&signedOrder, big.NewInt(0))
+	limitOrder := createLimitOrder(SHORT, userAddress, big.NewInt(-10), big.NewInt(20), Placed, big.NewInt(2), big.NewInt(2))
+	db.Add(&limitOrder)
+
+	assert.Nil(t, db.CancelSelfTradeOrder(signedOrder.Id, hu.CancelOldest))
+	assert.Nil(t, db.GetOrderById(signedOrder.Id))
+	removedOrders := db.PopRemovedOrders()
+	assert.Equal(t, 1, len(removedOrders))
+	assert.Equal(t, Lifecycle{Status: Cancelled, Reason: ReasonSelfTrade, Info: "cancel_oldest"}, removedOrders[0].getOrderStatus())
+
+	// orders placed on chain can't be cancelled by a validator
+	assert.NotNil(t, db.CancelSelfTradeOrder(limitOrder.Id, hu.CancelOldest))
+	assert.NotNil(t, db.GetOrderById(limitOrder.Id))
+}
+
 func TestGetCancellableOrders(t *testing.T) {
 	// also tests getTotalNotionalPositionAndUnrealizedPnl
 	inMemoryDatabase := getDatabase()
@@ -647,7 +722,7 @@ func TestRevertLastStatus(t *testing.T) {
 	t.Run("revert status for order that doesn't exist - expect error", func(t *testing.T) {
 		inMemoryDatabase := getDatabase()
 		orderId := common.BytesToHash([]byte("order id"))
-		err := inMemoryDatabase.RevertLastStatus(orderId)
+		err := inMemoryDatabase.RevertLastStatus(orderId, blockNumber.Uint64())
 
 		assert.Error(t, err)
 	})
@@ -656,7 +731,7 @@ func TestRevertLastStatus(t *testing.T) {
 		inMemoryDatabase := getDatabase()
 		orderId := addLimitOrder(inMemoryDatabase)
 
-		err := inMemoryDatabase.RevertLastStatus(orderId)
+		err := inMemoryDatabase.RevertLastStatus(orderId, blockNumber.Uint64())
 		assert.Nil(t, err)
 
 		assert.Equal(t, len(inMemoryDatabase.Orders[orderId].LifecycleList), 0)
@@ -668,7 +743,7 @@ func TestRevertLastStatus(t *testing.T) {
 		err := inMemoryDatabase.SetOrderStatus(orderId, FulFilled, "", 3)
 		assert.Nil(t, err)
 
-		err = inMemoryDatabase.RevertLastStatus(orderId)
+		err = inMemoryDatabase.RevertLastStatus(orderId, 3)
 		assert.Nil(t, err)
 
 		assert.Equal(t, len(inMemoryDatabase.Orders[orderId].LifecycleList), 1)
@@ -682,7 +757,7 @@ func TestRevertLastStatus(t *testing.T) {
 		assert.Nil(t, err)
 
 		inMemoryDatabase.Accept(3, 3)
-		err = inMemoryDatabase.RevertLastStatus(orderId)
+		err = inMemoryDatabase.RevertLastStatus(orderId, 3)
 		assert.Error(t, err)
 	})
 }
@@ -1031,21 +1106,21 @@ func TestSampleImpactPrice(t *testing.T) {
 			// 600*.5 + 520*.1 + 500*.4 = 552
 			// accNotional = 600*.5 + 520*.1 + 500*.296 = 500
 			// impact price = 500/(.5 + .1 + .296) = 558.035714
-			order1 := createLimitOrder(LONG, "0x22Bb736b64A0b4D4081E103f83bccF864F0404aa", big.NewInt(5e17), hu.Mul1e6(big.NewInt(600)), Placed, big.NewInt(2), big.NewInt(2))
+			order1 := createLimitOrder(LONG, "0x22Bb736b64A0b4D4081E103f83bccF864F0404aa", big.NewInt(5e17), hu.Mul1e6(big.NewInt(600)), Placed, big.NewInt(2), big.NewInt(1))
 			db.Add(&order1)
 			order2 := createLimitOrder(LONG, "0x22Bb736b64A0b4D4081E103f83bccF864F0404aa", big.NewInt(1e17), hu.Mul1e6(big.NewInt(520)), Placed, big.NewInt(2), big.NewInt(2))
 			db.Add(&order2)
-			order3 := createLimitOrder(LONG, "0x22Bb736b64A0b4D4081E103f83bccF864F0404aa", big.NewInt(4e17), hu.Mul1e6(big.NewInt(500)), Placed, big.NewInt(2), big.NewInt(2))
+			order3 := createLimitOrder(LONG, "0x22Bb736b64A0b4D4081E103f83bccF864F0404aa", big.NewInt(4e17), hu.Mul1e6(big.NewInt(500)), Placed, big.NewInt(2), big.NewInt(3))
 			db.Add(&order3)
 
 			// 700*.5 + 750*.1 + 800*.4 = 745
 			// accNotional = 700*.5 + 750*.1 = 425
 			// impact price = 500/(.5 + .1 + .09375) = 720.720720
-			order4 := createLimitOrder(SHORT, "0x22Bb736b64A0b4D4081E103f83bccF864F0404aa", big.NewInt(5e17), hu.Mul1e6(big.NewInt(700)), Placed, big.NewInt(2), big.NewInt(2))
+			order4 := createLimitOrder(SHORT, "0x22Bb736b64A0b4D4081E103f83bccF864F0404aa", big.NewInt(5e17), hu.Mul1e6(big.NewInt(700)), Placed, big.NewInt(2), big.NewInt(4))
 			db.Add(&order4)
-			order5 := createLimitOrder(SHORT, "0x22Bb736b64A0b4D4081E103f83bccF864F0404aa", big.NewInt(1e17), hu.Mul1e6(big.NewInt(750)), Placed, big.NewInt(2), big.NewInt(2))
+			order5 := createLimitOrder(SHORT, "0x22Bb736b64A0b4D4081E103f83bccF864F0404aa", big.NewInt(1e17), hu.Mul1e6(big.NewInt(750)), Placed, big.NewInt(2), big.NewInt(5))
 			db.Add(&order5)
-			order6 := createLimitOrder(SHORT, "0x22Bb736b64A0b4D4081E103f83bccF864F0404aa", big.NewInt(4e17), hu.Mul1e6(big.NewInt(800)), Placed, big.NewInt(2), big.NewInt(2))
+			order6 := createLimitOrder(SHORT, "0x22Bb736b64A0b4D4081E103f83bccF864F0404aa", big.NewInt(4e17), hu.Mul1e6(big.NewInt(800)), Placed, big.NewInt(2), big.NewInt(6))
 			db.Add(&order6)
 
 			impactBids, impactAsks, midPrices := db.SampleImpactPrice()
@@ -1151,3 +1226,369 @@ func TestGetOrderValidationFields(t *testing.T) {
 		db.Delete(order2.Id)
 	})
 }
+
+func createSignedOrder(positionType PositionType, userAddress string, baseAssetQuantity, price *big.Int, salt *big.Int, reduceOnly bool) Order {
+	order := createLimitOrder(positionType, userAddress, baseAssetQuantity, price, Placed, big.NewInt(0), salt)
+	order.OrderType = Signed
+	order.ReduceOnly = reduceOnly
+	order.RawOrder = &hu.SignedOrder{
+		LimitOrder: hu.LimitOrder{
+			BaseOrder: hu.BaseOrder{
+				AmmIndex:          big.NewInt(int64(order.Market)),
+				Trader:            order.Trader,
+				BaseAssetQuantity: baseAssetQuantity,
+				Price:             price,
+				Salt:              salt,
+				ReduceOnly:        reduceOnly,
+			},
+			PostOnly: true,
+		},
+		OrderType: uint8(Signed),
+		ExpireAt:  big.NewInt(time.Now().Unix() + 3600),
+	}
+	return order
+}
+
+func createReduceOnlySignedOrder(positionType PositionType, userAddress string, baseAssetQuantity, price *big.Int, salt *big.Int) Order {
+	return createSignedOrder(positionType, userAddress, baseAssetQuantity, price, salt, true)
+}
+
+func TestReduceOnlySignedOrders(t *testing.T) {
+	trader := common.HexToAddress(userAddress)
+
+	t.Run("reduce only amount is part of the validation fields", func(t *testing.T) {
+		db := getDatabase()
+		db.UpdatePosition(trader, market, big.NewInt(10), big.NewInt(100), false, 0)
+		order1 := createReduceOnlySignedOrder(SHORT, userAddress, big.NewInt(-4), big.NewInt(10), big.NewInt(1))
+		db.AddSignedOrder(&order1, big.NewInt(0))
+
+		newOrder := createReduceOnlySignedOrder(SHORT, userAddress, big.NewInt(-3), big.NewInt(11), big.NewInt(2))
+		fields := db.GetOrderValidationFields(newOrder.Id, newOrder.RawOrder.(*hu.SignedOrder))
+		assert.Equal(t, big.NewInt(10), fields.PosSize)
+		assert.Equal(t, big.NewInt(-4), fields.ReduceOnlyAmount)
+
+		// reduce only orders don't reserve margin
+		assert.Equal(t, big.NewInt(0), db.TraderMap[trader].Margin.VirtualReserved)
+	})
+	t.Run("reduce only orders are removed when the position shrinks", func(t *testing.T) {
+		db := getDatabase()
+		db.UpdatePosition(trader, market, big.NewInt(10), big.NewInt(100), false, 0)
+		order1 := createReduceOnlySignedOrder(SHORT, userAddress, big.NewInt(-4), big.NewInt(10), big.NewInt(1))
+		order2 := createReduceOnlySignedOrder(SHORT, userAddress, big.NewInt(-4), big.NewInt(11), big.NewInt(2))
+		order3 := createReduceOnlySignedOrder(SHORT, userAddress, big.NewInt(-2), big.NewInt(12), big.NewInt(3))
+		db.AddSignedOrder(&order1, big.NewInt(0))
+		db.AddSignedOrder(&order2, big.NewInt(0))
+		db.AddSignedOrder(&order3, big.NewInt(0))
+
+		db.RemoveStaleReduceOnlySignedOrders()
+		assert.Equal(t, 3, len(db.GetShortOrders(market, nil, nil)))
+
+		// order1 and order2 are matched first and cover the position, order3 can't be filled anymore
+		db.UpdatePosition(trader, market, big.NewInt(6), big.NewInt(60), false, 1)
+		db.RemoveStaleReduceOnlySignedOrders()
+		assert.NotNil(t, db.GetOrderById(order1.Id))
+		assert.NotNil(t, db.GetOrderById(order2.Id))
+		assert.Nil(t, db.GetOrderById(order3.Id))
+
+		// order1 is shrunk to the position in matching
+		db.UpdatePosition(trader, market, big.NewInt(3), big.NewInt(30), false, 2)
+		db.RemoveStaleReduceOnlySignedOrders()
+		assert.NotNil(t, db.GetOrderById(order1.Id))
+		assert.Nil(t, db.GetOrderById(order2.Id))
+		display := db.getReduceOnlyOrderDisplay(db.Orders[order1.Id])
+		assert.Equal(t, big.NewInt(-3), display.GetUnFilledBaseAssetQuantity())
+
+		// all of them are removed once the position is closed
+		db.UpdatePosition(trader, market, big.NewInt(0), big.NewInt(0), false, 3)
+		db.RemoveStaleReduceOnlySignedOrders()
+		assert.Equal(t, 0, len(db.GetShortOrders(market, nil, nil)))
+		assert.Equal(t, 0, len(db.GetAllOpenOrdersForTrader(trader)))
+	})
+	t.Run("orders at the same price are kept in the order they are matched", func(t *testing.T) {
+		db := getDatabase()
+		db.UpdatePosition(trader, market, big.NewInt(10), big.NewInt(100), false, 0)
+		order1 := createReduceOnlySignedOrder(SHORT, userAddress, big.NewInt(-4), big.NewInt(10), big.NewInt(1))
+		order2 := createReduceOnlySignedOrder(SHORT, userAddress, big.NewInt(-4), big.NewInt(10), big.NewInt(2))
+		if order1.Id.Hex() < order2.Id.Hex() {
+			// the order placed first has the larger id, so that the ids don't decide the priority
+			order1, order2 = order2, order1
+		}
+		db.AddSignedOrder(&order1, big.NewInt(0))
+		db.AddSignedOrder(&order2, big.NewInt(0))
+
+		db.UpdatePosition(trader, market, big.NewInt(4), big.NewInt(40), false, 1)
+		db.RemoveStaleReduceOnlySignedOrders()
+		assert.NotNil(t, db.GetOrderById(order1.Id))
+		assert.Nil(t, db.GetOrderById(order2.Id))
+	})
+	t.Run("traders whose position didn't change are not checked", func(t *testing.T) {
+		db := getDatabase()
+		db.UpdatePosition(trader, market, big.NewInt(10), big.NewInt(100), false, 0)
+		db.RemoveStaleReduceOnlySignedOrders()
+		assert.Equal(t, 0, len(db.positionChangedTraders))
+
+		order1 := createReduceOnlySignedOrder(SHORT, userAddress, big.NewInt(-4), big.NewInt(10), big.NewInt(1))
+		db.AddSignedOrder(&order1, big.NewInt(0))
+		db.UpdatePosition(trader, market, big.NewInt(2), big.NewInt(20), false, 1)
+		assert.Equal(t, 1, len(db.positionChangedTraders))
+		db.RemoveStaleReduceOnlySignedOrders()
+		assert.NotNil(t, db.GetOrderById(order1.Id))
+		assert.Equal(t, 0, len(db.positionChangedTraders))
+	})
+}
+
+func TestAmendSignedOrder(t *testing.T) {
+	trader := common.HexToAddress(userAddress)
+	minAllowableMargin := big.NewInt(2e5) // as in getDatabase
+	reservedMargin := func(order Order) *big.Int {
+		return hu.GetRequiredMargin(order.Price, hu.Abs(order.GetUnFilledBaseAssetQuantity()), minAllowableMargin, big.NewInt(0))
+	}
+	cancelOrder := func(order Order) *hu.CancelOrder {
+		return &hu.CancelOrder{Order: order.RawOrder.(*hu.SignedOrder)}
+	}
+	setup := func() (*InMemoryDatabase, Order, Order) {
+		db := getDatabase()
+		order1 := createSignedOrder(LONG, userAddress, big.NewInt(5e18), big.NewInt(10e6), big.NewInt(1), false)
+		order2 := createSignedOrder(LONG, "0x70997970C51812dc3A010C7d01b50e0d17dc79C8", big.NewInt(5e18), big.NewInt(10e6), big.NewInt(2), false)
+		db.AddSignedOrder(&order1, reservedMargin(order1))
+		db.AddSignedOrder(&order2, reservedMargin(order2))
+		return db, order1, order2
+	}
+
+	t.Run("size decrease keeps time priority", func(t *testing.T) {
+		db, order1, order2 := setup()
+		amendment := createSignedOrder(LONG, userAddress, big.NewInt(3e18), big.NewInt(10e6), big.NewInt(3), false)
+		assert.Nil(t, db.AmendSignedOrder(order1.Id, cancelOrder(order1), &amendment, reservedMargin(amendment)))
+
+		longOrders := db.GetLongOrders(market, nil, nil)
+		assert.Equal(t, 2, len(longOrders))
+		assert.Equal(t, amendment.Id, longOrders[0].Id)
+		assert.Equal(t, order2.Id, longOrders[1].Id)
+		assert.Nil(t, db.GetOrderById(order1.Id))
+		assert.Equal(t, reservedMargin(amendment), db.TraderMap[trader].Margin.VirtualReserved)
+		assert.Equal(t, 1, len(db.GetAllOpenOrdersForTrader(trader)))
+		assert.Equal(t, map[common.Hash]*hu.CancelOrder{order1.Id: cancelOrder(order1)}, db.GetSignedOrderCancellations())
+	})
+	t.Run("size increase or price change loses time priority", func(t *testing.T) {
+		db, order1, order2 := setup()
+		amendment := createSignedOrder(LONG, userAddress, big.NewInt(6e18), big.NewInt(10e6), big.NewInt(3), false)
+		assert.Nil(t, db.AmendSignedOrder(order1.Id, cancelOrder(order1), &amendment, reservedMargin(amendment)))
+		longOrders := db.GetLongOrders(market, nil, nil)
+		assert.Equal(t, order2.Id, longOrders[0].Id)
+		assert.Equal(t, amendment.Id, longOrders[1].Id)
+		assert.Equal(t, reservedMargin(amendment), db.TraderMap[trader].Margin.VirtualReserved)
+
+		repriced := createSignedOrder(LONG, userAddress, big.NewInt(6e18), big.NewInt(11e6), big.NewInt(4), false)
+		assert.Nil(t, db.AmendSignedOrder(amendment.Id, cancelOrder(amendment), &repriced, reservedMargin(repriced)))
+		longOrders = db.GetLongOrders(market, nil, nil)
+		assert.Equal(t, repriced.Id, longOrders[0].Id)
+		assert.Equal(t, big.NewInt(11e6), longOrders[0].Price)
+		assert.Equal(t, 2, len(longOrders))
+		assert.Equal(t, reservedMargin(repriced), db.TraderMap[trader].Margin.VirtualReserved)
+	})
+	t.Run("amended order can't be amended or placed again", func(t *testing.T) {
+		db, order1, _ := setup()
+		amendment := createSignedOrder(LONG, userAddress, big.NewInt(3e18), big.NewInt(10e6), big.NewInt(3), false)
+		assert.Nil(t, db.AmendSignedOrder(order1.Id, cancelOrder(order1), &amendment, reservedMargin(amendment)))
+
+		another := createSignedOrder(LONG, userAddress, big.NewInt(2e18), big.NewInt(10e6), big.NewInt(4), false)
+		assert.Equal(t, ErrAmendedOrderNotFound, db.AmendSignedOrder(order1.Id, cancelOrder(order1), &another, reservedMargin(another)))
+		fields := db.GetOrderValidationFields(order1.Id, order1.RawOrder.(*hu.SignedOrder))
+		assert.True(t, fields.Cancelled)
+	})
+	t.Run("amendment that arrives before the amended order", func(t *testing.T) {
+		db := getDatabase()
+		order1 := createSignedOrder(LONG, userAddress, big.NewInt(5e18), big.NewInt(10e6), big.NewInt(1), false)
+		amendment := createSignedOrder(LONG, userAddress, big.NewInt(3e18), big.NewInt(10e6), big.NewInt(3), false)
+		assert.Nil(t, db.AmendSignedOrder(order1.Id, cancelOrder(order1), &amendment, reservedMargin(amendment)))
+		assert.Equal(t, reservedMargin(amendment), db.TraderMap[trader].Margin.VirtualReserved)
+		assert.Equal(t, 1, len(db.GetLongOrders(market, nil, nil)))
+
+		fields := db.GetOrderValidationFields(order1.Id, order1.RawOrder.(*hu.SignedOrder))
+		assert.True(t, fields.Cancelled)
+	})
+	t.Run("cancel messages are kept till the amended order expires", func(t *testing.T) {
+		db, order1, _ := setup()
+		order1.RawOrder.(*hu.SignedOrder).ExpireAt = big.NewInt(time.Now().Unix() - 1)
+		amendment := createSignedOrder(LONG, userAddress, big.NewInt(3e18), big.NewInt(10e6), big.NewInt(3), false)
+		assert.Nil(t, db.AmendSignedOrder(order1.Id, cancelOrder(order1), &amendment, reservedMargin(amendment)))
+		assert.Equal(t, 1, len(db.GetSignedOrderCancellations()))
+
+		db.RemoveExpiredSignedOrders()
+		assert.Equal(t, 0, len(db.GetSignedOrderCancellations()))
+		assert.NotNil(t, db.GetOrderById(amendment.Id))
+	})
+}
+
+func TestCancelAllSignedOrders(t *testing.T) {
+	trader := common.HexToAddress(userAddress)
+	otherMarket := Market(1)
+	setup := func() (*InMemoryDatabase, []Order) {
+		db := getDatabase()
+		orders := []Order{
+			createSignedOrder(LONG, userAddress, big.NewInt(5e18), big.NewInt(10e6), big.NewInt(100), false),
+			createSignedOrder(SHORT, userAddress, big.NewInt(-5e18), big.NewInt(12e6), big.NewInt(200), false),
+			createSignedOrder(LONG, userAddress, big.NewInt(5e18), big.NewInt(10e6), big.NewInt(300), false),
+			createSignedOrder(LONG, "0x70997970C51812dc3A010C7d01b50e0d17dc79C8", big.NewInt(5e18), big.NewInt(10e6), big.NewInt(100), false),
+		}
+		orders[2].Market = otherMarket
+		orders[2].Id = getIdFromOrder(orders[2])
+		for i := range orders {
+			db.AddSignedOrder(&orders[i], big.NewInt(0))
+		}
+		return db, orders
+	}
+	cancelAll := func(market *Market, nonce int64) *hu.CancelAllOrders {
+		ammIndex := hu.AllMarkets
+		if market != nil {
+			ammIndex = big.NewInt(int64(*market))
+		}
+		return &hu.CancelAllOrders{Trader: trader, AmmIndex: ammIndex, Nonce: big.NewInt(nonce)}
+	}
+
+	t.Run("cancels all the orders of the trader in the market", func(t *testing.T) {
+		db, orders := setup()
+		// the order with salt 200 > nonce is cancelled too
+		cancelledOrderIds, err := db.CancelAllSignedOrders(cancelAll(&market, 150))
+		assert.Nil(t, err)
+		assert.ElementsMatch(t, []common.Hash{orders[0].Id, orders[1].Id}, cancelledOrderIds)
+		assert.Nil(t, db.GetOrderById(orders[0].Id))
+		assert.Nil(t, db.GetOrderById(orders[1].Id))
+		assert.NotNil(t, db.GetOrderById(orders[2].Id))
+		assert.NotNil(t, db.GetOrderById(orders[3].Id))
+
+		removed := db.PopRemovedOrders()
+		assert.Equal(t, 2, len(removed))
+		assert.Equal(t, ReasonCancelAll, removed[0].getOrderStatus().Reason)
+
+		// a peer that missed the cancellation can't place the orders again
+		assert.True(t, db.GetOrderValidationFields(orders[0].Id, orders[0].RawOrder.(*hu.SignedOrder)).Cancelled)
+		assert.True(t, db.GetOrderValidationFields(orders[1].Id, orders[1].RawOrder.(*hu.SignedOrder)).Cancelled)
+	})
+
+	t.Run("all markets", func(t *testing.T) {
+		db, orders := setup()
+		cancelledOrderIds, err := db.CancelAllSignedOrders(cancelAll(nil, 100))
+		assert.Nil(t, err)
+		assert.ElementsMatch(t, []common.Hash{orders[0].Id, orders[1].Id, orders[2].Id}, cancelledOrderIds)
+		assert.Equal(t, 0, len(db.GetAllOpenOrdersForTrader(trader)))
+		assert.NotNil(t, db.GetOrderById(orders[3].Id))
+	})
+
+	t.Run("nonce can only increase", func(t *testing.T) {
+		db, _ := setup()
+		_, err := db.CancelAllSignedOrders(cancelAll(&market, 150))
+		assert.Nil(t, err)
+		_, err = db.CancelAllSignedOrders(cancelAll(&market, 150))
+		assert.Equal(t, hu.ErrStaleCancelNonce, err)
+		// a market nonce doesn't apply to the other markets
+		_, err = db.CancelAllSignedOrders(cancelAll(&otherMarket, 100))
+		assert.Nil(t, err)
+		_, err = db.CancelAllSignedOrders(cancelAll(nil, 120))
+		assert.Nil(t, err)
+		// the all markets nonce applies to every market
+		_, err = db.CancelAllSignedOrders(cancelAll(&otherMarket, 110))
+		assert.Equal(t, hu.ErrStaleCancelNonce, err)
+	})
+
+	t.Run("keeps the last message for every market to be recorded with the juror", func(t *testing.T) {
+		db, _ := setup()
+		_, err := db.CancelAllSignedOrders(cancelAll(&market, 100))
+		assert.Nil(t, err)
+		_, err = db.CancelAllSignedOrders(cancelAll(&market, 150))
+		assert.Nil(t, err)
+		_, err = db.CancelAllSignedOrders(cancelAll(nil, 120))
+		assert.Nil(t, err)
+		assert.ElementsMatch(t, []*hu.CancelAllOrders{cancelAll(&market, 150), cancelAll(nil, 120)}, db.GetCancelAllMessages())
+	})
+
+	t.Run("validation fields include the nonce of the market", func(t *testing.T) {
+		db, _ := setup()
+		_, err := db.CancelAllSignedOrders(cancelAll(&market, 150))
+		assert.Nil(t, err)
+		newOrder := createSignedOrder(LONG, userAddress, big.NewInt(5e18), big.NewInt(10e6), big.NewInt(120), false)
+		fields := db.GetOrderValidationFields(newOrder.Id, newOrder.RawOrder.(*hu.SignedOrder))
+		assert.Equal(t, big.NewInt(150), fields.CancelNonce)
+
+		otherTraderOrder := createSignedOrder(LONG, "0x70997970C51812dc3A010C7d01b50e0d17dc79C8", big.NewInt(5e18), big.NewInt(10e6), big.NewInt(120), false)
+		fields = db.GetOrderValidationFields(otherTraderOrder.Id, otherTraderOrder.RawOrder.(*hu.SignedOrder))
+		assert.Nil(t, fields.CancelNonce)
+	})
+}
+
+func TestHeartbeat(t *testing.T) {
+	trader := common.HexToAddress(userAddress)
+	now := uint64(time.Now().Unix())
+	setup := func() (*InMemoryDatabase, []Order) {
+		db := getDatabase()
+		orders := []Order{
+			createSignedOrder(LONG, userAddress, big.NewInt(5e18), big.NewInt(10e6), big.NewInt(100), false),
+			createSignedOrder(SHORT, userAddress, big.NewInt(-5e18), big.NewInt(12e6), big.NewInt(200), false),
+			createSignedOrder(LONG, "0x70997970C51812dc3A010C7d01b50e0d17dc79C8", big.NewInt(5e18), big.NewInt(10e6), big.NewInt(100), false),
+		}
+		for i := range orders {
+			orders[i].PlacedAt = now - 30
+			db.AddSignedOrder(&orders[i], big.NewInt(0))
+		}
+		return db, orders
+	}
+
+	t.Run("orders are kept till the deadline", func(t *testing.T) {
+		db, _ := setup()
+		assert.Nil(t, db.UpdateHeartbeat(&hu.Heartbeat{Trader: trader, Timeout: 10, Timestamp: now}))
+		assert.Equal(t, 0, len(db.CancelOrdersOfExpiredHeartbeats()))
+		assert.Equal(t, 2, len(db.GetAllOpenOrdersForTrader(trader)))
+	})
+
+	t.Run("orders of the trader are cancelled after the deadline", func(t *testing.T) {
+		db, orders := setup()
+		heartbeat := &hu.Heartbeat{Trader: trader, Timeout: 10, Timestamp: now - 11}
+		assert.Nil(t, db.UpdateHeartbeat(heartbeat))
+		cancelledOrderIds := db.CancelOrdersOfExpiredHeartbeats()
+		assert.ElementsMatch(t, []common.Hash{orders[0].Id, orders[1].Id}, cancelledOrderIds)
+		assert.Equal(t, 0, len(db.GetAllOpenOrdersForTrader(trader)))
+		assert.NotNil(t, db.GetOrderById(orders[2].Id))
+
+		removed := db.PopRemovedOrders()
+		assert.Equal(t, 2, len(removed))
+		assert.Equal(t, ReasonHeartbeatExpired, removed[0].getOrderStatus().Reason)
+
+		// the switch is triggered once
+		assert.Equal(t, 0, len(db.CancelOrdersOfExpiredHeartbeats()))
+
+		// new orders are rejected till there is a new heartbeat
+		newOrder := createSignedOrder(LONG, userAddress, big.NewInt(5e18), big.NewInt(10e6), big.NewInt(300), false)
+		assert.True(t, db.GetOrderValidationFields(newOrder.Id, newOrder.RawOrder.(*hu.SignedOrder)).HeartbeatExpired)
+		assert.Equal(t, hu.ErrStaleHeartbeat, db.UpdateHeartbeat(heartbeat))
+		assert.Nil(t, db.UpdateHeartbeat(&hu.Heartbeat{Trader: trader, Timeout: 10, Timestamp: now}))
+		assert.False(t, db.GetOrderValidationFields(newOrder.Id, newOrder.RawOrder.(*hu.SignedOrder)).HeartbeatExpired)
+
+		// the cancelled orders can't be placed again after the new heartbeat, till they expire
+		assert.True(t, db.GetOrderValidationFields(orders[0].Id, orders[0].RawOrder.(*hu.SignedOrder)).Cancelled)
+		db.RemoveExpiredSignedOrders()
+		assert.True(t, db.GetOrderValidationFields(orders[0].Id, orders[0].RawOrder.(*hu.SignedOrder)).Cancelled)
+		db.CancelledSignedOrders[orders[0].Id] = now - 1
+		db.RemoveExpiredSignedOrders()
+		assert.False(t, db.GetOrderValidationFields(orders[0].Id, orders[0].RawOrder.(*hu.SignedOrder)).Cancelled)
+	})
+
+	t.Run("timeout of 0 unregisters the switch", func(t *testing.T) {
+		db, _ := setup()
+		assert.Nil(t, db.UpdateHeartbeat(&hu.Heartbeat{Trader: trader, Timeout: 10, Timestamp: now - 20}))
+		assert.Nil(t, db.UpdateHeartbeat(&hu.Heartbeat{Trader: trader, Timeout: 0, Timestamp: now - 19}))
+		assert.Equal(t, 0, len(db.CancelOrdersOfExpiredHeartbeats()))
+		assert.Equal(t, 2, len(db.GetAllOpenOrdersForTrader(trader)))
+	})
+
+	t.Run("orders received after the deadline are kept", func(t *testing.T) {
+		db, orders := setup()
+		assert.Nil(t, db.UpdateHeartbeat(&hu.Heartbeat{Trader: trader, Timeout: 10, Timestamp: now - 20}))
+		// received before the switch was triggered, e.g. after a newer heartbeat that didn't reach this node yet
+		newOrder := createSignedOrder(LONG, userAddress, big.NewInt(5e18), big.NewInt(10e6), big.NewInt(300), false)
+		newOrder.PlacedAt = now - 5
+		db.AddSignedOrder(&newOrder, big.NewInt(0))
+		cancelledOrderIds := db.CancelOrdersOfExpiredHeartbeats()
+		assert.ElementsMatch(t, []common.Hash{orders[0].Id, orders[1].Id}, cancelledOrderIds)
+		assert.NotNil(t, db.GetOrderById(newOrder.Id))
+	})
+}
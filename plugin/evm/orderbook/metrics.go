@@ -29,6 +29,8 @@ var (
 	RPCPanicsCounter                         = metrics.NewRegisteredCounter("rpc_panic", nil)
 	AwaitSignedOrdersGossipPanicsCounter     = metrics.NewRegisteredCounter("await_signed_orders_gossip_panics", nil)
 	MakerbookFileWriteChannelPanicsCounter   = metrics.NewRegisteredCounter("makerbook_file_write_channel_panics", nil)
+	StateAuditPanicsCounter                  = metrics.NewRegisteredCounter("state_audit_panics", nil)
+	SyncSignedOrdersPanicsCounter            = metrics.NewRegisteredCounter("sync_signed_orders_panics", nil)
 
 	BuildBlockFailedWithLowBlockGasCounter = metrics.NewRegisteredCounter("build_block_failed_low_block_gas", nil)
 
@@ -41,10 +43,39 @@ var (
 	// unquenched liquidations
 	unquenchedLiquidationsCounter = metrics.NewRegisteredCounter("unquenched_liquidations", nil)
 	placeSignedOrderCounter       = metrics.NewRegisteredCounter("place_signed_order", nil)
+	amendSignedOrderCounter       = metrics.NewRegisteredCounter("amend_signed_order", nil)
+	cancelAllSignedOrdersCounter  = metrics.NewRegisteredCounter("cancel_all_signed_orders", nil)
+	heartbeatExpiredCounter       = metrics.NewRegisteredCounter("heartbeat_expired", nil)
+
+	// depth stream subscribers that fell behind
+	depthSubscribersDroppedCounter = metrics.NewRegisteredCounter("depth_subscribers_dropped", nil)
+	depthResyncsCounter            = metrics.NewRegisteredCounter("depth_resyncs", nil)
+
+	// orders cancelled in a matching run by self trade prevention
+	selfTradesPreventedCounter = metrics.NewRegisteredCounter("self_trades_prevented", nil)
+
+	// trigger orders
+	placeTriggerOrderCounter      = metrics.NewRegisteredCounter("place_trigger_order", nil)
+	triggerOrdersActivatedCounter = metrics.NewRegisteredCounter("trigger_orders_activated", nil)
 
 	// makerbook write failures
 	makerBookWriteFailuresCounter = metrics.NewRegisteredCounter("makerbook_write_failures", nil)
 
 	// snapshot write failures
 	SnapshotWriteFailuresCounter = metrics.NewRegisteredCounter("snapshot_write_failures", nil)
+
+	// snapshots rejected while loading because they were corrupt or did not match the chain
+	SnapshotLoadFailuresCounter = metrics.NewRegisteredCounter("snapshot_load_failures", nil)
+
+	// memory DB write-ahead log failures
+	WALWriteFailuresCounter = metrics.NewRegisteredCounter("wal_write_failures", nil)
+
+	// trade indexer write failures
+	tradeIndexerWriteFailuresCounter = metrics.NewRegisteredCounter("trade_indexer_write_failures", nil)
+
+	// memory DB vs on-chain state audits
+	stateAuditRunsCounter        = metrics.NewRegisteredCounter("state_audit/runs", nil)
+	stateAuditFailuresCounter    = metrics.NewRegisteredCounter("state_audit/failures", nil)
+	stateAuditDiscrepanciesGauge = metrics.NewRegisteredGauge("state_audit/discrepancies", nil)
+	stateAuditTimer              = metrics.NewRegisteredTimer("state_audit/duration", nil)
 )
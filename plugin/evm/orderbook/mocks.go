@@ -5,6 +5,7 @@ import (
 
 	"github.com/ava-labs/subnet-evm/core/types"
 	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ava-labs/subnet-evm/precompile/contract"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/stretchr/testify/mock"
 )
@@ -21,7 +22,11 @@ func (db *MockLimitOrderDatabase) SetOrderStatus(orderId common.Hash, status Sta
 	return nil
 }
 
-func (db *MockLimitOrderDatabase) RevertLastStatus(orderId common.Hash) error {
+func (db *MockLimitOrderDatabase) UpdateOrderLifecycle(orderId common.Hash, lifecycle Lifecycle) error {
+	return nil
+}
+
+func (db *MockLimitOrderDatabase) RevertLastStatus(orderId common.Hash, blockNumber uint64) error {
 	return nil
 }
 
@@ -33,6 +38,10 @@ func (db *MockLimitOrderDatabase) GetAllOrders() []Order {
 	return args.Get(0).([]Order)
 }
 
+func (db *MockLimitOrderDatabase) GetSignedOrders() []Order {
+	return []Order{}
+}
+
 func (db *MockLimitOrderDatabase) GetMarketOrders(market Market) []Order {
 	args := db.Called()
 	return args.Get(0).([]Order)
@@ -44,9 +53,23 @@ func (db *MockLimitOrderDatabase) Add(order *Order) {
 func (db *MockLimitOrderDatabase) AddSignedOrder(order *Order, requiredMargin *big.Int) {
 }
 
+func (db *MockLimitOrderDatabase) AddTriggerOrder(order *Order) {
+}
+
+func (db *MockLimitOrderDatabase) ActivateTriggeredOrders(oraclePrices map[Market]*big.Int, blockNumber uint64) []Order {
+	return []Order{}
+}
+
 func (db *MockLimitOrderDatabase) UpdateFilledBaseAssetQuantity(quantity *big.Int, orderId common.Hash, blockNumber uint64) {
 }
 
+func (db *MockLimitOrderDatabase) UpdateOrderFill(quantity *big.Int, orderId common.Hash, fill Lifecycle) {
+}
+
+func (db *MockLimitOrderDatabase) PopRemovedOrders() []Order {
+	return nil
+}
+
 func (db *MockLimitOrderDatabase) Delete(id common.Hash) {
 }
 
@@ -170,6 +193,49 @@ func (db *MockLimitOrderDatabase) SampleImpactPrice() (impactBids, impactAsks, m
 
 func (db *MockLimitOrderDatabase) RemoveExpiredSignedOrders() {}
 
+func (db *MockLimitOrderDatabase) RemoveStaleReduceOnlySignedOrders() {}
+
+func (db *MockLimitOrderDatabase) GetDepthSnapshot(market Market) DepthSnapshot {
+	return DepthSnapshot{Depth: &MarketDepth{Market: market, Longs: map[string]string{}, Shorts: map[string]string{}}}
+}
+
+func (db *MockLimitOrderDatabase) SubscribeDepthUpdates(market Market) (DepthSnapshot, *DepthSubscription) {
+	return db.GetDepthSnapshot(market), newDepthFeed().subscribe(market)
+}
+
+func (db *MockLimitOrderDatabase) AmendSignedOrder(amendedOrderId common.Hash, cancelOrder *hu.CancelOrder, order *Order, requiredMargin *big.Int) error {
+	return nil
+}
+
+func (db *MockLimitOrderDatabase) GetSignedOrderCancellations() map[common.Hash]*hu.CancelOrder {
+	return map[common.Hash]*hu.CancelOrder{}
+}
+
+func (db *MockLimitOrderDatabase) CancelAllSignedOrders(msg *hu.CancelAllOrders) ([]common.Hash, error) {
+	return nil, nil
+}
+
+func (db *MockLimitOrderDatabase) GetCancelAllMessages() []*hu.CancelAllOrders {
+	return []*hu.CancelAllOrders{}
+}
+
+func (db *MockLimitOrderDatabase) UpdateHeartbeat(heartbeat *hu.Heartbeat) error {
+	return nil
+}
+
+func (db *MockLimitOrderDatabase) VerifyState(stateDB contract.StateDB, blockNumber uint64) *StateVerificationReport {
+	return &StateVerificationReport{BlockNumber: blockNumber, Discrepancies: []StateDiscrepancy{}}
+}
+
+func (db *MockLimitOrderDatabase) CancelSelfTradeOrder(orderId common.Hash, mode hu.SelfTradePreventionMode) error {
+	args := db.Called(orderId, mode)
+	return args.Error(0)
+}
+
+func (db *MockLimitOrderDatabase) CancelOrdersOfExpiredHeartbeats() []common.Hash {
+	return nil
+}
+
 func (db *MockLimitOrderDatabase) GetMarginAvailableForMakerbook(trader common.Address, prices map[int]*big.Int) *big.Int {
 	return big.NewInt(0)
 }
@@ -187,6 +253,11 @@ func (lotp *MockLimitOrderTxProcessor) ExecuteMatchedOrdersTx(incomingOrder Orde
 	return args.Error(0)
 }
 
+func (lotp *MockLimitOrderTxProcessor) ExecuteBatchAuctionTx(orders []Order, fillAmounts []*big.Int) error {
+	args := lotp.Called(orders, fillAmounts)
+	return args.Error(0)
+}
+
 func (lotp *MockLimitOrderTxProcessor) PurgeOrderBookTxs() {
 	lotp.Called()
 }
@@ -222,6 +293,16 @@ func (lotp *MockLimitOrderTxProcessor) ExecuteLimitOrderCancel(orderIds []LimitO
 	return args.Error(0)
 }
 
+func (lotp *MockLimitOrderTxProcessor) ExecuteCancelAllSignedOrdersTx(cancels []*hu.CancelAllOrders) error {
+	args := lotp.Called(cancels)
+	return args.Error(0)
+}
+
+func (lotp *MockLimitOrderTxProcessor) ExecuteCancelSignedOrdersTx(cancelOrders []*hu.CancelOrder) error {
+	args := lotp.Called(cancelOrders)
+	return args.Error(0)
+}
+
 func (lotp *MockLimitOrderTxProcessor) HandleOrderBookEvent(event *types.Log) {
 }
 
@@ -336,6 +417,14 @@ func (cs *MockConfigService) IsTradingAuthority(trader, signer common.Address) b
 	return false
 }
 
+func (cs *MockConfigService) IsSignedOrderCancelled(orderHash common.Hash) bool {
+	return false
+}
+
+func (cs *MockConfigService) GetCancelNonce(trader common.Address, ammIndex *big.Int) *big.Int {
+	return big.NewInt(0)
+}
+
 func NewMockConfigService() *MockConfigService {
 	return &MockConfigService{}
 }
@@ -356,6 +445,15 @@ func (cs *MockConfigService) GetReduceOnlyAmounts(trader common.Address) []*big.
 	return []*big.Int{big.NewInt(0)}
 }
 
+func (cs *MockConfigService) GetMatchingMode(market Market) hu.MatchingMode {
+	args := cs.Called(market)
+	return args.Get(0).(hu.MatchingMode)
+}
+
+func (cs *MockConfigService) GetSelfTradePreventionMode(market Market) hu.SelfTradePreventionMode {
+	return hu.NoSelfTradePrevention
+}
+
 func (cs *MockConfigService) IsSettledAll() bool {
 	return false
 }
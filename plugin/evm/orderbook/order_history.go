@@ -0,0 +1,151 @@
+package orderbook
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	traderOrdersKeyPrefix = append(common.CopyBytes(tradeIndexerPrefix), 'o')
+	ordersKeyPrefix       = append(common.CopyBytes(tradeIndexerPrefix), 'i')
+)
+
+// ArchivedOrder is an order that was removed from the memory DB for good, with its complete lifecycle
+type ArchivedOrder struct {
+	Id                      common.Hash    `json:"id"`
+	Market                  Market         `json:"market"`
+	PositionType            PositionType   `json:"positionType"`
+	Trader                  common.Address `json:"trader"`
+	BaseAssetQuantity       *big.Int       `json:"baseAssetQuantity"`
+	FilledBaseAssetQuantity *big.Int       `json:"filledBaseAssetQuantity"`
+	Salt                    *big.Int       `json:"salt"`
+	Price                   *big.Int       `json:"price"`
+	ReduceOnly              bool           `json:"reduceOnly"`
+	PostOnly                bool           `json:"postOnly"`
+	OrderType               OrderType      `json:"orderType"`
+	BlockNumber             uint64         `json:"blockNumber"` // block number the order was placed on
+	LifecycleList           []Lifecycle    `json:"lifecycleList"`
+}
+
+func newArchivedOrder(order *Order) *ArchivedOrder {
+	return &ArchivedOrder{
+		Id:                      order.Id,
+		Market:                  order.Market,
+		PositionType:            order.PositionType,
+		Trader:                  order.Trader,
+		BaseAssetQuantity:       order.BaseAssetQuantity,
+		FilledBaseAssetQuantity: order.FilledBaseAssetQuantity,
+		Salt:                    order.Salt,
+		Price:                   order.Price,
+		ReduceOnly:              order.ReduceOnly,
+		PostOnly:                order.isPostOnly(),
+		OrderType:               order.OrderType,
+		BlockNumber:             order.BlockNumber.Uint64(),
+		LifecycleList:           order.LifecycleList,
+	}
+}
+
+// toOrder returns the order without its raw order, so isPostOnly of the returned order is always false
+func (archivedOrder *ArchivedOrder) toOrder() *Order {
+	return &Order{
+		Id:                      archivedOrder.Id,
+		Market:                  archivedOrder.Market,
+		PositionType:            archivedOrder.PositionType,
+		Trader:                  archivedOrder.Trader,
+		BaseAssetQuantity:       archivedOrder.BaseAssetQuantity,
+		FilledBaseAssetQuantity: archivedOrder.FilledBaseAssetQuantity,
+		Salt:                    archivedOrder.Salt,
+		Price:                   archivedOrder.Price,
+		ReduceOnly:              archivedOrder.ReduceOnly,
+		LifecycleList:           archivedOrder.LifecycleList,
+		BlockNumber:             new(big.Int).SetUint64(archivedOrder.BlockNumber),
+		OrderType:               archivedOrder.OrderType,
+	}
+}
+
+func orderKey(orderId common.Hash) []byte {
+	return append(common.CopyBytes(ordersKeyPrefix), orderId.Bytes()...)
+}
+
+func traderOrdersPrefix(trader common.Address) []byte {
+	return append(common.CopyBytes(traderOrdersKeyPrefix), trader.Bytes()...)
+}
+
+// key suffix of the orders of a trader is the block number of the last lifecycle of the order | order id
+func traderOrderKey(order *Order) []byte {
+	prefix := traderOrdersPrefix(order.Trader)
+	key := make([]byte, len(prefix)+8+common.HashLength)
+	copy(key, prefix)
+	binary.BigEndian.PutUint64(key[len(prefix):], order.getOrderStatus().BlockNumber)
+	copy(key[len(prefix)+8:], order.Id.Bytes())
+	return key
+}
+
+// IndexRemovedOrders archives the orders removed from the memory DB for good. Orders are stored by id and indexed by trader
+func (indexer *TradeIndexer) IndexRemovedOrders(orders []Order) {
+	if len(orders) == 0 {
+		return
+	}
+	batch := indexer.db.NewBatch()
+	for i := range orders {
+		order := &orders[i]
+		if len(order.LifecycleList) == 0 {
+			continue
+		}
+		value, err := json.Marshal(newArchivedOrder(order))
+		if err == nil {
+			err = batch.Put(orderKey(order.Id), value)
+		}
+		if err == nil {
+			err = batch.Put(traderOrderKey(order), order.Id.Bytes())
+		}
+		if err != nil {
+			tradeIndexerWriteFailuresCounter.Inc(1)
+			log.Error("IndexRemovedOrders - error in encoding order", "orderId", order.Id, "err", err)
+			return
+		}
+	}
+	if err := batch.Write(); err != nil {
+		tradeIndexerWriteFailuresCounter.Inc(1)
+		log.Error("IndexRemovedOrders - error in writing to the trade indexer", "orders", len(orders), "err", err)
+	}
+}
+
+// GetArchivedOrder returns an order removed from the memory DB, or nil if it was not archived
+func (indexer *TradeIndexer) GetArchivedOrder(orderId common.Hash) (*ArchivedOrder, error) {
+	value, err := indexer.db.Get(orderKey(orderId))
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	archivedOrder := &ArchivedOrder{}
+	if err := json.Unmarshal(value, archivedOrder); err != nil {
+		return nil, fmt.Errorf("error in decoding order: err=%v", err)
+	}
+	return archivedOrder, nil
+}
+
+// GetOrderHistory returns the archived orders of a trader whose last lifecycle is in the blocks [fromBlock, toBlock], oldest first. toBlock = 0 means no upper bound
+func (indexer *TradeIndexer) GetOrderHistory(trader common.Address, fromBlock, toBlock uint64, page *PaginationArgs) ([]*ArchivedOrder, string, error) {
+	orders := []*ArchivedOrder{}
+	nextCursor, err := indexer.iterate(traderOrdersPrefix(trader), fromBlock, toBlock, pageLimit(page), pageCursor(page), func(value []byte) error {
+		archivedOrder, err := indexer.GetArchivedOrder(common.BytesToHash(value))
+		if err != nil {
+			return err
+		}
+		if archivedOrder != nil {
+			orders = append(orders, archivedOrder)
+		}
+		return nil
+	})
+	return orders, nextCursor, err
+}
@@ -0,0 +1,207 @@
+package orderbook
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/database/memdb"
+	"github.com/ava-labs/subnet-evm/core/types"
+	"github.com/ava-labs/subnet-evm/plugin/evm/orderbook/abis"
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderLifecycle(t *testing.T) {
+	counterparty := common.HexToHash("0x1234")
+	txHash := common.HexToHash("0xabcd")
+
+	t.Run("fills are recorded with price, counterparty and tx hash", func(t *testing.T) {
+		db := getDatabase()
+		order := createLimitOrder(LONG, userAddress, big.NewInt(10), big.NewInt(20), Placed, big.NewInt(2), big.NewInt(1))
+		db.Add(&order)
+
+		db.UpdateOrderFill(big.NewInt(4), order.Id, Lifecycle{BlockNumber: 3, FillPrice: big.NewInt(20), CounterpartyOrderId: &counterparty, TxHash: &txHash})
+		lifecycle := db.Orders[order.Id].getOrderStatus()
+		assert.Equal(t, Placed, lifecycle.Status)
+		assert.Equal(t, ReasonPartialFill, lifecycle.Reason)
+		assert.Equal(t, big.NewInt(4), lifecycle.FillAmount)
+		assert.Equal(t, big.NewInt(20), lifecycle.FillPrice)
+		assert.Equal(t, counterparty, *lifecycle.CounterpartyOrderId)
+		assert.Equal(t, txHash, *lifecycle.TxHash)
+
+		db.UpdateOrderFill(big.NewInt(6), order.Id, Lifecycle{BlockNumber: 4, FillPrice: big.NewInt(19), TxHash: &txHash})
+		lifecycle = db.Orders[order.Id].getOrderStatus()
+		assert.Equal(t, FulFilled, lifecycle.Status)
+		assert.Equal(t, ReasonFill, lifecycle.Reason)
+		assert.Equal(t, uint64(4), lifecycle.BlockNumber)
+		assert.Equal(t, 3, len(db.Orders[order.Id].LifecycleList))
+
+		// the fill is reverted in a reorg
+		db.UpdateOrderFill(big.NewInt(-6), order.Id, Lifecycle{BlockNumber: 4})
+		lifecycle = db.Orders[order.Id].getOrderStatus()
+		assert.Equal(t, ReasonPartialFill, lifecycle.Reason)
+		assert.Equal(t, 2, len(db.Orders[order.Id].LifecycleList))
+		assert.Equal(t, big.NewInt(4), db.Orders[order.Id].FilledBaseAssetQuantity)
+	})
+
+	t.Run("reverts remove the entry of the reverted block", func(t *testing.T) {
+		db := getDatabase()
+		order := createLimitOrder(LONG, userAddress, big.NewInt(10), big.NewInt(20), Placed, big.NewInt(2), big.NewInt(1))
+		db.Add(&order)
+
+		db.UpdateOrderFill(big.NewInt(4), order.Id, Lifecycle{BlockNumber: 3, FillPrice: big.NewInt(20), TxHash: &txHash})
+		assert.Nil(t, db.UpdateOrderLifecycle(order.Id, Lifecycle{BlockNumber: 3, Status: Execution_Failed, Reason: ReasonMatchingError}))
+		db.UpdateOrderFill(big.NewInt(2), order.Id, Lifecycle{BlockNumber: 4, FillPrice: big.NewInt(20), TxHash: &txHash})
+
+		// the partial fill of block 3 is reverted, the later entries are kept
+		db.UpdateOrderFill(big.NewInt(-4), order.Id, Lifecycle{BlockNumber: 3})
+		lifecycleList := db.Orders[order.Id].LifecycleList
+		assert.Equal(t, 3, len(lifecycleList))
+		assert.Equal(t, ReasonMatchingError, lifecycleList[1].Reason)
+		assert.Equal(t, uint64(4), lifecycleList[2].BlockNumber)
+		assert.Equal(t, big.NewInt(2), db.Orders[order.Id].FilledBaseAssetQuantity)
+
+		// the matching error of block 3 is reverted, not the fill of block 4
+		assert.Nil(t, db.RevertLastStatus(order.Id, 3))
+		lifecycleList = db.Orders[order.Id].LifecycleList
+		assert.Equal(t, 2, len(lifecycleList))
+		assert.Equal(t, Placed, lifecycleList[0].Status)
+		assert.Equal(t, ReasonPartialFill, lifecycleList[1].Reason)
+		assert.Equal(t, uint64(4), lifecycleList[1].BlockNumber)
+	})
+
+	t.Run("orders removed on accept are handed over once", func(t *testing.T) {
+		db := getDatabase()
+		order := createLimitOrder(SHORT, userAddress, big.NewInt(-10), big.NewInt(20), Placed, big.NewInt(2), big.NewInt(2))
+		db.Add(&order)
+		db.UpdateOrderFill(big.NewInt(10), order.Id, Lifecycle{BlockNumber: 3, FillPrice: big.NewInt(20), TxHash: &txHash})
+
+		db.Accept(3, 0)
+		assert.Nil(t, db.Orders[order.Id])
+		removed := db.PopRemovedOrders()
+		assert.Equal(t, 1, len(removed))
+		assert.Equal(t, order.Id, removed[0].Id)
+		assert.Equal(t, ReasonFill, removed[0].getOrderStatus().Reason)
+		assert.Equal(t, 0, len(db.PopRemovedOrders()))
+	})
+
+	t.Run("expired signed orders are cancelled with a reason", func(t *testing.T) {
+		db := getDatabase()
+		db.Accept(7, 0)
+		order := createSignedOrder(LONG, userAddress, big.NewInt(10), big.NewInt(20), big.NewInt(3), false)
+		order.RawOrder.(*hu.SignedOrder).ExpireAt = big.NewInt(time.Now().Unix() - 1)
+		db.AddSignedOrder(&order, big.NewInt(0))
+
+		db.RemoveExpiredSignedOrders()
+		assert.Nil(t, db.Orders[order.Id])
+		removed := db.PopRemovedOrders()
+		assert.Equal(t, 1, len(removed))
+		lifecycle := removed[0].getOrderStatus()
+		assert.Equal(t, Cancelled, lifecycle.Status)
+		assert.Equal(t, ReasonExpired, lifecycle.Reason)
+		assert.Equal(t, uint64(7), lifecycle.BlockNumber)
+	})
+}
+
+func TestGetMatchCounterparties(t *testing.T) {
+	cep := newcep(t, getDatabase())
+	orderBookABI := getABIfromJson(abis.OrderBookAbi)
+	event := getEventFromABI(orderBookABI, "OrderMatched")
+	trader := common.HexToAddress(userAddress)
+	txHash := common.HexToHash("0x01")
+
+	matchedLog := func(orderId common.Hash, txHash common.Hash, logIndex uint, isLiquidation bool) *types.Log {
+		data, err := event.Inputs.NonIndexed().Pack(big.NewInt(5), big.NewInt(10), big.NewInt(50), timestamp, isLiquidation)
+		assert.Nil(t, err)
+		return indexerEventLog(OrderBookContractAddress, []common.Hash{event.ID, trader.Hash(), orderId}, data, 5, txHash, logIndex)
+	}
+	longOrderId := common.HexToHash("0x11")
+	shortOrderId := common.HexToHash("0x12")
+	liquidatedOrderId := common.HexToHash("0x13")
+	longLog := matchedLog(longOrderId, txHash, 0, false)
+	shortLog := matchedLog(shortOrderId, txHash, 1, false)
+	liquidationLog := matchedLog(liquidatedOrderId, common.HexToHash("0x02"), 0, true)
+
+	counterparties := cep.getMatchCounterparties([]*types.Log{longLog, shortLog, liquidationLog})
+	assert.Equal(t, 2, len(counterparties))
+	assert.Equal(t, shortOrderId, counterparties[longLog])
+	assert.Equal(t, longOrderId, counterparties[shortLog])
+	_, ok := counterparties[liquidationLog]
+	assert.False(t, ok)
+
+	// matches in different transactions are not paired
+	counterparties = cep.getMatchCounterparties([]*types.Log{longLog, matchedLog(shortOrderId, common.HexToHash("0x03"), 0, false)})
+	assert.Equal(t, 0, len(counterparties))
+
+	// events of different fill amounts are not paired, and liquidations in between are skipped
+	otherLongLog := matchedLog(common.HexToHash("0x14"), txHash, 3, false)
+	unequalData, err := event.Inputs.NonIndexed().Pack(big.NewInt(3), big.NewInt(10), big.NewInt(50), timestamp, false)
+	assert.Nil(t, err)
+	unequalLog := indexerEventLog(OrderBookContractAddress, []common.Hash{event.ID, trader.Hash(), common.HexToHash("0x15")}, unequalData, 5, txHash, 4)
+	counterparties = cep.getMatchCounterparties([]*types.Log{longLog, liquidationLog, shortLog, otherLongLog, unequalLog})
+	assert.Equal(t, 2, len(counterparties))
+	assert.Equal(t, shortOrderId, counterparties[longLog])
+	_, ok = counterparties[otherLongLog]
+	assert.False(t, ok)
+
+	// the orders of a batch auction are not paired
+	auctionEvent := getEventFromABI(orderBookABI, "BatchAuctionCleared")
+	auctionData, err := auctionEvent.Inputs.NonIndexed().Pack(big.NewInt(10), big.NewInt(5), big.NewInt(2), timestamp)
+	assert.Nil(t, err)
+	auctionLog := indexerEventLog(OrderBookContractAddress, []common.Hash{auctionEvent.ID, common.BigToHash(big.NewInt(0))}, auctionData, 5, txHash, 2)
+	counterparties = cep.getMatchCounterparties([]*types.Log{longLog, shortLog, auctionLog})
+	assert.Equal(t, 0, len(counterparties))
+}
+
+func TestOrderHistory(t *testing.T) {
+	indexer := NewTradeIndexer(memdb.New())
+	trader := common.HexToAddress(userAddress)
+
+	archive := func(salt int64, status Status, reason LifecycleReason, blockNumber uint64) Order {
+		order := createLimitOrder(LONG, userAddress, big.NewInt(10), big.NewInt(20), Placed, big.NewInt(1), big.NewInt(salt))
+		order.LifecycleList = []Lifecycle{{BlockNumber: 1, Status: Placed}, {BlockNumber: blockNumber, Status: status, Reason: reason}}
+		return order
+	}
+	filled := archive(1, FulFilled, ReasonFill, 5)
+	expired := archive(2, Cancelled, ReasonExpired, 8)
+	cancelled := archive(3, Cancelled, ReasonCancelledByTrader, 12)
+	indexer.IndexRemovedOrders([]Order{filled, expired, cancelled})
+
+	archivedOrder, err := indexer.GetArchivedOrder(expired.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, expired.Id, archivedOrder.Id)
+	assert.Equal(t, ReasonExpired, archivedOrder.LifecycleList[1].Reason)
+	assert.Equal(t, uint64(1), archivedOrder.BlockNumber)
+
+	archivedOrder, err = indexer.GetArchivedOrder(common.HexToHash("0x99"))
+	assert.Nil(t, err)
+	assert.Nil(t, archivedOrder)
+
+	orders, nextCursor, err := indexer.GetOrderHistory(trader, 0, 0, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "", nextCursor)
+	assert.Equal(t, 3, len(orders))
+	assert.Equal(t, filled.Id, orders[0].Id)
+	assert.Equal(t, cancelled.Id, orders[2].Id)
+
+	orders, _, err = indexer.GetOrderHistory(trader, 6, 10, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(orders))
+	assert.Equal(t, expired.Id, orders[0].Id)
+
+	orders, nextCursor, err = indexer.GetOrderHistory(trader, 0, 0, &PaginationArgs{Limit: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(orders))
+	assert.NotEqual(t, "", nextCursor)
+	orders, nextCursor, err = indexer.GetOrderHistory(trader, 0, 0, &PaginationArgs{Limit: 2, Cursor: nextCursor})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(orders))
+	assert.Equal(t, cancelled.Id, orders[0].Id)
+	assert.Equal(t, "", nextCursor)
+
+	orders, _, err = indexer.GetOrderHistory(common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8"), 0, 0, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(orders))
+}
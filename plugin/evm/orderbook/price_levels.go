@@ -0,0 +1,209 @@
+package orderbook
+
+import (
+	"container/list"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/btree"
+)
+
+const priceLevelTreeDegree = 32
+
+// priceLevel holds all the orders resting at a single price, in the order that they should be matched
+type priceLevel struct {
+	price  *big.Int
+	orders *list.List // *Order
+}
+
+type orderRef struct {
+	level   *priceLevel
+	element *list.Element
+}
+
+// orderBookSide is one side (longs or shorts) of the book for a market.
+// Price levels are kept in a b-tree, ordered from the best price to the worst, and every level is a queue of orders.
+// An index from order id to its queue element makes insert, delete and lookup O(log n) in the number of price levels.
+type orderBookSide struct {
+	positionType PositionType
+	levels       *btree.BTreeG[*priceLevel]
+	index        map[common.Hash]orderRef
+}
+
+func newOrderBookSide(positionType PositionType) *orderBookSide {
+	var less btree.LessFunc[*priceLevel]
+	if positionType == LONG {
+		// highest bid comes first
+		less = func(a, b *priceLevel) bool { return a.price.Cmp(b.price) > 0 }
+	} else {
+		// lowest ask comes first
+		less = func(a, b *priceLevel) bool { return a.price.Cmp(b.price) < 0 }
+	}
+	return &orderBookSide{
+		positionType: positionType,
+		levels:       btree.NewG(priceLevelTreeDegree, less),
+		index:        map[common.Hash]orderRef{},
+	}
+}
+
+func (side *orderBookSide) add(order *Order) {
+	// an order id can only appear once in the book, same as in db.Orders
+	side.remove(order.Id)
+
+	level, ok := side.levels.Get(&priceLevel{price: order.Price})
+	if !ok {
+		level = &priceLevel{price: new(big.Int).Set(order.Price), orders: list.New()}
+		side.levels.ReplaceOrInsert(level)
+	}
+
+	// orders usually arrive in increasing block number, so the right position is almost always at the back of the queue
+	mark := level.orders.Back()
+	for mark != nil && hasTimePriority(order, mark.Value.(*Order)) {
+		mark = mark.Prev()
+	}
+
+	var element *list.Element
+	if mark == nil {
+		element = level.orders.PushFront(order)
+	} else {
+		element = level.orders.InsertAfter(order, mark)
+	}
+	side.index[order.Id] = orderRef{level: level, element: element}
+}
+
+// hasTimePriority returns true if order should be matched before other, given that both are at the same price
+func hasTimePriority(order, other *Order) bool {
+	blockDiff := order.BlockNumber.Cmp(other.BlockNumber)
+	if blockDiff == -1 { // order was placed before other
+		return true
+	}
+	if blockDiff == 0 && order.OrderType == IOC {
+		// prioritize fulfilling IOC orders first, because they are short-lived
+		return true
+	}
+	return false
+}
+
+func (side *orderBookSide) remove(orderId common.Hash) bool {
+	ref, ok := side.index[orderId]
+	if !ok {
+		return false
+	}
+	ref.level.orders.Remove(ref.element)
+	if ref.level.orders.Len() == 0 {
+		side.levels.Delete(ref.level)
+	}
+	delete(side.index, orderId)
+	return true
+}
+
+// replace puts order in the place of the order with orderId in its price level queue, so that it takes over its time priority.
+// Returns false if orderId is not in the book or order is at a different price
+func (side *orderBookSide) replace(orderId common.Hash, order *Order) bool {
+	ref, ok := side.index[orderId]
+	if !ok || ref.level.price.Cmp(order.Price) != 0 {
+		return false
+	}
+	ref.element.Value = order
+	delete(side.index, orderId)
+	side.index[order.Id] = ref
+	return true
+}
+
+// queuePosition returns the number of orders ahead of the order with orderId in its price level queue, or -1 if it is not in the book
+func (side *orderBookSide) queuePosition(orderId common.Hash) int {
+	ref, ok := side.index[orderId]
+	if !ok {
+		return -1
+	}
+	position := 0
+	for element := ref.element.Prev(); element != nil; element = element.Prev() {
+		position++
+	}
+	return position
+}
+
+func (side *orderBookSide) has(orderId common.Hash) bool {
+	if side == nil {
+		return false
+	}
+	_, ok := side.index[orderId]
+	return ok
+}
+
+func (side *orderBookSide) len() int {
+	if side == nil {
+		return 0
+	}
+	return len(side.index)
+}
+
+// ascend iterates over the orders starting from the best price level until fn returns false
+func (side *orderBookSide) ascend(fn func(order *Order) bool) {
+	if side == nil {
+		return
+	}
+	side.levels.Ascend(func(level *priceLevel) bool {
+		for e := level.orders.Front(); e != nil; e = e.Next() {
+			if !fn(e.Value.(*Order)) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// orders returns all the orders in matching priority
+func (side *orderBookSide) orders() []*Order {
+	orders := make([]*Order, 0, side.len())
+	side.ascend(func(order *Order) bool {
+		orders = append(orders, order)
+		return true
+	})
+	return orders
+}
+
+// quantity returns the total unfilled quantity of the placed orders at price
+func (side *orderBookSide) quantity(price *big.Int) *big.Int {
+	total := big.NewInt(0)
+	if side == nil {
+		return total
+	}
+	level, ok := side.levels.Get(&priceLevel{price: price})
+	if !ok {
+		return total
+	}
+	for e := level.orders.Front(); e != nil; e = e.Next() {
+		total.Add(total, placedQuantity(e.Value.(*Order)))
+	}
+	return total
+}
+
+// depth returns the total unfilled quantity of the placed orders at every non-empty price level
+func (side *orderBookSide) depth() map[string]string {
+	depth := map[string]string{}
+	if side == nil {
+		return depth
+	}
+	side.levels.Ascend(func(level *priceLevel) bool {
+		total := big.NewInt(0)
+		for e := level.orders.Front(); e != nil; e = e.Next() {
+			total.Add(total, placedQuantity(e.Value.(*Order)))
+		}
+		if total.Sign() != 0 {
+			depth[level.price.String()] = total.String()
+		}
+		return true
+	})
+	return depth
+}
+
+func placedQuantity(order *Order) *big.Int {
+	if len(order.LifecycleList) == 0 || order.getOrderStatus().Status != Placed || order.BaseAssetQuantity == nil {
+		return big.NewInt(0)
+	}
+	if order.FilledBaseAssetQuantity == nil {
+		return new(big.Int).Set(order.BaseAssetQuantity)
+	}
+	return order.GetUnFilledBaseAssetQuantity()
+}
@@ -0,0 +1,80 @@
+package orderbook
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderBookSide(t *testing.T) {
+	t.Run("longs are sorted by highest price first, then by block number", func(t *testing.T) {
+		side := newOrderBookSide(LONG)
+		order1 := createLimitOrder(LONG, userAddress, big.NewInt(1), big.NewInt(100), Placed, big.NewInt(2), big.NewInt(1))
+		order2 := createLimitOrder(LONG, userAddress, big.NewInt(1), big.NewInt(101), Placed, big.NewInt(3), big.NewInt(2))
+		order3 := createLimitOrder(LONG, userAddress, big.NewInt(1), big.NewInt(100), Placed, big.NewInt(1), big.NewInt(3))
+		side.add(&order1)
+		side.add(&order2)
+		side.add(&order3)
+
+		orders := side.orders()
+		assert.Equal(t, 3, side.len())
+		assert.Equal(t, order2.Id, orders[0].Id)
+		assert.Equal(t, order3.Id, orders[1].Id)
+		assert.Equal(t, order1.Id, orders[2].Id)
+	})
+	t.Run("shorts are sorted by lowest price first", func(t *testing.T) {
+		side := newOrderBookSide(SHORT)
+		order1 := createLimitOrder(SHORT, userAddress, big.NewInt(-1), big.NewInt(101), Placed, big.NewInt(1), big.NewInt(1))
+		order2 := createLimitOrder(SHORT, userAddress, big.NewInt(-1), big.NewInt(100), Placed, big.NewInt(2), big.NewInt(2))
+		side.add(&order1)
+		side.add(&order2)
+
+		orders := side.orders()
+		assert.Equal(t, order2.Id, orders[0].Id)
+		assert.Equal(t, order1.Id, orders[1].Id)
+	})
+	t.Run("IOC order is prioritized within the same block", func(t *testing.T) {
+		side := newOrderBookSide(LONG)
+		order1 := createLimitOrder(LONG, userAddress, big.NewInt(1), big.NewInt(100), Placed, big.NewInt(2), big.NewInt(1))
+		order2 := createIOCOrder(LONG, userAddress, big.NewInt(1), big.NewInt(100), Placed, big.NewInt(2), big.NewInt(2), big.NewInt(5))
+		side.add(&order1)
+		side.add(&order2)
+
+		orders := side.orders()
+		assert.Equal(t, order2.Id, orders[0].Id)
+		assert.Equal(t, order1.Id, orders[1].Id)
+	})
+	t.Run("remove deletes the order and the empty price level", func(t *testing.T) {
+		side := newOrderBookSide(LONG)
+		order1 := createLimitOrder(LONG, userAddress, big.NewInt(1), big.NewInt(100), Placed, big.NewInt(2), big.NewInt(1))
+		side.add(&order1)
+
+		assert.True(t, side.remove(order1.Id))
+		assert.False(t, side.remove(order1.Id))
+		assert.False(t, side.has(order1.Id))
+		assert.Equal(t, 0, side.len())
+		assert.Equal(t, 0, side.levels.Len())
+	})
+	t.Run("replace keeps the place in the queue", func(t *testing.T) {
+		side := newOrderBookSide(LONG)
+		order1 := createLimitOrder(LONG, userAddress, big.NewInt(2), big.NewInt(100), Placed, big.NewInt(1), big.NewInt(1))
+		order2 := createLimitOrder(LONG, userAddress, big.NewInt(1), big.NewInt(100), Placed, big.NewInt(2), big.NewInt(2))
+		side.add(&order1)
+		side.add(&order2)
+
+		order3 := createLimitOrder(LONG, userAddress, big.NewInt(1), big.NewInt(100), Placed, big.NewInt(3), big.NewInt(3))
+		assert.True(t, side.replace(order1.Id, &order3))
+		assert.False(t, side.has(order1.Id))
+		orders := side.orders()
+		assert.Equal(t, 2, side.len())
+		assert.Equal(t, order3.Id, orders[0].Id)
+		assert.Equal(t, order2.Id, orders[1].Id)
+		assert.Equal(t, 0, side.queuePosition(order3.Id))
+
+		// can't replace with an order at a different price
+		order4 := createLimitOrder(LONG, userAddress, big.NewInt(1), big.NewInt(101), Placed, big.NewInt(3), big.NewInt(4))
+		assert.False(t, side.replace(order3.Id, &order4))
+		assert.True(t, side.has(order3.Id))
+	})
+}
@@ -0,0 +1,174 @@
+package orderbook
+
+import (
+	"math/big"
+
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ava-labs/subnet-evm/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// selfTradeChecker finds orders of the same trader, or of traders that share a trading authority, with the same rule as the juror.
+// Signed and trigger orders that self trade prevention cancels are removed from the memory DB. Orders placed on chain are only taken out
+// of the current matching run, because a validator can't cancel the limit orders of a trader that has enough margin.
+type selfTradeChecker struct {
+	mode          hu.SelfTradePreventionMode
+	db            LimitOrderDatabase
+	configService IConfigService
+	signers       map[common.Hash]common.Address
+}
+
+func newSelfTradeChecker(db LimitOrderDatabase, configService IConfigService, market Market) *selfTradeChecker {
+	return &selfTradeChecker{
+		mode:          configService.GetSelfTradePreventionMode(market),
+		db:            db,
+		configService: configService,
+		signers:       map[common.Hash]common.Address{},
+	}
+}
+
+func (checker *selfTradeChecker) isSelfTrade(order0, order1 *Order) bool {
+	if checker.mode == hu.NoSelfTradePrevention {
+		return false
+	}
+	return hu.IsSelfTrade(order0.Trader, checker.getSigner(order0), order1.Trader, checker.getSigner(order1), checker.configService.IsTradingAuthority)
+}
+
+// isSelfLiquidation returns true if the order would take over the position of a trader in its own trader group
+func (checker *selfTradeChecker) isSelfLiquidation(trader common.Address, order *Order) bool {
+	if checker.mode == hu.NoSelfTradePrevention {
+		return false
+	}
+	return hu.IsSelfTrade(trader, trader, order.Trader, checker.getSigner(order), checker.configService.IsTradingAuthority)
+}
+
+// getSigner returns the address that signed a signed or trigger order, and the trader for orders placed on chain
+func (checker *selfTradeChecker) getSigner(order *Order) common.Address {
+	if order.OrderType != Signed && order.OrderType != Trigger {
+		return order.Trader
+	}
+	if signer, ok := checker.signers[order.Id]; ok {
+		return signer
+	}
+	var sig []byte
+	switch rawOrder := order.RawOrder.(type) {
+	case *hu.SignedOrder:
+		sig = rawOrder.Sig
+	case *hu.TriggerOrder:
+		sig = rawOrder.Sig
+	}
+	signer := order.Trader
+	// order id is the hash that was signed
+	if recovered, err := hu.ECRecover(order.Id.Bytes(), sig); err == nil {
+		signer = recovered
+	}
+	checker.signers[order.Id] = signer
+	return signer
+}
+
+// preventSelfTrade cancels one or both of the orders as per the mode, by exhausting them in the local copy of the order book
+func (checker *selfTradeChecker) preventSelfTrade(longOrder, shortOrder *Order) {
+	maker, taker := shortOrder, longOrder
+	if isLongOrderMaker(*longOrder, *shortOrder) {
+		maker, taker = longOrder, shortOrder
+	}
+	log.Info("self trade prevented", "mode", checker.mode, "maker", maker.Id, "taker", taker.Id)
+	selfTradesPreventedCounter.Inc(1)
+
+	switch checker.mode {
+	case hu.CancelNewest:
+		checker.cancel(taker)
+	case hu.CancelOldest:
+		checker.cancel(maker)
+	case hu.CancelBoth:
+		checker.cancel(maker)
+		checker.cancel(taker)
+	case hu.DecrementAndCancel:
+		// the larger order is only decremented in this run
+		decrement := utils.BigIntMinAbs(longOrder.GetUnFilledBaseAssetQuantity(), shortOrder.GetUnFilledBaseAssetQuantity())
+		longOrder.FilledBaseAssetQuantity = new(big.Int).Add(longOrder.FilledBaseAssetQuantity, decrement)
+		shortOrder.FilledBaseAssetQuantity = new(big.Int).Sub(shortOrder.FilledBaseAssetQuantity, decrement)
+		checker.cancelIfExhausted(longOrder)
+		checker.cancelIfExhausted(shortOrder)
+	}
+}
+
+// preventSelfLiquidation applies the mode to a resting order of the liquidated trader's group. The liquidation itself is never cancelled
+// or reduced, so the order is always skipped for this liquidation; returns true if the order is also taken out of the rest of the run.
+func (checker *selfTradeChecker) preventSelfLiquidation(trader common.Address, order *Order, liquidationSize *big.Int) bool {
+	log.Info("self liquidation prevented", "mode", checker.mode, "trader", trader, "order", order.Id)
+	selfTradesPreventedCounter.Inc(1)
+
+	switch checker.mode {
+	case hu.CancelOldest, hu.CancelBoth:
+		// order's FilledBaseAssetQuantity is shared with the order in the order map of the run
+		order.FilledBaseAssetQuantity.Set(order.BaseAssetQuantity)
+	case hu.DecrementAndCancel:
+		decrement := utils.BigIntMinAbs(order.GetUnFilledBaseAssetQuantity(), liquidationSize)
+		if order.PositionType == LONG {
+			order.FilledBaseAssetQuantity.Add(order.FilledBaseAssetQuantity, decrement)
+		} else {
+			order.FilledBaseAssetQuantity.Sub(order.FilledBaseAssetQuantity, decrement)
+		}
+	}
+	return checker.cancelIfExhausted(order)
+}
+
+// preventSelfTradeInLevel applies self trade prevention between the taker and the first resting order in makers of the same trader group.
+// Returns true if an order was cancelled
+func (checker *selfTradeChecker) preventSelfTradeInLevel(taker *Order, makers []Order, longIsMaker bool) bool {
+	for i := range makers {
+		if !checker.isSelfTrade(taker, &makers[i]) {
+			continue
+		}
+		if longIsMaker {
+			checker.preventSelfTrade(&makers[i], taker)
+		} else {
+			checker.preventSelfTrade(taker, &makers[i])
+		}
+		return true
+	}
+	return false
+}
+
+// preventSelfTradesInAuction applies self trade prevention between every pair of crossing orders of the same trader group in a batch auction.
+// After this, an auction can't fill orders of the same trader group on both sides, which the juror rejects
+func (checker *selfTradeChecker) preventSelfTradesInAuction(longOrders, shortOrders []Order) {
+	if checker.mode == hu.NoSelfTradePrevention {
+		return
+	}
+	for i := range longOrders {
+		for j := range shortOrders {
+			if longOrders[i].GetUnFilledBaseAssetQuantity().Sign() == 0 {
+				break
+			}
+			if shortOrders[j].GetUnFilledBaseAssetQuantity().Sign() == 0 || longOrders[i].Price.Cmp(shortOrders[j].Price) < 0 {
+				continue
+			}
+			if checker.isSelfTrade(&longOrders[i], &shortOrders[j]) {
+				checker.preventSelfTrade(&longOrders[i], &shortOrders[j])
+			}
+		}
+	}
+}
+
+// cancel marks the order as completely filled, so that it is not matched again in this run, and cancels it in the memory DB
+func (checker *selfTradeChecker) cancel(order *Order) {
+	order.FilledBaseAssetQuantity = new(big.Int).Set(order.BaseAssetQuantity)
+	checker.cancelIfExhausted(order)
+}
+
+// cancelIfExhausted cancels a signed or trigger order in the memory DB once self trade prevention has used up its unfilled quantity.
+// Returns true if the order is exhausted
+func (checker *selfTradeChecker) cancelIfExhausted(order *Order) bool {
+	if order.GetUnFilledBaseAssetQuantity().Sign() != 0 {
+		return false
+	}
+	if order.OrderType == Signed || order.OrderType == Trigger {
+		if err := checker.db.CancelSelfTradeOrder(order.Id, checker.mode); err != nil {
+			log.Error("could not cancel self trade order", "order", order.Id, "err", err)
+		}
+	}
+	return true
+}
@@ -0,0 +1,143 @@
+package orderbook
+
+import (
+	"math/big"
+	"testing"
+
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type selfTradePreventionConfigService struct {
+	*MockConfigService
+	mode hu.SelfTradePreventionMode
+}
+
+func (cs *selfTradePreventionConfigService) GetSelfTradePreventionMode(market Market) hu.SelfTradePreventionMode {
+	return cs.mode
+}
+
+func setupSelfTradePrevention(t *testing.T, mode hu.SelfTradePreventionMode) (*MockLimitOrderTxProcessor, *MatchingPipeline, *MockConfigService) {
+	_, lotp, pipeline, _, cs := setupDependencies(t)
+	pipeline.configService = &selfTradePreventionConfigService{MockConfigService: cs, mode: mode}
+	return lotp, pipeline, cs
+}
+
+func TestSelfTradePreventionInMatchingEngine(t *testing.T) {
+	minAllowableMargin := big.NewInt(1e6)
+	takerFee := big.NewInt(1e6)
+	upperBound := big.NewInt(22)
+	otherTrader := "0x710bf5f942331874dcbc7783319123679033b63b"
+	getMarginMap := func() map[common.Address]*big.Int {
+		return map[common.Address]*big.Int{
+			common.HexToAddress(userAddress): big.NewInt(0), // limit orders don't need any available margin
+			common.HexToAddress(otherTrader): big.NewInt(0),
+		}
+	}
+	// resting long order of the trader, its own short order that arrived later and a short order of another trader
+	getOrders := func() (Order, Order, Order) {
+		longOrder := createLimitOrder(LONG, userAddress, big.NewInt(10), big.NewInt(20), Placed, big.NewInt(2), big.NewInt(1))
+		selfShortOrder := createLimitOrder(SHORT, userAddress, big.NewInt(-4), big.NewInt(20), Placed, big.NewInt(3), big.NewInt(2))
+		otherShortOrder := createLimitOrder(SHORT, otherTrader, big.NewInt(-10), big.NewInt(20), Placed, big.NewInt(4), big.NewInt(3))
+		return longOrder, selfShortOrder, otherShortOrder
+	}
+
+	t.Run("self trades are matched when self trade prevention is disabled", func(t *testing.T) {
+		lotp, pipeline, _ := setupSelfTradePrevention(t, hu.NoSelfTradePrevention)
+		longOrder, selfShortOrder, otherShortOrder := getOrders()
+		lotp.On("ExecuteMatchedOrdersTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+		pipeline.runMatchingEngine(lotp, []Order{longOrder}, []Order{selfShortOrder, otherShortOrder}, getMarginMap(), minAllowableMargin, takerFee, upperBound)
+		lotp.AssertCalled(t, "ExecuteMatchedOrdersTx", longOrder, selfShortOrder, big.NewInt(4))
+	})
+	t.Run("cancel newest skips the taker", func(t *testing.T) {
+		lotp, pipeline, _ := setupSelfTradePrevention(t, hu.CancelNewest)
+		longOrder, selfShortOrder, otherShortOrder := getOrders()
+		lotp.On("ExecuteMatchedOrdersTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+		pipeline.runMatchingEngine(lotp, []Order{longOrder}, []Order{selfShortOrder, otherShortOrder}, getMarginMap(), minAllowableMargin, takerFee, upperBound)
+		lotp.AssertNumberOfCalls(t, "ExecuteMatchedOrdersTx", 1)
+		lotp.AssertCalled(t, "ExecuteMatchedOrdersTx", longOrder, otherShortOrder, big.NewInt(10))
+	})
+	t.Run("cancel oldest skips the resting order", func(t *testing.T) {
+		lotp, pipeline, _ := setupSelfTradePrevention(t, hu.CancelOldest)
+		longOrder, selfShortOrder, otherShortOrder := getOrders()
+
+		pipeline.runMatchingEngine(lotp, []Order{longOrder}, []Order{selfShortOrder, otherShortOrder}, getMarginMap(), minAllowableMargin, takerFee, upperBound)
+		lotp.AssertNotCalled(t, "ExecuteMatchedOrdersTx", mock.Anything, mock.Anything, mock.Anything)
+	})
+	t.Run("cancel oldest cancels a resting signed order in the memory DB", func(t *testing.T) {
+		lotp, pipeline, _ := setupSelfTradePrevention(t, hu.CancelOldest)
+		db := pipeline.db.(*MockLimitOrderDatabase)
+		signedOrder := createSignedOrder(LONG, userAddress, big.NewInt(10), big.NewInt(20), big.NewInt(1), false)
+		_, selfShortOrder, _ := getOrders()
+		db.On("CancelSelfTradeOrder", signedOrder.Id, hu.CancelOldest).Return(nil)
+
+		pipeline.runMatchingEngine(lotp, []Order{signedOrder}, []Order{selfShortOrder}, getMarginMap(), minAllowableMargin, takerFee, upperBound)
+		lotp.AssertNotCalled(t, "ExecuteMatchedOrdersTx", mock.Anything, mock.Anything, mock.Anything)
+		db.AssertCalled(t, "CancelSelfTradeOrder", signedOrder.Id, hu.CancelOldest)
+	})
+	t.Run("decrement and cancel reduces the larger order", func(t *testing.T) {
+		lotp, pipeline, _ := setupSelfTradePrevention(t, hu.DecrementAndCancel)
+		longOrder, selfShortOrder, otherShortOrder := getOrders()
+		lotp.On("ExecuteMatchedOrdersTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+		pipeline.runMatchingEngine(lotp, []Order{longOrder}, []Order{selfShortOrder, otherShortOrder}, getMarginMap(), minAllowableMargin, takerFee, upperBound)
+		lotp.AssertNumberOfCalls(t, "ExecuteMatchedOrdersTx", 1)
+		assert.Equal(t, otherShortOrder.Id, lotp.Calls[0].Arguments.Get(1).(Order).Id)
+		assert.Equal(t, big.NewInt(6), lotp.Calls[0].Arguments.Get(2))
+	})
+	t.Run("allocation engine skips the resting orders of the taker's group", func(t *testing.T) {
+		lotp, pipeline, _ := setupSelfTradePrevention(t, hu.CancelOldest)
+		longOrder, selfShortOrder, otherShortOrder := getOrders()
+		// both short orders rest at the same level and the long order arrives later
+		longOrder.BlockNumber = big.NewInt(5)
+		lotp.On("ExecuteMatchedOrdersTx", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+		pipeline.runAllocationMatchingEngine(lotp, []Order{longOrder}, []Order{selfShortOrder, otherShortOrder}, getMarginMap(), minAllowableMargin, takerFee, upperBound, big.NewInt(1), false)
+		lotp.AssertNumberOfCalls(t, "ExecuteMatchedOrdersTx", 1)
+		assert.Equal(t, otherShortOrder.Id, lotp.Calls[0].Arguments.Get(1).(Order).Id)
+		assert.Equal(t, big.NewInt(10), lotp.Calls[0].Arguments.Get(2))
+	})
+	t.Run("batch auction leaves out the orders that self trade prevention cancels", func(t *testing.T) {
+		lotp, pipeline, cs := setupSelfTradePrevention(t, hu.CancelNewest)
+		cs.On("GetAcceptableBounds").Return(big.NewInt(22e6), big.NewInt(18e6))
+		longOrder, selfShortOrder, otherShortOrder := getOrders()
+		for _, order := range []*Order{&longOrder, &selfShortOrder, &otherShortOrder} {
+			order.Price = big.NewInt(20e6)
+		}
+		lotp.On("ExecuteBatchAuctionTx", mock.Anything, mock.Anything).Return(nil)
+
+		pipeline.runBatchAuction(lotp, market, []Order{longOrder}, []Order{selfShortOrder, otherShortOrder}, getMarginMap(), minAllowableMargin, takerFee, big.NewInt(20e6))
+		lotp.AssertNumberOfCalls(t, "ExecuteBatchAuctionTx", 1)
+		orders := lotp.Calls[0].Arguments.Get(0).([]Order)
+		assert.Equal(t, []common.Hash{longOrder.Id, otherShortOrder.Id}, []common.Hash{orders[0].Id, orders[1].Id})
+		assert.Equal(t, bigInts(10, -10), lotp.Calls[0].Arguments.Get(1).([]*big.Int))
+	})
+}
+
+func TestSelfTradePreventionInLiquidations(t *testing.T) {
+	otherTrader := "0x710bf5f942331874dcbc7783319123679033b63b"
+	liqUpperBound := big.NewInt(22)
+	liqLowerBound := big.NewInt(18)
+
+	lotp, pipeline, cs := setupSelfTradePrevention(t, hu.CancelNewest)
+	cs.On("GetAcceptableBoundsForLiquidation", market).Return(liqUpperBound, liqLowerBound)
+	cs.On("GetMinAllowableMargin").Return(big.NewInt(1e5))
+	cs.On("GetTakerFee").Return(big.NewInt(1e5))
+	lotp.On("ExecuteLiquidation", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	selfLongOrder := createLimitOrder(LONG, userAddress, big.NewInt(10), big.NewInt(20), Placed, big.NewInt(2), big.NewInt(1))
+	otherLongOrder := createLimitOrder(LONG, otherTrader, big.NewInt(10), big.NewInt(20), Placed, big.NewInt(3), big.NewInt(2))
+	orderMap := map[Market]*Orders{market: {[]Order{selfLongOrder, otherLongOrder}, []Order{}}}
+	liquidable := getLiquidablePos(common.HexToAddress(userAddress), LONG, 10)
+
+	pipeline.runLiquidations([]LiquidablePosition{liquidable}, orderMap, map[Market]*big.Int{market: big.NewInt(20)}, map[common.Address]*big.Int{})
+	lotp.AssertNumberOfCalls(t, "ExecuteLiquidation", 1)
+	assert.Equal(t, otherLongOrder.Id, lotp.Calls[0].Arguments.Get(1).(Order).Id)
+	// the trader's own order is skipped for the liquidation but can still be matched
+	assert.Equal(t, 1, len(orderMap[market].longOrders))
+	assert.Equal(t, selfLongOrder.Id, orderMap[market].longOrders[0].Id)
+}
@@ -5,6 +5,7 @@ package orderbook
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
 	"runtime"
@@ -17,6 +18,7 @@ import (
 	"github.com/ava-labs/subnet-evm/eth"
 	"github.com/ava-labs/subnet-evm/metrics"
 	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ava-labs/subnet-evm/precompile/contracts/bibliophile"
 	"github.com/ava-labs/subnet-evm/rpc"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/event"
@@ -78,9 +80,14 @@ type GetDebugDataResponse struct {
 	LastPrice        map[Market]*big.Int
 	OraclePrice      map[Market]*big.Int
 	MidPrice         map[Market]*big.Int
+	// liquidation price of every position, 0 when it can't be liquidated by a change in the oracle price of its market alone
+	LiquidationPrice      map[common.Address]map[Market]*big.Int
+	DistanceToLiquidation map[common.Address]*big.Int
 }
 
-func (api *OrderBookAPI) GetDebugData(ctx context.Context, trader string) GetDebugDataResponse {
+// GetDebugData returns the accounts of all the traders, or of one trader, from the memory DB.
+// When blockNumber is given, the account of the trader is read from the chain state after the block, so trader is required then
+func (api *OrderBookAPI) GetDebugData(ctx context.Context, trader string, blockNumber *rpc.BlockNumber) (GetDebugDataResponse, error) {
 	traderHash := common.HexToAddress(trader)
 	response := GetDebugDataResponse{
 		MarginFraction:   map[common.Address]*big.Int{},
@@ -93,28 +100,53 @@ func (api *OrderBookAPI) GetDebugData(ctx context.Context, trader string) GetDeb
 		ReservedMargin:   map[common.Address]*big.Int{},
 		LastPrice:        map[Market]*big.Int{},
 		OraclePrice:      map[Market]*big.Int{},
+
+		LiquidationPrice:      map[common.Address]map[Market]*big.Int{},
+		DistanceToLiquidation: map[common.Address]*big.Int{},
 	}
 
-	traderMap := api.db.GetAllTraders()
-	if trader != "" {
+	configService := api.configService
+	var traderMap map[common.Address]Trader
+	var lastPrices map[Market]*big.Int
+	if blockNumber != nil {
+		if trader == "" {
+			return response, errors.New("trader is required with blockNumber")
+		}
+		stateDB, err := getStateAtBlock(ctx, api.backend, *blockNumber)
+		if err != nil {
+			return response, err
+		}
+		configService = NewConfigServiceFromStateDB(stateDB)
 		traderMap = map[common.Address]Trader{
-			traderHash: traderMap[traderHash],
+			traderHash: *getTraderFromState(stateDB, configService, traderHash),
+		}
+		lastPrices = map[Market]*big.Int{}
+		for i, marketAddress := range bibliophile.GetMarkets(stateDB) {
+			lastPrices[Market(i)] = bibliophile.GetLastPrice(stateDB, marketAddress)
 		}
+	} else {
+		traderMap = api.db.GetAllTraders()
+		if trader != "" {
+			traderMap = map[common.Address]Trader{
+				traderHash: traderMap[traderHash],
+			}
+		}
+		lastPrices = api.db.GetLastPrices()
 	}
 
-	prices := api.configService.GetUnderlyingPrices()
-	mPrices := api.configService.GetMidPrices()
+	prices := configService.GetUnderlyingPrices()
+	mPrices := configService.GetMidPrices()
 
 	oraclePrices := map[Market]*big.Int{}
 	midPrices := map[Market]*big.Int{}
-	count := api.configService.GetActiveMarketsCount()
+	count := configService.GetActiveMarketsCount()
 	markets := make([]Market, count)
 	for i := int64(0); i < count; i++ {
 		markets[i] = Market(i)
 		oraclePrices[Market(i)] = prices[Market(i)]
 		midPrices[Market(i)] = mPrices[Market(i)]
 	}
-	assets := api.configService.GetCollaterals()
+	assets := configService.GetCollaterals()
 	for addr, trader := range traderMap {
 		pendingFunding := getTotalFunding(&trader, markets)
 		margin := new(big.Int).Sub(getNormalisedMargin(&trader, assets), pendingFunding)
@@ -124,8 +156,8 @@ func (api *OrderBookAPI) GetDebugData(ctx context.Context, trader string) GetDeb
 			OraclePrices:       oraclePrices,
 			MidPrices:          midPrices,
 			ActiveMarkets:      markets,
-			MinAllowableMargin: api.configService.GetMinAllowableMargin(),
-			MaintenanceMargin:  api.configService.GetMaintenanceMargin(),
+			MinAllowableMargin: configService.GetMinAllowableMargin(),
+			MaintenanceMargin:  configService.GetMaintenanceMargin(),
 		}
 		marginFraction := calcMarginFraction(&trader, hState)
 		availableMargin := getAvailableMargin(&trader, hState)
@@ -139,12 +171,13 @@ func (api *OrderBookAPI) GetDebugData(ctx context.Context, trader string) GetDeb
 		response.NotionalPosition[addr] = notionalPosition
 		response.UnrealizePnL[addr] = unrealizePnL
 		response.ReservedMargin[addr] = trader.Margin.Reserved
+		response.LiquidationPrice[addr], response.DistanceToLiquidation[addr] = getLiquidationPrices(&trader, hState)
 	}
 
-	response.LastPrice = api.db.GetLastPrices()
+	response.LastPrice = lastPrices
 	response.OraclePrice = oraclePrices
 	response.MidPrice = midPrices
-	return response
+	return response, nil
 }
 
 func (api *OrderBookAPI) GetDetailedOrderBookData(ctx context.Context) InMemoryDatabase {
@@ -208,6 +241,11 @@ func (api *OrderBookAPI) GetOpenOrders(ctx context.Context, trader string, marke
 	return &OpenOrdersResponse{Orders: traderOrders}, nil
 }
 
+// VerifyState compares positions, margins and orders in the memory DB with the contract storage at the last accepted block
+func (api *OrderBookAPI) VerifyState(ctx context.Context) (*StateVerificationReport, error) {
+	return NewStateAuditor(api.db, api.backend).Verify(ctx)
+}
+
 // NewOrderBookState send a notification each time a new (header) block is appended to the chain.
 func (api *OrderBookAPI) NewOrderBookState(ctx context.Context) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
@@ -250,27 +288,15 @@ func (api *OrderBookAPI) GetDepthForMarket(ctx context.Context, market int) *Mar
 }
 
 // used by UI
+// The first notification is the depth of the market, followed by the updates to it as they happen
 func (api *OrderBookAPI) StreamDepthUpdateForMarket(ctx context.Context, market int) (*rpc.Subscription, error) {
 	notifier, _ := rpc.NotifierFromContext(ctx)
 	rpcSub := notifier.CreateSubscription()
 
-	ticker := time.NewTicker(1 * time.Second)
-
-	var oldMarketDepth = &MarketDepth{}
-
 	go executeFuncAndRecoverPanic(func() {
-		for {
-			select {
-			case <-ticker.C:
-				newMarketDepth := getDepthForMarket(api.db, Market(market))
-				depthUpdate := getUpdateInDepth(newMarketDepth, oldMarketDepth)
-				notifier.Notify(rpcSub.ID, depthUpdate)
-				oldMarketDepth = newMarketDepth
-			case <-notifier.Closed():
-				ticker.Stop()
-				return
-			}
-		}
+		streamDepthUpdates(api.db, Market(market), 0, notifier.Closed(), func(depth *MarketDepth, _ bool) {
+			notifier.Notify(rpcSub.ID, depth)
+		})
 	}, "panic in StreamDepthUpdateForMarket", RPCPanicsCounter)
 
 	return rpcSub, nil
@@ -278,6 +304,7 @@ func (api *OrderBookAPI) StreamDepthUpdateForMarket(ctx context.Context, market
 
 // used by UI
 // @todo: this is a duplicate of StreamDepthUpdateForMarket with a param for update frequency. Need to remove the original function later and keep this one.
+// Updates are batched and sent every updateFreq; FirstUpdateID and LastUpdateID of a batch can be used to detect missed updates.
 func (api *OrderBookAPI) StreamDepthUpdateForMarketAndFreq(ctx context.Context, market int, updateFreq string) (*rpc.Subscription, error) {
 	notifier, _ := rpc.NotifierFromContext(ctx)
 	rpcSub := notifier.CreateSubscription()
@@ -289,57 +316,81 @@ func (api *OrderBookAPI) StreamDepthUpdateForMarketAndFreq(ctx context.Context,
 	if err != nil {
 		return nil, fmt.Errorf("invalid update frequency %s", updateFreq)
 	}
-	ticker := time.NewTicker(duration)
-
-	var oldMarketDepth = &MarketDepth{}
 
 	go executeFuncAndRecoverPanic(func() {
-		for {
-			select {
-			case <-ticker.C:
-				newMarketDepth := getDepthForMarket(api.db, Market(market))
-				depthUpdate := getUpdateInDepth(newMarketDepth, oldMarketDepth)
-				notifier.Notify(rpcSub.ID, depthUpdate)
-				oldMarketDepth = newMarketDepth
-			case <-notifier.Closed():
-				ticker.Stop()
-				return
-			}
-		}
+		streamDepthUpdates(api.db, Market(market), duration, notifier.Closed(), func(depth *MarketDepth, _ bool) {
+			notifier.Notify(rpcSub.ID, depth)
+		})
 	}, "panic in StreamDepthUpdateForMarketAndFreq", RPCPanicsCounter)
 
 	return rpcSub, nil
 }
 
-func getUpdateInDepth(newMarketDepth *MarketDepth, oldMarketDepth *MarketDepth) *MarketDepth {
-	var diff = &MarketDepth{
-		Market: newMarketDepth.Market,
-		Longs:  map[string]string{},
-		Shorts: map[string]string{},
+// streamDepthUpdates calls notify with the depth of market, and then with the depth updates that follow it till closed is closed.
+// Updates are sent as they happen if interval is 0, otherwise they are batched and sent every interval.
+// Update IDs are gap free; if the subscriber falls behind, notify is called with a fresh snapshot of the depth.
+func streamDepthUpdates(db LimitOrderDatabase, market Market, interval time.Duration, closed <-chan interface{}, notify func(depth *MarketDepth, isSnapshot bool)) {
+	var tickerCh <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tickerCh = ticker.C
 	}
-	for price, depth := range newMarketDepth.Longs {
-		oldDepth := oldMarketDepth.Longs[price]
-		if oldDepth != depth {
-			diff.Longs[price] = depth
-		}
+
+	subscribe := func() *DepthSubscription {
+		snapshot, sub := db.SubscribeDepthUpdates(market)
+		snapshot.Depth.LastUpdateID = snapshot.LastUpdateID
+		notify(snapshot.Depth, true)
+		return sub
 	}
-	for price := range oldMarketDepth.Longs {
-		if newMarketDepth.Longs[price] == "" {
-			diff.Longs[price] = big.NewInt(0).String()
+	sub := subscribe()
+	defer func() { sub.Unsubscribe() }()
+
+	batch := newDepthBatch(market)
+	for {
+		select {
+		case update, ok := <-sub.Updates:
+			if !ok {
+				depthResyncsCounter.Inc(1)
+				batch = newDepthBatch(market)
+				sub = subscribe()
+				continue
+			}
+			batch.add(update)
+			if interval == 0 {
+				notify(batch, false)
+				batch = newDepthBatch(market)
+			}
+		case <-tickerCh:
+			if batch.LastUpdateID != 0 {
+				notify(batch, false)
+				batch = newDepthBatch(market)
+			}
+		case <-closed:
+			return
 		}
 	}
-	for price, depth := range newMarketDepth.Shorts {
-		oldDepth := oldMarketDepth.Shorts[price]
-		if oldDepth != depth {
-			diff.Shorts[price] = depth
-		}
+}
+
+func newDepthBatch(market Market) *MarketDepth {
+	return &MarketDepth{
+		Market: market,
+		Longs:  map[string]string{},
+		Shorts: map[string]string{},
 	}
-	for price := range oldMarketDepth.Shorts {
-		if newMarketDepth.Shorts[price] == "" {
-			diff.Shorts[price] = big.NewInt(0).String()
-		}
+}
+
+// add applies a depth update to a batch of updates
+func (depth *MarketDepth) add(update DepthUpdate) {
+	if depth.FirstUpdateID == 0 {
+		depth.FirstUpdateID = update.UpdateID
+	}
+	depth.LastUpdateID = update.UpdateID
+	if update.PositionType == LONG {
+		depth.Longs[update.Price.String()] = update.Quantity.String()
+	} else {
+		depth.Shorts[update.Price.String()] = update.Quantity.String()
 	}
-	return diff
 }
 
 func getDepthForMarket(db LimitOrderDatabase, market Market) *MarketDepth {
@@ -372,9 +423,11 @@ func aggregateOrdersByPrice(orders []Order) map[string]string {
 }
 
 type MarketDepth struct {
-	Market Market            `json:"market"`
-	Longs  map[string]string `json:"longs"`
-	Shorts map[string]string `json:"shorts"`
+	Market        Market            `json:"market"`
+	Longs         map[string]string `json:"longs"`
+	Shorts        map[string]string `json:"shorts"`
+	FirstUpdateID uint64            `json:"firstUpdateId,omitempty"` // only set in depth updates
+	LastUpdateID  uint64            `json:"lastUpdateId,omitempty"`
 }
 
 func executeFuncAndRecoverPanic(fn func(), panicMessage string, panicCounter metrics.Counter) {
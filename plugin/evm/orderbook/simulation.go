@@ -0,0 +1,214 @@
+package orderbook
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ava-labs/subnet-evm/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var ErrInsufficientLiquidity = errors.New("not enough liquidity in the order book to fill the order")
+
+// SimulatedOrder is an order to simulate. Price is 0 for a market order, which has to be filled completely by the order book
+type SimulatedOrder struct {
+	Market Market   `json:"market"`
+	Size   *big.Int `json:"size"`  // 18 decimals, negative for shorts
+	Price  *big.Int `json:"price"` // 6 decimals
+}
+
+// SimulateOrderResponse is the state of a trader after an order is placed. The part of a limit order that crosses the book is filled as a taker
+// and the rest stays on the book, reserving margin
+type SimulateOrderResponse struct {
+	Market           Market `json:"market"`
+	FilledSize       string `json:"filledSize"`
+	AvgFillPrice     string `json:"avgFillPrice"`
+	RestingSize      string `json:"restingSize"`
+	Fee              string `json:"fee"`
+	RealizedPnl      string `json:"realizedPnl"`
+	RequiredMargin   string `json:"requiredMargin"` // reserved for the resting part
+	Size             string `json:"size"`
+	OpenNotional     string `json:"openNotional"`
+	NotionalPosition string `json:"notionalPosition"` // of all the positions of the trader
+	MarginFraction   string `json:"marginFraction"`
+	AvailableMargin  string `json:"availableMargin"`
+	LiquidationPrice string `json:"liquidationPrice"`
+}
+
+// orderSimulator applies orders to a copy of the state of a trader.
+// consumed tracks the quantity of book orders filled by the previous orders of a batch, so that liquidity is not used twice
+type orderSimulator struct {
+	db              LimitOrderDatabase
+	hState          *hu.HubbleState
+	userState       *hu.UserState
+	virtualReserved *big.Int
+	consumed        map[common.Hash]*big.Int
+}
+
+func newOrderSimulator(db LimitOrderDatabase, configService IConfigService, trader common.Address) *orderSimulator {
+	hState := GetHubbleState(configService)
+	userState := &hu.UserState{
+		Positions:      map[Market]*hu.Position{},
+		Margins:        make([]*big.Int, len(hState.Assets)),
+		PendingFunding: big.NewInt(0),
+		ReservedMargin: big.NewInt(0),
+	}
+	virtualReserved := big.NewInt(0)
+	if traderInfo := db.GetTraderInfo(trader); traderInfo != nil {
+		userState.Positions = translatePositions(traderInfo.Positions)
+		userState.Margins = getMargins(traderInfo, len(hState.Assets))
+		userState.PendingFunding = getTotalFunding(traderInfo, hState.ActiveMarkets)
+		if traderInfo.Margin.Reserved != nil {
+			userState.ReservedMargin = new(big.Int).Set(traderInfo.Margin.Reserved)
+		}
+		if traderInfo.Margin.VirtualReserved != nil {
+			virtualReserved = traderInfo.Margin.VirtualReserved
+		}
+	}
+	for i := range userState.Margins {
+		if userState.Margins[i] == nil {
+			userState.Margins[i] = big.NewInt(0)
+		} else {
+			userState.Margins[i] = new(big.Int).Set(userState.Margins[i])
+		}
+	}
+	return &orderSimulator{
+		db:              db,
+		hState:          hState,
+		userState:       userState,
+		virtualReserved: virtualReserved,
+		consumed:        map[common.Hash]*big.Int{},
+	}
+}
+
+func (sim *orderSimulator) simulate(order SimulatedOrder) (SimulateOrderResponse, error) {
+	if order.Size == nil || order.Size.Sign() == 0 {
+		return SimulateOrderResponse{}, errors.New("size should not be 0")
+	}
+	if int64(order.Market) < 0 || int(order.Market) >= len(sim.hState.ActiveMarkets) {
+		return SimulateOrderResponse{}, fmt.Errorf("invalid market %d", order.Market)
+	}
+	price := order.Price
+	if price == nil {
+		price = big.NewInt(0)
+	}
+
+	var bookOrders []Order
+	if order.Size.Sign() > 0 {
+		var upperbound *big.Int
+		if price.Sign() > 0 {
+			upperbound = price
+		}
+		bookOrders = sim.db.GetShortOrders(order.Market, upperbound, nil)
+	} else {
+		var lowerbound *big.Int
+		if price.Sign() > 0 {
+			lowerbound = price
+		}
+		bookOrders = sim.db.GetLongOrders(order.Market, lowerbound, nil)
+	}
+	filled, notional := sim.getQuote(bookOrders, hu.Abs(order.Size))
+	resting := hu.Sub(hu.Abs(order.Size), filled)
+	if price.Sign() == 0 && resting.Sign() > 0 {
+		return SimulateOrderResponse{}, ErrInsufficientLiquidity
+	}
+
+	avgFillPrice := big.NewInt(0)
+	if filled.Sign() > 0 {
+		avgFillPrice = hu.Div(hu.Mul1e6(notional), filled)
+	}
+	fillAmount := new(big.Int).Set(filled)
+	if order.Size.Sign() < 0 {
+		fillAmount.Neg(fillAmount)
+	}
+	quoteAsset := hu.Unscale(notional, 12) // 6 decimals
+	fee := hu.Div1e6(hu.Mul(quoteAsset, sim.hState.TakerFee))
+
+	position := sim.userState.Positions[order.Market]
+	if position == nil {
+		position = &hu.Position{Size: big.NewInt(0), OpenNotional: big.NewInt(0)}
+		sim.userState.Positions[order.Market] = position
+	}
+	realizedPnl := applyFill(position, fillAmount, quoteAsset)
+	// realized pnl and fees are settled in the margin of the first collateral
+	if len(sim.userState.Margins) > int(HUSD) {
+		sim.userState.Margins[HUSD] = hu.Sub(hu.Add(sim.userState.Margins[HUSD], realizedPnl), fee)
+	}
+
+	requiredMargin := big.NewInt(0)
+	if resting.Sign() > 0 {
+		requiredMargin = hu.GetRequiredMargin(price, resting, sim.hState.MinAllowableMargin, sim.hState.TakerFee)
+		sim.userState.ReservedMargin = hu.Add(sim.userState.ReservedMargin, requiredMargin)
+	}
+
+	notionalPosition, _ := hu.GetNotionalPositionAndMargin(sim.hState, sim.userState, hu.Maintenance_Margin)
+	return SimulateOrderResponse{
+		Market:           order.Market,
+		FilledSize:       utils.BigIntToDecimal(fillAmount, 18, 8),
+		AvgFillPrice:     utils.BigIntToDecimal(avgFillPrice, 6, 8),
+		RestingSize:      utils.BigIntToDecimal(resting, 18, 8),
+		Fee:              utils.BigIntToDecimal(fee, 6, 8),
+		RealizedPnl:      utils.BigIntToDecimal(realizedPnl, 6, 8),
+		RequiredMargin:   utils.BigIntToDecimal(requiredMargin, 6, 8),
+		Size:             utils.BigIntToDecimal(position.Size, 18, 8),
+		OpenNotional:     utils.BigIntToDecimal(position.OpenNotional, 6, 8),
+		NotionalPosition: utils.BigIntToDecimal(notionalPosition, 6, 8),
+		MarginFraction:   utils.BigIntToDecimal(hu.GetMarginFraction(sim.hState, sim.userState), 6, 8),
+		AvailableMargin:  utils.BigIntToDecimal(hu.Sub(hu.GetAvailableMargin(sim.hState, sim.userState), sim.virtualReserved), 6, 8),
+		LiquidationPrice: utils.BigIntToDecimal(hu.GetLiquidationPrice(sim.hState, sim.userState, order.Market), 6, 8),
+	}, nil
+}
+
+// getQuote walks the orders the same way as ticks.GetQuote, but stops at the end of the orders instead of returning 0 when there is not enough liquidity.
+// Returns the filled quantity and its notional with 18 decimals
+func (sim *orderSimulator) getQuote(orders []Order, baseAssetQuantity *big.Int) (*big.Int, *big.Int) {
+	accNotional := big.NewInt(0) // 18 decimals
+	accBaseQ := big.NewInt(0)    // 18 decimals
+	for _, order := range orders {
+		consumed := sim.consumed[order.Id]
+		if consumed == nil {
+			consumed = big.NewInt(0)
+		}
+		amount := hu.Sub(hu.Abs(order.GetUnFilledBaseAssetQuantity()), consumed)
+		if amount.Sign() <= 0 {
+			continue
+		}
+		amount = utils.BigIntMin(amount, hu.Sub(baseAssetQuantity, accBaseQ))
+		accNotional.Add(accNotional, hu.Div1e6(hu.Mul(amount, order.Price)))
+		accBaseQ.Add(accBaseQ, amount)
+		sim.consumed[order.Id] = hu.Add(consumed, amount)
+		if accBaseQ.Cmp(baseAssetQuantity) >= 0 {
+			break
+		}
+	}
+	return accBaseQ, accNotional
+}
+
+// applyFill updates the position the same way as the clearing house and returns the realized pnl
+func applyFill(position *hu.Position, fillAmount, quoteAsset *big.Int) *big.Int {
+	if position.Size.Sign() == 0 || position.Size.Sign() == fillAmount.Sign() {
+		position.Size = hu.Add(position.Size, fillAmount)
+		position.OpenNotional = hu.Add(position.OpenNotional, quoteAsset)
+		return big.NewInt(0)
+	}
+
+	size := hu.Abs(position.Size)
+	closed := utils.BigIntMin(size, hu.Abs(fillAmount))
+	closedOpenNotional := hu.Div(hu.Mul(position.OpenNotional, closed), size)
+	closedQuoteAsset := hu.Div(hu.Mul(quoteAsset, closed), hu.Abs(fillAmount))
+	realizedPnl := hu.Sub(closedQuoteAsset, closedOpenNotional)
+	if position.Size.Sign() < 0 {
+		realizedPnl.Neg(realizedPnl)
+	}
+
+	position.Size = hu.Add(position.Size, fillAmount)
+	if closed.Cmp(size) < 0 {
+		position.OpenNotional = hu.Sub(position.OpenNotional, closedOpenNotional)
+	} else {
+		// the position is closed, and flipped if the fill is larger than it
+		position.OpenNotional = hu.Sub(quoteAsset, closedQuoteAsset)
+	}
+	return realizedPnl
+}
@@ -0,0 +1,108 @@
+package orderbook
+
+import (
+	"math/big"
+	"testing"
+
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyFill(t *testing.T) {
+	t.Run("open and increase", func(t *testing.T) {
+		position := &hu.Position{Size: big.NewInt(0), OpenNotional: big.NewInt(0)}
+		assert.Equal(t, big.NewInt(0), applyFill(position, hu.Mul1e18(big.NewInt(2)), hu.Mul1e6(big.NewInt(200))))
+		assert.Equal(t, big.NewInt(0), applyFill(position, hu.Mul1e18(big.NewInt(1)), hu.Mul1e6(big.NewInt(130))))
+		assert.Equal(t, hu.Mul1e18(big.NewInt(3)), position.Size)
+		assert.Equal(t, hu.Mul1e6(big.NewInt(330)), position.OpenNotional)
+	})
+	t.Run("reduce long", func(t *testing.T) {
+		position := &hu.Position{Size: hu.Mul1e18(big.NewInt(4)), OpenNotional: hu.Mul1e6(big.NewInt(400))}
+		realizedPnl := applyFill(position, hu.Mul1e18(big.NewInt(-1)), hu.Mul1e6(big.NewInt(110)))
+		assert.Equal(t, hu.Mul1e6(big.NewInt(10)), realizedPnl)
+		assert.Equal(t, hu.Mul1e18(big.NewInt(3)), position.Size)
+		assert.Equal(t, hu.Mul1e6(big.NewInt(300)), position.OpenNotional)
+	})
+	t.Run("flip short", func(t *testing.T) {
+		position := &hu.Position{Size: hu.Mul1e18(big.NewInt(-2)), OpenNotional: hu.Mul1e6(big.NewInt(200))}
+		realizedPnl := applyFill(position, hu.Mul1e18(big.NewInt(3)), hu.Mul1e6(big.NewInt(330)))
+		assert.Equal(t, hu.Mul1e6(big.NewInt(-20)), realizedPnl)
+		assert.Equal(t, hu.Mul1e18(big.NewInt(1)), position.Size)
+		assert.Equal(t, hu.Mul1e6(big.NewInt(110)), position.OpenNotional)
+	})
+}
+
+func TestSimulateOrder(t *testing.T) {
+	newSimulator := func(db LimitOrderDatabase) *orderSimulator {
+		return &orderSimulator{
+			db: db,
+			hState: &hu.HubbleState{
+				Assets:             []hu.Collateral{{Price: big.NewInt(1e6), Weight: big.NewInt(1e6), Decimals: 6}},
+				OraclePrices:       map[Market]*big.Int{market: hu.Mul1e6(big.NewInt(100))},
+				ActiveMarkets:      []Market{market},
+				MinAllowableMargin: big.NewInt(2e5),
+				MaintenanceMargin:  big.NewInt(1e5),
+				TakerFee:           big.NewInt(500), // 0.05%
+				UpgradeVersion:     hu.V2,
+			},
+			userState: &hu.UserState{
+				Positions:      map[Market]*hu.Position{},
+				Margins:        []*big.Int{hu.Mul1e6(big.NewInt(50))},
+				PendingFunding: big.NewInt(0),
+				ReservedMargin: big.NewInt(0),
+			},
+			virtualReserved: big.NewInt(0),
+			consumed:        map[common.Hash]*big.Int{},
+		}
+	}
+	db := getDatabase()
+	short1 := createLimitOrder(SHORT, userAddress, hu.Mul1e18(big.NewInt(-2)), hu.Mul1e6(big.NewInt(100)), Placed, big.NewInt(1), big.NewInt(1))
+	short2 := createLimitOrder(SHORT, userAddress, hu.Mul1e18(big.NewInt(-2)), hu.Mul1e6(big.NewInt(110)), Placed, big.NewInt(1), big.NewInt(2))
+	db.Add(&short1)
+	db.Add(&short2)
+
+	t.Run("market order walks the book", func(t *testing.T) {
+		response, err := newSimulator(db).simulate(SimulatedOrder{Market: market, Size: hu.Mul1e18(big.NewInt(3))})
+		assert.Nil(t, err)
+		assert.Equal(t, "3.00000000", response.FilledSize)
+		assert.Equal(t, "103.33333300", response.AvgFillPrice) // (2 * 100 + 110) / 3
+		assert.Equal(t, "0.00000000", response.RestingSize)
+		assert.Equal(t, "0.15500000", response.Fee) // 310 * 0.05%
+		assert.Equal(t, "3.00000000", response.Size)
+		assert.Equal(t, "310.00000000", response.OpenNotional)
+		assert.Equal(t, "300.00000000", response.NotionalPosition) // at the oracle price
+		assert.Equal(t, "0.00000000", response.RequiredMargin)
+		assert.Equal(t, "96.35370300", response.LiquidationPrice) // (310 - 49.845) / (3 * (1 - 10%))
+	})
+	t.Run("market order without enough liquidity", func(t *testing.T) {
+		_, err := newSimulator(db).simulate(SimulatedOrder{Market: market, Size: hu.Mul1e18(big.NewInt(5))})
+		assert.Equal(t, ErrInsufficientLiquidity, err)
+	})
+	t.Run("limit order rests on the book after the crossing part is filled", func(t *testing.T) {
+		response, err := newSimulator(db).simulate(SimulatedOrder{Market: market, Size: hu.Mul1e18(big.NewInt(3)), Price: hu.Mul1e6(big.NewInt(105))})
+		assert.Nil(t, err)
+		assert.Equal(t, "2.00000000", response.FilledSize)
+		assert.Equal(t, "100.00000000", response.AvgFillPrice)
+		assert.Equal(t, "1.00000000", response.RestingSize)
+		assert.Equal(t, "21.05250000", response.RequiredMargin) // 105 * (20% + 0.05%)
+		assert.Equal(t, "2.00000000", response.Size)
+	})
+	t.Run("orders of a batch don't reuse liquidity", func(t *testing.T) {
+		sim := newSimulator(db)
+		_, err := sim.simulate(SimulatedOrder{Market: market, Size: hu.Mul1e18(big.NewInt(2))})
+		assert.Nil(t, err)
+		response, err := sim.simulate(SimulatedOrder{Market: market, Size: hu.Mul1e18(big.NewInt(1))})
+		assert.Nil(t, err)
+		assert.Equal(t, "110.00000000", response.AvgFillPrice)
+		assert.Equal(t, "3.00000000", response.Size)
+		_, err = sim.simulate(SimulatedOrder{Market: market, Size: hu.Mul1e18(big.NewInt(2))})
+		assert.Equal(t, ErrInsufficientLiquidity, err)
+	})
+	t.Run("invalid orders", func(t *testing.T) {
+		_, err := newSimulator(db).simulate(SimulatedOrder{Market: market, Size: big.NewInt(0)})
+		assert.NotNil(t, err)
+		_, err = newSimulator(db).simulate(SimulatedOrder{Market: Market(1), Size: big.NewInt(1)})
+		assert.NotNil(t, err)
+	})
+}
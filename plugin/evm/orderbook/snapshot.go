@@ -0,0 +1,174 @@
+package orderbook
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"time"
+
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Snapshots are stored in an envelope so that a restore never silently decodes data written by an incompatible version:
+//
+//	magic (4 bytes) | version (2 bytes) | accepted block number (8 bytes) | accepted block hash (32 bytes) | keccak256(payload) (32 bytes) | payload
+//
+// The payload is the gob encoding of Snapshot. Whenever a change to Order, Trader or InMemoryDatabase changes how the payload
+// decodes, SnapshotVersion must be bumped and a migration from the previous version added to snapshotMigrations.
+const (
+	SnapshotVersion uint16 = 2
+
+	snapshotHeaderLength = 4 + 2 + 8 + common.HashLength + common.HashLength
+)
+
+var snapshotMagic = [4]byte{'h', 'm', 'd', 'b'}
+
+var (
+	ErrSnapshotTooShort          = errors.New("snapshot is too short")
+	ErrSnapshotChecksumMismatch  = errors.New("snapshot checksum mismatch")
+	ErrSnapshotUnsupportedFormat = errors.New("snapshot version is not supported")
+	ErrSnapshotHeaderMismatch    = errors.New("snapshot header does not match the payload")
+)
+
+// snapshotMigrations[v] converts a payload of version v into a payload of version v+1
+var snapshotMigrations = map[uint16]func(payload []byte) ([]byte, error){
+	// version 0 is the gob encoded Snapshot written without an envelope. Version 1 wraps the same encoding.
+	0: func(payload []byte) ([]byte, error) { return payload, nil },
+	// version 2 adds the trigger orders, cancel nonces, heartbeats, signed order cancellations and cancelled signed orders of InMemoryDatabase,
+	// the PlacedAt time of Order and the reason and fill details of Lifecycle
+	1: migrateSnapshotV1,
+}
+
+// migrateSnapshotV1 fills in the state that version 1 snapshots don't have. gob leaves the missing fields empty, so the payload decodes as it is.
+// The orders are treated as received at the time of the migration, which is what Add does for orders without a PlacedAt time.
+// Lifecycles are kept without a reason, there is no way to tell it for the old entries
+func migrateSnapshotV1(payload []byte) ([]byte, error) {
+	var snapshot Snapshot
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("error in gob decoding: err=%v", err)
+	}
+	if snapshot.Data == nil {
+		return nil, errors.New("snapshot data is missing")
+	}
+	data := snapshot.Data
+	placedAt := uint64(time.Now().Unix())
+	for _, order := range data.Orders {
+		if order.PlacedAt == 0 {
+			order.PlacedAt = placedAt
+		}
+	}
+	if data.TriggerOrders == nil {
+		data.TriggerOrders = map[common.Hash]*Order{}
+	}
+	if data.CancelNonces == nil {
+		data.CancelNonces = map[common.Address]*CancelNonce{}
+	}
+	if data.Heartbeats == nil {
+		data.Heartbeats = map[common.Address]*TraderHeartbeat{}
+	}
+	if data.SignedOrderCancellations == nil {
+		data.SignedOrderCancellations = map[common.Hash]*hu.CancelOrder{}
+	}
+	if data.CancelledSignedOrders == nil {
+		data.CancelledSignedOrders = map[common.Hash]uint64{}
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&snapshot); err != nil {
+		return nil, fmt.Errorf("error in gob encoding: err=%v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// SnapshotHeader is the metadata stored in front of the snapshot payload
+type SnapshotHeader struct {
+	Version             uint16
+	AcceptedBlockNumber uint64
+	AcceptedBlockHash   common.Hash
+	Checksum            common.Hash
+}
+
+func EncodeSnapshot(snapshot *Snapshot) ([]byte, error) {
+	if snapshot.AcceptedBlockNumber == nil || !snapshot.AcceptedBlockNumber.IsUint64() {
+		return nil, fmt.Errorf("invalid accepted block number in snapshot: %v", snapshot.AcceptedBlockNumber)
+	}
+
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(snapshot); err != nil {
+		return nil, fmt.Errorf("error in gob encoding: err=%v", err)
+	}
+
+	buf := make([]byte, snapshotHeaderLength, snapshotHeaderLength+payload.Len())
+	copy(buf[0:4], snapshotMagic[:])
+	binary.BigEndian.PutUint16(buf[4:6], SnapshotVersion)
+	binary.BigEndian.PutUint64(buf[6:14], snapshot.AcceptedBlockNumber.Uint64())
+	copy(buf[14:46], snapshot.AcceptedBlockHash.Bytes())
+	copy(buf[46:78], crypto.Keccak256(payload.Bytes()))
+	return append(buf, payload.Bytes()...), nil
+}
+
+// DecodeSnapshot verifies the envelope, migrates the payload to the current version and decodes it.
+// Data without the magic prefix is treated as a version 0 snapshot, which has no checksum or block hash.
+func DecodeSnapshot(data []byte) (*Snapshot, *SnapshotHeader, error) {
+	header, payload, err := decodeSnapshotHeader(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for version := header.Version; version < SnapshotVersion; version++ {
+		migrate, ok := snapshotMigrations[version]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: no migration from version %d", ErrSnapshotUnsupportedFormat, version)
+		}
+		payload, err = migrate(payload)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error in migrating snapshot from version %d: err=%v", version, err)
+		}
+	}
+
+	var snapshot Snapshot
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&snapshot); err != nil {
+		return nil, nil, fmt.Errorf("error in gob decoding: err=%v", err)
+	}
+	if snapshot.AcceptedBlockNumber == nil {
+		return nil, nil, fmt.Errorf("%w: accepted block number is missing", ErrSnapshotHeaderMismatch)
+	}
+
+	if header.Version > 0 {
+		if snapshot.AcceptedBlockNumber.Uint64() != header.AcceptedBlockNumber || snapshot.AcceptedBlockHash != header.AcceptedBlockHash {
+			return nil, nil, fmt.Errorf("%w: header=(%d, %s), payload=(%d, %s)", ErrSnapshotHeaderMismatch, header.AcceptedBlockNumber, header.AcceptedBlockHash.Hex(), snapshot.AcceptedBlockNumber.Uint64(), snapshot.AcceptedBlockHash.Hex())
+		}
+	} else {
+		header.AcceptedBlockNumber = snapshot.AcceptedBlockNumber.Uint64()
+	}
+	return &snapshot, header, nil
+}
+
+func decodeSnapshotHeader(data []byte) (*SnapshotHeader, []byte, error) {
+	if len(data) < 4 || !bytes.Equal(data[0:4], snapshotMagic[:]) {
+		return &SnapshotHeader{Version: 0}, data, nil
+	}
+	if len(data) < snapshotHeaderLength {
+		return nil, nil, fmt.Errorf("%w: length=%d", ErrSnapshotTooShort, len(data))
+	}
+
+	header := &SnapshotHeader{
+		Version:             binary.BigEndian.Uint16(data[4:6]),
+		AcceptedBlockNumber: binary.BigEndian.Uint64(data[6:14]),
+		AcceptedBlockHash:   common.BytesToHash(data[14:46]),
+		Checksum:            common.BytesToHash(data[46:78]),
+	}
+	if header.Version == 0 || header.Version > SnapshotVersion {
+		return nil, nil, fmt.Errorf("%w: version=%d, supported=%d", ErrSnapshotUnsupportedFormat, header.Version, SnapshotVersion)
+	}
+
+	payload := data[snapshotHeaderLength:]
+	if checksum := crypto.Keccak256Hash(payload); checksum != header.Checksum {
+		return nil, nil, fmt.Errorf("%w: expected=%s, actual=%s", ErrSnapshotChecksumMismatch, header.Checksum.Hex(), checksum.Hex())
+	}
+	return header, payload, nil
+}
@@ -0,0 +1,117 @@
+package orderbook
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func getSnapshotForTest() *Snapshot {
+	db := getDatabase()
+	order := createLimitOrder(LONG, userAddress, big.NewInt(10), big.NewInt(20), status, big.NewInt(2), big.NewInt(1))
+	db.Add(&order)
+	return &Snapshot{
+		Data:                db,
+		AcceptedBlockNumber: big.NewInt(5),
+		AcceptedBlockHash:   common.HexToHash("0x1234"),
+	}
+}
+
+func TestEncodeDecodeSnapshot(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		snapshot := getSnapshotForTest()
+		data, err := EncodeSnapshot(snapshot)
+		assert.Nil(t, err)
+
+		decoded, header, err := DecodeSnapshot(data)
+		assert.Nil(t, err)
+		assert.Equal(t, SnapshotVersion, header.Version)
+		assert.Equal(t, uint64(5), header.AcceptedBlockNumber)
+		assert.Equal(t, snapshot.AcceptedBlockHash, decoded.AcceptedBlockHash)
+		assert.Equal(t, 1, len(decoded.Data.Orders))
+
+		db := NewInMemoryDatabase(nil)
+		assert.Nil(t, db.LoadFromSnapshot(*decoded))
+		assert.Equal(t, 1, db.longOrders[market].len())
+		assert.Equal(t, 1, len(db.GetAllOpenOrdersForTrader(trader)))
+	})
+	t.Run("corrupt payload is rejected", func(t *testing.T) {
+		data, err := EncodeSnapshot(getSnapshotForTest())
+		assert.Nil(t, err)
+
+		data[len(data)-1] ^= 0xff
+		_, _, err = DecodeSnapshot(data)
+		assert.True(t, errors.Is(err, ErrSnapshotChecksumMismatch))
+	})
+	t.Run("truncated snapshot is rejected", func(t *testing.T) {
+		data, err := EncodeSnapshot(getSnapshotForTest())
+		assert.Nil(t, err)
+
+		_, _, err = DecodeSnapshot(data[:snapshotHeaderLength-1])
+		assert.True(t, errors.Is(err, ErrSnapshotTooShort))
+		_, _, err = DecodeSnapshot(data[:len(data)-10])
+		assert.True(t, errors.Is(err, ErrSnapshotChecksumMismatch))
+	})
+	t.Run("unknown version is rejected", func(t *testing.T) {
+		data, err := EncodeSnapshot(getSnapshotForTest())
+		assert.Nil(t, err)
+
+		binary.BigEndian.PutUint16(data[4:6], SnapshotVersion+1)
+		_, _, err = DecodeSnapshot(data)
+		assert.True(t, errors.Is(err, ErrSnapshotUnsupportedFormat))
+	})
+	t.Run("header that does not match the payload is rejected", func(t *testing.T) {
+		data, err := EncodeSnapshot(getSnapshotForTest())
+		assert.Nil(t, err)
+
+		binary.BigEndian.PutUint64(data[6:14], 6)
+		_, _, err = DecodeSnapshot(data)
+		assert.True(t, errors.Is(err, ErrSnapshotHeaderMismatch))
+	})
+	t.Run("unversioned snapshot is migrated", func(t *testing.T) {
+		snapshot := getSnapshotForTest()
+		snapshot.AcceptedBlockHash = common.Hash{}
+		var buf bytes.Buffer
+		assert.Nil(t, gob.NewEncoder(&buf).Encode(snapshot))
+
+		decoded, header, err := DecodeSnapshot(buf.Bytes())
+		assert.Nil(t, err)
+		assert.Equal(t, uint16(0), header.Version)
+		assert.Equal(t, uint64(5), header.AcceptedBlockNumber)
+		assert.Equal(t, 1, len(decoded.Data.Orders))
+	})
+	t.Run("version 1 snapshot is migrated", func(t *testing.T) {
+		// gob doesn't encode empty fields, so the payload is the same as the one of a version 1 snapshot
+		snapshot := getSnapshotForTest()
+		for _, order := range snapshot.Data.Orders {
+			order.PlacedAt = 0
+		}
+		snapshot.Data.TriggerOrders = nil
+		snapshot.Data.CancelNonces = nil
+		snapshot.Data.Heartbeats = nil
+		snapshot.Data.SignedOrderCancellations = nil
+		snapshot.Data.CancelledSignedOrders = nil
+		data, err := EncodeSnapshot(snapshot)
+		assert.Nil(t, err)
+		binary.BigEndian.PutUint16(data[4:6], 1)
+
+		decoded, header, err := DecodeSnapshot(data)
+		assert.Nil(t, err)
+		assert.Equal(t, uint16(1), header.Version)
+		assert.Equal(t, 1, len(decoded.Data.Orders))
+		for _, order := range decoded.Data.Orders {
+			assert.NotEqual(t, uint64(0), order.PlacedAt)
+		}
+		assert.NotNil(t, decoded.Data.TriggerOrders)
+		assert.NotNil(t, decoded.Data.CancelNonces)
+		assert.NotNil(t, decoded.Data.Heartbeats)
+		assert.NotNil(t, decoded.Data.SignedOrderCancellations)
+		assert.NotNil(t, decoded.Data.CancelledSignedOrders)
+	})
+}
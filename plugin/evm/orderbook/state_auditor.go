@@ -0,0 +1,224 @@
+package orderbook
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ava-labs/subnet-evm/eth"
+	"github.com/ava-labs/subnet-evm/metrics"
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ava-labs/subnet-evm/precompile/contract"
+	"github.com/ava-labs/subnet-evm/precompile/contracts/bibliophile"
+	"github.com/ava-labs/subnet-evm/rpc"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// StateDiscrepancy is a value in the memory DB that doesn't match the contract storage
+type StateDiscrepancy struct {
+	Trader   common.Address `json:"trader"`
+	Market   *Market        `json:"market,omitempty"`
+	OrderId  *common.Hash   `json:"order_id,omitempty"`
+	Field    string         `json:"field"`
+	MemoryDB string         `json:"memory_db"`
+	OnChain  string         `json:"on_chain"`
+}
+
+type StateVerificationReport struct {
+	BlockNumber    uint64             `json:"block_number"`
+	BlockHash      common.Hash        `json:"block_hash"`
+	TradersChecked int                `json:"traders_checked"`
+	OrdersChecked  int                `json:"orders_checked"`
+	OrdersSkipped  int                `json:"orders_skipped"` // orders that changed after the audited block
+	Discrepancies  []StateDiscrepancy `json:"discrepancies"`
+}
+
+// StateAuditor compares the memory DB, which is built only from contract events, with the contract storage at the last accepted block
+type StateAuditor struct {
+	db      LimitOrderDatabase
+	backend *eth.EthAPIBackend
+}
+
+func NewStateAuditor(db LimitOrderDatabase, backend *eth.EthAPIBackend) *StateAuditor {
+	return &StateAuditor{
+		db:      db,
+		backend: backend,
+	}
+}
+
+// Verify checks positions, margins, reserved margin and the status and filled amount of every order in the memory DB.
+// Orders placed or updated after the last accepted block are skipped because the contract storage doesn't have them yet.
+func (auditor *StateAuditor) Verify(ctx context.Context) (*StateVerificationReport, error) {
+	start := time.Now()
+	stateAuditRunsCounter.Inc(1)
+
+	acceptedBlock := auditor.backend.LastAcceptedBlock()
+	stateDB, _, err := auditor.backend.StateAndHeaderByNumber(ctx, rpc.BlockNumber(acceptedBlock.NumberU64()))
+	if err != nil || stateDB == nil {
+		stateAuditFailuresCounter.Inc(1)
+		return nil, fmt.Errorf("could not get state at block %d: err=%v", acceptedBlock.NumberU64(), err)
+	}
+
+	report := auditor.db.VerifyState(stateDB, acceptedBlock.NumberU64())
+	report.BlockHash = acceptedBlock.Hash()
+
+	stateAuditDiscrepanciesGauge.Update(int64(len(report.Discrepancies)))
+	for _, discrepancy := range report.Discrepancies {
+		metrics.GetOrRegisterCounter(fmt.Sprintf("state_audit/discrepancies/%s", discrepancy.Field), nil).Inc(1)
+	}
+	stateAuditTimer.UpdateSince(start)
+	return report, nil
+}
+
+func verifyState(stateDB contract.StateDB, blockNumber uint64, traders map[common.Address]Trader, orders []Order) *StateVerificationReport {
+	report := &StateVerificationReport{
+		BlockNumber:   blockNumber,
+		Discrepancies: []StateDiscrepancy{},
+	}
+
+	collateralCount := len(bibliophile.GetCollaterals(stateDB))
+	for address, trader := range traders {
+		report.Discrepancies = append(report.Discrepancies, verifyTrader(stateDB, address, &trader, collateralCount)...)
+		report.TradersChecked++
+	}
+
+	for i := range orders {
+		order := &orders[i]
+		if order.BlockNumber.Uint64() > blockNumber || order.getOrderStatus().BlockNumber > blockNumber || order.getOrderStatus().Status == Execution_Failed {
+			report.OrdersSkipped++
+			continue
+		}
+		report.Discrepancies = append(report.Discrepancies, verifyOrder(stateDB, order)...)
+		report.OrdersChecked++
+	}
+	return report
+}
+
+func verifyTrader(stateDB contract.StateDB, address common.Address, trader *Trader, collateralCount int) []StateDiscrepancy {
+	discrepancies := []StateDiscrepancy{}
+	addIfDifferent := func(market *Market, field string, memoryValue, onChainValue *big.Int) {
+		if bigOrZero(memoryValue).Cmp(bigOrZero(onChainValue)) != 0 {
+			discrepancies = append(discrepancies, StateDiscrepancy{
+				Trader:   address,
+				Market:   market,
+				Field:    field,
+				MemoryDB: bigOrZero(memoryValue).String(),
+				OnChain:  bigOrZero(onChainValue).String(),
+			})
+		}
+	}
+
+	for i := 0; i < collateralCount; i++ {
+		marginVars := bibliophile.GetMarginAccountVariables(stateDB, big.NewInt(int64(i)), address)
+		addIfDifferent(nil, fmt.Sprintf("margin_%d", i), trader.Margin.Deposited[Collateral(i)], marginVars.Margin)
+		if i == 0 {
+			addIfDifferent(nil, "reserved_margin", trader.Margin.Reserved, marginVars.ReservedMargin)
+		}
+	}
+
+	clearingHouseVars := bibliophile.GetClearingHouseVariables(stateDB, address)
+	for i, onChainSize := range clearingHouseVars.PositionSizes {
+		market := Market(i)
+		position := trader.Positions[market]
+		if position == nil {
+			position = &Position{}
+		}
+		addIfDifferent(&market, "size", position.Size, onChainSize)
+		if bigOrZero(position.Size).Sign() == 0 && onChainSize.Sign() == 0 {
+			continue
+		}
+		ammAddress := bibliophile.GetMarketAddressFromMarketID(int64(i), stateDB)
+		ammVars := bibliophile.GetAMMVariables(stateDB, ammAddress, int64(i), address)
+		addIfDifferent(&market, "open_notional", position.OpenNotional, ammVars.Position.OpenNotional)
+	}
+	return discrepancies
+}
+
+func verifyOrder(stateDB contract.StateDB, order *Order) []StateDiscrepancy {
+	var onChainStatus int64
+	var onChainFilledAmount *big.Int
+	switch order.OrderType {
+	case Limit:
+		orderBookVars := bibliophile.GetOrderBookVariables(stateDB, order.Trader.String(), order.Trader.String(), order.Id)
+		onChainStatus, onChainFilledAmount = orderBookVars.OrderDetails.OrderStatus, orderBookVars.OrderDetails.FilledAmount
+	case IOC:
+		iocVars := bibliophile.GetIOCOrdersVariables(stateDB, order.Id)
+		onChainStatus, onChainFilledAmount = iocVars.OrderDetails.OrderStatus, iocVars.OrderDetails.FilledAmount
+	case Signed, Trigger:
+		onChainStatus, onChainFilledAmount = bibliophile.GetSignedOrderStatus(stateDB, order.Id), bibliophile.GetSignedOrderFilledAmount(stateDB, order.Id)
+	default:
+		return nil
+	}
+
+	discrepancies := []StateDiscrepancy{}
+	memoryStatus := order.getOrderStatus().Status
+	if !isExpectedOnChainStatus(order.OrderType, memoryStatus, hu.OrderStatus(onChainStatus)) {
+		discrepancies = append(discrepancies, StateDiscrepancy{
+			Trader:   order.Trader,
+			Market:   &order.Market,
+			OrderId:  &order.Id,
+			Field:    "order_status",
+			MemoryDB: mapStatus[memoryStatus],
+			OnChain:  fmt.Sprintf("%d", onChainStatus),
+		})
+	}
+	if bigOrZero(order.FilledBaseAssetQuantity).Cmp(bigOrZero(onChainFilledAmount)) != 0 {
+		discrepancies = append(discrepancies, StateDiscrepancy{
+			Trader:   order.Trader,
+			Market:   &order.Market,
+			OrderId:  &order.Id,
+			Field:    "filled_amount",
+			MemoryDB: bigOrZero(order.FilledBaseAssetQuantity).String(),
+			OnChain:  bigOrZero(onChainFilledAmount).String(),
+		})
+	}
+	return discrepancies
+}
+
+func isExpectedOnChainStatus(orderType OrderType, memoryStatus Status, onChainStatus hu.OrderStatus) bool {
+	switch memoryStatus {
+	case Placed:
+		// signed and trigger orders are only stored on chain once they are matched or cancelled
+		return onChainStatus == hu.Placed || ((orderType == Signed || orderType == Trigger) && onChainStatus == hu.Invalid)
+	case FulFilled:
+		return onChainStatus == hu.Filled
+	case Cancelled:
+		return onChainStatus == hu.Cancelled
+	}
+	return true
+}
+
+func bigOrZero(value *big.Int) *big.Int {
+	if value == nil {
+		return big.NewInt(0)
+	}
+	return value
+}
+
+// RunStateAuditor verifies the memory DB every interval till shutdownChan is closed
+func (auditor *StateAuditor) RunStateAuditor(interval time.Duration, shutdownChan <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			executeFuncAndRecoverPanic(func() {
+				report, err := auditor.Verify(context.Background())
+				if err != nil {
+					log.Error("state auditor - error in verifying state", "err", err)
+					return
+				}
+				if len(report.Discrepancies) > 0 {
+					log.Error("state auditor - memory DB does not match on-chain state", "blockNumber", report.BlockNumber, "discrepancies", len(report.Discrepancies), "details", report.Discrepancies)
+				} else {
+					log.Info("state auditor - memory DB matches on-chain state", "blockNumber", report.BlockNumber, "traders", report.TradersChecked, "orders", report.OrdersChecked)
+				}
+			}, StateAuditPanicMessage, StateAuditPanicsCounter)
+		case <-shutdownChan:
+			return
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package orderbook
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ava-labs/subnet-evm/core/rawdb"
+	"github.com/ava-labs/subnet-evm/core/state"
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsExpectedOnChainStatus(t *testing.T) {
+	assert.True(t, isExpectedOnChainStatus(Limit, Placed, hu.Placed))
+	assert.False(t, isExpectedOnChainStatus(Limit, Placed, hu.Invalid))
+	assert.True(t, isExpectedOnChainStatus(Signed, Placed, hu.Invalid))
+	assert.True(t, isExpectedOnChainStatus(IOC, FulFilled, hu.Filled))
+	assert.False(t, isExpectedOnChainStatus(IOC, FulFilled, hu.Placed))
+	assert.True(t, isExpectedOnChainStatus(Limit, Cancelled, hu.Cancelled))
+	assert.False(t, isExpectedOnChainStatus(Limit, Cancelled, hu.Filled))
+	assert.True(t, isExpectedOnChainStatus(Limit, Execution_Failed, hu.Placed))
+}
+
+func TestVerifyState(t *testing.T) {
+	stateDB, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	assert.Nil(t, err)
+
+	limitOrder := createLimitOrder(LONG, userAddress, big.NewInt(10), big.NewInt(20), Placed, big.NewInt(2), big.NewInt(1))
+	signedOrder := createLimitOrder(SHORT, userAddress, big.NewInt(-10), big.NewInt(20), Placed, big.NewInt(2), big.NewInt(2))
+	signedOrder.OrderType = Signed
+	futureOrder := createLimitOrder(LONG, userAddress, big.NewInt(10), big.NewInt(20), Placed, big.NewInt(6), big.NewInt(3))
+
+	orders := []Order{limitOrder, signedOrder, futureOrder}
+	for i := range orders {
+		orders[i].LifecycleList = []Lifecycle{{BlockNumber: orders[i].BlockNumber.Uint64(), Status: Placed}}
+	}
+
+	report := verifyState(stateDB, 5, map[common.Address]Trader{}, orders)
+	assert.Equal(t, uint64(5), report.BlockNumber)
+	assert.Equal(t, 2, report.OrdersChecked)
+	assert.Equal(t, 1, report.OrdersSkipped)
+
+	// limit order is not present in the contract storage, signed orders are only stored after they are matched
+	assert.Equal(t, 1, len(report.Discrepancies))
+	assert.Equal(t, "order_status", report.Discrepancies[0].Field)
+	assert.Equal(t, limitOrder.Id, *report.Discrepancies[0].OrderId)
+}
+
+func TestInMemoryDatabaseVerifyState(t *testing.T) {
+	stateDB, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	assert.Nil(t, err)
+
+	db := getDatabase()
+	limitOrder := createLimitOrder(LONG, userAddress, big.NewInt(10), big.NewInt(20), Placed, big.NewInt(2), big.NewInt(1))
+	limitOrder.LifecycleList = []Lifecycle{{BlockNumber: 2, Status: Placed}}
+	db.Add(&limitOrder)
+	db.UpdatePosition(common.HexToAddress(userAddress), 0, big.NewInt(10), big.NewInt(200), false, 2)
+
+	report := db.VerifyState(stateDB, 5)
+	assert.Equal(t, 1, report.TradersChecked)
+	assert.Equal(t, 1, report.OrdersChecked)
+	// the order is not present in the contract storage, and positions are only checked for the markets in the contract storage
+	assert.Equal(t, 1, len(report.Discrepancies))
+	assert.Equal(t, "order_status", report.Discrepancies[0].Field)
+}
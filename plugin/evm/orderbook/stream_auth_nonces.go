@@ -0,0 +1,70 @@
+package orderbook
+
+import (
+	"crypto/rand"
+	"errors"
+	"sync"
+
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// maxStreamAuthNonces bounds the nonces that are issued and not used yet
+const maxStreamAuthNonces = 10_000
+
+var (
+	ErrInvalidStreamAuthNonce  = errors.New("stream auth nonce was not issued for the trader, expired or was already used")
+	ErrTooManyStreamAuthNonces = errors.New("too many stream auth nonces, try again later")
+)
+
+type streamAuthNonce struct {
+	trader   common.Address
+	expireAt uint64
+}
+
+// streamAuthNonces are the nonces issued for StreamAuth challenges. A nonce is valid for hu.MaxStreamAuthValidity seconds and can be used once.
+// The zero value is ready to use
+type streamAuthNonces struct {
+	mu     sync.Mutex
+	nonces map[common.Hash]streamAuthNonce
+}
+
+func (n *streamAuthNonces) issue(trader common.Address, now uint64) (common.Hash, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.nonces == nil {
+		n.nonces = map[common.Hash]streamAuthNonce{}
+	}
+	for nonce, issued := range n.nonces {
+		if issued.expireAt < now {
+			delete(n.nonces, nonce)
+		}
+	}
+	if len(n.nonces) >= maxStreamAuthNonces {
+		return common.Hash{}, ErrTooManyStreamAuthNonces
+	}
+
+	var nonce common.Hash
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return common.Hash{}, err
+	}
+	n.nonces[nonce] = streamAuthNonce{trader: trader, expireAt: now + hu.MaxStreamAuthValidity}
+	return nonce, nil
+}
+
+// use removes the nonce, it returns ErrInvalidStreamAuthNonce if the nonce wasn't issued for the trader or has expired
+func (n *streamAuthNonces) use(nonce common.Hash, trader common.Address, now uint64) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	issued, ok := n.nonces[nonce]
+	if !ok || issued.trader != trader {
+		return ErrInvalidStreamAuthNonce
+	}
+	delete(n.nonces, nonce)
+	if issued.expireAt < now {
+		return ErrInvalidStreamAuthNonce
+	}
+	return nil
+}
@@ -4,9 +4,7 @@
 package orderbook
 
 import (
-	"bytes"
 	"context"
-	"encoding/gob"
 	"fmt"
 	"math/big"
 
@@ -66,13 +64,12 @@ func (api *TestingAPI) GetSnapshot(ctx context.Context) (Snapshot, error) {
 		return snapshot, fmt.Errorf("Error in fetching snapshot from hubbleDB; err=%v", err)
 	}
 
-	buf := bytes.NewBuffer(memorySnapshotBytes)
-	err = gob.NewDecoder(buf).Decode(&snapshot)
+	decoded, _, err := DecodeSnapshot(memorySnapshotBytes)
 	if err != nil {
 		return snapshot, fmt.Errorf("Error in snapshot parsing; err=%v", err)
 	}
 
-	return snapshot, nil
+	return *decoded, nil
 }
 
 func getCurrentBlockNumber(backend *eth.EthAPIBackend) uint64 {
@@ -0,0 +1,658 @@
+package orderbook
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/subnet-evm/accounts/abi"
+	"github.com/ava-labs/subnet-evm/core/types"
+	"github.com/ava-labs/subnet-evm/plugin/evm/orderbook/abis"
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	defaultIndexerPageSize = 100
+	maxIndexerPageSize     = 1000
+	maxCandlesPerQuery     = 1000
+)
+
+var (
+	tradeIndexerPrefix    = []byte("indexer")
+	tradesKeyPrefix       = append(common.CopyBytes(tradeIndexerPrefix), 't')
+	fillsKeyPrefix        = append(common.CopyBytes(tradeIndexerPrefix), 'f')
+	fundingRatesKeyPrefix = append(common.CopyBytes(tradeIndexerPrefix), 'r')
+	indexedRangeKey       = append(common.CopyBytes(tradeIndexerPrefix), 's')
+
+	ErrTradeIndexerDisabled = errors.New("trade indexer is not enabled on this node")
+	ErrInvalidCursor        = errors.New("invalid cursor")
+	ErrInvalidInterval      = errors.New("interval should be greater than 0")
+	ErrTooManyCandles       = fmt.Errorf("at most %d candles can be queried at once", maxCandlesPerQuery)
+)
+
+// Fill is a change in the position of a trader because of a match or a liquidation
+type Fill struct {
+	Market        Market         `json:"market"`
+	Trader        common.Address `json:"trader"`
+	OrderHash     common.Hash    `json:"orderHash"` // empty for the position of the liquidated trader
+	BaseAsset     *big.Int       `json:"baseAsset"` // positive for longs, negative for shorts
+	Price         *big.Int       `json:"price"`
+	RealizedPnl   *big.Int       `json:"realizedPnl"`
+	Fee           *big.Int       `json:"fee"`
+	IsLiquidation bool           `json:"isLiquidation"`
+	BlockNumber   uint64         `json:"blockNumber"`
+	TxHash        common.Hash    `json:"txHash"`
+	LogIndex      uint           `json:"logIndex"`
+	Timestamp     uint64         `json:"timestamp"`
+}
+
+// Trade is a match between a long and a short fill in a market. The fills of a batch auction are matched against the auction,
+// so a trade is recorded for each of them, with an empty Seller for a long fill and an empty Buyer for a short fill
+type Trade struct {
+	Market         Market         `json:"market"`
+	Price          *big.Int       `json:"price"`
+	Size           *big.Int       `json:"size"`
+	Buyer          common.Address `json:"buyer"`
+	Seller         common.Address `json:"seller"`
+	IsLiquidation  bool           `json:"isLiquidation"`
+	IsBatchAuction bool           `json:"isBatchAuction"`
+	BlockNumber    uint64         `json:"blockNumber"`
+	TxHash         common.Hash    `json:"txHash"`
+	LogIndex       uint           `json:"logIndex"` // log index of the later OrderMatched event of the match
+	Timestamp      uint64         `json:"timestamp"`
+}
+
+type Candle struct {
+	OpenTime    uint64   `json:"openTime"`
+	Open        *big.Int `json:"open"`
+	High        *big.Int `json:"high"`
+	Low         *big.Int `json:"low"`
+	Close       *big.Int `json:"close"`
+	Volume      *big.Int `json:"volume"`
+	QuoteVolume *big.Int `json:"quoteVolume"`
+	Trades      uint64   `json:"trades"`
+}
+
+type PaginationArgs struct {
+	Limit  int    `json:"limit"`
+	Cursor string `json:"cursor"` // nextCursor of the previous page
+}
+
+type TradesResponse struct {
+	Trades     []*Trade `json:"trades"`
+	NextCursor string   `json:"nextCursor,omitempty"`
+}
+
+type FillsResponse struct {
+	Fills      []*Fill `json:"fills"`
+	NextCursor string  `json:"nextCursor,omitempty"`
+}
+
+// TradeIndexer stores the trades and funding rates of every market, and the fills and archived orders of every trader in hubbleDB, so that they can be queried by time.
+// Only the logs of accepted blocks are indexed, so the index never has to be rolled back on a reorg.
+// Keys are deterministic, which makes indexing the same logs again a no-op.
+type TradeIndexer struct {
+	db               database.Database
+	orderBookABI     abi.ABI
+	clearingHouseABI abi.ABI
+}
+
+func NewTradeIndexer(db database.Database) *TradeIndexer {
+	orderBookABI, err := abi.FromSolidityJson(string(abis.OrderBookAbi))
+	if err != nil {
+		panic(err)
+	}
+
+	clearingHouseABI, err := abi.FromSolidityJson(string(abis.ClearingHouseAbi))
+	if err != nil {
+		panic(err)
+	}
+
+	return &TradeIndexer{
+		db:               db,
+		orderBookABI:     orderBookABI,
+		clearingHouseABI: clearingHouseABI,
+	}
+}
+
+// key suffix is timestamp | block number | log index, so that iterating a prefix returns records in the order they were accepted
+func indexerKey(prefix []byte, timestamp, blockNumber uint64, logIndex uint) []byte {
+	key := make([]byte, len(prefix)+20)
+	copy(key, prefix)
+	binary.BigEndian.PutUint64(key[len(prefix):], timestamp)
+	binary.BigEndian.PutUint64(key[len(prefix)+8:], blockNumber)
+	binary.BigEndian.PutUint32(key[len(prefix)+16:], uint32(logIndex))
+	return key
+}
+
+func marketTradesPrefix(market Market) []byte {
+	return marketPrefix(tradesKeyPrefix, market)
+}
+
+func marketFundingRatesPrefix(market Market) []byte {
+	return marketPrefix(fundingRatesKeyPrefix, market)
+}
+
+func marketPrefix(keyPrefix []byte, market Market) []byte {
+	prefix := make([]byte, len(keyPrefix)+4)
+	copy(prefix, keyPrefix)
+	binary.BigEndian.PutUint32(prefix[len(keyPrefix):], uint32(market))
+	return prefix
+}
+
+func traderFillsPrefix(trader common.Address) []byte {
+	return append(common.CopyBytes(fillsKeyPrefix), trader.Bytes()...)
+}
+
+// IndexedRange is the range of accepted blocks whose logs are all indexed
+type IndexedRange struct {
+	FromBlock     uint64 `json:"fromBlock"`
+	FromTimestamp uint64 `json:"fromTimestamp"`
+	ToBlock       uint64 `json:"toBlock"` // last block with indexed logs
+}
+
+// StartIndexing is called before the logs of the accepted blocks from fromBlock are indexed, fromTimestamp is the timestamp of fromBlock.
+// The indexed range is kept if it reaches fromBlock, otherwise the logs in between were never indexed and the range starts again at fromBlock.
+// Blocks without logs don't move ToBlock, so the range can start again when it didn't have to, but it never covers a gap
+func (indexer *TradeIndexer) StartIndexing(fromBlock, fromTimestamp uint64) error {
+	indexedRange, err := indexer.GetIndexedRange()
+	if err != nil {
+		return err
+	}
+	if indexedRange != nil && indexedRange.FromBlock <= fromBlock && fromBlock <= indexedRange.ToBlock+1 {
+		return nil
+	}
+	if indexedRange != nil {
+		log.Warn("StartIndexing - logs were not indexed since the last indexed block, the indexed range starts again", "lastIndexedBlock", indexedRange.ToBlock, "fromBlock", fromBlock)
+	}
+	return indexer.putIndexedRange(indexer.db, &IndexedRange{FromBlock: fromBlock, FromTimestamp: fromTimestamp, ToBlock: fromBlock})
+}
+
+// GetIndexedRange returns nil if StartIndexing was never called
+func (indexer *TradeIndexer) GetIndexedRange() (*IndexedRange, error) {
+	value, err := indexer.db.Get(indexedRangeKey)
+	if err == database.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	indexedRange := &IndexedRange{}
+	if err := json.Unmarshal(value, indexedRange); err != nil {
+		return nil, fmt.Errorf("error in decoding indexed range: err=%v", err)
+	}
+	return indexedRange, nil
+}
+
+func (indexer *TradeIndexer) putIndexedRange(writer database.KeyValueWriter, indexedRange *IndexedRange) error {
+	value, err := json.Marshal(indexedRange)
+	if err != nil {
+		return fmt.Errorf("error in encoding indexed range: err=%v", err)
+	}
+	return writer.Put(indexedRangeKey, value)
+}
+
+// IndexAcceptedLogs records the fills, trades and funding rates in the logs. logs should only contain logs of accepted blocks
+func (indexer *TradeIndexer) IndexAcceptedLogs(logs []*types.Log) {
+	if len(logs) == 0 {
+		return
+	}
+	toBlock := uint64(0)
+	for _, event := range logs {
+		toBlock = max(toBlock, event.BlockNumber)
+	}
+	fills, trades, fundingRates := indexer.parseLogs(logs)
+	if err := indexer.write(fills, trades, fundingRates, toBlock); err != nil {
+		tradeIndexerWriteFailuresCounter.Inc(1)
+		log.Error("IndexAcceptedLogs - error in writing to the trade indexer", "toBlock", toBlock, "err", err)
+	}
+}
+
+func (indexer *TradeIndexer) write(fills []*Fill, trades []*Trade, fundingRates []*FundingRate, toBlock uint64) error {
+	batch := indexer.db.NewBatch()
+	indexedRange, err := indexer.GetIndexedRange()
+	if err != nil {
+		return err
+	}
+	if indexedRange != nil && toBlock > indexedRange.ToBlock {
+		indexedRange.ToBlock = toBlock
+		if err := indexer.putIndexedRange(batch, indexedRange); err != nil {
+			return err
+		}
+	}
+	for _, fill := range fills {
+		value, err := json.Marshal(fill)
+		if err != nil {
+			return fmt.Errorf("error in encoding fill: err=%v", err)
+		}
+		if err := batch.Put(indexerKey(traderFillsPrefix(fill.Trader), fill.Timestamp, fill.BlockNumber, fill.LogIndex), value); err != nil {
+			return err
+		}
+	}
+	for _, trade := range trades {
+		value, err := json.Marshal(trade)
+		if err != nil {
+			return fmt.Errorf("error in encoding trade: err=%v", err)
+		}
+		if err := batch.Put(indexerKey(marketTradesPrefix(trade.Market), trade.Timestamp, trade.BlockNumber, trade.LogIndex), value); err != nil {
+			return err
+		}
+	}
+	for _, fundingRate := range fundingRates {
+		value, err := json.Marshal(fundingRate)
+		if err != nil {
+			return fmt.Errorf("error in encoding funding rate: err=%v", err)
+		}
+		if err := batch.Put(indexerKey(marketFundingRatesPrefix(fundingRate.Market), fundingRate.Timestamp, fundingRate.BlockNumber, fundingRate.LogIndex), value); err != nil {
+			return err
+		}
+	}
+	return batch.Write()
+}
+
+type txTrader struct {
+	txHash common.Hash
+	trader common.Address
+}
+
+type orderMatch struct {
+	*orderMatchedEvent
+	fill *Fill
+}
+
+// orderMatchedEvent is the payload of an OrderMatched event
+type orderMatchedEvent struct {
+	log           *types.Log
+	orderHash     common.Hash
+	fillAmount    *big.Int
+	price         *big.Int
+	isLiquidation bool
+	// side is the sign of the base asset quantity of the order, or 0 if it is not known
+	side int
+}
+
+// pairOrderMatches returns the OrderMatched events of the long and short order of each match, in the order of the events.
+// A match emits the OrderMatched event of its long order and then the one of its short order, both with the fill amount and price of the match,
+// so an event is only paired with the next event of the same tx if it is for another order on the other side with the same fill amount and price.
+// The order of a liquidation is matched against the position of the liquidated trader, so its event is not paired.
+func pairOrderMatches(events []*orderMatchedEvent) [][2]*orderMatchedEvent {
+	orderEvents := []*orderMatchedEvent{}
+	for _, event := range events {
+		if !event.isLiquidation {
+			orderEvents = append(orderEvents, event)
+		}
+	}
+	pairs := [][2]*orderMatchedEvent{}
+	for i := 0; i+1 < len(orderEvents); i++ {
+		long, short := orderEvents[i], orderEvents[i+1]
+		if long.log.TxHash != short.log.TxHash || long.orderHash == short.orderHash {
+			continue
+		}
+		if long.side < 0 || short.side > 0 || long.fillAmount.Cmp(short.fillAmount) != 0 || long.price.Cmp(short.price) != 0 {
+			continue
+		}
+		pairs = append(pairs, [2]*orderMatchedEvent{long, short})
+		i++
+	}
+	return pairs
+}
+
+// parseLogs converts PositionModified and PositionLiquidated events to fills. The OrderMatched events of a trader in a
+// transaction are emitted for the same matches as their PositionModified events and in the same order, so the order hash of a fill is taken from them.
+// Trades are made from the OrderMatched events of the two orders of a match, see pairOrderMatches, and a liquidation is paired with the
+// PositionLiquidated event of the same size and price before it. The size and price of a trade are the ones of the match.
+// The OrderMatched events of a tx with a BatchAuctionCleared event are not paired, each of them is a trade at the clearing price, see newBatchAuctionTrade.
+func (indexer *TradeIndexer) parseLogs(logs []*types.Log) ([]*Fill, []*Trade, []*FundingRate) {
+	logs = sortedLogs(logs)
+	fills := []*Fill{}
+	fundingRates := []*FundingRate{}
+	positionModifiedFills := []*Fill{}
+	liquidatedFills := []*Fill{}
+	orderMatches := map[txTrader][]*orderMatch{}
+	matchedEvents := []*orderMatch{}
+	clearingPrices := map[common.Hash]*big.Int{}
+	for _, event := range logs {
+		if event.Removed || len(event.Topics) < 2 {
+			continue
+		}
+		args := map[string]interface{}{}
+		switch {
+		// event FundingRateUpdated(uint indexed idx, int256 premiumFraction, uint256 underlyingPrice, int256 cumulativePremiumFraction, uint256 nextFundingTime, uint256 timestamp, uint256 blockNumber);
+		case event.Address == ClearingHouseContractAddress && event.Topics[0] == indexer.clearingHouseABI.Events["FundingRateUpdated"].ID:
+			if err := indexer.clearingHouseABI.UnpackIntoMap(args, "FundingRateUpdated", event.Data); err != nil {
+				log.Error("error in clearingHouseABI.UnpackIntoMap", "method", "FundingRateUpdated", "err", err)
+				continue
+			}
+			fundingRates = append(fundingRates, newFundingRate(event, args))
+			continue
+
+		// event BatchAuctionCleared(uint256 indexed ammIndex, uint256 clearingPrice, uint256 volume, uint256 numOrders, uint256 timestamp);
+		case event.Address == OrderBookContractAddress && event.Topics[0] == indexer.orderBookABI.Events["BatchAuctionCleared"].ID:
+			if err := indexer.orderBookABI.UnpackIntoMap(args, "BatchAuctionCleared", event.Data); err != nil {
+				log.Error("error in orderBookAbi.UnpackIntoMap", "method", "BatchAuctionCleared", "err", err)
+				continue
+			}
+			clearingPrices[event.TxHash] = args["clearingPrice"].(*big.Int)
+			continue
+		}
+
+		if len(event.Topics) < 3 {
+			continue
+		}
+		switch {
+		// event OrderMatched(address indexed trader, bytes32 indexed orderHash, uint256 fillAmount, uint price, uint openInterestNotional, uint timestamp, bool isLiquidation);
+		case event.Address == OrderBookContractAddress && event.Topics[0] == indexer.orderBookABI.Events["OrderMatched"].ID:
+			if err := indexer.orderBookABI.UnpackIntoMap(args, "OrderMatched", event.Data); err != nil {
+				log.Error("error in orderBookAbi.UnpackIntoMap", "method", "OrderMatched", "err", err)
+				continue
+			}
+			match := &orderMatch{orderMatchedEvent: newOrderMatchedEvent(event, args)}
+			key := txTrader{event.TxHash, getAddressFromTopicHash(event.Topics[1])}
+			orderMatches[key] = append(orderMatches[key], match)
+			matchedEvents = append(matchedEvents, match)
+
+		case event.Address == ClearingHouseContractAddress && event.Topics[0] == indexer.clearingHouseABI.Events["PositionModified"].ID:
+			if err := indexer.clearingHouseABI.UnpackIntoMap(args, "PositionModified", event.Data); err != nil {
+				log.Error("error in clearingHouseABI.UnpackIntoMap", "method", "PositionModified", "err", err)
+				continue
+			}
+			fill := newFill(event, args, false)
+			fills = append(fills, fill)
+			positionModifiedFills = append(positionModifiedFills, fill)
+
+		// the liquidated trader doesn't have an order, so there is no OrderMatched event for this fill
+		case event.Address == ClearingHouseContractAddress && event.Topics[0] == indexer.clearingHouseABI.Events["PositionLiquidated"].ID:
+			if err := indexer.clearingHouseABI.UnpackIntoMap(args, "PositionLiquidated", event.Data); err != nil {
+				log.Error("error in clearingHouseABI.UnpackIntoMap", "method", "PositionLiquidated", "err", err)
+				continue
+			}
+			fill := newFill(event, args, true)
+			fills = append(fills, fill)
+			liquidatedFills = append(liquidatedFills, fill)
+		}
+	}
+
+	matched := map[txTrader]int{}
+	for _, fill := range positionModifiedFills {
+		key := txTrader{fill.TxHash, fill.Trader}
+		if matches := orderMatches[key]; matched[key] < len(matches) {
+			match := matches[matched[key]]
+			fill.OrderHash = match.orderHash
+			fill.IsLiquidation = match.isLiquidation
+			match.fill = fill
+			match.side = fill.BaseAsset.Sign()
+		}
+		matched[key]++
+	}
+
+	events := []*orderMatchedEvent{}
+	fillOf := map[*orderMatchedEvent]*Fill{}
+	trades := []*Trade{}
+	liquidations := map[*Fill]struct{}{}
+	for _, match := range matchedEvents {
+		if match.fill == nil {
+			log.Warn("parseLogs - OrderMatched event without a fill", "txHash", match.log.TxHash, "orderHash", match.orderHash)
+			continue
+		}
+		if clearingPrice, ok := clearingPrices[match.log.TxHash]; ok && !match.isLiquidation {
+			trades = append(trades, newBatchAuctionTrade(match.fill, clearingPrice, match.orderMatchedEvent))
+			continue
+		}
+		if !match.isLiquidation {
+			events = append(events, match.orderMatchedEvent)
+			fillOf[match.orderMatchedEvent] = match.fill
+			continue
+		}
+		liquidated := findLiquidatedFill(liquidatedFills, liquidations, match)
+		if liquidated == nil {
+			log.Warn("parseLogs - liquidation without a liquidated position", "txHash", match.log.TxHash, "orderHash", match.orderHash)
+			continue
+		}
+		liquidations[liquidated] = struct{}{}
+		if match.side > 0 {
+			trades = append(trades, newTrade(match.fill, liquidated, match.orderMatchedEvent))
+		} else {
+			trades = append(trades, newTrade(liquidated, match.fill, match.orderMatchedEvent))
+		}
+	}
+
+	pairs := pairOrderMatches(events)
+	if 2*len(pairs) != len(events) {
+		log.Warn("parseLogs - OrderMatched events without a counterparty", "events", len(events), "pairs", len(pairs))
+	}
+	for _, pair := range pairs {
+		trades = append(trades, newTrade(fillOf[pair[0]], fillOf[pair[1]], pair[1]))
+	}
+	sort.SliceStable(trades, func(i, j int) bool {
+		if trades[i].BlockNumber != trades[j].BlockNumber {
+			return trades[i].BlockNumber < trades[j].BlockNumber
+		}
+		return trades[i].LogIndex < trades[j].LogIndex
+	})
+	return fills, trades, fundingRates
+}
+
+// findLiquidatedFill returns the closest PositionLiquidated fill before the OrderMatched event of a liquidation in the same tx and market,
+// on the other side and with the size and price of the match, that isn't paired yet
+func findLiquidatedFill(liquidatedFills []*Fill, paired map[*Fill]struct{}, match *orderMatch) *Fill {
+	for i := len(liquidatedFills) - 1; i >= 0; i-- {
+		fill := liquidatedFills[i]
+		if _, ok := paired[fill]; ok || fill.TxHash != match.log.TxHash || fill.LogIndex > match.log.Index || fill.Market != match.fill.Market {
+			continue
+		}
+		if fill.BaseAsset.Sign() == match.side || hu.Abs(fill.BaseAsset).Cmp(match.fillAmount) != 0 || fill.Price.Cmp(match.price) != 0 {
+			continue
+		}
+		return fill
+	}
+	return nil
+}
+
+func sortedLogs(logs []*types.Log) []*types.Log {
+	sorted := make([]*types.Log, len(logs))
+	copy(sorted, logs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].BlockNumber != sorted[j].BlockNumber {
+			return sorted[i].BlockNumber < sorted[j].BlockNumber
+		}
+		return sorted[i].Index < sorted[j].Index
+	})
+	return sorted
+}
+
+func newOrderMatchedEvent(event *types.Log, args map[string]interface{}) *orderMatchedEvent {
+	return &orderMatchedEvent{
+		log:           event,
+		orderHash:     event.Topics[2],
+		fillAmount:    args["fillAmount"].(*big.Int),
+		price:         args["price"].(*big.Int),
+		isLiquidation: args["isLiquidation"].(bool),
+	}
+}
+
+func newFill(event *types.Log, args map[string]interface{}, isLiquidation bool) *Fill {
+	return &Fill{
+		Market:        Market(int(event.Topics[2].Big().Int64())),
+		Trader:        getAddressFromTopicHash(event.Topics[1]),
+		BaseAsset:     args["baseAsset"].(*big.Int),
+		Price:         args["price"].(*big.Int),
+		RealizedPnl:   args["realizedPnl"].(*big.Int),
+		Fee:           args["fee"].(*big.Int),
+		IsLiquidation: isLiquidation,
+		BlockNumber:   event.BlockNumber,
+		TxHash:        event.TxHash,
+		LogIndex:      event.Index,
+		Timestamp:     args["timestamp"].(*big.Int).Uint64(),
+	}
+}
+
+// newTrade makes the trade of a match from the fills of both sides and the later OrderMatched event of the match
+func newTrade(long, short *Fill, match *orderMatchedEvent) *Trade {
+	return &Trade{
+		Market:        long.Market,
+		Price:         match.price,
+		Size:          match.fillAmount,
+		Buyer:         long.Trader,
+		Seller:        short.Trader,
+		IsLiquidation: long.IsLiquidation || short.IsLiquidation,
+		BlockNumber:   match.log.BlockNumber,
+		TxHash:        match.log.TxHash,
+		LogIndex:      match.log.Index,
+		Timestamp:     long.Timestamp,
+	}
+}
+
+// newBatchAuctionTrade makes the trade of the fill of an order in a batch auction, at the clearing price of the auction
+func newBatchAuctionTrade(fill *Fill, clearingPrice *big.Int, match *orderMatchedEvent) *Trade {
+	trade := &Trade{
+		Market:         fill.Market,
+		Price:          clearingPrice,
+		Size:           match.fillAmount,
+		IsBatchAuction: true,
+		BlockNumber:    match.log.BlockNumber,
+		TxHash:         match.log.TxHash,
+		LogIndex:       match.log.Index,
+		Timestamp:      fill.Timestamp,
+	}
+	if fill.BaseAsset.Sign() > 0 {
+		trade.Buyer = fill.Trader
+	} else {
+		trade.Seller = fill.Trader
+	}
+	return trade
+}
+
+func pageLimit(page *PaginationArgs) int {
+	if page == nil || page.Limit <= 0 {
+		return defaultIndexerPageSize
+	}
+	return min(page.Limit, maxIndexerPageSize)
+}
+
+func pageCursor(page *PaginationArgs) string {
+	if page == nil {
+		return ""
+	}
+	return page.Cursor
+}
+
+// iterate calls fn with the values of the keys with the given prefix and a timestamp in [from, to], in increasing order of key, starting at cursor if set.
+// The timestamp is the first 8 bytes after the prefix, which is a block number for the keys of archived orders.
+// If there are more than limit values, the key of the next value is returned as the cursor of the next page
+func (indexer *TradeIndexer) iterate(prefix []byte, from, to uint64, limit int, cursor string, fn func(value []byte) error) (string, error) {
+	start := indexerKey(prefix, from, 0, 0)
+	if cursor != "" {
+		cursorKey, err := hex.DecodeString(cursor)
+		if err != nil || len(cursorKey) < len(start) || string(cursorKey[:len(prefix)]) != string(prefix) {
+			return "", ErrInvalidCursor
+		}
+		start = cursorKey
+	}
+
+	iterator := indexer.db.NewIteratorWithStartAndPrefix(start, prefix)
+	defer iterator.Release()
+
+	count := 0
+	for iterator.Next() {
+		key := iterator.Key()
+		if to != 0 && binary.BigEndian.Uint64(key[len(prefix):]) > to {
+			break
+		}
+		if count == limit {
+			return hex.EncodeToString(key), nil
+		}
+		if err := fn(iterator.Value()); err != nil {
+			return "", err
+		}
+		count++
+	}
+	if err := iterator.Error(); err != nil {
+		return "", fmt.Errorf("error in iterating trade indexer: err=%v", err)
+	}
+	return "", nil
+}
+
+// GetTrades returns the trades of a market with a timestamp in [from, to]. to = 0 means no upper bound
+func (indexer *TradeIndexer) GetTrades(market Market, from, to uint64, page *PaginationArgs) (TradesResponse, error) {
+	response := TradesResponse{Trades: []*Trade{}}
+	nextCursor, err := indexer.iterate(marketTradesPrefix(market), from, to, pageLimit(page), pageCursor(page), func(value []byte) error {
+		trade := &Trade{}
+		if err := json.Unmarshal(value, trade); err != nil {
+			return fmt.Errorf("error in decoding trade: err=%v", err)
+		}
+		response.Trades = append(response.Trades, trade)
+		return nil
+	})
+	response.NextCursor = nextCursor
+	return response, err
+}
+
+// GetUserFills returns the fills of a trader in all markets with a timestamp in [from, to]. to = 0 means no upper bound
+func (indexer *TradeIndexer) GetUserFills(trader common.Address, from, to uint64, page *PaginationArgs) (FillsResponse, error) {
+	response := FillsResponse{Fills: []*Fill{}}
+	nextCursor, err := indexer.iterate(traderFillsPrefix(trader), from, to, pageLimit(page), pageCursor(page), func(value []byte) error {
+		fill := &Fill{}
+		if err := json.Unmarshal(value, fill); err != nil {
+			return fmt.Errorf("error in decoding fill: err=%v", err)
+		}
+		response.Fills = append(response.Fills, fill)
+		return nil
+	})
+	response.NextCursor = nextCursor
+	return response, err
+}
+
+// GetCandles aggregates the trades of a market with a timestamp in [from, to] into candles of interval seconds.
+// Candles are aligned to multiples of interval and intervals without trades are skipped.
+// Both sides of a batch auction are recorded as trades, so only the long fills are counted in the volume and the number of trades
+func (indexer *TradeIndexer) GetCandles(market Market, interval, from, to uint64) ([]*Candle, error) {
+	if interval == 0 {
+		return nil, ErrInvalidInterval
+	}
+	if to < from || (to-from)/interval >= maxCandlesPerQuery {
+		return nil, ErrTooManyCandles
+	}
+
+	candles := []*Candle{}
+	_, err := indexer.iterate(marketTradesPrefix(market), from, to, math.MaxInt, "", func(value []byte) error {
+		trade := &Trade{}
+		if err := json.Unmarshal(value, trade); err != nil {
+			return fmt.Errorf("error in decoding trade: err=%v", err)
+		}
+		openTime := trade.Timestamp - trade.Timestamp%interval
+		if len(candles) == 0 || candles[len(candles)-1].OpenTime != openTime {
+			candles = append(candles, &Candle{
+				OpenTime:    openTime,
+				Open:        trade.Price,
+				High:        trade.Price,
+				Low:         trade.Price,
+				Volume:      big.NewInt(0),
+				QuoteVolume: big.NewInt(0),
+			})
+		}
+		candle := candles[len(candles)-1]
+		if trade.Price.Cmp(candle.High) > 0 {
+			candle.High = trade.Price
+		}
+		if trade.Price.Cmp(candle.Low) < 0 {
+			candle.Low = trade.Price
+		}
+		candle.Close = trade.Price
+		if trade.IsBatchAuction && trade.Buyer == (common.Address{}) {
+			return nil
+		}
+		candle.Volume.Add(candle.Volume, trade.Size)
+		candle.QuoteVolume.Add(candle.QuoteVolume, hu.Div1e18(hu.Mul(trade.Size, trade.Price)))
+		candle.Trades++
+		return nil
+	})
+	return candles, err
+}
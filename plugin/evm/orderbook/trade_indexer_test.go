@@ -0,0 +1,224 @@
+package orderbook
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/database/memdb"
+	"github.com/ava-labs/subnet-evm/core/types"
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTradeIndexer(t *testing.T) {
+	indexer := NewTradeIndexer(memdb.New())
+	longTrader := common.HexToAddress(userAddress)
+	shortTrader := common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+	liquidatedTrader := common.HexToAddress("0x3C44CdDdB6a900fa2b585dd299e03d12FA4293BC")
+	longOrder := common.HexToHash("0x01")
+	shortOrder := common.HexToHash("0x02")
+	liquidationOrder := common.HexToHash("0x03")
+
+	positionModified := func(trader common.Address, baseAsset, price int64, timestamp uint64, txHash common.Hash, logIndex uint) *types.Log {
+		event := getEventFromABI(indexer.clearingHouseABI, "PositionModified")
+		data, err := event.Inputs.NonIndexed().Pack(hu.Mul1e18(big.NewInt(baseAsset)), hu.Mul1e6(big.NewInt(price)), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(1), uint8(0), new(big.Int).SetUint64(timestamp))
+		assert.Nil(t, err)
+		return indexerEventLog(ClearingHouseContractAddress, []common.Hash{event.ID, trader.Hash(), common.BigToHash(big.NewInt(int64(market)))}, data, timestamp, txHash, logIndex)
+	}
+	positionLiquidated := func(trader common.Address, baseAsset, price int64, timestamp uint64, txHash common.Hash, logIndex uint) *types.Log {
+		event := getEventFromABI(indexer.clearingHouseABI, "PositionLiquidated")
+		data, err := event.Inputs.NonIndexed().Pack(hu.Mul1e18(big.NewInt(baseAsset)), hu.Mul1e6(big.NewInt(price)), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(1), new(big.Int).SetUint64(timestamp))
+		assert.Nil(t, err)
+		return indexerEventLog(ClearingHouseContractAddress, []common.Hash{event.ID, trader.Hash(), common.BigToHash(big.NewInt(int64(market)))}, data, timestamp, txHash, logIndex)
+	}
+	orderMatched := func(trader common.Address, orderHash common.Hash, fillAmount, price int64, isLiquidation bool, timestamp uint64, txHash common.Hash, logIndex uint) *types.Log {
+		event := getEventFromABI(indexer.orderBookABI, "OrderMatched")
+		data, err := event.Inputs.NonIndexed().Pack(hu.Mul1e18(big.NewInt(fillAmount)), hu.Mul1e6(big.NewInt(price)), big.NewInt(0), new(big.Int).SetUint64(timestamp), isLiquidation)
+		assert.Nil(t, err)
+		return indexerEventLog(OrderBookContractAddress, []common.Hash{event.ID, trader.Hash(), orderHash}, data, timestamp, txHash, logIndex)
+	}
+
+	matchTx := common.HexToHash("0x11")
+	liquidationTx := common.HexToHash("0x12")
+	indexer.IndexAcceptedLogs([]*types.Log{
+		positionModified(longTrader, 2, 100, 60, matchTx, 0),
+		positionModified(shortTrader, -2, 100, 60, matchTx, 1),
+		orderMatched(longTrader, longOrder, 2, 100, false, 60, matchTx, 2),
+		orderMatched(shortTrader, shortOrder, 2, 100, false, 60, matchTx, 3),
+	})
+	indexer.IndexAcceptedLogs([]*types.Log{
+		positionLiquidated(liquidatedTrader, -1, 90, 130, liquidationTx, 0),
+		positionModified(longTrader, 1, 90, 130, liquidationTx, 1),
+		orderMatched(longTrader, liquidationOrder, 1, 90, true, 130, liquidationTx, 2),
+	})
+
+	t.Run("fills of a trader", func(t *testing.T) {
+		response, err := indexer.GetUserFills(longTrader, 0, 0, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(response.Fills))
+		assert.Equal(t, "", response.NextCursor)
+		assert.Equal(t, longOrder, response.Fills[0].OrderHash)
+		assert.Equal(t, hu.Mul1e18(big.NewInt(2)), response.Fills[0].BaseAsset)
+		assert.False(t, response.Fills[0].IsLiquidation)
+		assert.Equal(t, liquidationOrder, response.Fills[1].OrderHash)
+		assert.True(t, response.Fills[1].IsLiquidation)
+
+		response, err = indexer.GetUserFills(liquidatedTrader, 0, 0, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(response.Fills))
+		assert.Equal(t, common.Hash{}, response.Fills[0].OrderHash)
+		assert.True(t, response.Fills[0].IsLiquidation)
+	})
+	t.Run("trades of a market", func(t *testing.T) {
+		response, err := indexer.GetTrades(market, 0, 0, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(response.Trades))
+		assert.Equal(t, longTrader, response.Trades[0].Buyer)
+		assert.Equal(t, shortTrader, response.Trades[0].Seller)
+		assert.Equal(t, hu.Mul1e18(big.NewInt(2)), response.Trades[0].Size)
+		assert.False(t, response.Trades[0].IsLiquidation)
+		assert.Equal(t, liquidatedTrader, response.Trades[1].Seller)
+		assert.True(t, response.Trades[1].IsLiquidation)
+
+		response, err = indexer.GetTrades(market, 61, 0, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(response.Trades))
+		assert.Equal(t, uint64(130), response.Trades[0].Timestamp)
+
+		response, err = indexer.GetTrades(market, 0, 129, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(response.Trades))
+		assert.Equal(t, uint64(60), response.Trades[0].Timestamp)
+
+		response, err = indexer.GetTrades(Market(1), 0, 0, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(response.Trades))
+	})
+	t.Run("pagination", func(t *testing.T) {
+		response, err := indexer.GetTrades(market, 0, 0, &PaginationArgs{Limit: 1})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(response.Trades))
+		assert.Equal(t, uint64(60), response.Trades[0].Timestamp)
+		assert.NotEqual(t, "", response.NextCursor)
+
+		response, err = indexer.GetTrades(market, 0, 0, &PaginationArgs{Limit: 1, Cursor: response.NextCursor})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(response.Trades))
+		assert.Equal(t, uint64(130), response.Trades[0].Timestamp)
+		assert.Equal(t, "", response.NextCursor)
+
+		_, err = indexer.GetUserFills(longTrader, 0, 0, &PaginationArgs{Cursor: "abcd"})
+		assert.Equal(t, ErrInvalidCursor, err)
+	})
+	t.Run("candles", func(t *testing.T) {
+		// a short order is matched with two long orders of different sizes in the same tx
+		makerTx := common.HexToHash("0x13")
+		indexer.IndexAcceptedLogs([]*types.Log{
+			positionModified(longTrader, 1, 110, 140, makerTx, 0),
+			positionModified(shortTrader, -1, 110, 140, makerTx, 1),
+			orderMatched(longTrader, common.HexToHash("0x04"), 1, 110, false, 140, makerTx, 2),
+			orderMatched(shortTrader, shortOrder, 1, 110, false, 140, makerTx, 3),
+			positionModified(liquidatedTrader, 3, 110, 140, makerTx, 4),
+			positionModified(shortTrader, -3, 110, 140, makerTx, 5),
+			orderMatched(liquidatedTrader, common.HexToHash("0x05"), 3, 110, false, 140, makerTx, 6),
+			orderMatched(shortTrader, shortOrder, 3, 110, false, 140, makerTx, 7),
+		})
+
+		response, err := indexer.GetTrades(market, 140, 140, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(response.Trades))
+		assert.Equal(t, longTrader, response.Trades[0].Buyer)
+		assert.Equal(t, hu.Mul1e18(big.NewInt(1)), response.Trades[0].Size)
+		assert.Equal(t, liquidatedTrader, response.Trades[1].Buyer)
+		assert.Equal(t, shortTrader, response.Trades[1].Seller)
+		assert.Equal(t, hu.Mul1e18(big.NewInt(3)), response.Trades[1].Size)
+
+		candles, err := indexer.GetCandles(market, 60, 0, 179)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(candles))
+		assert.Equal(t, uint64(60), candles[0].OpenTime)
+		assert.Equal(t, uint64(1), candles[0].Trades)
+		assert.Equal(t, uint64(120), candles[1].OpenTime)
+		assert.Equal(t, hu.Mul1e6(big.NewInt(90)), candles[1].Open)
+		assert.Equal(t, hu.Mul1e6(big.NewInt(110)), candles[1].High)
+		assert.Equal(t, hu.Mul1e6(big.NewInt(90)), candles[1].Low)
+		assert.Equal(t, hu.Mul1e6(big.NewInt(110)), candles[1].Close)
+		assert.Equal(t, hu.Mul1e18(big.NewInt(5)), candles[1].Volume)
+		assert.Equal(t, hu.Mul1e6(big.NewInt(530)), candles[1].QuoteVolume)
+		assert.Equal(t, uint64(3), candles[1].Trades)
+
+		_, err = indexer.GetCandles(market, 0, 0, 179)
+		assert.Equal(t, ErrInvalidInterval, err)
+		_, err = indexer.GetCandles(market, 1, 0, maxCandlesPerQuery)
+		assert.Equal(t, ErrTooManyCandles, err)
+	})
+	t.Run("indexing the same logs again is a no-op and removed logs are ignored", func(t *testing.T) {
+		removed := positionModified(longTrader, 5, 100, 200, common.HexToHash("0x14"), 0)
+		removed.Removed = true
+		indexer.IndexAcceptedLogs([]*types.Log{
+			positionModified(longTrader, 2, 100, 60, matchTx, 0),
+			positionModified(shortTrader, -2, 100, 60, matchTx, 1),
+			orderMatched(longTrader, longOrder, 2, 100, false, 60, matchTx, 2),
+			orderMatched(shortTrader, shortOrder, 2, 100, false, 60, matchTx, 3),
+			removed,
+		})
+		response, err := indexer.GetTrades(market, 0, 0, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 4, len(response.Trades))
+
+		fills, err := indexer.GetUserFills(longTrader, 0, 60, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(fills.Fills))
+		assert.Equal(t, longOrder, fills.Fills[0].OrderHash)
+	})
+	t.Run("fills of a batch auction are trades at the clearing price", func(t *testing.T) {
+		auctionTx := common.HexToHash("0x15")
+		auctionEvent := getEventFromABI(indexer.orderBookABI, "BatchAuctionCleared")
+		auctionData, err := auctionEvent.Inputs.NonIndexed().Pack(hu.Mul1e6(big.NewInt(105)), hu.Mul1e18(big.NewInt(3)), big.NewInt(3), big.NewInt(300))
+		assert.Nil(t, err)
+		indexer.IndexAcceptedLogs([]*types.Log{
+			positionModified(longTrader, 2, 105, 300, auctionTx, 0),
+			orderMatched(longTrader, common.HexToHash("0x06"), 2, 105, false, 300, auctionTx, 1),
+			positionModified(liquidatedTrader, 1, 105, 300, auctionTx, 2),
+			orderMatched(liquidatedTrader, common.HexToHash("0x07"), 1, 105, false, 300, auctionTx, 3),
+			positionModified(shortTrader, -3, 105, 300, auctionTx, 4),
+			orderMatched(shortTrader, common.HexToHash("0x08"), 3, 105, false, 300, auctionTx, 5),
+			indexerEventLog(OrderBookContractAddress, []common.Hash{auctionEvent.ID, common.BigToHash(big.NewInt(int64(market)))}, auctionData, 300, auctionTx, 6),
+		})
+
+		response, err := indexer.GetTrades(market, 300, 300, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(response.Trades))
+		for _, trade := range response.Trades {
+			assert.True(t, trade.IsBatchAuction)
+			assert.Equal(t, hu.Mul1e6(big.NewInt(105)), trade.Price)
+		}
+		assert.Equal(t, longTrader, response.Trades[0].Buyer)
+		assert.Equal(t, common.Address{}, response.Trades[0].Seller)
+		assert.Equal(t, hu.Mul1e18(big.NewInt(2)), response.Trades[0].Size)
+		assert.Equal(t, liquidatedTrader, response.Trades[1].Buyer)
+		assert.Equal(t, common.Address{}, response.Trades[2].Buyer)
+		assert.Equal(t, shortTrader, response.Trades[2].Seller)
+		assert.Equal(t, hu.Mul1e18(big.NewInt(3)), response.Trades[2].Size)
+
+		fills, err := indexer.GetUserFills(shortTrader, 300, 300, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(fills.Fills))
+		assert.Equal(t, common.HexToHash("0x08"), fills.Fills[0].OrderHash)
+
+		candles, err := indexer.GetCandles(market, 60, 300, 359)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(candles))
+		assert.Equal(t, hu.Mul1e18(big.NewInt(3)), candles[0].Volume)
+		assert.Equal(t, hu.Mul1e6(big.NewInt(315)), candles[0].QuoteVolume)
+		assert.Equal(t, uint64(2), candles[0].Trades)
+	})
+}
+
+func indexerEventLog(contractAddress common.Address, topics []common.Hash, data []byte, blockNumber uint64, txHash common.Hash, logIndex uint) *types.Log {
+	log := getEventLog(contractAddress, topics, data, blockNumber)
+	log.TxHash = txHash
+	log.Index = logIndex
+	return log
+}
@@ -9,15 +9,20 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/ava-labs/subnet-evm/accounts/abi"
 	"github.com/ava-labs/subnet-evm/eth"
+	"github.com/ava-labs/subnet-evm/internal/ethapi"
+	"github.com/ava-labs/subnet-evm/plugin/evm/orderbook/abis"
 	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
 	"github.com/ava-labs/subnet-evm/rpc"
 	"github.com/ava-labs/subnet-evm/utils"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
 )
@@ -26,6 +31,12 @@ var traderFeed event.Feed
 var marketFeed event.Feed
 var MakerbookDatabaseFile string
 
+var (
+	ErrAmendedOrderNotFound = errors.New("amended order not found")
+	ErrInvalidAmendment     = errors.New("amendment should be from the same trader, in the same market and direction")
+	ErrStreamAuthRequired   = errors.New("trader updates require authentication, subscribe to streamPrivateTraderUpdates")
+)
+
 type TradingAPI struct {
 	db                     LimitOrderDatabase
 	backend                *eth.EthAPIBackend
@@ -33,9 +44,18 @@ type TradingAPI struct {
 	makerbookFileWriteChan chan Order
 	shutdownChan           <-chan struct{}
 	shutdownWg             *sync.WaitGroup
+	tradeIndexer           *TradeIndexer // nil if the trade indexer is disabled
+	requireStreamAuth      bool          // if set, trader updates are only streamed to authenticated subscribers
+	streamAuthNonces       streamAuthNonces
+	ammABI                 abi.ABI
 }
 
-func NewTradingAPI(database LimitOrderDatabase, backend *eth.EthAPIBackend, configService IConfigService, shutdownChan <-chan struct{}, shutdownWg *sync.WaitGroup) *TradingAPI {
+func NewTradingAPI(database LimitOrderDatabase, backend *eth.EthAPIBackend, configService IConfigService, tradeIndexer *TradeIndexer, requireStreamAuth bool, shutdownChan <-chan struct{}, shutdownWg *sync.WaitGroup) *TradingAPI {
+	ammABI, err := abi.FromSolidityJson(string(abis.AMMAbi))
+	if err != nil {
+		panic(err)
+	}
+
 	tradingAPI := &TradingAPI{
 		db:                     database,
 		backend:                backend,
@@ -43,6 +63,9 @@ func NewTradingAPI(database LimitOrderDatabase, backend *eth.EthAPIBackend, conf
 		makerbookFileWriteChan: make(chan Order, 100),
 		shutdownChan:           shutdownChan,
 		shutdownWg:             shutdownWg,
+		tradeIndexer:           tradeIndexer,
+		requireStreamAuth:      requireStreamAuth,
+		ammABI:                 ammABI,
 	}
 
 	shutdownWg.Add(1)
@@ -62,7 +85,7 @@ func NewTradingAPI(database LimitOrderDatabase, backend *eth.EthAPIBackend, conf
 }
 
 type TradingOrderBookDepthResponse struct {
-	LastUpdateID int        `json:"lastUpdateId"`
+	LastUpdateID uint64     `json:"lastUpdateId"`
 	E            int64      `json:"E"`
 	T            int64      `json:"T"`
 	Symbol       int64      `json:"symbol"`
@@ -70,11 +93,17 @@ type TradingOrderBookDepthResponse struct {
 	Asks         [][]string `json:"asks"`
 }
 
+// TradingOrderBookDepthUpdateResponse is a diff of the order book depth, from update FirstUpdateID to LastUpdateID of the market.
+// The first notification of a stream, and the one after the subscriber falls behind, is a snapshot of the depth instead.
 type TradingOrderBookDepthUpdateResponse struct {
-	T      int64      `json:"T"`
-	Symbol int64      `json:"s"`
-	Bids   [][]string `json:"b"`
-	Asks   [][]string `json:"a"`
+	T             int64      `json:"T"`
+	Symbol        int64      `json:"s"`
+	FirstUpdateID uint64     `json:"U"`
+	LastUpdateID  uint64     `json:"u"`
+	PrevUpdateID  uint64     `json:"pu"` // LastUpdateID of the previous notification
+	IsSnapshot    bool       `json:"snapshot,omitempty"`
+	Bids          [][]string `json:"b"`
+	Asks          [][]string `json:"a"`
 }
 
 // found at https://binance-docs.github.io/apidocs/futures/en/#query-order-user_data
@@ -93,6 +122,25 @@ type OrderStatusResponse struct {
 	Type         string   `json:"type"`         // "LIMIT"
 	UpdateTime   int64    `json:"updateTime"`   // 1579276756075
 	Salt         *big.Int `json:"salt"`
+
+	Lifecycle []OrderLifecycleResponse `json:"lifecycle"`
+}
+
+// OrderLifecycleResponse is a change in the status of an order, or a fill of the order
+type OrderLifecycleResponse struct {
+	BlockNumber         uint64          `json:"blockNumber"`
+	Status              string          `json:"status"`
+	Reason              LifecycleReason `json:"reason,omitempty"`
+	Info                string          `json:"info,omitempty"`
+	FillQty             string          `json:"fillQty,omitempty"`
+	FillPrice           string          `json:"fillPrice,omitempty"`
+	CounterpartyOrderID string          `json:"counterpartyOrderId,omitempty"`
+	TxHash              string          `json:"txHash,omitempty"`
+}
+
+type OrderHistoryResponse struct {
+	Orders     []OrderStatusResponse `json:"orders"`
+	NextCursor string                `json:"nextCursor,omitempty"`
 }
 
 type TraderPosition struct {
@@ -109,9 +157,11 @@ type TraderPosition struct {
 }
 
 type GetPositionsResponse struct {
-	Margin         string           `json:"margin"`
-	ReservedMargin string           `json:"reservedMargin"`
-	Positions      []TraderPosition `json:"positions"`
+	Margin         string `json:"margin"`
+	ReservedMargin string `json:"reservedMargin"`
+	// fraction of the margin that can be lost across all positions before the trader can be liquidated, <= 0 when it can be
+	DistanceToLiquidation string           `json:"distanceToLiquidation"`
+	Positions             []TraderPosition `json:"positions"`
 }
 
 var mapStatus = map[Status]string{
@@ -122,29 +172,59 @@ var mapStatus = map[Status]string{
 }
 
 func (api *TradingAPI) GetTradingOrderBookDepth(ctx context.Context, market int8) TradingOrderBookDepthResponse {
-	depth := getDepthForMarket(api.db, Market(market))
+	snapshot := api.db.GetDepthSnapshot(Market(market))
 
-	response := transformMarketDepth(depth)
+	response := transformMarketDepth(snapshot.Depth)
+	response.LastUpdateID = snapshot.LastUpdateID
 	response.T = time.Now().Unix()
 
 	return response
 }
 
+// GetOrderStatus returns an order in the memory DB, or an archived order if the trade indexer is enabled
 func (api *TradingAPI) GetOrderStatus(ctx context.Context, orderId common.Hash) (OrderStatusResponse, error) {
-	response := OrderStatusResponse{}
+	if limitOrder := api.db.GetOrderById(orderId); limitOrder != nil {
+		return api.getOrderStatusResponse(ctx, limitOrder, limitOrder.isPostOnly()), nil
+	}
+	if api.tradeIndexer != nil {
+		archivedOrder, err := api.tradeIndexer.GetArchivedOrder(orderId)
+		if err != nil {
+			return OrderStatusResponse{}, err
+		}
+		if archivedOrder != nil {
+			return api.getOrderStatusResponse(ctx, archivedOrder.toOrder(), archivedOrder.PostOnly), nil
+		}
+	}
+	return OrderStatusResponse{}, fmt.Errorf("order not found")
+}
 
-	limitOrder := api.db.GetOrderById(orderId)
-	if limitOrder == nil {
-		return response, fmt.Errorf("order not found")
+// GetOrderHistory returns the orders of a trader that were removed from the order book, i.e. filled, cancelled or expired,
+// whose last lifecycle is in the blocks [fromBlock, toBlock]. toBlock = 0 means no upper bound
+func (api *TradingAPI) GetOrderHistory(ctx context.Context, trader common.Address, fromBlock, toBlock uint64, page *PaginationArgs) (OrderHistoryResponse, error) {
+	response := OrderHistoryResponse{Orders: []OrderStatusResponse{}}
+	if api.tradeIndexer == nil {
+		return response, ErrTradeIndexerDisabled
+	}
+	archivedOrders, nextCursor, err := api.tradeIndexer.GetOrderHistory(trader, fromBlock, toBlock, page)
+	if err != nil {
+		return response, err
 	}
+	for _, archivedOrder := range archivedOrders {
+		response.Orders = append(response.Orders, api.getOrderStatusResponse(ctx, archivedOrder.toOrder(), archivedOrder.PostOnly))
+	}
+	response.NextCursor = nextCursor
+	return response, nil
+}
 
-	status := mapStatus[limitOrder.getOrderStatus().Status]
-	if limitOrder.FilledBaseAssetQuantity.Sign() != 0 {
+func (api *TradingAPI) getOrderStatusResponse(ctx context.Context, limitOrder *Order, postOnly bool) OrderStatusResponse {
+	lastLifecycle := limitOrder.getOrderStatus()
+	status := mapStatus[lastLifecycle.Status]
+	if lastLifecycle.Status == Placed && limitOrder.FilledBaseAssetQuantity.Sign() != 0 {
 		status = "PARTIALLY_FILLED"
 	}
-
-	limitOrder.BaseAssetQuantity.Abs(limitOrder.BaseAssetQuantity)
-	limitOrder.FilledBaseAssetQuantity.Abs(limitOrder.FilledBaseAssetQuantity)
+	if lastLifecycle.Reason == ReasonExpired {
+		status = "EXPIRED"
+	}
 
 	var positionSide string
 	switch limitOrder.PositionType {
@@ -156,22 +236,27 @@ func (api *TradingAPI) GetOrderStatus(ctx context.Context, orderId common.Hash)
 
 	var time, updateTime int64
 	placedBlock, err := api.backend.BlockByNumber(ctx, rpc.BlockNumber(limitOrder.BlockNumber.Int64()))
-	if err == nil {
+	if err == nil && placedBlock != nil {
 		time = int64(placedBlock.Time())
 	}
 
-	updateBlock, err := api.backend.BlockByNumber(ctx, rpc.BlockNumber(limitOrder.getOrderStatus().BlockNumber))
-	if err == nil {
+	updateBlock, err := api.backend.BlockByNumber(ctx, rpc.BlockNumber(lastLifecycle.BlockNumber))
+	if err == nil && updateBlock != nil {
 		updateTime = int64(updateBlock.Time())
 	}
 
-	response = OrderStatusResponse{
-		ExecutedQty:  utils.BigIntToDecimal(limitOrder.FilledBaseAssetQuantity, 18, 8),
+	lifecycle := make([]OrderLifecycleResponse, 0, len(limitOrder.LifecycleList))
+	for _, entry := range limitOrder.LifecycleList {
+		lifecycle = append(lifecycle, newOrderLifecycleResponse(entry))
+	}
+
+	return OrderStatusResponse{
+		ExecutedQty:  utils.BigIntToDecimal(new(big.Int).Abs(limitOrder.FilledBaseAssetQuantity), 18, 8),
 		OrderID:      limitOrder.Id.String(),
-		OrigQty:      utils.BigIntToDecimal(limitOrder.BaseAssetQuantity, 18, 8),
+		OrigQty:      utils.BigIntToDecimal(new(big.Int).Abs(limitOrder.BaseAssetQuantity), 18, 8),
 		Price:        utils.BigIntToDecimal(limitOrder.Price, 6, 8),
 		ReduceOnly:   limitOrder.ReduceOnly,
-		PostOnly:     limitOrder.isPostOnly(),
+		PostOnly:     postOnly,
 		PositionSide: positionSide,
 		Status:       status,
 		Symbol:       int64(limitOrder.Market),
@@ -179,35 +264,72 @@ func (api *TradingAPI) GetOrderStatus(ctx context.Context, orderId common.Hash)
 		Type:         "LIMIT_ORDER",
 		UpdateTime:   updateTime,
 		Salt:         limitOrder.Salt,
+		Lifecycle:    lifecycle,
 	}
+}
 
-	return response, nil
+func newOrderLifecycleResponse(lifecycle Lifecycle) OrderLifecycleResponse {
+	response := OrderLifecycleResponse{
+		BlockNumber: lifecycle.BlockNumber,
+		Status:      mapStatus[lifecycle.Status],
+		Reason:      lifecycle.Reason,
+		Info:        lifecycle.Info,
+	}
+	if lifecycle.FillAmount != nil {
+		response.FillQty = utils.BigIntToDecimal(new(big.Int).Abs(lifecycle.FillAmount), 18, 8)
+	}
+	if lifecycle.FillPrice != nil {
+		response.FillPrice = utils.BigIntToDecimal(lifecycle.FillPrice, 6, 8)
+	}
+	if lifecycle.CounterpartyOrderId != nil {
+		response.CounterpartyOrderID = lifecycle.CounterpartyOrderId.String()
+	}
+	if lifecycle.TxHash != nil {
+		response.TxHash = lifecycle.TxHash.String()
+	}
+	return response
 }
 
-func (api *TradingAPI) GetMarginAndPositions(ctx context.Context, trader string) (GetPositionsResponse, error) {
+// GetMarginAndPositions returns the account of a trader from the memory DB, or from the chain state after blockNumber when it is given
+func (api *TradingAPI) GetMarginAndPositions(ctx context.Context, trader string, blockNumber *rpc.BlockNumber) (GetPositionsResponse, error) {
 	response := GetPositionsResponse{Positions: []TraderPosition{}}
 
 	traderAddr := common.HexToAddress(trader)
 
-	traderInfo := api.db.GetTraderInfo(traderAddr)
+	configService := api.configService
+	var traderInfo *Trader
+	if blockNumber != nil {
+		stateDB, err := getStateAtBlock(ctx, api.backend, *blockNumber)
+		if err != nil {
+			return response, err
+		}
+		configService = NewConfigServiceFromStateDB(stateDB)
+		traderInfo = getTraderFromState(stateDB, configService, traderAddr)
+	} else {
+		traderInfo = api.db.GetTraderInfo(traderAddr)
+	}
 	if traderInfo == nil {
 		return response, fmt.Errorf("trader not found")
 	}
 
-	count := int64(len(api.configService.GetMarketsIncludingSettled()))
+	count := int64(len(configService.GetMarketsIncludingSettled()))
 	markets := make([]Market, count)
 	for i := int64(0); i < count; i++ {
 		markets[i] = Market(i)
 	}
 
-	assets := api.configService.GetCollaterals()
+	assets := configService.GetCollaterals()
 	pendingFunding := getTotalFunding(traderInfo, markets)
 	margin := new(big.Int).Sub(getNormalisedMargin(traderInfo, assets), pendingFunding)
 	response.Margin = utils.BigIntToDecimal(margin, 6, 8)
 	response.ReservedMargin = utils.BigIntToDecimal(traderInfo.Margin.Reserved, 6, 8)
 
+	liquidationPrices, distanceToLiquidation := getLiquidationPrices(traderInfo, GetHubbleState(configService))
+	response.DistanceToLiquidation = utils.BigIntToDecimal(distanceToLiquidation, 6, 8)
+
+	midPrices := configService.GetMidPrices()
 	for market, position := range traderInfo.Positions {
-		midPrice := api.configService.GetMidPrices()[market]
+		midPrice := midPrices[market]
 		notionalPosition, uPnL, mf := getPositionMetadata(midPrice, position.OpenNotional, position.Size, margin)
 
 		response.Positions = append(response.Positions, TraderPosition{
@@ -219,7 +341,7 @@ func (api *TradingAPI) GetMarginAndPositions(ctx context.Context, trader string)
 			UnrealisedProfit:     utils.BigIntToDecimal(uPnL, 6, 8),
 			MarginFraction:       utils.BigIntToDecimal(mf, 6, 8),
 			NotionalPosition:     utils.BigIntToDecimal(notionalPosition, 6, 8),
-			LiquidationPrice:     "0", // todo: calculate
+			LiquidationPrice:     utils.BigIntToDecimal(liquidationPrices[market], 6, 8),
 			MarkPrice:            utils.BigIntToDecimal(midPrice, 6, 8),
 		})
 	}
@@ -232,30 +354,23 @@ func (api *TradingAPI) StreamDepthUpdateForMarket(ctx context.Context, market in
 	notifier, _ := rpc.NotifierFromContext(ctx)
 	rpcSub := notifier.CreateSubscription()
 
-	ticker := time.NewTicker(1 * time.Second)
-
-	var oldMarketDepth = &MarketDepth{}
-
 	go executeFuncAndRecoverPanic(func() {
-		for {
-			select {
-			case <-ticker.C:
-				newMarketDepth := getDepthForMarket(api.db, Market(market))
-				depthUpdate := getUpdateInDepth(newMarketDepth, oldMarketDepth)
-				transformedDepthUpdate := transformMarketDepth(depthUpdate)
-				response := TradingOrderBookDepthUpdateResponse{
-					T:      time.Now().Unix(),
-					Symbol: int64(market),
-					Bids:   transformedDepthUpdate.Bids,
-					Asks:   transformedDepthUpdate.Asks,
-				}
-				notifier.Notify(rpcSub.ID, response)
-				oldMarketDepth = newMarketDepth
-			case <-notifier.Closed():
-				ticker.Stop()
-				return
+		var prevUpdateID uint64
+		streamDepthUpdates(api.db, Market(market), 0, notifier.Closed(), func(depth *MarketDepth, isSnapshot bool) {
+			transformedDepthUpdate := transformMarketDepth(depth)
+			response := TradingOrderBookDepthUpdateResponse{
+				T:             time.Now().Unix(),
+				Symbol:        int64(market),
+				FirstUpdateID: depth.FirstUpdateID,
+				LastUpdateID:  depth.LastUpdateID,
+				PrevUpdateID:  prevUpdateID,
+				IsSnapshot:    isSnapshot,
+				Bids:          transformedDepthUpdate.Bids,
+				Asks:          transformedDepthUpdate.Asks,
 			}
-		}
+			notifier.Notify(rpcSub.ID, response)
+			prevUpdateID = depth.LastUpdateID
+		})
 	}, "panic in StreamDepthUpdateForMarket", RPCPanicsCounter)
 
 	return rpcSub, nil
@@ -285,9 +400,41 @@ func transformMarketDepth(depth *MarketDepth) TradingOrderBookDepthResponse {
 }
 
 func (api *TradingAPI) StreamTraderUpdates(ctx context.Context, trader string, blockStatus string) (*rpc.Subscription, error) {
+	if api.requireStreamAuth {
+		return nil, ErrStreamAuthRequired
+	}
+	return api.streamTraderUpdates(ctx, common.HexToAddress(trader), BlockConfirmationLevel(blockStatus), nil)
+}
+
+// GetStreamAuthNonce issues the nonce of a StreamAuth challenge for streamPrivateTraderUpdates. It can be used once, within hu.MaxStreamAuthValidity seconds
+func (api *TradingAPI) GetStreamAuthNonce(ctx context.Context, trader common.Address) (common.Hash, error) {
+	return api.streamAuthNonces.issue(trader, uint64(time.Now().Unix()))
+}
+
+// StreamPrivateTraderUpdates streams the order updates, fills and margin updates of a trader to a subscriber that signed
+// a StreamAuth challenge as the trader or its trading authority, with a nonce from GetStreamAuthNonce.
+// The stream ends at the expiry of the challenge with a StreamAuthExpired event, after which the subscriber has to subscribe again
+func (api *TradingAPI) StreamPrivateTraderUpdates(ctx context.Context, auth hu.StreamAuth, blockStatus string) (*rpc.Subscription, error) {
+	now := uint64(time.Now().Unix())
+	signer, err := hu.ValidateStreamAuth(&auth, now)
+	if err != nil {
+		return nil, err
+	}
+	if signer != auth.Trader && !api.configService.IsTradingAuthority(auth.Trader, signer) {
+		log.Error("StreamPrivateTraderUpdates - not trading authority", "trader", auth.Trader.String(), "signer", signer.String())
+		return nil, hu.ErrNoTradingAuthority
+	}
+	if err := api.streamAuthNonces.use(auth.Nonce, auth.Trader, now); err != nil {
+		return nil, err
+	}
+	expired := time.After(time.Until(time.Unix(auth.ExpireAt.Int64(), 0)))
+	return api.streamTraderUpdates(ctx, auth.Trader, BlockConfirmationLevel(blockStatus), expired)
+}
+
+// streamTraderUpdates streams till the subscriber unsubscribes or expired fires, a nil expired never fires
+func (api *TradingAPI) streamTraderUpdates(ctx context.Context, trader common.Address, confirmationLevel BlockConfirmationLevel, expired <-chan time.Time) (*rpc.Subscription, error) {
 	notifier, _ := rpc.NotifierFromContext(ctx)
 	rpcSub := notifier.CreateSubscription()
-	confirmationLevel := BlockConfirmationLevel(blockStatus)
 
 	traderFeedCh := make(chan TraderEvent)
 	traderFeedSubscription := traderFeed.Subscribe(traderFeedCh)
@@ -297,9 +444,12 @@ func (api *TradingAPI) StreamTraderUpdates(ctx context.Context, trader string, b
 		for {
 			select {
 			case event := <-traderFeedCh:
-				if strings.EqualFold(event.Trader.String(), trader) && event.BlockStatus == confirmationLevel {
+				if event.Trader == trader && event.BlockStatus == confirmationLevel {
 					notifier.Notify(rpcSub.ID, event)
 				}
+			case <-expired:
+				notifier.Notify(rpcSub.ID, TraderEvent{Trader: trader, EventName: "StreamAuthExpired", BlockStatus: confirmationLevel})
+				return
 			case <-notifier.Closed():
 				return
 			}
@@ -334,54 +484,339 @@ func (api *TradingAPI) StreamMarketTrades(ctx context.Context, market Market, bl
 	return rpcSub, nil
 }
 
+// GetTrades returns the trades of a market with a timestamp in [from, to], oldest first. to = 0 means no upper bound
+func (api *TradingAPI) GetTrades(ctx context.Context, market Market, from, to uint64, page *PaginationArgs) (TradesResponse, error) {
+	if api.tradeIndexer == nil {
+		return TradesResponse{}, ErrTradeIndexerDisabled
+	}
+	return api.tradeIndexer.GetTrades(market, from, to, page)
+}
+
+// GetCandles returns the OHLCV candles of a market, of interval seconds, for the trades with a timestamp in [from, to]
+func (api *TradingAPI) GetCandles(ctx context.Context, market Market, interval, from, to uint64) ([]*Candle, error) {
+	if api.tradeIndexer == nil {
+		return nil, ErrTradeIndexerDisabled
+	}
+	return api.tradeIndexer.GetCandles(market, interval, from, to)
+}
+
+// GetUserFills returns the fills of a trader in all markets with a timestamp in [from, to], oldest first. to = 0 means no upper bound
+func (api *TradingAPI) GetUserFills(ctx context.Context, trader common.Address, from, to uint64, page *PaginationArgs) (FillsResponse, error) {
+	if api.tradeIndexer == nil {
+		return FillsResponse{}, ErrTradeIndexerDisabled
+	}
+	return api.tradeIndexer.GetUserFills(trader, from, to, page)
+}
+
+// SimulateOrder returns the state of a trader after placing an order, without placing it. size has 18 decimals and price 6 decimals; price = 0 is a market order
+func (api *TradingAPI) SimulateOrder(ctx context.Context, trader common.Address, market Market, size *big.Int, price *big.Int) (SimulateOrderResponse, error) {
+	return newOrderSimulator(api.db, api.configService, trader).simulate(SimulatedOrder{Market: market, Size: size, Price: price})
+}
+
+// SimulateOrders simulates orders one after the other, so every response includes the effect of the orders before it
+func (api *TradingAPI) SimulateOrders(ctx context.Context, trader common.Address, orders []SimulatedOrder) ([]SimulateOrderResponse, error) {
+	sim := newOrderSimulator(api.db, api.configService, trader)
+	responses := make([]SimulateOrderResponse, 0, len(orders))
+	for i, order := range orders {
+		response, err := sim.simulate(order)
+		if err != nil {
+			return nil, fmt.Errorf("order %d: %w", i, err)
+		}
+		responses = append(responses, response)
+	}
+	return responses, nil
+}
+
+// GetFundingRates returns the fundings settled for a market with a timestamp in [from, to], oldest first. to = 0 means no upper bound
+func (api *TradingAPI) GetFundingRates(ctx context.Context, market Market, from, to uint64, page *PaginationArgs) (FundingRatesResponse, error) {
+	if api.tradeIndexer == nil {
+		return FundingRatesResponse{}, ErrTradeIndexerDisabled
+	}
+	return api.tradeIndexer.GetFundingRates(market, from, to, page)
+}
+
+// GetFundingPayments returns the funding for the position of a trader in the fundings of a market with a timestamp in [from, to],
+// along with the funding that is pending since the last settlement of the position
+func (api *TradingAPI) GetFundingPayments(ctx context.Context, trader common.Address, market Market, from, to uint64, page *PaginationArgs) (FundingPaymentsResponse, error) {
+	if api.tradeIndexer == nil {
+		return FundingPaymentsResponse{}, ErrTradeIndexerDisabled
+	}
+
+	size, pendingFunding := big.NewInt(0), big.NewInt(0)
+	if traderInfo := api.db.GetTraderInfo(trader); traderInfo != nil {
+		if position := traderInfo.Positions[market]; position != nil && position.Size != nil {
+			size = position.Size
+			pendingFunding = calcPendingFunding(api.configService.GetCumulativePremiumFraction(market), position.LastPremiumFraction, position.Size)
+		}
+	}
+	response, err := api.tradeIndexer.GetFundingPayments(trader, market, size, from, to, page)
+	response.PendingFunding = pendingFunding
+	return response, err
+}
+
+// GetPredictedFunding returns the funding rate of a market from the premium index samples of the current funding period,
+// the current impact prices of the order book and the oracle price
+func (api *TradingAPI) GetPredictedFunding(ctx context.Context, market Market) (PredictedFunding, error) {
+	if int64(market) < 0 || int64(market) >= api.configService.GetActiveMarketsCount() {
+		return PredictedFunding{}, fmt.Errorf("invalid market %d", market)
+	}
+	params, err := api.getFundingParams(ctx, market)
+	if err != nil {
+		return PredictedFunding{}, err
+	}
+	impactBids, impactAsks, _ := api.db.SampleImpactPrice()
+	underlyingPrice := api.configService.GetUnderlyingPrices()[market]
+	return getPredictedFunding(market, params, impactBids[market], impactAsks[market], underlyingPrice, uint64(time.Now().Unix()), api.db.GetNextFundingTime()), nil
+}
+
+// getFundingParams reads the funding parameters and the premium index samples of a market from its AMM contract at the latest block
+func (api *TradingAPI) getFundingParams(ctx context.Context, market Market) (FundingParams, error) {
+	fundingPeriod, err := api.callAMM(ctx, market, "fundingPeriod")
+	if err != nil {
+		return FundingParams{}, err
+	}
+	maxFundingRate, err := api.callAMM(ctx, market, "maxFundingRate")
+	if err != nil {
+		return FundingParams{}, err
+	}
+	piData, err := api.callAMM(ctx, market, "piData")
+	if err != nil {
+		return FundingParams{}, err
+	}
+	return FundingParams{
+		FundingPeriod:   fundingPeriod[0].(*big.Int).Uint64(),
+		MaxFundingRate:  maxFundingRate[0].(*big.Int),
+		PITwap:          piData[0].(*big.Int),
+		PIAccTime:       piData[1].(*big.Int).Uint64(),
+		PILast:          piData[2].(*big.Int),
+		PILastTimestamp: piData[3].(*big.Int).Uint64(),
+	}, nil
+}
+
+func (api *TradingAPI) callAMM(ctx context.Context, market Market, method string) ([]interface{}, error) {
+	data, err := api.ammABI.Pack(method)
+	if err != nil {
+		return nil, err
+	}
+	amm := api.configService.GetMarketAddressFromMarketID(int64(market))
+	args := ethapi.TransactionArgs{To: &amm, Data: (*hexutil.Bytes)(&data)}
+	result, err := ethapi.DoCall(ctx, api.backend, args, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber), nil, nil, api.backend.RPCEVMTimeout(), api.backend.RPCGasCap())
+	if err != nil {
+		return nil, fmt.Errorf("error in calling %s of market %d: %w", method, market, err)
+	}
+	if result.Err != nil {
+		return nil, fmt.Errorf("error in calling %s of market %d: %w", method, market, result.Err)
+	}
+	return api.ammABI.Unpack(method, result.Return())
+}
+
 // @todo cache api.configService values to avoid db lookups on every order placement
 func (api *TradingAPI) PlaceOrder(order *hu.SignedOrder) (common.Hash, bool, error) {
+	return api.placeOrder(order, nil)
+}
+
+// PlaceOrders places a batch of signed orders, with the same per order results as PlaceOrder. shouldTriggerMatching is true if
+// any of the orders should trigger matching. The order hashes, signatures and the other checks that don't depend on the makerbook
+// run on a pool of workers, the rest of the validation and the insertion in the makerbook run serially in the order of the batch.
+func (api *TradingAPI) PlaceOrders(orders []*hu.SignedOrder) (orderIds []common.Hash, shouldTriggerMatching bool, errs []error) {
+	orderIds = make([]common.Hash, len(orders))
+	errs = make([]error, len(orders))
+	verifiedOrders := api.verifySignedOrders(orders)
+	for i, order := range orders {
+		var triggerMatching bool
+		orderIds[i], triggerMatching, errs[i] = api.placeOrder(order, verifiedOrders[i])
+		shouldTriggerMatching = shouldTriggerMatching || triggerMatching
+	}
+	return orderIds, shouldTriggerMatching, errs
+}
+
+func (api *TradingAPI) placeOrder(order *hu.SignedOrder, verifiedOrder *verifiedSignedOrder) (common.Hash, bool, error) {
+	orderId, trader, requiredMargin, fields, err := api.validateNewSignedOrder(order, nil, verifiedOrder)
+	if err != nil {
+		return orderId, false, err
+	}
+
+	// validations passed, add to db
+	signedOrder := newSignedOrder(orderId, trader, order)
+	placeSignedOrderCounter.Inc(1)
+	api.db.AddSignedOrder(signedOrder, requiredMargin)
+
+	api.writeToMakerbookFile(signedOrder)
+	go sendSignedOrderEvent(order, orderId, trader, "OrderAccepted", nil)
+
+	return orderId, fields.ShouldTriggerMatching, nil
+}
+
+// AmendOrder atomically replaces a signed order in the makerbook with a new signed order from the same trader, in the same market and direction.
+// The amended order is cancelled by cancelOrder, which the validators record on chain so that the juror rejects the amended order, see
+// ExecuteCancelSignedOrdersTx. The amendment is queued like a new order, and the difference in reserved margin is applied in one step.
+// The amended order doesn't have to be in the makerbook, as the amendment might reach this node before it.
+func (api *TradingAPI) AmendOrder(cancelOrder *hu.CancelOrder, order *hu.SignedOrder) (common.Hash, bool, error) {
+	if !hu.IsSignedOrderCancellationsActive(uint64(time.Now().Unix())) {
+		return common.Hash{}, false, hu.ErrCancellationsNotActive
+	}
+	amendedOrderId, signer, err := hu.ValidateCancelOrder(cancelOrder)
+	if err != nil {
+		return common.Hash{}, false, err
+	}
+	original := cancelOrder.Order
+	if original.Trader != signer && !api.configService.IsTradingAuthority(original.Trader, signer) {
+		log.Error("AmendOrder - not trading authority", "trader", original.Trader.String(), "signer", signer.String())
+		return common.Hash{}, false, hu.ErrNoTradingAuthority
+	}
+	if original.Trader != order.Trader || original.AmmIndex.Cmp(order.AmmIndex) != 0 || original.BaseAssetQuantity.Sign() != order.BaseAssetQuantity.Sign() {
+		return common.Hash{}, false, ErrInvalidAmendment
+	}
+	amendedOrder := api.db.GetOrderById(amendedOrderId)
+	if amendedOrder != nil && (amendedOrder.OrderType != Signed || amendedOrder.getOrderStatus().Status != Placed) {
+		return common.Hash{}, false, ErrAmendedOrderNotFound
+	}
+
+	orderId, trader, requiredMargin, fields, err := api.validateNewSignedOrder(order, amendedOrder, nil)
+	if err != nil {
+		return orderId, false, err
+	}
+
+	signedOrder := newSignedOrder(orderId, trader, order)
+	if err := api.db.AmendSignedOrder(amendedOrderId, cancelOrder, signedOrder, requiredMargin); err != nil {
+		return orderId, false, err
+	}
+	amendSignedOrderCounter.Inc(1)
+
+	api.writeToMakerbookFile(signedOrder)
+	go sendSignedOrderEvent(order, orderId, trader, "OrderAmended", map[string]interface{}{"amendedOrderId": amendedOrderId})
+
+	return orderId, fields.ShouldTriggerMatching, nil
+}
+
+// CancelAllOrders removes all the trader's signed orders from the makerbook, in a market or in all markets, and rejects orders with a
+// salt <= nonce from then on. The matching pipeline records the message with the juror, which rejects such orders on chain, see recordCancelAllMessages.
+func (api *TradingAPI) CancelAllOrders(msg *hu.CancelAllOrders) ([]common.Hash, error) {
+	signer, err := hu.ValidateCancelAllOrders(msg, api.configService.GetActiveMarketsCount())
+	if err != nil {
+		return nil, err
+	}
+	if signer != msg.Trader && !api.configService.IsTradingAuthority(msg.Trader, signer) {
+		log.Error("CancelAllOrders - not trading authority", "trader", msg.Trader.String(), "signer", signer.String())
+		return nil, hu.ErrNoTradingAuthority
+	}
+	cancelledOrderIds, err := api.db.CancelAllSignedOrders(msg)
+	if err != nil {
+		return nil, err
+	}
+	cancelAllSignedOrdersCounter.Inc(1)
+	return cancelledOrderIds, nil
+}
+
+// Heartbeat keeps the trader's dead man's switch alive till the deadline of the heartbeat, or unregisters it if the timeout is 0.
+// The heartbeat is sent to this node or gossiped by another node. When the deadline passes without a newer heartbeat, the sanitary pipeline
+// cancels the signed orders of the trader, see CancelOrdersOfExpiredHeartbeats.
+func (api *TradingAPI) Heartbeat(heartbeat *hu.Heartbeat) error {
+	signer, err := hu.ValidateHeartbeat(heartbeat, uint64(time.Now().Unix()))
+	if err != nil {
+		return err
+	}
+	if signer != heartbeat.Trader && !api.configService.IsTradingAuthority(heartbeat.Trader, signer) {
+		log.Error("Heartbeat - not trading authority", "trader", heartbeat.Trader.String(), "signer", signer.String())
+		return hu.ErrNoTradingAuthority
+	}
+	return api.db.UpdateHeartbeat(heartbeat)
+}
+
+// validateNewSignedOrder runs the placement checks on a signed order. When the order amends amendedOrder, the margin reserved for
+// and the reduce only amount of amendedOrder are considered free, since amendedOrder is removed when the amendment is accepted.
+// verifiedOrder is the result of verifySignedOrders when the order is placed as part of a batch, nil otherwise.
+func (api *TradingAPI) validateNewSignedOrder(order *hu.SignedOrder, amendedOrder *Order, verifiedOrder *verifiedSignedOrder) (orderId common.Hash, trader common.Address, requiredMargin *big.Int, fields OrderValidationFields, err error) {
 	if api.configService.IsSettledAll() {
-		return common.Hash{}, false, errors.New("all markets are settled now")
+		return common.Hash{}, trader, nil, fields, errors.New("all markets are settled now")
 	}
 
-	orderId, err := order.Hash()
+	if verifiedOrder != nil {
+		orderId, err = verifiedOrder.orderId, verifiedOrder.hashErr
+	} else {
+		orderId, err = order.Hash()
+	}
 	if err != nil {
-		return common.Hash{}, false, fmt.Errorf("failed to hash order: %s", err)
+		return common.Hash{}, trader, nil, fields, fmt.Errorf("failed to hash order: %s", err)
 	}
-	fields := api.db.GetOrderValidationFields(orderId, order)
+	fields = api.db.GetOrderValidationFields(orderId, order)
 	// P1. Order is not already in memdb
 	if fields.Exists {
-		return orderId, false, hu.ErrOrderAlreadyExists
+		return orderId, trader, nil, fields, hu.ErrOrderAlreadyExists
 	}
 	marketId := int(order.AmmIndex.Int64())
-	trader, signer, err := hu.ValidateSignedOrder(
-		order,
-		hu.SignedOrderValidationFields{
-			OrderHash:          orderId,
-			Now:                uint64(time.Now().Unix()),
-			ActiveMarketsCount: api.configService.GetActiveMarketsCount(),
-			MinSize:            api.configService.getMinSizeRequirement(marketId),
-			PriceMultiplier:    api.configService.GetPriceMultiplier(marketId),
-			Status:             api.configService.GetSignedOrderStatus(orderId),
-		},
-	)
+	now := uint64(time.Now().Unix())
+	var signer common.Address
+	if verifiedOrder != nil && verifiedOrder.checked {
+		signer, err = verifiedOrder.signer, verifiedOrder.err
+	} else {
+		signer, err = hu.ValidateSignedOrderStateless(order, hu.SignedOrderValidationFields{
+			OrderHash:       orderId,
+			Now:             now,
+			MinSize:         api.configService.getMinSizeRequirement(marketId),
+			PriceMultiplier: api.configService.GetPriceMultiplier(marketId),
+		})
+	}
 	if err != nil {
-		return orderId, false, err
+		return orderId, trader, nil, fields, err
+	}
+	trader = order.Trader
+
+	validationFields := hu.SignedOrderValidationFields{
+		OrderHash:          orderId,
+		Now:                now,
+		ActiveMarketsCount: api.configService.GetActiveMarketsCount(),
+		Status:             api.configService.GetSignedOrderStatus(orderId),
+		CancelNonce:        fields.CancelNonce,
+		HeartbeatExpired:   fields.HeartbeatExpired,
+		Cancelled:          fields.Cancelled || (hu.IsSignedOrderCancellationsActive(now) && api.configService.IsSignedOrderCancelled(orderId)),
+	}
+	if hu.IsSignedOrderCancellationsActive(now) {
+		// the juror has the nonces of cancel all messages that this node missed
+		if chainNonce := api.configService.GetCancelNonce(order.Trader, order.AmmIndex); chainNonce.Sign() > 0 && (validationFields.CancelNonce == nil || chainNonce.Cmp(validationFields.CancelNonce) > 0) {
+			validationFields.CancelNonce = chainNonce
+		}
+	}
+	if order.ReduceOnly {
+		if !hu.IsReduceOnlySignedOrdersActive(now) {
+			return orderId, trader, nil, fields, hu.ErrReduceOnlyNotSupported
+		}
+		// P3. Sum of all reduce only orders should not exceed the total position size
+		// on-chain reduce only orders are tracked by the contracts, signed ones only in the memdb
+		reduceOnlyAmount := big.NewInt(0)
+		if fields.ReduceOnlyAmount != nil {
+			reduceOnlyAmount.Set(fields.ReduceOnlyAmount)
+		}
+		if reduceOnlyAmounts := api.configService.GetReduceOnlyAmounts(order.Trader); marketId < len(reduceOnlyAmounts) && reduceOnlyAmounts[marketId] != nil {
+			reduceOnlyAmount.Add(reduceOnlyAmount, reduceOnlyAmounts[marketId])
+		}
+		if amendedOrder != nil && amendedOrder.ReduceOnly {
+			reduceOnlyAmount.Sub(reduceOnlyAmount, amendedOrder.GetUnFilledBaseAssetQuantity())
+		}
+		validationFields.PositionSize = fields.PosSize
+		validationFields.ReduceOnlyAmount = reduceOnlyAmount
+	}
+	if err := hu.ValidateSignedOrderState(order, validationFields); err != nil {
+		return orderId, trader, nil, fields, err
 	}
 	if trader != signer && !api.configService.IsTradingAuthority(trader, signer) {
 		log.Error("not trading authority", "trader", trader.String(), "signer", signer.String())
-		return orderId, false, hu.ErrNoTradingAuthority
+		return orderId, trader, nil, fields, hu.ErrNoTradingAuthority
 	}
 
-	requiredMargin := big.NewInt(0)
+	requiredMargin = big.NewInt(0)
 	if !order.ReduceOnly {
 		// P2. Margin is available for non-reduce only orders
 		minAllowableMargin := api.configService.GetMinAllowableMargin()
 		// even tho order might be matched at a different price, we reserve margin at the price the order was placed at to keep it simple
 		requiredMargin = hu.GetRequiredMargin(order.Price, hu.Abs(order.BaseAssetQuantity), minAllowableMargin, big.NewInt(0))
 		availableMargin := api.db.GetMarginAvailableForMakerbook(trader, hu.ArrayToMap(api.configService.GetUnderlyingPrices()))
+		if amendedOrder != nil && !amendedOrder.ReduceOnly {
+			availableMargin.Add(availableMargin, hu.GetRequiredMargin(amendedOrder.Price, hu.Abs(amendedOrder.GetUnFilledBaseAssetQuantity()), minAllowableMargin, big.NewInt(0)))
+		}
 		if availableMargin.Cmp(requiredMargin) == -1 {
-			return orderId, false, hu.ErrInsufficientMargin
+			return orderId, trader, nil, fields, hu.ErrInsufficientMargin
 		}
-	} else {
-		// @todo P3. Sum of all reduce only orders should not exceed the total position size
-		return orderId, false, errors.New("reduce only orders via makerbook are not supported yet")
 	}
 
 	// P4. Post only order shouldn't cross the market
@@ -393,17 +828,90 @@ func (api *TradingAPI) PlaceOrder(order *hu.SignedOrder) (common.Hash, bool, err
 		asksHead := fields.AsksHead
 		bidsHead := fields.BidsHead
 		if (orderSide == hu.Side(hu.Short) && bidsHead.Sign() != 0 && order.Price.Cmp(bidsHead) != 1) || (orderSide == hu.Side(hu.Long) && asksHead.Sign() != 0 && order.Price.Cmp(asksHead) != -1) {
-			return orderId, false, hu.ErrCrossingMarket
+			return orderId, trader, nil, fields, hu.ErrCrossingMarket
 		}
 	}
 
 	// P5. HasReferrer
 	if !api.configService.HasReferrer(order.Trader) {
-		return orderId, false, hu.ErrNoReferrer
+		return orderId, trader, nil, fields, hu.ErrNoReferrer
 	}
+	return orderId, trader, requiredMargin, fields, nil
+}
 
-	// validations passed, add to db
-	signedOrder := &Order{
+// verifiedSignedOrder is the result of the checks of a signed order that don't depend on the makerbook, see verifySignedOrders
+type verifiedSignedOrder struct {
+	orderId common.Hash
+	hashErr error
+	// checked is false if the checks were skipped because the order was already in the makerbook
+	checked bool
+	signer  common.Address
+	err     error
+}
+
+// verifySignedOrders hashes the orders, recovers their signers and runs hu.ValidateSignedOrderStateless on a pool of workers.
+// The market config is read once per batch, so that the workers don't read the chain state
+func (api *TradingAPI) verifySignedOrders(orders []*hu.SignedOrder) []*verifiedSignedOrder {
+	now := uint64(time.Now().Unix())
+	marketFields := map[int64]hu.SignedOrderValidationFields{}
+	for _, order := range orders {
+		marketId := order.AmmIndex.Int64()
+		if _, ok := marketFields[marketId]; !ok {
+			marketFields[marketId] = hu.SignedOrderValidationFields{
+				Now:             now,
+				MinSize:         api.configService.getMinSizeRequirement(int(marketId)),
+				PriceMultiplier: api.configService.GetPriceMultiplier(int(marketId)),
+			}
+		}
+	}
+
+	verifiedOrders := make([]*verifiedSignedOrder, len(orders))
+	indexes := make(chan int, len(orders))
+	for i := range orders {
+		indexes <- i
+	}
+	close(indexes)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(len(orders), runtime.NumCPU()); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				verifiedOrders[i] = api.verifySignedOrder(orders[i], marketFields[orders[i].AmmIndex.Int64()])
+			}
+		}()
+	}
+	wg.Wait()
+	return verifiedOrders
+}
+
+func (api *TradingAPI) verifySignedOrder(order *hu.SignedOrder, fields hu.SignedOrderValidationFields) (verifiedOrder *verifiedSignedOrder) {
+	verifiedOrder = &verifiedSignedOrder{}
+	defer func() {
+		// a malformed order shouldn't take the node down from a worker goroutine
+		if r := recover(); r != nil {
+			verifiedOrder.checked = true
+			verifiedOrder.err = fmt.Errorf("failed to verify order: %v", r)
+		}
+	}()
+
+	verifiedOrder.orderId, verifiedOrder.hashErr = order.Hash()
+	if verifiedOrder.hashErr != nil {
+		return verifiedOrder
+	}
+	// known orders are rejected by validateNewSignedOrder before their signature is needed
+	if api.db.GetOrderValidationFields(verifiedOrder.orderId, order).Exists {
+		return verifiedOrder
+	}
+	fields.OrderHash = verifiedOrder.orderId
+	verifiedOrder.signer, verifiedOrder.err = hu.ValidateSignedOrderStateless(order, fields)
+	verifiedOrder.checked = true
+	return verifiedOrder
+}
+
+func newSignedOrder(orderId common.Hash, trader common.Address, order *hu.SignedOrder) *Order {
+	return &Order{
 		Id:                      orderId,
 		Market:                  Market(order.AmmIndex.Int64()),
 		PositionType:            getPositionTypeBasedOnBaseAssetQuantity(order.BaseAssetQuantity),
@@ -417,10 +925,9 @@ func (api *TradingAPI) PlaceOrder(order *hu.SignedOrder) (common.Hash, bool, err
 		RawOrder:                order,
 		OrderType:               Signed,
 	}
+}
 
-	placeSignedOrderCounter.Inc(1)
-	api.db.AddSignedOrder(signedOrder, requiredMargin)
-
+func (api *TradingAPI) writeToMakerbookFile(signedOrder *Order) {
 	if len(MakerbookDatabaseFile) > 0 {
 		go func() {
 			select {
@@ -431,24 +938,127 @@ func (api *TradingAPI) PlaceOrder(order *hu.SignedOrder) (common.Hash, bool, err
 			}
 		}()
 	}
+}
 
-	// send to trader feed - both for head and accepted block
-	go func() {
-		orderMap := order.Map()
-		orderMap["orderType"] = "signed"
-		orderMap["expireAt"] = order.ExpireAt.String()
-		args := map[string]interface{}{
-			"order": orderMap,
+// sendSignedOrderEvent sends a trader event for a signed order that was added to the makerbook - both for head and accepted block
+func sendSignedOrderEvent(order *hu.SignedOrder, orderId common.Hash, trader common.Address, eventName string, extraArgs map[string]interface{}) {
+	orderMap := order.Map()
+	orderMap["orderType"] = "signed"
+	orderMap["expireAt"] = order.ExpireAt.String()
+	args := map[string]interface{}{
+		"order": orderMap,
+	}
+	for key, value := range extraArgs {
+		args[key] = value
+	}
+
+	traderEvent := TraderEvent{
+		Trader:      trader,
+		Removed:     false,
+		EventName:   eventName,
+		Args:        args,
+		BlockStatus: ConfirmationLevelHead,
+		OrderId:     orderId,
+		OrderType:   Signed.String(),
+		Timestamp:   big.NewInt(time.Now().Unix()),
+	}
+
+	traderFeed.Send(traderEvent)
+
+	traderEvent.BlockStatus = ConfirmationLevelAccepted
+	traderFeed.Send(traderEvent)
+}
+
+// PlaceTriggerOrder validates a stop loss or take profit order and keeps it off the order book till the oracle price reaches its trigger price.
+// Once triggered, it is matched as a taker order by the matching pipeline.
+func (api *TradingAPI) PlaceTriggerOrder(order *hu.TriggerOrder) (common.Hash, error) {
+	if api.configService.IsSettledAll() {
+		return common.Hash{}, errors.New("all markets are settled now")
+	}
+	if !hu.IsTriggerOrdersActive(uint64(time.Now().Unix())) {
+		return common.Hash{}, hu.ErrTriggerOrdersNotActive
+	}
+
+	orderId, err := order.Hash()
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to hash order: %s", err)
+	}
+	// P1. Order is not already in memdb
+	if api.db.GetOrderById(orderId) != nil {
+		return orderId, hu.ErrOrderAlreadyExists
+	}
+	marketId := int(order.AmmIndex.Int64())
+	trader, signer, err := hu.ValidateTriggerOrder(
+		order,
+		hu.SignedOrderValidationFields{
+			OrderHash:          orderId,
+			Now:                uint64(time.Now().Unix()),
+			ActiveMarketsCount: api.configService.GetActiveMarketsCount(),
+			MinSize:            api.configService.getMinSizeRequirement(marketId),
+			PriceMultiplier:    api.configService.GetPriceMultiplier(marketId),
+			// trigger orders are filled and cancelled by the signed order book
+			Status: api.configService.GetSignedOrderStatus(orderId),
+		},
+	)
+	if err != nil {
+		return orderId, err
+	}
+	if trader != signer && !api.configService.IsTradingAuthority(trader, signer) {
+		log.Error("not trading authority", "trader", trader.String(), "signer", signer.String())
+		return orderId, hu.ErrNoTradingAuthority
+	}
+
+	if order.ReduceOnly {
+		// P3. reduce only order should close at least a part of the current position. It is sized down to the position when it is matched
+		positionSize := big.NewInt(0)
+		if traderInfo := api.db.GetTraderInfo(trader); traderInfo != nil && traderInfo.Positions[marketId] != nil && traderInfo.Positions[marketId].Size != nil {
+			positionSize = traderInfo.Positions[marketId].Size
+		}
+		if positionSize.Sign() == 0 || positionSize.Sign() == order.BaseAssetQuantity.Sign() {
+			return orderId, hu.ErrNotReducingPosition
 		}
+	} else {
+		// P2. no margin is reserved for a trigger order, but the trader should have enough margin for it at the time of placing it
+		requiredMargin := hu.GetRequiredMargin(order.Price, hu.Abs(order.BaseAssetQuantity), api.configService.GetMinAllowableMargin(), api.configService.GetTakerFee())
+		availableMargin := api.db.GetMarginAvailableForMakerbook(trader, hu.ArrayToMap(api.configService.GetUnderlyingPrices()))
+		if availableMargin.Cmp(requiredMargin) == -1 {
+			return orderId, hu.ErrInsufficientMargin
+		}
+	}
+
+	// P5. HasReferrer
+	if !api.configService.HasReferrer(order.Trader) {
+		return orderId, hu.ErrNoReferrer
+	}
 
+	triggerOrder := &Order{
+		Id:                      orderId,
+		Market:                  Market(order.AmmIndex.Int64()),
+		PositionType:            getPositionTypeBasedOnBaseAssetQuantity(order.BaseAssetQuantity),
+		Trader:                  trader,
+		BaseAssetQuantity:       order.BaseAssetQuantity,
+		FilledBaseAssetQuantity: big.NewInt(0),
+		Price:                   order.Price,
+		Salt:                    order.Salt,
+		ReduceOnly:              order.ReduceOnly,
+		BlockNumber:             big.NewInt(0),
+		RawOrder:                order,
+		OrderType:               Trigger,
+	}
+
+	placeTriggerOrderCounter.Inc(1)
+	api.db.AddTriggerOrder(triggerOrder)
+
+	// send to trader feed - both for head and accepted block
+	go func() {
 		traderEvent := TraderEvent{
 			Trader:      trader,
 			Removed:     false,
 			EventName:   "OrderAccepted",
-			Args:        args,
+			Args:        map[string]interface{}{"order": order.Map()},
 			BlockStatus: ConfirmationLevelHead,
 			OrderId:     orderId,
-			OrderType:   Signed.String(),
+			OrderType:   Trigger.String(),
 			Timestamp:   big.NewInt(time.Now().Unix()),
 		}
 
@@ -458,7 +1068,7 @@ func (api *TradingAPI) PlaceOrder(order *hu.SignedOrder) (common.Hash, bool, err
 		traderFeed.Send(traderEvent)
 	}()
 
-	return orderId, fields.ShouldTriggerMatching, nil
+	return orderId, nil
 }
 
 func writeOrderToFile(order Order) {
@@ -0,0 +1,268 @@
+package orderbook
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamAuth(t *testing.T) {
+	hu.SetChainIdAndVerifyingSignedOrdersContract(321123, "0x4c5859f0F772848b2D91F1D83E2Fe57935348029")
+	db := getDatabase()
+	api := &TradingAPI{db: db, configService: db.configService, requireStreamAuth: true}
+
+	key, err := crypto.GenerateKey()
+	assert.Nil(t, err)
+	signAuth := func(t *testing.T, trader common.Address, expireAt int64) hu.StreamAuth {
+		nonce, err := api.GetStreamAuthNonce(context.Background(), trader)
+		assert.Nil(t, err)
+		auth := hu.StreamAuth{Trader: trader, ExpireAt: big.NewInt(expireAt), Nonce: nonce}
+		hash, err := auth.Hash()
+		assert.Nil(t, err)
+		sig, err := crypto.Sign(hash.Bytes(), key)
+		assert.Nil(t, err)
+		sig[crypto.RecoveryIDOffset] += 27
+		auth.Sig = sig
+		return auth
+	}
+	signer := crypto.PubkeyToAddress(key.PublicKey)
+
+	t.Run("unauthenticated stream is rejected when auth is required", func(t *testing.T) {
+		_, err := api.StreamTraderUpdates(context.Background(), userAddress, string(ConfirmationLevelHead))
+		assert.Equal(t, ErrStreamAuthRequired, err)
+	})
+
+	t.Run("expired challenge is rejected", func(t *testing.T) {
+		auth := signAuth(t, signer, time.Now().Unix()-10)
+		_, err := api.StreamPrivateTraderUpdates(context.Background(), auth, string(ConfirmationLevelHead))
+		assert.Equal(t, hu.ErrStreamAuthExpired, err)
+	})
+
+	t.Run("challenge signed by an address that is not a trading authority of the trader is rejected", func(t *testing.T) {
+		auth := signAuth(t, common.HexToAddress(userAddress), time.Now().Unix()+60)
+		_, err := api.StreamPrivateTraderUpdates(context.Background(), auth, string(ConfirmationLevelHead))
+		assert.Equal(t, hu.ErrNoTradingAuthority, err)
+	})
+
+	t.Run("challenge with a nonce that was not issued by the node is rejected", func(t *testing.T) {
+		auth := hu.StreamAuth{Trader: signer, ExpireAt: big.NewInt(time.Now().Unix() + 60), Nonce: common.Hash{1}}
+		hash, err := auth.Hash()
+		assert.Nil(t, err)
+		auth.Sig, err = crypto.Sign(hash.Bytes(), key)
+		assert.Nil(t, err)
+		auth.Sig[crypto.RecoveryIDOffset] += 27
+		_, err = api.StreamPrivateTraderUpdates(context.Background(), auth, string(ConfirmationLevelHead))
+		assert.Equal(t, ErrInvalidStreamAuthNonce, err)
+	})
+}
+
+func TestStreamAuthNonces(t *testing.T) {
+	nonces := streamAuthNonces{}
+	trader := common.HexToAddress(userAddress)
+	now := uint64(1700000000)
+
+	t.Run("a nonce can be used once", func(t *testing.T) {
+		nonce, err := nonces.issue(trader, now)
+		assert.Nil(t, err)
+		assert.Nil(t, nonces.use(nonce, trader, now+1))
+		assert.Equal(t, ErrInvalidStreamAuthNonce, nonces.use(nonce, trader, now+1))
+	})
+	t.Run("a nonce is bound to the trader", func(t *testing.T) {
+		nonce, err := nonces.issue(trader, now)
+		assert.Nil(t, err)
+		assert.Equal(t, ErrInvalidStreamAuthNonce, nonces.use(nonce, common.Address{1}, now))
+	})
+	t.Run("expired nonces are rejected and pruned", func(t *testing.T) {
+		nonce, err := nonces.issue(trader, now)
+		assert.Nil(t, err)
+		assert.Equal(t, ErrInvalidStreamAuthNonce, nonces.use(nonce, trader, now+hu.MaxStreamAuthValidity+1))
+
+		_, err = nonces.issue(trader, now)
+		assert.Nil(t, err)
+		_, err = nonces.issue(trader, now+hu.MaxStreamAuthValidity+1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(nonces.nonces))
+	})
+}
+
+func TestPlaceOrders(t *testing.T) {
+	hu.SetChainIdAndVerifyingSignedOrdersContract(321123, "0x4c5859f0F772848b2D91F1D83E2Fe57935348029")
+	key, err := crypto.GenerateKey()
+	assert.Nil(t, err)
+	otherKey, err := crypto.GenerateKey()
+	assert.Nil(t, err)
+	trader := crypto.PubkeyToAddress(key.PublicKey)
+
+	newOrder := func(salt int64, price int64, expireAt int64) *hu.SignedOrder {
+		return &hu.SignedOrder{
+			LimitOrder: hu.LimitOrder{
+				BaseOrder: hu.BaseOrder{
+					AmmIndex:          big.NewInt(0),
+					Trader:            trader,
+					BaseAssetQuantity: big.NewInt(1e18),
+					Price:             big.NewInt(price),
+					Salt:              big.NewInt(salt),
+				},
+				PostOnly: true,
+			},
+			OrderType: uint8(hu.Signed),
+			ExpireAt:  big.NewInt(expireAt),
+		}
+	}
+	sign := func(order *hu.SignedOrder, key *ecdsa.PrivateKey) *hu.SignedOrder {
+		hash, err := order.Hash()
+		assert.Nil(t, err)
+		sig, err := crypto.Sign(hash.Bytes(), key)
+		assert.Nil(t, err)
+		sig[crypto.RecoveryIDOffset] += 27
+		order.Sig = sig
+		return order
+	}
+	expireAt := time.Now().Unix() + 3600
+	orders := []*hu.SignedOrder{
+		sign(newOrder(1, 10e6, expireAt), key),
+		sign(newOrder(2, 11e6, expireAt), key),
+		sign(newOrder(3, 12e6, expireAt), otherKey),        // not signed by the trader
+		sign(newOrder(4, 13e6, time.Now().Unix()-10), key), // expired
+		sign(newOrder(5, 10e6+1, expireAt), key),           // price precision
+		sign(newOrder(1, 10e6, expireAt), key),             // same order as the first one
+		sign(newOrder(6, 14e6, expireAt), key),
+	}
+	expectedErrs := []error{nil, nil, hu.ErrNoTradingAuthority, hu.ErrOrderExpired, hu.ErrPricePrecision, hu.ErrOrderAlreadyExists, nil}
+
+	newAPI := func() *TradingAPI {
+		db := getDatabase()
+		db.TraderMap[trader] = &Trader{
+			Margin: Margin{Deposited: map[Collateral]*big.Int{0: big.NewInt(1000e6)}, Reserved: big.NewInt(0)},
+		}
+		return &TradingAPI{db: db, configService: db.configService}
+	}
+
+	api := newAPI()
+	orderIds, _, errs := api.PlaceOrders(orders)
+	assert.Equal(t, expectedErrs, errs)
+	for i, order := range orders {
+		orderId, err := order.Hash()
+		assert.Nil(t, err)
+		assert.Equal(t, orderId, orderIds[i])
+	}
+	assert.Equal(t, 3, len(api.db.GetSignedOrders()))
+
+	// same results as placing the orders one by one
+	api = newAPI()
+	for i, order := range orders {
+		orderId, _, err := api.PlaceOrder(order)
+		assert.Equal(t, expectedErrs[i], err)
+		assert.Equal(t, orderIds[i], orderId)
+	}
+}
+
+func TestAmendOrder(t *testing.T) {
+	defer func(activationTime uint64) { hu.SignedOrderCancellationsActivationTime = activationTime }(hu.SignedOrderCancellationsActivationTime)
+	hu.SetChainIdAndVerifyingSignedOrdersContract(321123, "0x4c5859f0F772848b2D91F1D83E2Fe57935348029")
+	key, err := crypto.GenerateKey()
+	assert.Nil(t, err)
+	otherKey, err := crypto.GenerateKey()
+	assert.Nil(t, err)
+	trader := crypto.PubkeyToAddress(key.PublicKey)
+
+	expireAt := time.Now().Unix() + 3600
+	newOrder := func(salt, baseAssetQuantity int64) *hu.SignedOrder {
+		order := &hu.SignedOrder{
+			LimitOrder: hu.LimitOrder{
+				BaseOrder: hu.BaseOrder{
+					AmmIndex:          big.NewInt(0),
+					Trader:            trader,
+					BaseAssetQuantity: big.NewInt(baseAssetQuantity),
+					Price:             big.NewInt(10e6),
+					Salt:              big.NewInt(salt),
+				},
+				PostOnly: true,
+			},
+			OrderType: uint8(hu.Signed),
+			ExpireAt:  big.NewInt(expireAt),
+		}
+		hash, err := order.Hash()
+		assert.Nil(t, err)
+		order.Sig, err = crypto.Sign(hash.Bytes(), key)
+		assert.Nil(t, err)
+		order.Sig[crypto.RecoveryIDOffset] += 27
+		return order
+	}
+	cancel := func(order *hu.SignedOrder, key *ecdsa.PrivateKey) *hu.CancelOrder {
+		cancelOrder := &hu.CancelOrder{Order: order}
+		hash, err := cancelOrder.Hash()
+		assert.Nil(t, err)
+		cancelOrder.Sig, err = crypto.Sign(hash.Bytes(), key)
+		assert.Nil(t, err)
+		cancelOrder.Sig[crypto.RecoveryIDOffset] += 27
+		return cancelOrder
+	}
+	newAPI := func() *TradingAPI {
+		db := getDatabase()
+		db.TraderMap[trader] = &Trader{
+			Margin: Margin{Deposited: map[Collateral]*big.Int{0: big.NewInt(1000e6)}, Reserved: big.NewInt(0)},
+		}
+		return &TradingAPI{db: db, configService: db.configService}
+	}
+
+	t.Run("amendments are rejected before cancellations are active", func(t *testing.T) {
+		api := newAPI()
+		original := newOrder(1, 1e18)
+		_, _, err := api.PlaceOrder(original)
+		assert.Nil(t, err)
+		_, _, err = api.AmendOrder(cancel(original, key), newOrder(2, 2e18))
+		assert.Equal(t, hu.ErrCancellationsNotActive, err)
+	})
+
+	hu.SignedOrderCancellationsActivationTime = 0
+
+	t.Run("amended order is cancelled", func(t *testing.T) {
+		api := newAPI()
+		original := newOrder(1, 1e18)
+		originalId, _, err := api.PlaceOrder(original)
+		assert.Nil(t, err)
+		amendment := newOrder(2, 2e18)
+		amendmentId, _, err := api.AmendOrder(cancel(original, key), amendment)
+		assert.Nil(t, err)
+		assert.Nil(t, api.db.GetOrderById(originalId))
+		assert.NotNil(t, api.db.GetOrderById(amendmentId))
+		assert.Contains(t, api.db.GetSignedOrderCancellations(), originalId)
+
+		_, _, err = api.PlaceOrder(original)
+		assert.Equal(t, hu.ErrOrderCancelled, err)
+	})
+
+	t.Run("amendment that arrives before the amended order", func(t *testing.T) {
+		api := newAPI()
+		original := newOrder(1, 1e18)
+		amendment := newOrder(2, 2e18)
+		amendmentId, _, err := api.AmendOrder(cancel(original, key), amendment)
+		assert.Nil(t, err)
+		assert.NotNil(t, api.db.GetOrderById(amendmentId))
+
+		_, _, err = api.PlaceOrder(original)
+		assert.Equal(t, hu.ErrOrderCancelled, err)
+	})
+
+	t.Run("cancel message is not signed by the trader", func(t *testing.T) {
+		api := newAPI()
+		original := newOrder(1, 1e18)
+		_, _, err := api.AmendOrder(cancel(original, otherKey), newOrder(2, 2e18))
+		assert.Equal(t, hu.ErrNoTradingAuthority, err)
+	})
+
+	t.Run("amendment in the other direction", func(t *testing.T) {
+		api := newAPI()
+		original := newOrder(1, 1e18)
+		_, _, err := api.AmendOrder(cancel(original, key), newOrder(2, -1e18))
+		assert.Equal(t, ErrInvalidAmendment, err)
+	})
+}
@@ -0,0 +1,108 @@
+package orderbook
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTriggerOrder(positionType PositionType, userAddress string, baseAssetQuantity, price, triggerPrice *big.Int, triggerKind hu.TriggerKind, salt *big.Int) Order {
+	order := createLimitOrder(positionType, userAddress, baseAssetQuantity, price, Placed, big.NewInt(0), salt)
+	order.OrderType = Trigger
+	order.RawOrder = &hu.TriggerOrder{
+		LimitOrder: hu.LimitOrder{
+			BaseOrder: hu.BaseOrder{
+				AmmIndex:          big.NewInt(int64(order.Market)),
+				Trader:            order.Trader,
+				BaseAssetQuantity: baseAssetQuantity,
+				Price:             price,
+				Salt:              salt,
+			},
+		},
+		OrderType:    uint8(Trigger),
+		ExpireAt:     big.NewInt(time.Now().Unix() + 3600),
+		TriggerPrice: triggerPrice,
+		TriggerKind:  uint8(triggerKind),
+	}
+	return order
+}
+
+func TestTriggerOrdersInMemoryDatabase(t *testing.T) {
+	// stop loss of a long position at 19
+	getOrder := func() Order {
+		return createTriggerOrder(SHORT, userAddress, big.NewInt(-10), big.NewInt(18), big.NewInt(19), hu.StopLoss, big.NewInt(1))
+	}
+
+	t.Run("trigger order is not part of the order book till it is triggered", func(t *testing.T) {
+		db := getDatabase()
+		order := getOrder()
+		db.AddTriggerOrder(&order)
+
+		assert.Equal(t, 0, len(db.GetShortOrders(market, nil, nil)))
+		assert.Equal(t, 0, len(db.GetAllOpenOrdersForTrader(common.HexToAddress(userAddress))))
+		assert.NotNil(t, db.GetOrderById(order.Id))
+
+		activated := db.ActivateTriggeredOrders(map[Market]*big.Int{market: big.NewInt(20)}, 5)
+		assert.Equal(t, 0, len(activated))
+		assert.Equal(t, 0, len(db.GetShortOrders(market, nil, nil)))
+
+		activated = db.ActivateTriggeredOrders(map[Market]*big.Int{market: big.NewInt(19)}, 6)
+		assert.Equal(t, 1, len(activated))
+		assert.Equal(t, 0, len(db.TriggerOrders))
+
+		shortOrders := db.GetShortOrders(market, nil, nil)
+		assert.Equal(t, 1, len(shortOrders))
+		assert.Equal(t, order.Id, shortOrders[0].Id)
+		assert.Equal(t, order.BlockNumber, shortOrders[0].BlockNumber)
+		assert.Equal(t, Lifecycle{BlockNumber: 6, Status: Placed, Info: "triggered", Reason: ReasonTriggered}, shortOrders[0].LifecycleList[1])
+		assert.Equal(t, 1, len(db.GetAllOpenOrdersForTrader(common.HexToAddress(userAddress))))
+	})
+	t.Run("cancelled trigger order is removed when the cancellation is accepted", func(t *testing.T) {
+		db := getDatabase()
+		order := getOrder()
+		db.AddTriggerOrder(&order)
+
+		assert.Nil(t, db.SetOrderStatus(order.Id, Cancelled, "", 5))
+		db.Accept(5, uint64(time.Now().Unix()))
+		assert.Nil(t, db.GetOrderById(order.Id))
+
+		activated := db.ActivateTriggeredOrders(map[Market]*big.Int{market: big.NewInt(19)}, 6)
+		assert.Equal(t, 0, len(activated))
+	})
+}
+
+func TestTriggerOrdersInMatching(t *testing.T) {
+	longOrder := createLimitOrder(LONG, userAddress, big.NewInt(10), big.NewInt(19), Placed, big.NewInt(2), big.NewInt(1))
+	triggerOrder := createTriggerOrder(SHORT, userAddress, big.NewInt(-10), big.NewInt(18), big.NewInt(19), hu.StopLoss, big.NewInt(2))
+	triggerOrder.BlockNumber = big.NewInt(1)
+
+	t.Run("triggered orders are takers", func(t *testing.T) {
+		assert.Equal(t, -1, compareBlockPlaced(longOrder, triggerOrder))
+		assert.Equal(t, 1, compareBlockPlaced(triggerOrder, longOrder))
+		assert.Equal(t, 0, compareBlockPlaced(triggerOrder, triggerOrder))
+		assert.True(t, isLongOrderMaker(longOrder, triggerOrder))
+	})
+	t.Run("triggered orders are not matched when the oracle price moves back", func(t *testing.T) {
+		orders := []Order{longOrder, triggerOrder}
+		assert.Equal(t, 2, len(removeUntriggeredOrders(orders, big.NewInt(19))))
+		filtered := removeUntriggeredOrders(orders, big.NewInt(20))
+		assert.Equal(t, 1, len(filtered))
+		assert.Equal(t, longOrder.Id, filtered[0].Id)
+	})
+	t.Run("margin with taker fee is required for trigger orders", func(t *testing.T) {
+		minAllowableMargin, takerFee, upperBound := big.NewInt(1e5), big.NewInt(1e3), big.NewInt(22)
+		fillAmount := big.NewInt(1e18)
+		order := triggerOrder
+		required := getRequiredMargin(&order, fillAmount, minAllowableMargin, takerFee, upperBound)
+
+		_, err := isExecutable(&order, fillAmount, minAllowableMargin, takerFee, upperBound, new(big.Int).Sub(required, big.NewInt(1)))
+		assert.NotNil(t, err)
+		margin, err := isExecutable(&order, fillAmount, minAllowableMargin, takerFee, upperBound, required)
+		assert.Nil(t, err)
+		assert.Equal(t, required, margin)
+	})
+}
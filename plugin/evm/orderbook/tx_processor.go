@@ -17,6 +17,7 @@ import (
 	"github.com/ava-labs/subnet-evm/eth"
 	"github.com/ava-labs/subnet-evm/metrics"
 	"github.com/ava-labs/subnet-evm/plugin/evm/orderbook/abis"
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
 	"github.com/ava-labs/subnet-evm/utils"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -29,6 +30,7 @@ var MarginAccountContractAddress = common.HexToAddress("0x0300000000000000000000
 var ClearingHouseContractAddress = common.HexToAddress("0x03000000000000000000000000000000000000b2")
 var LimitOrderBookContractAddress = common.HexToAddress("0x03000000000000000000000000000000000000b3")
 var IOCOrderBookContractAddress = common.HexToAddress("0x03000000000000000000000000000000000000b4")
+var JurorContractAddress = common.HexToAddress("0x03000000000000000000000000000000000000a2")
 
 type LimitOrderTxProcessor interface {
 	GetOrderBookTxsCount() uint64
@@ -36,11 +38,14 @@ type LimitOrderTxProcessor interface {
 	SetOrderBookTxsBlockNumber(blockNumber uint64)
 	PurgeOrderBookTxs()
 	ExecuteMatchedOrdersTx(incomingOrder Order, matchedOrder Order, fillAmount *big.Int) error
+	ExecuteBatchAuctionTx(orders []Order, fillAmounts []*big.Int) error
 	ExecuteFundingPaymentTx() error
 	ExecuteSamplePITx() error
 	ExecuteLiquidation(trader common.Address, matchedOrder Order, fillAmount *big.Int) error
 	UpdateMetrics(block *types.Block)
 	ExecuteLimitOrderCancel(orders []LimitOrder) error
+	ExecuteCancelSignedOrdersTx(cancelOrders []*hu.CancelOrder) error
+	ExecuteCancelAllSignedOrdersTx(cancels []*hu.CancelAllOrders) error
 }
 
 type ValidatorTxFeeConfig struct {
@@ -55,10 +60,12 @@ type limitOrderTxProcessor struct {
 	limitOrderBookABI             abi.ABI
 	clearingHouseABI              abi.ABI
 	marginAccountABI              abi.ABI
+	jurorABI                      abi.ABI
 	orderBookContractAddress      common.Address
 	limitOrderBookContractAddress common.Address
 	clearingHouseContractAddress  common.Address
 	marginAccountContractAddress  common.Address
+	jurorContractAddress          common.Address
 	backend                       *eth.EthAPIBackend
 	validatorAddress              common.Address
 	validatorPrivateKey           string
@@ -86,6 +93,11 @@ func NewLimitOrderTxProcessor(txPool *txpool.TxPool, memoryDb LimitOrderDatabase
 		panic(err)
 	}
 
+	jurorABI, err := abi.FromSolidityJson(string(abis.JurorAbi))
+	if err != nil {
+		panic(err)
+	}
+
 	validatorAddress, err := getAddressFromPrivateKey(validatorPrivateKey)
 	if err != nil {
 		panic("Unable to get address from validator private key")
@@ -97,11 +109,13 @@ func NewLimitOrderTxProcessor(txPool *txpool.TxPool, memoryDb LimitOrderDatabase
 		orderBookABI:                  orderBookABI,
 		clearingHouseABI:              clearingHouseABI,
 		marginAccountABI:              marginAccountABI,
+		jurorABI:                      jurorABI,
 		memoryDb:                      memoryDb,
 		limitOrderBookContractAddress: LimitOrderBookContractAddress,
 		orderBookContractAddress:      OrderBookContractAddress,
 		clearingHouseContractAddress:  ClearingHouseContractAddress,
 		marginAccountContractAddress:  MarginAccountContractAddress,
+		jurorContractAddress:          JurorContractAddress,
 		backend:                       backend,
 		validatorAddress:              validatorAddress,
 		validatorPrivateKey:           validatorPrivateKey,
@@ -159,12 +173,62 @@ func (lotp *limitOrderTxProcessor) ExecuteMatchedOrdersTx(longOrder Order, short
 	return err
 }
 
+// ExecuteBatchAuctionTx fills all the orders of a batch auction in one tx. The fill amounts are positive for long orders and negative for short orders.
+// It is only called after hu.BatchAuctionActivationTime, the OrderBook contract gets executeBatchAuction with the upgrade
+func (lotp *limitOrderTxProcessor) ExecuteBatchAuctionTx(orders []Order, fillAmounts []*big.Int) error {
+	encodedOrders := make([][]byte, len(orders))
+	for i, order := range orders {
+		var err error
+		encodedOrders[i], err = order.RawOrder.EncodeToABI()
+		if err != nil {
+			log.Error("EncodeToABI failed for batch auction order", "order", order, "err", err)
+			return err
+		}
+	}
+
+	txHash, err := lotp.executeLocalTx(lotp.orderBookContractAddress, lotp.orderBookABI, "executeBatchAuction", encodedOrders, fillAmounts)
+	log.Info("ExecuteBatchAuctionTx", "orders", orders, "fillAmounts", fillAmounts, "txHash", txHash.String(), "err", err)
+	return err
+}
+
 func (lotp *limitOrderTxProcessor) ExecuteLimitOrderCancel(orders []LimitOrder) error {
 	txHash, err := lotp.executeLocalTx(lotp.limitOrderBookContractAddress, lotp.limitOrderBookABI, "cancelOrdersWithLowMargin", orders)
 	log.Info("ExecuteLimitOrderCancel", "orders", orders, "txHash", txHash.String(), "err", err)
 	return err
 }
 
+// ExecuteCancelSignedOrdersTx records the cancel messages of signed orders with the juror, which rejects the cancelled orders after that
+func (lotp *limitOrderTxProcessor) ExecuteCancelSignedOrdersTx(cancelOrders []*hu.CancelOrder) error {
+	data := make([][]byte, 0, len(cancelOrders))
+	for _, cancelOrder := range cancelOrders {
+		encoded, err := cancelOrder.EncodeToABI()
+		if err != nil {
+			log.Error("EncodeToABI failed for cancel order", "order", cancelOrder.Order, "err", err)
+			continue
+		}
+		data = append(data, encoded)
+	}
+	txHash, err := lotp.executeLocalTx(lotp.jurorContractAddress, lotp.jurorABI, "cancelSignedOrders", data)
+	log.Info("ExecuteCancelSignedOrdersTx", "num", len(data), "txHash", txHash.String(), "err", err)
+	return err
+}
+
+// ExecuteCancelAllSignedOrdersTx records the nonces of cancel all messages with the juror, which rejects the signed orders with a salt <= nonce after that
+func (lotp *limitOrderTxProcessor) ExecuteCancelAllSignedOrdersTx(cancels []*hu.CancelAllOrders) error {
+	data := make([][]byte, 0, len(cancels))
+	for _, cancel := range cancels {
+		encoded, err := cancel.EncodeToABI()
+		if err != nil {
+			log.Error("EncodeToABI failed for cancel all message", "trader", cancel.Trader, "err", err)
+			continue
+		}
+		data = append(data, encoded)
+	}
+	txHash, err := lotp.executeLocalTx(lotp.jurorContractAddress, lotp.jurorABI, "cancelAllSignedOrders", data)
+	log.Info("ExecuteCancelAllSignedOrdersTx", "num", len(data), "txHash", txHash.String(), "err", err)
+	return err
+}
+
 func (lotp *limitOrderTxProcessor) executeLocalTx(contract common.Address, contractABI abi.ABI, method string, args ...interface{}) (common.Hash, error) {
 	var txHash common.Hash
 	nonce := lotp.txPool.GetOrderBookTxNonce(common.HexToAddress(lotp.validatorAddress.Hex())) // admin address
@@ -381,7 +445,7 @@ func (lotp *limitOrderTxProcessor) UpdateMetrics(block *types.Block) {
 
 			// log the failure for validator txs irrespective of whether the tx is from this validator or not
 			// this will help us identify tx failures that are not due to a hubble's validator
-			validatorMethods := []string{"liquidateAndExecuteOrder", "executeMatchedOrders", "settleFunding", "samplePI", "cancelOrdersWithLowMargin"}
+			validatorMethods := []string{"liquidateAndExecuteOrder", "executeMatchedOrders", "executeBatchAuction", "settleFunding", "samplePI", "cancelOrdersWithLowMargin"}
 			if receipt.Status == 0 && utils.ContainsString(validatorMethods, method.Name) {
 				log.Error("validator tx failed", "method", method.Name, "contractName", contractName, "tx", tx.Hash().String(), "from", from.String(), "receipt", formatReceiptForLogging(receipt))
 			}
@@ -230,6 +230,8 @@ type VM struct {
 	limitOrderProcesser LimitOrderProcesser
 
 	orderGossiper OrderGossiper
+	// per peer budget of signed orders, shared by order gossip and signed order sync requests
+	orderGossipLimiter *orderGossipLimiter
 
 	clock mockable.Clock
 
@@ -569,6 +571,13 @@ func (vm *VM) initializeChain(lastAcceptedHash common.Hash, ethConfig ethconfig.
 	vm.miner = vm.eth.Miner()
 
 	vm.limitOrderProcesser = vm.NewLimitOrderProcesser()
+	vm.orderGossipLimiter = newOrderGossipLimiter(
+		vm.config.OrderGossipPeerRate,
+		vm.config.OrderGossipPeerBurst,
+		vm.config.OrderGossipPeerMaxInvalid,
+		vm.config.OrderGossipPeerInvalidWindow.Duration,
+		vm.config.OrderGossipPeerMuteDuration.Duration,
+	)
 	tempMatcher := orderbook.NewTempMatcher(vm.limitOrderProcesser.GetMemoryDB(), vm.limitOrderProcesser.GetLimitOrderTxProcessor())
 	vm.eth.SetOrderbookChecker(tempMatcher)
 	vm.eth.Start()
@@ -788,6 +797,7 @@ func (vm *VM) initBlockBuilding() error {
 		vm.shutdownWg.Done()
 	}()
 	vm.limitOrderProcesser.ListenAndProcessTransactions(vm.builder)
+	vm.awaitSignedOrdersSync(ctx)
 	return nil
 }
 
@@ -804,7 +814,7 @@ func (vm *VM) setAppRequestHandlers() {
 		},
 	)
 
-	networkHandler := newNetworkHandler(vm.blockChain, vm.chaindb, evmTrieDB, vm.warpBackend, vm.networkCodec)
+	networkHandler := newNetworkHandler(vm.blockChain, vm.chaindb, evmTrieDB, vm.warpBackend, vm.limitOrderProcesser.GetMemoryDB(), vm.orderGossipLimiter, vm.networkCodec)
 	vm.Network.SetRequestHandler(networkHandler)
 }
 
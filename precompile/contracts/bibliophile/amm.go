@@ -36,6 +36,10 @@ func getAsksHead(stateDB contract.StateDB, market common.Address) *big.Int {
 	return stateDB.GetState(market, common.BigToHash(big.NewInt(ASKS_HEAD_SLOT))).Big()
 }
 
+func GetLastPrice(stateDB contract.StateDB, market common.Address) *big.Int {
+	return getLastPrice(stateDB, market)
+}
+
 func getLastPrice(stateDB contract.StateDB, market common.Address) *big.Int {
 	return stateDB.GetState(market, common.BigToHash(big.NewInt(LAST_TRADE_PRICE_SLOT))).Big()
 }
@@ -144,6 +148,11 @@ func GetImpactMarginNotional(stateDB contract.StateDB, market common.Address) *b
 	return stateDB.GetState(market, common.BigToHash(big.NewInt(IMPACT_MARGIN_NOTIONAL_SLOT))).Big()
 }
 
+// GetPosition returns the size and open notional of the position of a trader in a market
+func GetPosition(stateDB contract.StateDB, market common.Address, trader *common.Address) *hu.Position {
+	return getPosition(stateDB, market, trader)
+}
+
 func getPosition(stateDB contract.StateDB, market common.Address, trader *common.Address) *hu.Position {
 	return &hu.Position{
 		Size:         getSize(stateDB, market, trader),
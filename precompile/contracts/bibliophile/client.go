@@ -37,6 +37,8 @@ type BibliophileClient interface {
 	// Signed Order
 	GetSignedOrderFilledAmount(orderHash [32]byte) *big.Int
 	GetSignedOrderStatus(orderHash [32]byte) int64
+	IsSignedOrderCancelled(orderHash [32]byte) bool
+	GetCancelNonce(trader common.Address, ammIndex *big.Int) *big.Int
 
 	// AMM
 	GetMinSizeRequirement(marketId int64) *big.Int
@@ -51,8 +53,13 @@ type BibliophileClient interface {
 	GetPriceMultiplier(market common.Address) *big.Int
 	GetUpperAndLowerBoundForMarket(marketId int64) (*big.Int, *big.Int)
 	GetAcceptableBoundsForLiquidation(marketId int64) (*big.Int, *big.Int)
+	GetMatchingMode(marketId int64) hu.MatchingMode
+	GetLastBatchAuctionBlock(marketId int64) *big.Int
+	GetSelfTradePreventionMode(marketId int64) hu.SelfTradePreventionMode
+	GetUnderlyingPrice(marketId int64) *big.Int
 
 	GetTimeStamp() uint64
+	GetBlockNumber() *big.Int
 	GetNotionalPositionAndMargin(trader common.Address, includeFundingPayments bool, mode uint8, upgradeVersion hu.UpgradeVersion) (*big.Int, *big.Int)
 	HasReferrer(trader common.Address) bool
 	GetActiveMarketsCount() int64
@@ -83,6 +90,14 @@ func (b *bibliophileClient) GetSignedOrderStatus(orderHash [32]byte) int64 {
 	return GetSignedOrderStatus(b.accessibleState.GetStateDB(), orderHash)
 }
 
+func (b *bibliophileClient) IsSignedOrderCancelled(orderHash [32]byte) bool {
+	return IsSignedOrderCancelled(b.accessibleState.GetStateDB(), orderHash)
+}
+
+func (b *bibliophileClient) GetCancelNonce(trader common.Address, ammIndex *big.Int) *big.Int {
+	return GetCancelNonce(b.accessibleState.GetStateDB(), trader, ammIndex)
+}
+
 func (b *bibliophileClient) GetActiveMarketsCount() int64 {
 	return GetActiveMarketsCount(b.accessibleState.GetStateDB())
 }
@@ -91,10 +106,30 @@ func (b *bibliophileClient) GetTimeStamp() uint64 {
 	return b.accessibleState.GetBlockContext().Timestamp()
 }
 
+func (b *bibliophileClient) GetBlockNumber() *big.Int {
+	return b.accessibleState.GetBlockContext().Number()
+}
+
 func (b *bibliophileClient) GetSize(market common.Address, trader *common.Address) *big.Int {
 	return getSize(b.accessibleState.GetStateDB(), market, trader)
 }
 
+func (b *bibliophileClient) GetMatchingMode(marketId int64) hu.MatchingMode {
+	return GetMatchingMode(b.accessibleState.GetStateDB(), marketId, b.GetTimeStamp())
+}
+
+func (b *bibliophileClient) GetLastBatchAuctionBlock(marketId int64) *big.Int {
+	return GetLastBatchAuctionBlock(b.accessibleState.GetStateDB(), marketId)
+}
+
+func (b *bibliophileClient) GetSelfTradePreventionMode(marketId int64) hu.SelfTradePreventionMode {
+	return GetSelfTradePreventionMode(b.accessibleState.GetStateDB(), marketId)
+}
+
+func (b *bibliophileClient) GetUnderlyingPrice(marketId int64) *big.Int {
+	return getUnderlyingPriceForMarket(b.accessibleState.GetStateDB(), marketId)
+}
+
 func (b *bibliophileClient) GetMinSizeRequirement(marketId int64) *big.Int {
 	return GetMinSizeRequirement(b.accessibleState.GetStateDB(), marketId)
 }
@@ -150,6 +150,20 @@ func (mr *MockBibliophileClientMockRecorder) GetBidsHead(market interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBidsHead", reflect.TypeOf((*MockBibliophileClient)(nil).GetBidsHead), market)
 }
 
+// GetBlockNumber mocks base method.
+func (m *MockBibliophileClient) GetBlockNumber() *big.Int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBlockNumber")
+	ret0, _ := ret[0].(*big.Int)
+	return ret0
+}
+
+// GetBlockNumber indicates an expected call of GetBlockNumber.
+func (mr *MockBibliophileClientMockRecorder) GetBlockNumber() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBlockNumber", reflect.TypeOf((*MockBibliophileClient)(nil).GetBlockNumber))
+}
+
 // GetBlockPlaced mocks base method.
 func (m *MockBibliophileClient) GetBlockPlaced(orderHash [32]byte) *big.Int {
 	m.ctrl.T.Helper()
@@ -164,6 +178,20 @@ func (mr *MockBibliophileClientMockRecorder) GetBlockPlaced(orderHash interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBlockPlaced", reflect.TypeOf((*MockBibliophileClient)(nil).GetBlockPlaced), orderHash)
 }
 
+// GetCancelNonce mocks base method.
+func (m *MockBibliophileClient) GetCancelNonce(trader common.Address, ammIndex *big.Int) *big.Int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCancelNonce", trader, ammIndex)
+	ret0, _ := ret[0].(*big.Int)
+	return ret0
+}
+
+// GetCancelNonce indicates an expected call of GetCancelNonce.
+func (mr *MockBibliophileClientMockRecorder) GetCancelNonce(trader, ammIndex interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCancelNonce", reflect.TypeOf((*MockBibliophileClient)(nil).GetCancelNonce), trader, ammIndex)
+}
+
 // GetImpactMarginNotional mocks base method.
 func (m *MockBibliophileClient) GetImpactMarginNotional(ammAddress common.Address) *big.Int {
 	m.ctrl.T.Helper()
@@ -178,6 +206,20 @@ func (mr *MockBibliophileClientMockRecorder) GetImpactMarginNotional(ammAddress
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImpactMarginNotional", reflect.TypeOf((*MockBibliophileClient)(nil).GetImpactMarginNotional), ammAddress)
 }
 
+// GetLastBatchAuctionBlock mocks base method.
+func (m *MockBibliophileClient) GetLastBatchAuctionBlock(marketId int64) *big.Int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLastBatchAuctionBlock", marketId)
+	ret0, _ := ret[0].(*big.Int)
+	return ret0
+}
+
+// GetLastBatchAuctionBlock indicates an expected call of GetLastBatchAuctionBlock.
+func (mr *MockBibliophileClientMockRecorder) GetLastBatchAuctionBlock(marketId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLastBatchAuctionBlock", reflect.TypeOf((*MockBibliophileClient)(nil).GetLastBatchAuctionBlock), marketId)
+}
+
 // GetLastPrice mocks base method.
 func (m *MockBibliophileClient) GetLastPrice(ammAddress common.Address) *big.Int {
 	m.ctrl.T.Helper()
@@ -220,6 +262,20 @@ func (mr *MockBibliophileClientMockRecorder) GetMarketAddressFromMarketID(market
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMarketAddressFromMarketID", reflect.TypeOf((*MockBibliophileClient)(nil).GetMarketAddressFromMarketID), marketId)
 }
 
+// GetMatchingMode mocks base method.
+func (m *MockBibliophileClient) GetMatchingMode(marketId int64) hubbleutils.MatchingMode {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMatchingMode", marketId)
+	ret0, _ := ret[0].(hubbleutils.MatchingMode)
+	return ret0
+}
+
+// GetMatchingMode indicates an expected call of GetMatchingMode.
+func (mr *MockBibliophileClientMockRecorder) GetMatchingMode(marketId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMatchingMode", reflect.TypeOf((*MockBibliophileClient)(nil).GetMatchingMode), marketId)
+}
+
 // GetMinAllowableMargin mocks base method.
 func (m *MockBibliophileClient) GetMinAllowableMargin() *big.Int {
 	m.ctrl.T.Helper()
@@ -347,6 +403,20 @@ func (mr *MockBibliophileClientMockRecorder) GetReduceOnlyAmount(trader, ammInde
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReduceOnlyAmount", reflect.TypeOf((*MockBibliophileClient)(nil).GetReduceOnlyAmount), trader, ammIndex)
 }
 
+// GetSelfTradePreventionMode mocks base method.
+func (m *MockBibliophileClient) GetSelfTradePreventionMode(marketId int64) hubbleutils.SelfTradePreventionMode {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSelfTradePreventionMode", marketId)
+	ret0, _ := ret[0].(hubbleutils.SelfTradePreventionMode)
+	return ret0
+}
+
+// GetSelfTradePreventionMode indicates an expected call of GetSelfTradePreventionMode.
+func (mr *MockBibliophileClientMockRecorder) GetSelfTradePreventionMode(marketId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSelfTradePreventionMode", reflect.TypeOf((*MockBibliophileClient)(nil).GetSelfTradePreventionMode), marketId)
+}
+
 // GetShortOpenOrdersAmount mocks base method.
 func (m *MockBibliophileClient) GetShortOpenOrdersAmount(trader common.Address, ammIndex *big.Int) *big.Int {
 	m.ctrl.T.Helper()
@@ -431,6 +501,20 @@ func (mr *MockBibliophileClientMockRecorder) GetTimeStamp() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTimeStamp", reflect.TypeOf((*MockBibliophileClient)(nil).GetTimeStamp))
 }
 
+// GetUnderlyingPrice mocks base method.
+func (m *MockBibliophileClient) GetUnderlyingPrice(marketId int64) *big.Int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUnderlyingPrice", marketId)
+	ret0, _ := ret[0].(*big.Int)
+	return ret0
+}
+
+// GetUnderlyingPrice indicates an expected call of GetUnderlyingPrice.
+func (mr *MockBibliophileClientMockRecorder) GetUnderlyingPrice(marketId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUnderlyingPrice", reflect.TypeOf((*MockBibliophileClient)(nil).GetUnderlyingPrice), marketId)
+}
+
 // GetUpperAndLowerBoundForMarket mocks base method.
 func (m *MockBibliophileClient) GetUpperAndLowerBoundForMarket(marketId int64) (*big.Int, *big.Int) {
 	m.ctrl.T.Helper()
@@ -516,6 +600,20 @@ func (mr *MockBibliophileClientMockRecorder) IOC_GetOrderStatus(orderHash interf
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IOC_GetOrderStatus", reflect.TypeOf((*MockBibliophileClient)(nil).IOC_GetOrderStatus), orderHash)
 }
 
+// IsSignedOrderCancelled mocks base method.
+func (m *MockBibliophileClient) IsSignedOrderCancelled(orderHash [32]byte) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsSignedOrderCancelled", orderHash)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsSignedOrderCancelled indicates an expected call of IsSignedOrderCancelled.
+func (mr *MockBibliophileClientMockRecorder) IsSignedOrderCancelled(orderHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsSignedOrderCancelled", reflect.TypeOf((*MockBibliophileClient)(nil).IsSignedOrderCancelled), orderHash)
+}
+
 // IsTradingAuthority mocks base method.
 func (m *MockBibliophileClient) IsTradingAuthority(trader, senderOrSigner common.Address) bool {
 	m.ctrl.T.Helper()
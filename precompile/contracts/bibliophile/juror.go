@@ -0,0 +1,101 @@
+package bibliophile
+
+import (
+	"math/big"
+
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ava-labs/subnet-evm/precompile/contract"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// the jurorv2 precompile keeps the market config that the contracts don't have in its own storage
+const (
+	JUROR_ADDRESS = "0x03000000000000000000000000000000000000a2"
+
+	JUROR_MATCHING_MODE_SLOT           int64 = 0
+	JUROR_SELF_TRADE_PREVENTION_SLOT   int64 = 1
+	JUROR_CANCELLED_SIGNED_ORDERS_SLOT int64 = 2
+	JUROR_CANCEL_NONCES_SLOT           int64 = 3
+	JUROR_BATCH_AUCTION_BLOCKS_SLOT    int64 = 4
+)
+
+// GetMatchingMode returns the matching mode for a given market at the given block timestamp, set by the jurorv2 precompile config.
+// Markets that don't have one use FIFO, and so do markets set to BatchAuction before hu.BatchAuctionActivationTime
+func GetMatchingMode(stateDB contract.StateDB, marketID int64, timestamp uint64) hu.MatchingMode {
+	mode := stateDB.GetState(common.HexToAddress(JUROR_ADDRESS), jurorMarketMappingStorageSlot(marketID, JUROR_MATCHING_MODE_SLOT)).Big()
+	if !mode.IsUint64() || mode.Uint64() > uint64(hu.BatchAuction) {
+		return hu.FIFO
+	}
+	if hu.MatchingMode(mode.Uint64()) == hu.BatchAuction && !hu.IsBatchAuctionActive(timestamp) {
+		return hu.FIFO
+	}
+	return hu.MatchingMode(mode.Uint64())
+}
+
+func SetMatchingMode(stateDB contract.StateDB, marketID int64, mode hu.MatchingMode) {
+	stateDB.SetState(common.HexToAddress(JUROR_ADDRESS), jurorMarketMappingStorageSlot(marketID, JUROR_MATCHING_MODE_SLOT), common.BigToHash(big.NewInt(int64(mode))))
+}
+
+// GetSelfTradePreventionMode returns the self trade prevention mode for a given market, set by the jurorv2 precompile config.
+// Markets that don't have one allow self trades
+func GetSelfTradePreventionMode(stateDB contract.StateDB, marketID int64) hu.SelfTradePreventionMode {
+	mode := stateDB.GetState(common.HexToAddress(JUROR_ADDRESS), jurorMarketMappingStorageSlot(marketID, JUROR_SELF_TRADE_PREVENTION_SLOT)).Big()
+	if !mode.IsUint64() || mode.Uint64() > uint64(hu.DecrementAndCancel) {
+		return hu.NoSelfTradePrevention
+	}
+	return hu.SelfTradePreventionMode(mode.Uint64())
+}
+
+func SetSelfTradePreventionMode(stateDB contract.StateDB, marketID int64, mode hu.SelfTradePreventionMode) {
+	stateDB.SetState(common.HexToAddress(JUROR_ADDRESS), jurorMarketMappingStorageSlot(marketID, JUROR_SELF_TRADE_PREVENTION_SLOT), common.BigToHash(big.NewInt(int64(mode))))
+}
+
+// GetLastBatchAuctionBlock returns the number of the last block in which the juror validated a batch auction of the market, 0 if there is none
+func GetLastBatchAuctionBlock(stateDB contract.StateDB, marketID int64) *big.Int {
+	return stateDB.GetState(common.HexToAddress(JUROR_ADDRESS), jurorMarketMappingStorageSlot(marketID, JUROR_BATCH_AUCTION_BLOCKS_SLOT)).Big()
+}
+
+func SetLastBatchAuctionBlock(stateDB contract.StateDB, marketID int64, blockNumber *big.Int) {
+	stateDB.SetState(common.HexToAddress(JUROR_ADDRESS), jurorMarketMappingStorageSlot(marketID, JUROR_BATCH_AUCTION_BLOCKS_SLOT), common.BigToHash(blockNumber))
+}
+
+// IsSignedOrderCancelled returns true if a CancelOrder message for the signed order was recorded by the juror's cancelSignedOrders
+func IsSignedOrderCancelled(stateDB contract.StateDB, orderHash [32]byte) bool {
+	return stateDB.GetState(common.HexToAddress(JUROR_ADDRESS), jurorCancelledSignedOrderStorageSlot(orderHash)).Big().Sign() != 0
+}
+
+func SetSignedOrderCancelled(stateDB contract.StateDB, orderHash [32]byte) {
+	stateDB.SetState(common.HexToAddress(JUROR_ADDRESS), jurorCancelledSignedOrderStorageSlot(orderHash), common.BigToHash(big.NewInt(1)))
+}
+
+// GetCancelNonce returns the nonce of the trader's last hu.CancelAllOrders message that the juror's cancelAllSignedOrders recorded and
+// that applies to the signed orders of the market, or the nonce for all markets if ammIndex is hu.AllMarkets. Returns 0 if there is none
+func GetCancelNonce(stateDB contract.StateDB, trader common.Address, ammIndex *big.Int) *big.Int {
+	nonce := stateDB.GetState(common.HexToAddress(JUROR_ADDRESS), jurorCancelNonceStorageSlot(trader, hu.AllMarkets)).Big()
+	if ammIndex.Cmp(hu.AllMarkets) != 0 {
+		if marketNonce := stateDB.GetState(common.HexToAddress(JUROR_ADDRESS), jurorCancelNonceStorageSlot(trader, ammIndex)).Big(); marketNonce.Cmp(nonce) > 0 {
+			nonce = marketNonce
+		}
+	}
+	return nonce
+}
+
+func SetCancelNonce(stateDB contract.StateDB, trader common.Address, ammIndex *big.Int, nonce *big.Int) {
+	stateDB.SetState(common.HexToAddress(JUROR_ADDRESS), jurorCancelNonceStorageSlot(trader, ammIndex), common.BigToHash(nonce))
+}
+
+func jurorMarketMappingStorageSlot(marketID int64, slot int64) common.Hash {
+	return common.BytesToHash(crypto.Keccak256(append(common.LeftPadBytes(big.NewInt(marketID).Bytes(), 32), common.LeftPadBytes(big.NewInt(slot).Bytes(), 32)...)))
+}
+
+func jurorCancelledSignedOrderStorageSlot(orderHash [32]byte) common.Hash {
+	return common.BytesToHash(crypto.Keccak256(append(orderHash[:], common.LeftPadBytes(big.NewInt(JUROR_CANCELLED_SIGNED_ORDERS_SLOT).Bytes(), 32)...)))
+}
+
+// jurorCancelNonceStorageSlot is the slot of mapping(address trader => mapping(int256 ammIndex => uint256 nonce))
+func jurorCancelNonceStorageSlot(trader common.Address, ammIndex *big.Int) common.Hash {
+	traderSlot := crypto.Keccak256(append(common.LeftPadBytes(trader.Bytes(), 32), common.LeftPadBytes(big.NewInt(JUROR_CANCEL_NONCES_SLOT).Bytes(), 32)...))
+	return common.BytesToHash(crypto.Keccak256(append(math.U256Bytes(new(big.Int).Set(ammIndex)), traderSlot...)))
+}
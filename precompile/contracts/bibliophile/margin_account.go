@@ -24,6 +24,11 @@ func GetNormalizedMargin(stateDB contract.StateDB, trader common.Address) *big.I
 	return hu.GetNormalizedMargin(assets, margins)
 }
 
+// GetMargins returns the margin of a trader in every collateral
+func GetMargins(stateDB contract.StateDB, trader common.Address) []*big.Int {
+	return getMargins(stateDB, trader)
+}
+
 func getMargins(stateDB contract.StateDB, trader common.Address) []*big.Int {
 	numAssets := getCollateralCount(stateDB)
 	margins := make([]*big.Int, numAssets)
@@ -39,6 +44,11 @@ func getMargin(stateDB contract.StateDB, idx *big.Int, trader common.Address) *b
 	return fromTwosComplement(stateDB.GetState(common.HexToAddress(MARGIN_ACCOUNT_GENESIS_ADDRESS), common.BytesToHash(marginStorageSlot)).Bytes())
 }
 
+// GetReservedMargin returns the margin reserved for the open orders of a trader
+func GetReservedMargin(stateDB contract.StateDB, trader common.Address) *big.Int {
+	return getReservedMargin(stateDB, trader)
+}
+
 func getReservedMargin(stateDB contract.StateDB, trader common.Address) *big.Int {
 	baseMappingHash := crypto.Keccak256(append(common.LeftPadBytes(trader.Bytes(), 32), common.LeftPadBytes(big.NewInt(RESERVED_MARGIN_SLOT).Bytes(), 32)...))
 	return stateDB.GetState(common.HexToAddress(MARGIN_ACCOUNT_GENESIS_ADDRESS), common.BytesToHash(baseMappingHash)).Big()
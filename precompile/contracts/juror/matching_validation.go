@@ -13,6 +13,7 @@ import (
 type Metadata struct {
 	AmmIndex          *big.Int
 	Trader            common.Address
+	Signer            common.Address // trader for orders placed on chain
 	BaseAssetQuantity *big.Int
 	Price             *big.Int
 	BlockPlaced       *big.Int
@@ -47,6 +48,7 @@ var (
 	ErrNotSameAMM        = errors.New("OB_orders_for_different_amms")
 	ErrNoMatch           = errors.New("OB_orders_do_not_match")
 	ErrNotMultiple       = errors.New("not multiple")
+	ErrSelfTrade         = errors.New("self trade")
 
 	ErrInvalidOrder                       = errors.New("invalid order")
 	ErrNotIOCOrder                        = errors.New("not_ioc_order")
@@ -124,6 +126,10 @@ func ValidateOrdersAndDetermineFillPrice(bibliophile b.BibliophileClient, inputS
 		return getValidateOrdersAndDetermineFillPriceErrorOutput(ErrNotMultiple, Generic, common.Hash{})
 	}
 
+	if bibliophile.GetSelfTradePreventionMode(m0.AmmIndex.Int64()) != hu.NoSelfTradePrevention && hu.IsSelfTrade(m0.Trader, m0.Signer, m1.Trader, m1.Signer, bibliophile.IsTradingAuthority) {
+		return getValidateOrdersAndDetermineFillPriceErrorOutput(ErrSelfTrade, Generic, common.Hash{})
+	}
+
 	fillPriceAndModes, err, element := determineFillPrice(bibliophile, m0, m1)
 	if err != nil {
 		orderHash := common.Hash{}
@@ -328,6 +334,7 @@ func validateExecuteLimitOrder(bibliophile b.BibliophileClient, order *hu.LimitO
 	return &Metadata{
 		AmmIndex:          order.AmmIndex,
 		Trader:            order.Trader,
+		Signer:            order.Trader,
 		BaseAssetQuantity: order.BaseAssetQuantity,
 		BlockPlaced:       bibliophile.GetBlockPlaced(orderHash),
 		Price:             order.Price,
@@ -354,6 +361,7 @@ func validateExecuteIOCOrder(bibliophile b.BibliophileClient, order *hu.IOCOrder
 	return &Metadata{
 		AmmIndex:          order.AmmIndex,
 		Trader:            order.Trader,
+		Signer:            order.Trader,
 		BaseAssetQuantity: order.BaseAssetQuantity,
 		BlockPlaced:       bibliophile.IOC_GetBlockPlaced(orderHash),
 		Price:             order.Price,
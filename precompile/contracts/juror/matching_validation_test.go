@@ -905,6 +905,7 @@ func TestValidateOrdersAndDetermineFillPrice(t *testing.T) {
 		mockBibliophile.EXPECT().GetBlockPlaced(order1Hash).Return(big.NewInt(12))
 
 		mockBibliophile.EXPECT().GetMinSizeRequirement(order1.AmmIndex.Int64()).Return(big.NewInt(1))
+		mockBibliophile.EXPECT().GetSelfTradePreventionMode(order1.AmmIndex.Int64()).Return(hu.NoSelfTradePrevention)
 		mockBibliophile.EXPECT().GetUpperAndLowerBoundForMarket(order1.AmmIndex.Int64()).Return(big.NewInt(110), big.NewInt(90))
 
 		testCase := ValidateOrdersAndDetermineFillPriceTestCase{
@@ -0,0 +1,170 @@
+package jurorv2
+
+import (
+	"math/big"
+
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	b "github.com/ava-labs/subnet-evm/precompile/contracts/bibliophile"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// MaxBatchAuctionOrders bounds the work of a single validateBatchAuctionAndDetermineClearingPrice call
+const MaxBatchAuctionOrders = 64
+
+// genericBatchAuctionError is the BadOrderIndex of an error that is not caused by a single order
+const genericBatchAuctionError = -1
+
+// ValidateBatchAuctionAndDetermineClearingPrice validates the fills of a batch auction in a market with the BatchAuction matching mode.
+// The sign of a fill amount is the side of the order, positive for long orders and negative for short orders.
+//
+// Every order is validated the same way as in a pairwise match, then the auction is cleared again from the unfilled amounts of the
+// submitted orders with hu.ClearBatchAuction, and the fills have to match it exactly. So given the submitted orders, a validator can't
+// pick the fills or the price, and all the orders are filled at the same clearing price, which is read from the oracle price and bounds
+// of the market at execution. Only one auction of a market is accepted in a block, see GetLastBatchAuctionBlock.
+//
+// The juror only sees the submitted orders, signed orders are not in the chain state. So the validator still chooses which of the
+// crossing orders take part in the auction, and this doesn't prevent a validator from leaving out orders to front-run them.
+//
+// Orders in an auction don't rest in the book, so post only and signed orders are filled as makers and other orders as takers.
+// All the orders trade at the clearing price, so with self trade prevention, the auction can't fill long and short orders of the same trader group.
+func ValidateBatchAuctionAndDetermineClearingPrice(bibliophile b.BibliophileClient, inputStruct *ValidateBatchAuctionAndDetermineClearingPriceInput) ValidateBatchAuctionAndDetermineClearingPriceOutput {
+	if len(inputStruct.Data) < 2 || len(inputStruct.Data) > MaxBatchAuctionOrders || len(inputStruct.Data) != len(inputStruct.FillAmounts) {
+		return getValidateBatchAuctionErrorOutput(ErrInvalidBatchAuctionSize, genericBatchAuctionError)
+	}
+
+	res := IOrderHandlerBatchAuctionValidationRes{
+		Instructions:  make([]IClearingHouseInstruction, len(inputStruct.Data)),
+		OrderTypes:    make([]uint8, len(inputStruct.Data)),
+		EncodedOrders: make([][]byte, len(inputStruct.Data)),
+		FillAmounts:   inputStruct.FillAmounts,
+	}
+	metadatas := make([]*Metadata, len(inputStruct.Data))
+	orderHashes := make(map[common.Hash]struct{}, len(inputStruct.Data))
+	for i, data := range inputStruct.Data {
+		fillAmount := inputStruct.FillAmounts[i]
+		if fillAmount == nil || fillAmount.Sign() == 0 {
+			return getValidateBatchAuctionErrorOutput(ErrInvalidFillAmount, i)
+		}
+		decodeStep, err := hu.DecodeTypeAndEncodedOrder(data)
+		if err != nil {
+			return getValidateBatchAuctionErrorOutput(err, i)
+		}
+		side := Long
+		if fillAmount.Sign() < 0 {
+			side = Short
+		}
+		m, err := validateOrder(bibliophile, decodeStep.OrderType, decodeStep.EncodedOrder, side, fillAmount)
+		if err != nil {
+			return getValidateBatchAuctionErrorOutput(err, i)
+		}
+		if i > 0 && m.AmmIndex.Cmp(metadatas[0].AmmIndex) != 0 {
+			return getValidateBatchAuctionErrorOutput(ErrNotSameAMM, i)
+		}
+		if _, ok := orderHashes[m.OrderHash]; ok {
+			return getValidateBatchAuctionErrorOutput(ErrDuplicateOrder, i)
+		}
+		orderHashes[m.OrderHash] = struct{}{}
+		metadatas[i] = m
+
+		mode := Taker
+		if m.PostOnly {
+			mode = Maker
+		}
+		res.Instructions[i] = IClearingHouseInstruction{
+			AmmIndex:  m.AmmIndex,
+			Trader:    m.Trader,
+			OrderHash: m.OrderHash,
+			Mode:      uint8(mode),
+		}
+		res.OrderTypes[i] = uint8(decodeStep.OrderType)
+		res.EncodedOrders[i] = decodeStep.EncodedOrder
+	}
+
+	ammIndex := metadatas[0].AmmIndex.Int64()
+	if bibliophile.GetMatchingMode(ammIndex) != hu.BatchAuction {
+		return getValidateBatchAuctionErrorOutput(ErrNotBatchAuctionMarket, genericBatchAuctionError)
+	}
+	if bibliophile.GetLastBatchAuctionBlock(ammIndex).Cmp(bibliophile.GetBlockNumber()) == 0 {
+		return getValidateBatchAuctionErrorOutput(ErrBatchAuctionInBlock, genericBatchAuctionError)
+	}
+	if bibliophile.GetSelfTradePreventionMode(ammIndex) != hu.NoSelfTradePrevention {
+		for i, m0 := range metadatas {
+			for j := i + 1; j < len(metadatas); j++ {
+				m1 := metadatas[j]
+				if inputStruct.FillAmounts[i].Sign() != inputStruct.FillAmounts[j].Sign() && hu.IsSelfTrade(m0.Trader, m0.Signer, m1.Trader, m1.Signer, bibliophile.IsTradingAuthority) {
+					return getValidateBatchAuctionErrorOutput(ErrSelfTrade, j)
+				}
+			}
+		}
+	}
+
+	minSize := bibliophile.GetMinSizeRequirement(ammIndex)
+	upperBound, lowerBound := bibliophile.GetUpperAndLowerBoundForMarket(ammIndex)
+	longs, shorts := []hu.AuctionOrder{}, []hu.AuctionOrder{}
+	longIndexes, shortIndexes := []int{}, []int{}
+	longVolume, shortVolume := big.NewInt(0), big.NewInt(0)
+	for i, m := range metadatas {
+		fillAmount := inputStruct.FillAmounts[i]
+		if new(big.Int).Mod(fillAmount, minSize).Sign() != 0 {
+			return getValidateBatchAuctionErrorOutput(ErrNotMultiple, i)
+		}
+		auctionOrder := hu.AuctionOrder{
+			OrderHash:   m.OrderHash,
+			Price:       m.Price,
+			Size:        new(big.Int).Abs(new(big.Int).Sub(m.BaseAssetQuantity, m.FilledAmount)),
+			BlockPlaced: m.BlockPlaced,
+		}
+		if fillAmount.Sign() > 0 {
+			if m.Price.Cmp(lowerBound) < 0 {
+				return getValidateBatchAuctionErrorOutput(ErrTooLow, i)
+			}
+			longs, longIndexes = append(longs, auctionOrder), append(longIndexes, i)
+			longVolume.Add(longVolume, fillAmount)
+		} else {
+			if m.Price.Cmp(upperBound) > 0 {
+				return getValidateBatchAuctionErrorOutput(ErrTooHigh, i)
+			}
+			shorts, shortIndexes = append(shorts, auctionOrder), append(shortIndexes, i)
+			shortVolume.Sub(shortVolume, fillAmount)
+		}
+	}
+	if longVolume.Cmp(shortVolume) != 0 {
+		return getValidateBatchAuctionErrorOutput(ErrUnbalancedFills, genericBatchAuctionError)
+	}
+
+	oraclePrice := bibliophile.GetUnderlyingPrice(ammIndex)
+	tickSize := bibliophile.GetPriceMultiplier(bibliophile.GetMarketAddressFromMarketID(ammIndex))
+	result, ok := hu.ClearBatchAuction(longs, shorts, oraclePrice, lowerBound, upperBound, tickSize)
+	if !ok {
+		return getValidateBatchAuctionErrorOutput(ErrNoMatch, genericBatchAuctionError)
+	}
+	for k, i := range longIndexes {
+		if inputStruct.FillAmounts[i].Cmp(result.LongFills[k]) != 0 {
+			return getValidateBatchAuctionErrorOutput(ErrBatchAuctionFillMismatch, i)
+		}
+	}
+	for k, i := range shortIndexes {
+		if new(big.Int).Neg(inputStruct.FillAmounts[i]).Cmp(result.ShortFills[k]) != 0 {
+			return getValidateBatchAuctionErrorOutput(ErrBatchAuctionFillMismatch, i)
+		}
+	}
+
+	res.ClearingPrice = result.ClearingPrice
+	return ValidateBatchAuctionAndDetermineClearingPriceOutput{
+		Err:           "",
+		BadOrderIndex: big.NewInt(genericBatchAuctionError),
+		Res:           res,
+	}
+}
+
+func getValidateBatchAuctionErrorOutput(err error, badOrderIndex int) ValidateBatchAuctionAndDetermineClearingPriceOutput {
+	// same as getValidateOrdersAndDetermineFillPriceErrorOutput, the res has to be packable
+	emptyRes := IOrderHandlerBatchAuctionValidationRes{
+		Instructions:  []IClearingHouseInstruction{},
+		OrderTypes:    []uint8{},
+		EncodedOrders: [][]byte{},
+		FillAmounts:   []*big.Int{},
+		ClearingPrice: big.NewInt(0),
+	}
+	return ValidateBatchAuctionAndDetermineClearingPriceOutput{Err: err.Error(), BadOrderIndex: big.NewInt(int64(badOrderIndex)), Res: emptyRes}
+}
@@ -0,0 +1,221 @@
+package jurorv2
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ava-labs/subnet-evm/core/state"
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ava-labs/subnet-evm/precompile/contract"
+	b "github.com/ava-labs/subnet-evm/precompile/contracts/bibliophile"
+	"github.com/ava-labs/subnet-evm/precompile/testutils"
+	"github.com/ava-labs/subnet-evm/vmerrs"
+	"github.com/ethereum/go-ethereum/common"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateBatchAuctionAndDetermineClearingPrice(t *testing.T) {
+	trader := common.HexToAddress("0x3C44CdDdB6a900fa2b585dd299e03d12FA4293BC")
+	market := common.Address{101}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	limitOrder := func(baseAssetQuantity, price, salt int64) *hu.LimitOrder {
+		return &hu.LimitOrder{
+			BaseOrder: hu.BaseOrder{
+				AmmIndex:          big.NewInt(0),
+				Trader:            trader,
+				BaseAssetQuantity: big.NewInt(baseAssetQuantity),
+				Price:             big.NewInt(price),
+				Salt:              big.NewInt(salt),
+			},
+		}
+	}
+	// the long orders cross the short order at 95, and the second long order is partially filled already
+	orders := []*hu.LimitOrder{limitOrder(10, 110, 1), limitOrder(5, 100, 2), limitOrder(-12, 95, 3)}
+	filledAmounts := []int64{0, 3, 0}
+	data := make([][]byte, len(orders))
+	for i, order := range orders {
+		var err error
+		data[i], err = order.EncodeToABI()
+		assert.Nil(t, err)
+	}
+
+	newMockBibliophile := func(mode hu.MatchingMode, lastAuctionBlock int64, stpMode hu.SelfTradePreventionMode) *b.MockBibliophileClient {
+		mockBibliophile := b.NewMockBibliophileClient(ctrl)
+		for i, order := range orders {
+			orderHash, _ := order.Hash()
+			mockBibliophile.EXPECT().GetOrderFilledAmount(orderHash).Return(big.NewInt(filledAmounts[i])).AnyTimes()
+			mockBibliophile.EXPECT().GetOrderStatus(orderHash).Return(int64(1)).AnyTimes() // placed
+			mockBibliophile.EXPECT().GetBlockPlaced(orderHash).Return(big.NewInt(int64(10 + i))).AnyTimes()
+		}
+		mockBibliophile.EXPECT().GetMarketAddressFromMarketID(int64(0)).Return(market).AnyTimes()
+		mockBibliophile.EXPECT().GetMatchingMode(int64(0)).Return(mode).AnyTimes()
+		mockBibliophile.EXPECT().GetLastBatchAuctionBlock(int64(0)).Return(big.NewInt(lastAuctionBlock)).AnyTimes()
+		mockBibliophile.EXPECT().GetSelfTradePreventionMode(int64(0)).Return(stpMode).AnyTimes()
+		mockBibliophile.EXPECT().GetBlockNumber().Return(big.NewInt(20)).AnyTimes()
+		mockBibliophile.EXPECT().GetMinSizeRequirement(int64(0)).Return(big.NewInt(1)).AnyTimes()
+		mockBibliophile.EXPECT().GetUpperAndLowerBoundForMarket(int64(0)).Return(big.NewInt(120), big.NewInt(80)).AnyTimes()
+		mockBibliophile.EXPECT().GetUnderlyingPrice(int64(0)).Return(big.NewInt(97)).AnyTimes()
+		mockBibliophile.EXPECT().GetPriceMultiplier(market).Return(big.NewInt(1)).AnyTimes()
+		return mockBibliophile
+	}
+	fillAmounts := func(fills ...int64) []*big.Int {
+		amounts := make([]*big.Int, len(fills))
+		for i, fill := range fills {
+			amounts[i] = big.NewInt(fill)
+		}
+		return amounts
+	}
+
+	t.Run("success", func(t *testing.T) {
+		output := ValidateBatchAuctionAndDetermineClearingPrice(newMockBibliophile(hu.BatchAuction, 19, hu.NoSelfTradePrevention), &ValidateBatchAuctionAndDetermineClearingPriceInput{
+			Data:        data,
+			FillAmounts: fillAmounts(10, 2, -12),
+		})
+		assert.Equal(t, "", output.Err)
+		assert.Equal(t, big.NewInt(-1), output.BadOrderIndex)
+		// between the marginal prices 95 and 100, at the oracle price
+		assert.Equal(t, big.NewInt(97), output.Res.ClearingPrice)
+		assert.Equal(t, fillAmounts(10, 2, -12), output.Res.FillAmounts)
+		for i, order := range orders {
+			orderHash, _ := order.Hash()
+			assert.Equal(t, IClearingHouseInstruction{AmmIndex: big.NewInt(0), Trader: trader, OrderHash: orderHash, Mode: uint8(Taker)}, output.Res.Instructions[i])
+		}
+
+		_, err := PackValidateBatchAuctionAndDetermineClearingPriceOutput(output)
+		assert.Nil(t, err)
+	})
+
+	t.Run("fills that don't match the auction", func(t *testing.T) {
+		output := ValidateBatchAuctionAndDetermineClearingPrice(newMockBibliophile(hu.BatchAuction, 19, hu.NoSelfTradePrevention), &ValidateBatchAuctionAndDetermineClearingPriceInput{
+			Data:        data,
+			FillAmounts: fillAmounts(9, 2, -11),
+		})
+		assert.Equal(t, ErrBatchAuctionFillMismatch.Error(), output.Err)
+		assert.Equal(t, big.NewInt(0), output.BadOrderIndex)
+
+		_, err := PackValidateBatchAuctionAndDetermineClearingPriceOutput(output)
+		assert.Nil(t, err)
+	})
+
+	t.Run("unbalanced fills", func(t *testing.T) {
+		output := ValidateBatchAuctionAndDetermineClearingPrice(newMockBibliophile(hu.BatchAuction, 19, hu.NoSelfTradePrevention), &ValidateBatchAuctionAndDetermineClearingPriceInput{
+			Data:        data,
+			FillAmounts: fillAmounts(10, 2, -10),
+		})
+		assert.Equal(t, ErrUnbalancedFills.Error(), output.Err)
+		assert.Equal(t, big.NewInt(-1), output.BadOrderIndex)
+	})
+
+	t.Run("overfill", func(t *testing.T) {
+		output := ValidateBatchAuctionAndDetermineClearingPrice(newMockBibliophile(hu.BatchAuction, 19, hu.NoSelfTradePrevention), &ValidateBatchAuctionAndDetermineClearingPriceInput{
+			Data:        data,
+			FillAmounts: fillAmounts(9, 3, -12),
+		})
+		assert.Equal(t, ErrOverFill.Error(), output.Err)
+		assert.Equal(t, big.NewInt(1), output.BadOrderIndex)
+	})
+
+	t.Run("duplicate order", func(t *testing.T) {
+		output := ValidateBatchAuctionAndDetermineClearingPrice(newMockBibliophile(hu.BatchAuction, 19, hu.NoSelfTradePrevention), &ValidateBatchAuctionAndDetermineClearingPriceInput{
+			Data:        [][]byte{data[0], data[0], data[2]},
+			FillAmounts: fillAmounts(5, 5, -10),
+		})
+		assert.Equal(t, ErrDuplicateOrder.Error(), output.Err)
+		assert.Equal(t, big.NewInt(1), output.BadOrderIndex)
+	})
+
+	t.Run("market doesn't match in batch auctions", func(t *testing.T) {
+		output := ValidateBatchAuctionAndDetermineClearingPrice(newMockBibliophile(hu.FIFO, 19, hu.NoSelfTradePrevention), &ValidateBatchAuctionAndDetermineClearingPriceInput{
+			Data:        data,
+			FillAmounts: fillAmounts(10, 2, -12),
+		})
+		assert.Equal(t, ErrNotBatchAuctionMarket.Error(), output.Err)
+	})
+
+	t.Run("second auction of the market in a block", func(t *testing.T) {
+		output := ValidateBatchAuctionAndDetermineClearingPrice(newMockBibliophile(hu.BatchAuction, 20, hu.NoSelfTradePrevention), &ValidateBatchAuctionAndDetermineClearingPriceInput{
+			Data:        data,
+			FillAmounts: fillAmounts(10, 2, -12),
+		})
+		assert.Equal(t, ErrBatchAuctionInBlock.Error(), output.Err)
+		assert.Equal(t, big.NewInt(-1), output.BadOrderIndex)
+	})
+
+	t.Run("self trade", func(t *testing.T) {
+		// all the orders are of the same trader
+		output := ValidateBatchAuctionAndDetermineClearingPrice(newMockBibliophile(hu.BatchAuction, 19, hu.CancelNewest), &ValidateBatchAuctionAndDetermineClearingPriceInput{
+			Data:        data,
+			FillAmounts: fillAmounts(10, 2, -12),
+		})
+		assert.Equal(t, ErrSelfTrade.Error(), output.Err)
+		assert.Equal(t, big.NewInt(2), output.BadOrderIndex)
+	})
+
+	t.Run("pairwise fills are rejected in a batch auction market", func(t *testing.T) {
+		output := ValidateOrdersAndDetermineFillPrice(newMockBibliophile(hu.BatchAuction, 19, hu.NoSelfTradePrevention), &ValidateOrdersAndDetermineFillPriceInput{
+			Data:       [2][]byte{data[0], data[2]},
+			FillAmount: big.NewInt(10),
+		})
+		assert.Equal(t, ErrBatchAuctionMarket.Error(), output.Err)
+		assert.Equal(t, uint8(Generic), output.Element)
+	})
+}
+
+func TestValidateBatchAuctionAndDetermineClearingPriceRun(t *testing.T) {
+	defer func(activationTime uint64) { hu.BatchAuctionActivationTime = activationTime }(hu.BatchAuctionActivationTime)
+	hu.BatchAuctionActivationTime = 1688994800
+	input, err := PackValidateBatchAuctionAndDetermineClearingPrice(ValidateBatchAuctionAndDetermineClearingPriceInput{
+		Data:        [][]byte{{1}, {2}, {3}},
+		FillAmounts: []*big.Int{big.NewInt(1), big.NewInt(1), big.NewInt(-2)},
+	})
+	require.NoError(t, err)
+	withTimestamp := func(timestamp uint64) func(*contract.MockBlockContext) {
+		return func(blockContext *contract.MockBlockContext) {
+			blockContext.EXPECT().Timestamp().Return(timestamp).AnyTimes()
+		}
+	}
+
+	tests := map[string]testutils.PrecompileTest{
+		"validateBatchAuctionAndDetermineClearingPrice is charged per order": {
+			Caller:            common.HexToAddress(b.ORDERBOOK_GENESIS_ADDRESS),
+			Input:             input,
+			SetupBlockContext: withTimestamp(1688994800),
+			SuppliedGas:       ValidateBatchAuctionAndDetermineClearingPriceGasCost + 3*ValidateBatchAuctionAndDetermineClearingPriceGasCostPerOrder - 1,
+			ReadOnly:          false,
+			ExpectedErr:       vmerrs.ErrOutOfGas.Error(),
+		},
+		"validateBatchAuctionAndDetermineClearingPrice in a static call should fail": {
+			Caller:            common.Address{1},
+			Input:             input,
+			SetupBlockContext: withTimestamp(1688994800),
+			SuppliedGas:       ValidateBatchAuctionAndDetermineClearingPriceGasCost,
+			ReadOnly:          true,
+			ExpectedErr:       vmerrs.ErrWriteProtection.Error(),
+		},
+		"validateBatchAuctionAndDetermineClearingPrice from a caller other than the order book should fail": {
+			Caller:            common.Address{1},
+			Input:             input,
+			SetupBlockContext: withTimestamp(1688994800),
+			SuppliedGas:       ValidateBatchAuctionAndDetermineClearingPriceGasCost,
+			ReadOnly:          false,
+			ExpectedErr:       ErrNotOrderBook.Error(),
+		},
+		"validateBatchAuctionAndDetermineClearingPrice before activation should fail": {
+			Caller:            common.Address{1},
+			Input:             input,
+			SetupBlockContext: withTimestamp(1688994799),
+			SuppliedGas:       0,
+			ReadOnly:          false,
+			ExpectedErr:       hu.ErrBatchAuctionNotActive.Error(),
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.Run(t, Module, state.NewTestStateDB(t))
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package jurorv2
+
+import (
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	b "github.com/ava-labs/subnet-evm/precompile/contracts/bibliophile"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// ValidateCancelSignedOrders returns the hashes of the signed orders cancelled by the hu.CancelOrder messages in the input,
+// which cancelSignedOrders records in the juror storage. validateExecuteSignedOrder rejects the recorded orders.
+//
+// Anyone can submit the messages, since each one is signed by the trader of the order or its trading authority. The validators submit
+// the ones they receive over gossip. A message that doesn't decode or isn't signed by the trader or its trading authority is skipped,
+// so that one bad message doesn't revert the others.
+func ValidateCancelSignedOrders(bibliophile b.BibliophileClient, inputStruct *CancelSignedOrdersInput) []common.Hash {
+	setSignedOrdersDomain(bibliophile)
+
+	orderHashes := []common.Hash{}
+	for _, data := range inputStruct.Data {
+		cancelOrder, err := hu.DecodeCancelOrder(data)
+		if err != nil {
+			log.Info("ValidateCancelSignedOrders: failed to decode", "err", err)
+			continue
+		}
+		orderHash, signer, err := hu.ValidateCancelOrder(cancelOrder)
+		if err != nil {
+			log.Info("ValidateCancelSignedOrders: invalid message", "err", err)
+			continue
+		}
+		trader := cancelOrder.Order.Trader
+		if trader != signer && !bibliophile.IsTradingAuthority(trader, signer) {
+			log.Info("ValidateCancelSignedOrders: no trading authority", "trader", trader, "signer", signer, "orderHash", orderHash)
+			continue
+		}
+		orderHashes = append(orderHashes, orderHash)
+	}
+	return orderHashes
+}
+
+// ValidateCancelAllSignedOrders returns the hu.CancelAllOrders messages in the input that cancelAllSignedOrders records in the juror storage.
+// validateExecuteSignedOrder rejects the signed orders of the trader in the market with a salt <= the recorded nonce.
+// Like in ValidateCancelSignedOrders, a message that doesn't decode, has an invalid market or nonce or isn't signed by the trader or its
+// trading authority is skipped.
+func ValidateCancelAllSignedOrders(bibliophile b.BibliophileClient, inputStruct *CancelAllSignedOrdersInput) []*hu.CancelAllOrders {
+	setSignedOrdersDomain(bibliophile)
+
+	activeMarketsCount := bibliophile.GetActiveMarketsCount()
+	cancels := []*hu.CancelAllOrders{}
+	for _, data := range inputStruct.Data {
+		cancelAll, err := hu.DecodeCancelAllOrders(data)
+		if err != nil {
+			log.Info("ValidateCancelAllSignedOrders: failed to decode", "err", err)
+			continue
+		}
+		signer, err := hu.ValidateCancelAllOrders(cancelAll, activeMarketsCount)
+		if err != nil {
+			log.Info("ValidateCancelAllSignedOrders: invalid message", "err", err)
+			continue
+		}
+		if cancelAll.Trader != signer && !bibliophile.IsTradingAuthority(cancelAll.Trader, signer) {
+			log.Info("ValidateCancelAllSignedOrders: no trading authority", "trader", cancelAll.Trader, "signer", signer)
+			continue
+		}
+		cancels = append(cancels, cancelAll)
+	}
+	return cancels
+}
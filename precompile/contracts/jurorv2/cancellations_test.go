@@ -0,0 +1,264 @@
+package jurorv2
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/ava-labs/subnet-evm/core/state"
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ava-labs/subnet-evm/precompile/contract"
+	b "github.com/ava-labs/subnet-evm/precompile/contracts/bibliophile"
+	"github.com/ava-labs/subnet-evm/precompile/testutils"
+	"github.com/ava-labs/subnet-evm/vmerrs"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCancelOrder(t testing.TB, key *ecdsa.PrivateKey, orderTrader common.Address, salt int64) ([]byte, common.Hash) {
+	c := &hu.CancelOrder{
+		Order: &hu.SignedOrder{
+			LimitOrder: hu.LimitOrder{
+				BaseOrder: hu.BaseOrder{
+					AmmIndex:          big.NewInt(0),
+					Trader:            orderTrader,
+					BaseAssetQuantity: big.NewInt(5000000000000000000),
+					Price:             big.NewInt(1000000000),
+					Salt:              big.NewInt(salt),
+				},
+				PostOnly: true,
+			},
+			OrderType: uint8(hu.Signed),
+			ExpireAt:  big.NewInt(1688994854),
+			Sig:       []byte{1},
+		},
+	}
+	hash, err := c.Hash()
+	require.NoError(t, err)
+	c.Sig, err = crypto.Sign(hash.Bytes(), key)
+	require.NoError(t, err)
+	c.Sig[crypto.RecoveryIDOffset] += 27
+	data, err := c.EncodeToABI()
+	require.NoError(t, err)
+	orderHash, err := c.Order.Hash()
+	require.NoError(t, err)
+	return data, orderHash
+}
+
+func TestValidateCancelSignedOrders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	hu.SetChainIdAndVerifyingSignedOrdersContract(321123, "0x4c5859f0F772848b2D91F1D83E2Fe57935348029")
+	key, err := crypto.GenerateKey()
+	assert.Nil(t, err)
+	trader := crypto.PubkeyToAddress(key.PublicKey)
+	newCancelOrder := func(orderTrader common.Address, salt int64) ([]byte, common.Hash) {
+		return newTestCancelOrder(t, key, orderTrader, salt)
+	}
+
+	t.Run("messages signed by the trader or its trading authority are recorded", func(t *testing.T) {
+		mockBibliophile := b.NewMockBibliophileClient(ctrl)
+		otherTrader := common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+		mockBibliophile.EXPECT().IsTradingAuthority(otherTrader, trader).Return(true).Times(1)
+		data1, orderHash1 := newCancelOrder(trader, 1)
+		data2, orderHash2 := newCancelOrder(otherTrader, 2)
+		orderHashes := ValidateCancelSignedOrders(mockBibliophile, &CancelSignedOrdersInput{Data: [][]byte{data1, data2}})
+		assert.Equal(t, []common.Hash{orderHash1, orderHash2}, orderHashes)
+	})
+
+	t.Run("messages for orders of other traders and bad messages are skipped", func(t *testing.T) {
+		mockBibliophile := b.NewMockBibliophileClient(ctrl)
+		otherTrader := common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+		mockBibliophile.EXPECT().IsTradingAuthority(otherTrader, trader).Return(false).Times(1)
+		data1, _ := newCancelOrder(otherTrader, 1)
+		data2, orderHash2 := newCancelOrder(trader, 2)
+		orderHashes := ValidateCancelSignedOrders(mockBibliophile, &CancelSignedOrdersInput{Data: [][]byte{data1, {1, 2, 3}, data2}})
+		assert.Equal(t, []common.Hash{orderHash2}, orderHashes)
+	})
+}
+
+func TestCancelSignedOrdersRun(t *testing.T) {
+	defer func(activationTime uint64) { hu.SignedOrderCancellationsActivationTime = activationTime }(hu.SignedOrderCancellationsActivationTime)
+	hu.SignedOrderCancellationsActivationTime = 1688994800
+	hu.SetChainIdAndVerifyingSignedOrdersContract(321123, "0x4c5859f0F772848b2D91F1D83E2Fe57935348029")
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	data, orderHash := newTestCancelOrder(t, key, crypto.PubkeyToAddress(key.PublicKey), 1)
+	input, err := PackCancelSignedOrders(CancelSignedOrdersInput{Data: [][]byte{data}})
+	require.NoError(t, err)
+	withTimestamp := func(timestamp uint64) func(*contract.MockBlockContext) {
+		return func(blockContext *contract.MockBlockContext) {
+			blockContext.EXPECT().Timestamp().Return(timestamp).AnyTimes()
+		}
+	}
+
+	tests := map[string]testutils.PrecompileTest{
+		"cancelSignedOrders records the cancelled orders": {
+			Caller:            common.Address{1},
+			Input:             input,
+			SetupBlockContext: withTimestamp(1688994800),
+			SuppliedGas:       CancelSignedOrdersGasCost + CancelSignedOrdersGasCostPerOrder,
+			ReadOnly:          false,
+			ExpectedRes:       []byte{},
+			AfterHook: func(t testing.TB, stateDB contract.StateDB) {
+				require.True(t, b.IsSignedOrderCancelled(stateDB, orderHash))
+			},
+		},
+		"cancelSignedOrders is charged per order": {
+			Caller:            common.Address{1},
+			Input:             input,
+			SetupBlockContext: withTimestamp(1688994800),
+			SuppliedGas:       CancelSignedOrdersGasCost + CancelSignedOrdersGasCostPerOrder - 1,
+			ReadOnly:          false,
+			ExpectedErr:       vmerrs.ErrOutOfGas.Error(),
+		},
+		"cancelSignedOrders in a static call should fail": {
+			Caller:            common.Address{1},
+			Input:             input,
+			SetupBlockContext: withTimestamp(1688994800),
+			SuppliedGas:       CancelSignedOrdersGasCost,
+			ReadOnly:          true,
+			ExpectedErr:       vmerrs.ErrWriteProtection.Error(),
+		},
+		"cancelSignedOrders before activation should fail": {
+			Caller:            common.Address{1},
+			Input:             input,
+			SetupBlockContext: withTimestamp(1688994799),
+			SuppliedGas:       0,
+			ReadOnly:          false,
+			ExpectedErr:       hu.ErrCancellationsNotActive.Error(),
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.Run(t, Module, state.NewTestStateDB(t))
+		})
+	}
+}
+
+func newTestCancelAllOrders(t testing.TB, key *ecdsa.PrivateKey, trader common.Address, ammIndex *big.Int, nonce int64) []byte {
+	c := &hu.CancelAllOrders{Trader: trader, AmmIndex: ammIndex, Nonce: big.NewInt(nonce)}
+	hash, err := c.Hash()
+	require.NoError(t, err)
+	c.Sig, err = crypto.Sign(hash.Bytes(), key)
+	require.NoError(t, err)
+	c.Sig[crypto.RecoveryIDOffset] += 27
+	data, err := c.EncodeToABI()
+	require.NoError(t, err)
+	return data
+}
+
+func TestValidateCancelAllSignedOrders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	hu.SetChainIdAndVerifyingSignedOrdersContract(321123, "0x4c5859f0F772848b2D91F1D83E2Fe57935348029")
+	key, err := crypto.GenerateKey()
+	assert.Nil(t, err)
+	trader := crypto.PubkeyToAddress(key.PublicKey)
+	otherTrader := common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+
+	t.Run("messages signed by the trader or its trading authority are recorded", func(t *testing.T) {
+		mockBibliophile := b.NewMockBibliophileClient(ctrl)
+		mockBibliophile.EXPECT().GetActiveMarketsCount().Return(int64(2)).Times(1)
+		mockBibliophile.EXPECT().IsTradingAuthority(otherTrader, trader).Return(true).Times(1)
+		data1 := newTestCancelAllOrders(t, key, trader, big.NewInt(1), 100)
+		data2 := newTestCancelAllOrders(t, key, otherTrader, hu.AllMarkets, 200)
+		cancels := ValidateCancelAllSignedOrders(mockBibliophile, &CancelAllSignedOrdersInput{Data: [][]byte{data1, data2}})
+		require.Equal(t, 2, len(cancels))
+		assert.Equal(t, trader, cancels[0].Trader)
+		assert.Equal(t, big.NewInt(1), cancels[0].AmmIndex)
+		assert.Equal(t, big.NewInt(100), cancels[0].Nonce)
+		assert.Equal(t, otherTrader, cancels[1].Trader)
+		assert.True(t, cancels[1].IsAllMarkets())
+	})
+
+	t.Run("messages of other traders, for inactive markets and bad messages are skipped", func(t *testing.T) {
+		mockBibliophile := b.NewMockBibliophileClient(ctrl)
+		mockBibliophile.EXPECT().GetActiveMarketsCount().Return(int64(2)).Times(1)
+		mockBibliophile.EXPECT().IsTradingAuthority(otherTrader, trader).Return(false).Times(1)
+		data1 := newTestCancelAllOrders(t, key, otherTrader, big.NewInt(0), 100)
+		data2 := newTestCancelAllOrders(t, key, trader, big.NewInt(2), 100)
+		data3 := newTestCancelAllOrders(t, key, trader, big.NewInt(0), 100)
+		cancels := ValidateCancelAllSignedOrders(mockBibliophile, &CancelAllSignedOrdersInput{Data: [][]byte{data1, data2, {1, 2, 3}, data3}})
+		require.Equal(t, 1, len(cancels))
+		assert.Equal(t, trader, cancels[0].Trader)
+	})
+}
+
+func TestCancelAllSignedOrdersRun(t *testing.T) {
+	defer func(activationTime uint64) { hu.SignedOrderCancellationsActivationTime = activationTime }(hu.SignedOrderCancellationsActivationTime)
+	hu.SignedOrderCancellationsActivationTime = 1688994800
+	hu.SetChainIdAndVerifyingSignedOrdersContract(321123, "0x4c5859f0F772848b2D91F1D83E2Fe57935348029")
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	trader := crypto.PubkeyToAddress(key.PublicKey)
+	data := newTestCancelAllOrders(t, key, trader, hu.AllMarkets, 100)
+	input, err := PackCancelAllSignedOrders(CancelAllSignedOrdersInput{Data: [][]byte{data}})
+	require.NoError(t, err)
+	withTimestamp := func(timestamp uint64) func(*contract.MockBlockContext) {
+		return func(blockContext *contract.MockBlockContext) {
+			blockContext.EXPECT().Timestamp().Return(timestamp).AnyTimes()
+		}
+	}
+
+	tests := map[string]testutils.PrecompileTest{
+		"cancelAllSignedOrders records the nonce": {
+			Caller:            common.Address{1},
+			Input:             input,
+			SetupBlockContext: withTimestamp(1688994800),
+			SuppliedGas:       CancelAllSignedOrdersGasCost + CancelAllSignedOrdersGasCostPerMessage,
+			ReadOnly:          false,
+			ExpectedRes:       []byte{},
+			AfterHook: func(t testing.TB, stateDB contract.StateDB) {
+				require.Equal(t, big.NewInt(100), b.GetCancelNonce(stateDB, trader, hu.AllMarkets))
+				require.Equal(t, big.NewInt(100), b.GetCancelNonce(stateDB, trader, big.NewInt(3)))
+			},
+		},
+		"cancelAllSignedOrders doesn't decrease the nonce": {
+			Caller:            common.Address{1},
+			Input:             input,
+			SetupBlockContext: withTimestamp(1688994800),
+			BeforeHook: func(t testing.TB, stateDB contract.StateDB) {
+				b.SetCancelNonce(stateDB, trader, hu.AllMarkets, big.NewInt(150))
+			},
+			SuppliedGas: CancelAllSignedOrdersGasCost + CancelAllSignedOrdersGasCostPerMessage,
+			ReadOnly:    false,
+			ExpectedRes: []byte{},
+			AfterHook: func(t testing.TB, stateDB contract.StateDB) {
+				require.Equal(t, big.NewInt(150), b.GetCancelNonce(stateDB, trader, hu.AllMarkets))
+			},
+		},
+		"cancelAllSignedOrders is charged per message": {
+			Caller:            common.Address{1},
+			Input:             input,
+			SetupBlockContext: withTimestamp(1688994800),
+			SuppliedGas:       CancelAllSignedOrdersGasCost + CancelAllSignedOrdersGasCostPerMessage - 1,
+			ReadOnly:          false,
+			ExpectedErr:       vmerrs.ErrOutOfGas.Error(),
+		},
+		"cancelAllSignedOrders in a static call should fail": {
+			Caller:            common.Address{1},
+			Input:             input,
+			SetupBlockContext: withTimestamp(1688994800),
+			SuppliedGas:       CancelAllSignedOrdersGasCost,
+			ReadOnly:          true,
+			ExpectedErr:       vmerrs.ErrWriteProtection.Error(),
+		},
+		"cancelAllSignedOrders before activation should fail": {
+			Caller:            common.Address{1},
+			Input:             input,
+			SetupBlockContext: withTimestamp(1688994799),
+			SuppliedGas:       0,
+			ReadOnly:          false,
+			ExpectedErr:       hu.ErrCancellationsNotActive.Error(),
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.Run(t, Module, state.NewTestStateDB(t))
+		})
+	}
+}
@@ -5,8 +5,10 @@
 package jurorv2
 
 import (
+	"fmt"
 	"math/big"
 
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
 	"github.com/ava-labs/subnet-evm/precompile/precompileconfig"
 )
 
@@ -17,7 +19,15 @@ var _ precompileconfig.Config = &Config{}
 type Config struct {
 	precompileconfig.Upgrade
 	// CUSTOM CODE STARTS HERE
-	// Add your own custom fields for Config here
+	// MarketConfigs is keyed by market id, the config of a market is written to the juror storage when the precompile is activated
+	MarketConfigs map[int64]MarketConfig `json:"marketConfigs,omitempty"`
+}
+
+// MarketConfig is the config of a market that is not stored in the market contracts. Markets without one use FIFO matching
+// and allow self trades
+type MarketConfig struct {
+	MatchingMode            hu.MatchingMode            `json:"matchingMode"`
+	SelfTradePreventionMode hu.SelfTradePreventionMode `json:"selfTradePreventionMode"`
 }
 
 // NewConfig returns a config for a network upgrade at [blockTimestamp] that enables
@@ -48,8 +58,17 @@ func (*Config) Key() string { return ConfigKey }
 // Verify tries to verify Config and returns an error accordingly.
 func (c *Config) Verify(precompileconfig.ChainConfig) error {
 	// CUSTOM CODE STARTS HERE
-	// Add your own custom verify code for Config here
-	// and return an error accordingly
+	for marketID, marketConfig := range c.MarketConfigs {
+		if marketID < 0 {
+			return fmt.Errorf("invalid market id %d", marketID)
+		}
+		if marketConfig.MatchingMode > hu.BatchAuction {
+			return fmt.Errorf("invalid matching mode %d for market %d", marketConfig.MatchingMode, marketID)
+		}
+		if marketConfig.SelfTradePreventionMode > hu.DecrementAndCancel {
+			return fmt.Errorf("invalid self trade prevention mode %d for market %d", marketConfig.SelfTradePreventionMode, marketID)
+		}
+	}
 	return nil
 }
 
@@ -63,6 +82,10 @@ func (c *Config) Equal(s precompileconfig.Config) bool {
 	// CUSTOM CODE STARTS HERE
 	// modify this boolean accordingly with your custom Config, to check if [other] and the current [c] are equal
 	// if Config contains only Upgrade you can skip modifying it.
-	equals := c.Upgrade.Equal(&other.Upgrade)
+	equals := c.Upgrade.Equal(&other.Upgrade) && len(c.MarketConfigs) == len(other.MarketConfigs)
+	for marketID, marketConfig := range c.MarketConfigs {
+		otherMarketConfig, ok := other.MarketConfigs[marketID]
+		equals = equals && ok && marketConfig == otherMarketConfig
+	}
 	return equals
 }
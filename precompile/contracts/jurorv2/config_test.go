@@ -8,8 +8,14 @@ import (
 	"math/big"
 	"testing"
 
+	"github.com/ava-labs/subnet-evm/core/rawdb"
+	"github.com/ava-labs/subnet-evm/core/state"
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
+	"github.com/ava-labs/subnet-evm/precompile/contracts/bibliophile"
 	"github.com/ava-labs/subnet-evm/precompile/precompileconfig"
 	"github.com/ava-labs/subnet-evm/precompile/testutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 )
 
@@ -21,11 +27,22 @@ func TestVerify(t *testing.T) {
 			ExpectedError: "",
 		},
 		// CUSTOM CODE STARTS HERE
-		// Add your own Verify tests here, e.g.:
-		// "your custom test name": {
-		// 	Config: NewConfig(big.NewInt(3),),
-		// 	ExpectedError: ErrYourCustomError.Error(),
-		// },
+		"valid market configs": {
+			Config:        newConfigWithMarketConfigs(3, map[int64]MarketConfig{0: {MatchingMode: hu.ProRata}, 1: {MatchingMode: hu.TopOfBookPriority}}),
+			ExpectedError: "",
+		},
+		"invalid matching mode": {
+			Config:        newConfigWithMarketConfigs(3, map[int64]MarketConfig{1: {MatchingMode: hu.MatchingMode(7)}}),
+			ExpectedError: "invalid matching mode 7 for market 1",
+		},
+		"invalid self trade prevention mode": {
+			Config:        newConfigWithMarketConfigs(3, map[int64]MarketConfig{0: {SelfTradePreventionMode: hu.SelfTradePreventionMode(9)}}),
+			ExpectedError: "invalid self trade prevention mode 9 for market 0",
+		},
+		"invalid market id": {
+			Config:        newConfigWithMarketConfigs(3, map[int64]MarketConfig{-1: {MatchingMode: hu.ProRata}}),
+			ExpectedError: "invalid market id -1",
+		},
 	}
 	// Run verify tests.
 	testutils.RunVerifyTests(t, tests)
@@ -55,8 +72,52 @@ func TestEqual(t *testing.T) {
 			Expected: true,
 		},
 		// CUSTOM CODE STARTS HERE
-		// Add your own Equal tests here
+		"different market configs": {
+			Config:   newConfigWithMarketConfigs(3, map[int64]MarketConfig{0: {MatchingMode: hu.ProRata}}),
+			Other:    newConfigWithMarketConfigs(3, map[int64]MarketConfig{0: {MatchingMode: hu.TopOfBookPriority}}),
+			Expected: false,
+		},
+		"market config and no market config": {
+			Config:   newConfigWithMarketConfigs(3, map[int64]MarketConfig{0: {MatchingMode: hu.FIFO}}),
+			Other:    NewConfig(big.NewInt(3)),
+			Expected: false,
+		},
+		"same market configs": {
+			Config:   newConfigWithMarketConfigs(3, map[int64]MarketConfig{0: {MatchingMode: hu.ProRata}}),
+			Other:    newConfigWithMarketConfigs(3, map[int64]MarketConfig{0: {MatchingMode: hu.ProRata}}),
+			Expected: true,
+		},
 	}
 	// Run equal tests.
 	testutils.RunEqualTests(t, tests)
 }
+
+func TestConfigureWritesMarketConfigs(t *testing.T) {
+	stateDB, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	require.NoError(t, err)
+
+	config := newConfigWithMarketConfigs(3, map[int64]MarketConfig{1: {MatchingMode: hu.ProRata, SelfTradePreventionMode: hu.CancelBoth}})
+	require.NoError(t, Module.Configure(nil, config, stateDB, nil))
+	require.Equal(t, hu.FIFO, bibliophile.GetMatchingMode(stateDB, 0, 0))
+	require.Equal(t, hu.NoSelfTradePrevention, bibliophile.GetSelfTradePreventionMode(stateDB, 0))
+	require.Equal(t, hu.ProRata, bibliophile.GetMatchingMode(stateDB, 1, 0))
+	require.Equal(t, hu.CancelBoth, bibliophile.GetSelfTradePreventionMode(stateDB, 1))
+}
+
+func TestGetMatchingModeBeforeBatchAuctionActivation(t *testing.T) {
+	defer func(activationTime uint64) { hu.BatchAuctionActivationTime = activationTime }(hu.BatchAuctionActivationTime)
+	hu.BatchAuctionActivationTime = 1688994800
+	stateDB, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	require.NoError(t, err)
+
+	config := newConfigWithMarketConfigs(3, map[int64]MarketConfig{0: {MatchingMode: hu.BatchAuction}})
+	require.NoError(t, Module.Configure(nil, config, stateDB, nil))
+	require.Equal(t, hu.FIFO, bibliophile.GetMatchingMode(stateDB, 0, 1688994799))
+	require.Equal(t, hu.BatchAuction, bibliophile.GetMatchingMode(stateDB, 0, 1688994800))
+}
+
+func newConfigWithMarketConfigs(blockTimestamp int64, marketConfigs map[int64]MarketConfig) *Config {
+	config := NewConfig(big.NewInt(blockTimestamp))
+	config.MarketConfigs = marketConfigs
+	return config
+}
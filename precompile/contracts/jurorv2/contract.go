@@ -10,8 +10,10 @@ import (
 	"math/big"
 
 	"github.com/ava-labs/subnet-evm/accounts/abi"
+	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
 	"github.com/ava-labs/subnet-evm/precompile/contract"
 	"github.com/ava-labs/subnet-evm/precompile/contracts/bibliophile"
+	"github.com/ava-labs/subnet-evm/vmerrs"
 
 	_ "embed"
 
@@ -23,11 +25,25 @@ const (
 	// You should set a gas cost for each function in your contract.
 	// Generally, you should not set gas costs very low as this may cause your network to be vulnerable to DoS attacks.
 	// There are some predefined gas costs in contract/utils.go that you can use.
+	CancelAllSignedOrdersGasCost                         uint64 = 69
+	CancelSignedOrdersGasCost                            uint64 = 69
 	GetNotionalPositionAndMarginGasCost                  uint64 = 69
+	ValidateBatchAuctionAndDetermineClearingPriceGasCost uint64 = 69
 	ValidateLiquidationOrderAndDetermineFillPriceGasCost uint64 = 69
 	ValidateOrdersAndDetermineFillPriceGasCost           uint64 = 69
 )
 
+// CancelAllSignedOrdersGasCostPerMessage is charged for every message passed to cancelAllSignedOrders, for recovering its signer
+// and reading and writing the nonce of the trader
+const CancelAllSignedOrdersGasCostPerMessage uint64 = 2*contract.ReadGasCostPerSlot + 3_000 + contract.WriteGasCostPerSlot
+
+// ValidateBatchAuctionAndDetermineClearingPriceGasCostPerOrder is charged for every order of a batch auction, for validating the order
+// like in a pairwise match, with its signature and storage reads
+const ValidateBatchAuctionAndDetermineClearingPriceGasCostPerOrder uint64 = 10*contract.ReadGasCostPerSlot + 3_000
+
+// CancelSignedOrdersGasCostPerOrder is charged for every message passed to cancelSignedOrders, for recovering its signer and writing the order to storage
+const CancelSignedOrdersGasCostPerOrder uint64 = contract.ReadGasCostPerSlot + 3_000 + contract.WriteGasCostPerSlot
+
 // CUSTOM CODE STARTS HERE
 // Reference imports to suppress errors from unused imports. This code and any unnecessary imports can be removed.
 var (
@@ -67,6 +83,15 @@ type ILimitOrderBookOrder struct {
 	PostOnly          bool
 }
 
+// IOrderHandlerBatchAuctionValidationRes is an auto generated low-level Go binding around an user-defined struct.
+type IOrderHandlerBatchAuctionValidationRes struct {
+	Instructions  []IClearingHouseInstruction
+	OrderTypes    []uint8
+	EncodedOrders [][]byte
+	FillAmounts   []*big.Int
+	ClearingPrice *big.Int
+}
+
 // IOrderHandlerLiquidationMatchingValidationRes is an auto generated low-level Go binding around an user-defined struct.
 type IOrderHandlerLiquidationMatchingValidationRes struct {
 	Instruction  IClearingHouseInstruction
@@ -84,6 +109,14 @@ type IOrderHandlerMatchingValidationRes struct {
 	FillPrice     *big.Int
 }
 
+type CancelAllSignedOrdersInput struct {
+	Data [][]byte
+}
+
+type CancelSignedOrdersInput struct {
+	Data [][]byte
+}
+
 type GetNotionalPositionAndMarginInput struct {
 	Trader                 common.Address
 	IncludeFundingPayments bool
@@ -95,6 +128,17 @@ type GetNotionalPositionAndMarginOutput struct {
 	Margin           *big.Int
 }
 
+type ValidateBatchAuctionAndDetermineClearingPriceInput struct {
+	Data        [][]byte
+	FillAmounts []*big.Int
+}
+
+type ValidateBatchAuctionAndDetermineClearingPriceOutput struct {
+	Err           string
+	BadOrderIndex *big.Int
+	Res           IOrderHandlerBatchAuctionValidationRes
+}
+
 type ValidateLiquidationOrderAndDetermineFillPriceInput struct {
 	Data              []byte
 	LiquidationAmount *big.Int
@@ -117,6 +161,109 @@ type ValidateOrdersAndDetermineFillPriceOutput struct {
 	Res     IOrderHandlerMatchingValidationRes
 }
 
+// UnpackCancelAllSignedOrdersInput attempts to unpack [input] as CancelAllSignedOrdersInput
+// assumes that [input] does not include selector (omits first 4 func signature bytes)
+func UnpackCancelAllSignedOrdersInput(input []byte) (CancelAllSignedOrdersInput, error) {
+	inputStruct := CancelAllSignedOrdersInput{}
+	err := JurorABI.UnpackInputIntoInterface(&inputStruct, "cancelAllSignedOrders", input, true)
+
+	return inputStruct, err
+}
+
+// PackCancelAllSignedOrders packs [inputStruct] of type CancelAllSignedOrdersInput into the appropriate arguments for cancelAllSignedOrders.
+func PackCancelAllSignedOrders(inputStruct CancelAllSignedOrdersInput) ([]byte, error) {
+	return JurorABI.Pack("cancelAllSignedOrders", inputStruct.Data)
+}
+
+func cancelAllSignedOrders(accessibleState contract.AccessibleState, caller common.Address, addr common.Address, input []byte, suppliedGas uint64, readOnly bool) (ret []byte, remainingGas uint64, err error) {
+	// behaves like a function that doesn't exist until it is activated
+	if !hu.IsSignedOrderCancellationsActive(accessibleState.GetBlockContext().Timestamp()) {
+		return nil, suppliedGas, hu.ErrCancellationsNotActive
+	}
+	if remainingGas, err = contract.DeductGas(suppliedGas, CancelAllSignedOrdersGasCost); err != nil {
+		return nil, 0, err
+	}
+	if readOnly {
+		return nil, remainingGas, vmerrs.ErrWriteProtection
+	}
+	// attempts to unpack [input] into the arguments to the CancelAllSignedOrdersInput.
+	// Assumes that [input] does not include selector
+	// You can use unpacked [inputStruct] variable in your code
+	inputStruct, err := UnpackCancelAllSignedOrdersInput(input)
+	if err != nil {
+		return nil, remainingGas, err
+	}
+	if remainingGas, err = contract.DeductGas(remainingGas, CancelAllSignedOrdersGasCostPerMessage*uint64(len(inputStruct.Data))); err != nil {
+		return nil, 0, err
+	}
+
+	// CUSTOM CODE STARTS HERE
+	bibliophileClient := bibliophile.NewBibliophileClient(accessibleState)
+	stateDB := accessibleState.GetStateDB()
+	for _, cancelAll := range ValidateCancelAllSignedOrders(bibliophileClient, &inputStruct) {
+		// the nonce of a market can only increase
+		if cancelAll.Nonce.Cmp(bibliophile.GetCancelNonce(stateDB, cancelAll.Trader, cancelAll.AmmIndex)) > 0 {
+			bibliophile.SetCancelNonce(stateDB, cancelAll.Trader, cancelAll.AmmIndex, cancelAll.Nonce)
+		}
+	}
+
+	// this function does not return an output, leave this one as is
+	packedOutput := []byte{}
+
+	// Return the packed output and the remaining gas
+	return packedOutput, remainingGas, nil
+}
+
+// UnpackCancelSignedOrdersInput attempts to unpack [input] as CancelSignedOrdersInput
+// assumes that [input] does not include selector (omits first 4 func signature bytes)
+func UnpackCancelSignedOrdersInput(input []byte) (CancelSignedOrdersInput, error) {
+	inputStruct := CancelSignedOrdersInput{}
+	err := JurorABI.UnpackInputIntoInterface(&inputStruct, "cancelSignedOrders", input, true)
+
+	return inputStruct, err
+}
+
+// PackCancelSignedOrders packs [inputStruct] of type CancelSignedOrdersInput into the appropriate arguments for cancelSignedOrders.
+func PackCancelSignedOrders(inputStruct CancelSignedOrdersInput) ([]byte, error) {
+	return JurorABI.Pack("cancelSignedOrders", inputStruct.Data)
+}
+
+func cancelSignedOrders(accessibleState contract.AccessibleState, caller common.Address, addr common.Address, input []byte, suppliedGas uint64, readOnly bool) (ret []byte, remainingGas uint64, err error) {
+	// behaves like a function that doesn't exist until it is activated
+	if !hu.IsSignedOrderCancellationsActive(accessibleState.GetBlockContext().Timestamp()) {
+		return nil, suppliedGas, hu.ErrCancellationsNotActive
+	}
+	if remainingGas, err = contract.DeductGas(suppliedGas, CancelSignedOrdersGasCost); err != nil {
+		return nil, 0, err
+	}
+	if readOnly {
+		return nil, remainingGas, vmerrs.ErrWriteProtection
+	}
+	// attempts to unpack [input] into the arguments to the CancelSignedOrdersInput.
+	// Assumes that [input] does not include selector
+	// You can use unpacked [inputStruct] variable in your code
+	inputStruct, err := UnpackCancelSignedOrdersInput(input)
+	if err != nil {
+		return nil, remainingGas, err
+	}
+	if remainingGas, err = contract.DeductGas(remainingGas, CancelSignedOrdersGasCostPerOrder*uint64(len(inputStruct.Data))); err != nil {
+		return nil, 0, err
+	}
+
+	// CUSTOM CODE STARTS HERE
+	bibliophileClient := bibliophile.NewBibliophileClient(accessibleState)
+	stateDB := accessibleState.GetStateDB()
+	for _, orderHash := range ValidateCancelSignedOrders(bibliophileClient, &inputStruct) {
+		bibliophile.SetSignedOrderCancelled(stateDB, orderHash)
+	}
+
+	// this function does not return an output, leave this one as is
+	packedOutput := []byte{}
+
+	// Return the packed output and the remaining gas
+	return packedOutput, remainingGas, nil
+}
+
 // UnpackGetNotionalPositionAndMarginInput attempts to unpack [input] as GetNotionalPositionAndMarginInput
 // assumes that [input] does not include selector (omits first 4 func signature bytes)
 func UnpackGetNotionalPositionAndMarginInput(input []byte) (GetNotionalPositionAndMarginInput, error) {
@@ -164,6 +311,73 @@ func getNotionalPositionAndMargin(accessibleState contract.AccessibleState, call
 	return packedOutput, remainingGas, nil
 }
 
+// UnpackValidateBatchAuctionAndDetermineClearingPriceInput attempts to unpack [input] as ValidateBatchAuctionAndDetermineClearingPriceInput
+// assumes that [input] does not include selector (omits first 4 func signature bytes)
+func UnpackValidateBatchAuctionAndDetermineClearingPriceInput(input []byte) (ValidateBatchAuctionAndDetermineClearingPriceInput, error) {
+	inputStruct := ValidateBatchAuctionAndDetermineClearingPriceInput{}
+	err := JurorABI.UnpackInputIntoInterface(&inputStruct, "validateBatchAuctionAndDetermineClearingPrice", input, true)
+
+	return inputStruct, err
+}
+
+// PackValidateBatchAuctionAndDetermineClearingPrice packs [inputStruct] of type ValidateBatchAuctionAndDetermineClearingPriceInput into the appropriate arguments for validateBatchAuctionAndDetermineClearingPrice.
+func PackValidateBatchAuctionAndDetermineClearingPrice(inputStruct ValidateBatchAuctionAndDetermineClearingPriceInput) ([]byte, error) {
+	return JurorABI.Pack("validateBatchAuctionAndDetermineClearingPrice", inputStruct.Data, inputStruct.FillAmounts)
+}
+
+// PackValidateBatchAuctionAndDetermineClearingPriceOutput attempts to pack given [outputStruct] of type ValidateBatchAuctionAndDetermineClearingPriceOutput
+// to conform the ABI outputs.
+func PackValidateBatchAuctionAndDetermineClearingPriceOutput(outputStruct ValidateBatchAuctionAndDetermineClearingPriceOutput) ([]byte, error) {
+	return JurorABI.PackOutput("validateBatchAuctionAndDetermineClearingPrice",
+		outputStruct.Err,
+		outputStruct.BadOrderIndex,
+		outputStruct.Res,
+	)
+}
+
+func validateBatchAuctionAndDetermineClearingPrice(accessibleState contract.AccessibleState, caller common.Address, addr common.Address, input []byte, suppliedGas uint64, readOnly bool) (ret []byte, remainingGas uint64, err error) {
+	// behaves like a function that doesn't exist until it is activated
+	if !hu.IsBatchAuctionActive(accessibleState.GetBlockContext().Timestamp()) {
+		return nil, suppliedGas, hu.ErrBatchAuctionNotActive
+	}
+	if remainingGas, err = contract.DeductGas(suppliedGas, ValidateBatchAuctionAndDetermineClearingPriceGasCost); err != nil {
+		return nil, 0, err
+	}
+	// the block of the auction is recorded
+	if readOnly {
+		return nil, remainingGas, vmerrs.ErrWriteProtection
+	}
+	// so that only an auction that is executed can use up the auction of the market in the block
+	if caller != common.HexToAddress(bibliophile.ORDERBOOK_GENESIS_ADDRESS) {
+		return nil, remainingGas, ErrNotOrderBook
+	}
+	// attempts to unpack [input] into the arguments to the ValidateBatchAuctionAndDetermineClearingPriceInput.
+	// Assumes that [input] does not include selector
+	// You can use unpacked [inputStruct] variable in your code
+	inputStruct, err := UnpackValidateBatchAuctionAndDetermineClearingPriceInput(input)
+	if err != nil {
+		return nil, remainingGas, err
+	}
+	if remainingGas, err = contract.DeductGas(remainingGas, ValidateBatchAuctionAndDetermineClearingPriceGasCostPerOrder*uint64(len(inputStruct.Data))); err != nil {
+		return nil, 0, err
+	}
+
+	// CUSTOM CODE STARTS HERE
+	bibliophileClient := bibliophile.NewBibliophileClient(accessibleState)
+	output := ValidateBatchAuctionAndDetermineClearingPrice(bibliophileClient, &inputStruct)
+	if output.Err == "" {
+		// only one auction of the market can be validated in a block
+		bibliophile.SetLastBatchAuctionBlock(accessibleState.GetStateDB(), output.Res.Instructions[0].AmmIndex.Int64(), accessibleState.GetBlockContext().Number())
+	}
+	packedOutput, err := PackValidateBatchAuctionAndDetermineClearingPriceOutput(output)
+	if err != nil {
+		return nil, remainingGas, err
+	}
+
+	// Return the packed output and the remaining gas
+	return packedOutput, remainingGas, nil
+}
+
 // UnpackValidateLiquidationOrderAndDetermineFillPriceInput attempts to unpack [input] as ValidateLiquidationOrderAndDetermineFillPriceInput
 // assumes that [input] does not include selector (omits first 4 func signature bytes)
 func UnpackValidateLiquidationOrderAndDetermineFillPriceInput(input []byte) (ValidateLiquidationOrderAndDetermineFillPriceInput, error) {
@@ -266,7 +480,10 @@ func createJurorPrecompile() contract.StatefulPrecompiledContract {
 	var functions []*contract.StatefulPrecompileFunction
 
 	abiFunctionMap := map[string]contract.RunStatefulPrecompileFunc{
+		"cancelAllSignedOrders":                         cancelAllSignedOrders,
+		"cancelSignedOrders":                            cancelSignedOrders,
 		"getNotionalPositionAndMargin":                  getNotionalPositionAndMargin,
+		"validateBatchAuctionAndDetermineClearingPrice": validateBatchAuctionAndDetermineClearingPrice,
 		"validateLiquidationOrderAndDetermineFillPrice": validateLiquidationOrderAndDetermineFillPrice,
 		"validateOrdersAndDetermineFillPrice":           validateOrdersAndDetermineFillPrice,
 	}
@@ -2,6 +2,7 @@ package jurorv2
 
 import (
 	"errors"
+	"math"
 	"math/big"
 	"strings"
 
@@ -16,7 +17,9 @@ import (
 type Metadata struct {
 	AmmIndex          *big.Int
 	Trader            common.Address
+	Signer            common.Address // trader for orders placed on chain
 	BaseAssetQuantity *big.Int
+	FilledAmount      *big.Int // before the fill that is being validated
 	Price             *big.Int
 	BlockPlaced       *big.Int
 	OrderHash         common.Hash
@@ -51,6 +54,16 @@ var (
 	ErrNoMatch           = errors.New("OB_orders_do_not_match")
 	ErrBothPostOnly      = errors.New("both orders are post only")
 	ErrNotMultiple       = errors.New("not multiple")
+	ErrSelfTrade         = errors.New("self trade")
+
+	ErrBatchAuctionMarket       = errors.New("market only matches in batch auctions")
+	ErrNotBatchAuctionMarket    = errors.New("market doesn't match in batch auctions")
+	ErrInvalidBatchAuctionSize  = errors.New("invalid number of orders in batch auction")
+	ErrDuplicateOrder           = errors.New("duplicate order")
+	ErrUnbalancedFills          = errors.New("long and short fills don't match")
+	ErrBatchAuctionFillMismatch = errors.New("fill amount doesn't match the batch auction")
+	ErrBatchAuctionInBlock      = errors.New("market already had a batch auction in this block")
+	ErrNotOrderBook             = errors.New("caller is not the order book")
 
 	ErrInvalidOrder                       = errors.New("invalid order")
 	ErrNotIOCOrder                        = errors.New("not_ioc_order")
@@ -128,11 +141,24 @@ func ValidateOrdersAndDetermineFillPrice(bibliophile b.BibliophileClient, inputS
 		return getValidateOrdersAndDetermineFillPriceErrorOutput(ErrBothPostOnly, Generic, common.Hash{})
 	}
 
+	// there is no time priority check, so the partial fills of the pro-rata and top of book priority modes are accepted
 	minSize := bibliophile.GetMinSizeRequirement(m0.AmmIndex.Int64())
 	if new(big.Int).Mod(inputStruct.FillAmount, minSize).Cmp(big.NewInt(0)) != 0 {
 		return getValidateOrdersAndDetermineFillPriceErrorOutput(ErrNotMultiple, Generic, common.Hash{})
 	}
 
+	// the orders of a batch auction market are only filled together at the clearing price, see ValidateBatchAuctionAndDetermineClearingPrice.
+	// GetMatchingMode doesn't return BatchAuction before hu.BatchAuctionActivationTime
+	if bibliophile.GetMatchingMode(m0.AmmIndex.Int64()) == hu.BatchAuction {
+		return getValidateOrdersAndDetermineFillPriceErrorOutput(ErrBatchAuctionMarket, Generic, common.Hash{})
+	}
+
+	// the self trade prevention mode is only set by the jurorv2 config, so markets allow self trades till it is activated with one.
+	// juror v1 applies the same check, so that a validator can't use either of them to match orders that the matching engine wouldn't
+	if bibliophile.GetSelfTradePreventionMode(m0.AmmIndex.Int64()) != hu.NoSelfTradePrevention && hu.IsSelfTrade(m0.Trader, m0.Signer, m1.Trader, m1.Signer, bibliophile.IsTradingAuthority) {
+		return getValidateOrdersAndDetermineFillPriceErrorOutput(ErrSelfTrade, Generic, common.Hash{})
+	}
+
 	fillPriceAndModes, err, element := determineFillPrice(bibliophile, m0, m1)
 	if err != nil {
 		orderHash := common.Hash{}
@@ -261,6 +287,7 @@ func ValidateLiquidationOrderAndDetermineFillPrice(bibliophile b.BibliophileClie
 		fillAmount = new(big.Int).Neg(fillAmount)
 	}
 
+	// the liquidated trader is not part of the input, so self trade prevention for liquidations is only applied by the matching engine
 	minSize := bibliophile.GetMinSizeRequirement(m0.AmmIndex.Int64())
 	if new(big.Int).Mod(fillAmount, minSize).Cmp(big.NewInt(0)) != 0 {
 		return getValidateLiquidationOrderAndDetermineFillPriceErrorOutput(ErrNotMultiple, Generic, common.Hash{})
@@ -330,6 +357,13 @@ func validateOrder(bibliophile b.BibliophileClient, orderType ob.OrderType, enco
 		}
 		return validateExecuteSignedOrder(bibliophile, order, side, fillAmount)
 	}
+	if orderType == ob.Trigger && hu.IsTriggerOrdersActive(bibliophile.GetTimeStamp()) {
+		order, err := hu.DecodeTriggerOrder(encodedOrder)
+		if err != nil {
+			return &Metadata{OrderHash: common.Hash{}}, err
+		}
+		return validateExecuteTriggerOrder(bibliophile, order, side, fillAmount)
+	}
 	return &Metadata{OrderHash: common.Hash{}}, errors.New("invalid order type")
 }
 
@@ -338,13 +372,16 @@ func validateExecuteLimitOrder(bibliophile b.BibliophileClient, order *ob.LimitO
 	if err != nil {
 		return &Metadata{OrderHash: common.Hash{}}, err
 	}
-	if err := validateLimitOrderLike(bibliophile, &order.BaseOrder, bibliophile.GetOrderFilledAmount(orderHash), OrderStatus(bibliophile.GetOrderStatus(orderHash)), side, fillAmount); err != nil {
+	filledAmount := bibliophile.GetOrderFilledAmount(orderHash)
+	if err := validateLimitOrderLike(bibliophile, &order.BaseOrder, filledAmount, OrderStatus(bibliophile.GetOrderStatus(orderHash)), side, fillAmount); err != nil {
 		return &Metadata{OrderHash: orderHash}, err
 	}
 	return &Metadata{
 		AmmIndex:          order.AmmIndex,
 		Trader:            order.Trader,
+		Signer:            order.Trader,
 		BaseAssetQuantity: order.BaseAssetQuantity,
+		FilledAmount:      filledAmount,
 		BlockPlaced:       bibliophile.GetBlockPlaced(orderHash),
 		Price:             order.Price,
 		OrderHash:         orderHash,
@@ -364,13 +401,16 @@ func validateExecuteIOCOrder(bibliophile b.BibliophileClient, order *ob.IOCOrder
 	if order.ExpireAt.Uint64() < bibliophile.GetTimeStamp() {
 		return &Metadata{OrderHash: orderHash}, errors.New("ioc expired")
 	}
-	if err := validateLimitOrderLike(bibliophile, &order.BaseOrder, bibliophile.IOC_GetOrderFilledAmount(orderHash), OrderStatus(bibliophile.IOC_GetOrderStatus(orderHash)), side, fillAmount); err != nil {
+	filledAmount := bibliophile.IOC_GetOrderFilledAmount(orderHash)
+	if err := validateLimitOrderLike(bibliophile, &order.BaseOrder, filledAmount, OrderStatus(bibliophile.IOC_GetOrderStatus(orderHash)), side, fillAmount); err != nil {
 		return &Metadata{OrderHash: orderHash}, err
 	}
 	return &Metadata{
 		AmmIndex:          order.AmmIndex,
 		Trader:            order.Trader,
+		Signer:            order.Trader,
 		BaseAssetQuantity: order.BaseAssetQuantity,
+		FilledAmount:      filledAmount,
 		BlockPlaced:       bibliophile.IOC_GetBlockPlaced(orderHash),
 		Price:             order.Price,
 		OrderHash:         orderHash,
@@ -379,7 +419,7 @@ func validateExecuteIOCOrder(bibliophile b.BibliophileClient, order *ob.IOCOrder
 	}, nil
 }
 
-func validateExecuteSignedOrder(bibliophile b.BibliophileClient, order *hu.SignedOrder, side Side, fillAmount *big.Int) (metadata *Metadata, err error) {
+func setSignedOrdersDomain(bibliophile b.BibliophileClient) {
 	// these fields are only set in plugin/evm/limit_order.go.NewLimitOrderProcesser
 	// however, the above is not invoked until the node bootstraps completely, and hence causes the signed order match validations during bootstrap to fail
 	// here we hardcode the values for mainnet and aylin testnet
@@ -391,12 +431,81 @@ func validateExecuteSignedOrder(bibliophile b.BibliophileClient, order *hu.Signe
 			hu.SetChainIdAndVerifyingSignedOrdersContract(486, "0xb589490250fAEaF7D80D0b5A41db5059d55A85Df")
 		}
 	}
+}
+
+func validateExecuteSignedOrder(bibliophile b.BibliophileClient, order *hu.SignedOrder, side Side, fillAmount *big.Int) (metadata *Metadata, err error) {
+	setSignedOrdersDomain(bibliophile)
+
+	orderHash, err := order.Hash()
+	if err != nil {
+		return &Metadata{OrderHash: common.Hash{}}, err
+	}
+	market := bibliophile.GetMarketAddressFromMarketID(order.AmmIndex.Int64())
+	fields := hu.SignedOrderValidationFields{
+		OrderHash:          orderHash,
+		Now:                bibliophile.GetTimeStamp(),
+		ActiveMarketsCount: bibliophile.GetActiveMarketsCount(),
+		MinSize:            bibliophile.GetMinSizeRequirement(order.AmmIndex.Int64()),
+		PriceMultiplier:    bibliophile.GetPriceMultiplier(market),
+		Status:             bibliophile.GetSignedOrderStatus(orderHash),
+		// HeartbeatExpired is not set: heartbeats are gossiped off-chain, so they are not part of the state that all validators agree on
+	}
+	if hu.IsSignedOrderCancellationsActive(fields.Now) {
+		fields.Cancelled = bibliophile.IsSignedOrderCancelled(orderHash)
+		if cancelNonce := bibliophile.GetCancelNonce(order.Trader, order.AmmIndex); cancelNonce.Sign() > 0 {
+			fields.CancelNonce = cancelNonce
+		}
+	}
+	if order.ReduceOnly && hu.IsReduceOnlySignedOrdersActive(fields.Now) {
+		// the sum of reduce only orders (P3) is only checked at placement, the fill amount is checked against the position in M2
+		fields.PositionSize = bibliophile.GetSize(market, &order.Trader)
+	}
+	trader, signer, err := hu.ValidateSignedOrder(order, fields)
+	if err != nil {
+		return &Metadata{OrderHash: orderHash}, err
+	}
+
+	log.Info("validateExecuteSignedOrder", "trader", trader, "signer", signer, "orderHash", orderHash)
+	if trader != signer && !bibliophile.IsTradingAuthority(trader, signer) {
+		return &Metadata{OrderHash: orderHash}, hu.ErrNoTradingAuthority
+	}
+
+	// M1, M2
+	filledAmount := bibliophile.GetSignedOrderFilledAmount(orderHash)
+	if err := validateLimitOrderLike(bibliophile, &order.BaseOrder, filledAmount, Placed, side, fillAmount); err != nil {
+		return &Metadata{OrderHash: orderHash}, err
+	}
+
+	// M3
+	if !bibliophile.HasReferrer(order.Trader) {
+		return &Metadata{OrderHash: orderHash}, ErrNoReferrer
+	}
+
+	return &Metadata{
+		AmmIndex:          order.AmmIndex,
+		Trader:            order.Trader,
+		Signer:            signer,
+		BaseAssetQuantity: order.BaseAssetQuantity,
+		FilledAmount:      filledAmount,
+		BlockPlaced:       big.NewInt(0), // will always be treated as a maker order
+		Price:             order.Price,
+		OrderHash:         orderHash,
+		OrderType:         ob.Signed,
+		PostOnly:          true,
+	}, nil
+}
+
+// validateExecuteTriggerOrder validates a stop loss or take profit order. Trigger orders are filled and cancelled by the signed order book,
+// and can only be matched while the oracle price is past the trigger price, since there is no record of when the order was triggered.
+// They are rejected as an invalid order type before hu.TriggerOrdersActivationTime, till the contracts execute them.
+func validateExecuteTriggerOrder(bibliophile b.BibliophileClient, order *hu.TriggerOrder, side Side, fillAmount *big.Int) (metadata *Metadata, err error) {
+	setSignedOrdersDomain(bibliophile)
 
 	orderHash, err := order.Hash()
 	if err != nil {
 		return &Metadata{OrderHash: common.Hash{}}, err
 	}
-	trader, signer, err := hu.ValidateSignedOrder(
+	trader, signer, err := hu.ValidateTriggerOrder(
 		order,
 		hu.SignedOrderValidationFields{
 			OrderHash:          orderHash,
@@ -411,13 +520,17 @@ func validateExecuteSignedOrder(bibliophile b.BibliophileClient, order *hu.Signe
 		return &Metadata{OrderHash: orderHash}, err
 	}
 
-	log.Info("validateExecuteSignedOrder", "trader", trader, "signer", signer, "orderHash", orderHash)
 	if trader != signer && !bibliophile.IsTradingAuthority(trader, signer) {
 		return &Metadata{OrderHash: orderHash}, hu.ErrNoTradingAuthority
 	}
 
+	if !order.IsTriggered(bibliophile.GetUnderlyingPrice(order.AmmIndex.Int64())) {
+		return &Metadata{OrderHash: orderHash}, hu.ErrNotTriggered
+	}
+
 	// M1, M2
-	if err := validateLimitOrderLike(bibliophile, &order.BaseOrder, bibliophile.GetSignedOrderFilledAmount(orderHash), Placed, side, fillAmount); err != nil {
+	filledAmount := bibliophile.GetSignedOrderFilledAmount(orderHash)
+	if err := validateLimitOrderLike(bibliophile, &order.BaseOrder, filledAmount, Placed, side, fillAmount); err != nil {
 		return &Metadata{OrderHash: orderHash}, err
 	}
 
@@ -429,12 +542,14 @@ func validateExecuteSignedOrder(bibliophile b.BibliophileClient, order *hu.Signe
 	return &Metadata{
 		AmmIndex:          order.AmmIndex,
 		Trader:            order.Trader,
+		Signer:            signer,
 		BaseAssetQuantity: order.BaseAssetQuantity,
-		BlockPlaced:       big.NewInt(0), // will always be treated as a maker order
+		FilledAmount:      filledAmount,
+		BlockPlaced:       new(big.Int).SetUint64(math.MaxUint64), // will always be treated as a taker order
 		Price:             order.Price,
 		OrderHash:         orderHash,
-		OrderType:         ob.Signed,
-		PostOnly:          true,
+		OrderType:         ob.Trigger,
+		PostOnly:          false,
 	}, nil
 }
 
@@ -552,6 +667,19 @@ func formatOrder(orderBytes []byte) interface{} {
 		orderJson["hash"] = orderHash.String()
 		return orderJson
 	}
+	if decodeStep0.OrderType == ob.Trigger {
+		order, err := hu.DecodeTriggerOrder(decodeStep0.EncodedOrder)
+		if err != nil {
+			return decodeStep0
+		}
+		orderJson := order.Map()
+		orderHash, err := order.Hash()
+		if err != nil {
+			return orderJson
+		}
+		orderJson["hash"] = orderHash.String()
+		return orderJson
+	}
 	return nil
 }
 
@@ -15,6 +15,7 @@ import (
 	ob "github.com/ava-labs/subnet-evm/plugin/evm/orderbook"
 	hu "github.com/ava-labs/subnet-evm/plugin/evm/orderbook/hubbleutils"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/stretchr/testify/assert"
 
 	b "github.com/ava-labs/subnet-evm/precompile/contracts/bibliophile"
@@ -262,6 +263,90 @@ func TestValidateExecuteSignedOrder(t *testing.T) {
 		testValidateExecuteSignedOrder(t, mockBibliophile, order, orderHash, Short, fillAmount, filledAmount)
 	})
 
+	t.Run("validateExecuteSignedOrder - cancelled order", func(t *testing.T) {
+		defer func(activationTime uint64) { hu.SignedOrderCancellationsActivationTime = activationTime }(hu.SignedOrderCancellationsActivationTime)
+		hu.SignedOrderCancellationsActivationTime = 1688994800
+		hu.SetChainIdAndVerifyingSignedOrdersContract(321123, "0x4c5859f0F772848b2D91F1D83E2Fe57935348029")
+		sig, err := hex.DecodeString("3027ae4ab98663490d0facab04c71665e41da867a44b7ddc29e14cb8de3a3cfa12985be54945ce040196b2fcdcc4dafc56f7955ee72628bc9e7a634a7f258ce61c")
+		assert.Nil(t, err)
+		order := &hu.SignedOrder{
+			LimitOrder: hu.LimitOrder{
+				BaseOrder: hu.BaseOrder{
+					AmmIndex:          big.NewInt(0),
+					Trader:            common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8"),
+					BaseAssetQuantity: big.NewInt(5000000000000000000), // 5
+					Price:             big.NewInt(1000000000),
+					Salt:              big.NewInt(1688994806105),
+					ReduceOnly:        false,
+				},
+				PostOnly: true,
+			},
+			OrderType: 2,
+			ExpireAt:  big.NewInt(1688994854),
+			Sig:       sig,
+		}
+		h, err := order.Hash()
+		assert.Nil(t, err)
+		encodedOrder, err := order.EncodeToABIWithoutType()
+		assert.Nil(t, err)
+
+		marketAddress := common.HexToAddress("0xa72b463C21dA61cCc86069cFab82e9e8491152a0")
+		mockBibliophile.EXPECT().GetTimeStamp().Return(order.ExpireAt.Uint64()).Times(1)
+		mockBibliophile.EXPECT().GetActiveMarketsCount().Return(int64(1)).Times(1)
+		mockBibliophile.EXPECT().GetMinSizeRequirement(order.AmmIndex.Int64()).Return(big.NewInt(1e18))
+		mockBibliophile.EXPECT().GetMarketAddressFromMarketID(order.AmmIndex.Int64()).Return(marketAddress).Times(1)
+		mockBibliophile.EXPECT().GetPriceMultiplier(marketAddress).Return(big.NewInt(1e6))
+		mockBibliophile.EXPECT().GetSignedOrderStatus(h).Return(int64(0)).Times(1) // Invalid
+		mockBibliophile.EXPECT().IsSignedOrderCancelled(h).Return(true).Times(1)
+		mockBibliophile.EXPECT().GetCancelNonce(order.Trader, order.AmmIndex).Return(big.NewInt(0)).Times(1)
+
+		m, err := validateOrder(mockBibliophile, ob.Signed, encodedOrder, Long, big.NewInt(1e18))
+		assert.Equal(t, hu.ErrOrderCancelled, err)
+		assert.Equal(t, h, m.OrderHash)
+	})
+
+	t.Run("validateExecuteSignedOrder - salt <= cancel nonce", func(t *testing.T) {
+		defer func(activationTime uint64) { hu.SignedOrderCancellationsActivationTime = activationTime }(hu.SignedOrderCancellationsActivationTime)
+		hu.SignedOrderCancellationsActivationTime = 1688994800
+		hu.SetChainIdAndVerifyingSignedOrdersContract(321123, "0x4c5859f0F772848b2D91F1D83E2Fe57935348029")
+		sig, err := hex.DecodeString("3027ae4ab98663490d0facab04c71665e41da867a44b7ddc29e14cb8de3a3cfa12985be54945ce040196b2fcdcc4dafc56f7955ee72628bc9e7a634a7f258ce61c")
+		assert.Nil(t, err)
+		order := &hu.SignedOrder{
+			LimitOrder: hu.LimitOrder{
+				BaseOrder: hu.BaseOrder{
+					AmmIndex:          big.NewInt(0),
+					Trader:            common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8"),
+					BaseAssetQuantity: big.NewInt(5000000000000000000), // 5
+					Price:             big.NewInt(1000000000),
+					Salt:              big.NewInt(1688994806105),
+					ReduceOnly:        false,
+				},
+				PostOnly: true,
+			},
+			OrderType: 2,
+			ExpireAt:  big.NewInt(1688994854),
+			Sig:       sig,
+		}
+		h, err := order.Hash()
+		assert.Nil(t, err)
+		encodedOrder, err := order.EncodeToABIWithoutType()
+		assert.Nil(t, err)
+
+		marketAddress := common.HexToAddress("0xa72b463C21dA61cCc86069cFab82e9e8491152a0")
+		mockBibliophile.EXPECT().GetTimeStamp().Return(order.ExpireAt.Uint64()).Times(1)
+		mockBibliophile.EXPECT().GetActiveMarketsCount().Return(int64(1)).Times(1)
+		mockBibliophile.EXPECT().GetMinSizeRequirement(order.AmmIndex.Int64()).Return(big.NewInt(1e18))
+		mockBibliophile.EXPECT().GetMarketAddressFromMarketID(order.AmmIndex.Int64()).Return(marketAddress).Times(1)
+		mockBibliophile.EXPECT().GetPriceMultiplier(marketAddress).Return(big.NewInt(1e6))
+		mockBibliophile.EXPECT().GetSignedOrderStatus(h).Return(int64(0)).Times(1) // Invalid
+		mockBibliophile.EXPECT().IsSignedOrderCancelled(h).Return(false).Times(1)
+		mockBibliophile.EXPECT().GetCancelNonce(order.Trader, order.AmmIndex).Return(order.Salt).Times(1)
+
+		m, err := validateOrder(mockBibliophile, ob.Signed, encodedOrder, Long, big.NewInt(1e18))
+		assert.Equal(t, hu.ErrCancelledByNonce, err)
+		assert.Equal(t, h, m.OrderHash)
+	})
+
 	// t.Run("validateExecuteLimitOrder returns orderHash even when validation fails", func(t *testing.T) {
 	// 	orderHash, err := order.Hash()
 	// 	assert.Nil(t, err)
@@ -307,6 +392,174 @@ func testValidateExecuteSignedOrder(t *testing.T, mockBibliophile *b.MockBibliop
 	}, m)
 }
 
+func TestValidateExecuteTriggerOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	defer func(activationTime uint64) { hu.TriggerOrdersActivationTime = activationTime }(hu.TriggerOrdersActivationTime)
+	hu.TriggerOrdersActivationTime = 1688994800
+
+	mockBibliophile := b.NewMockBibliophileClient(ctrl)
+	hu.SetChainIdAndVerifyingSignedOrdersContract(321123, "0x4c5859f0F772848b2D91F1D83E2Fe57935348029")
+	key, err := crypto.GenerateKey()
+	assert.Nil(t, err)
+	marketAddress := common.HexToAddress("0xa72b463C21dA61cCc86069cFab82e9e8491152a0")
+
+	// reduce only stop loss that closes a long position of 5
+	order := &hu.TriggerOrder{
+		LimitOrder: hu.LimitOrder{
+			BaseOrder: hu.BaseOrder{
+				AmmIndex:          big.NewInt(0),
+				Trader:            crypto.PubkeyToAddress(key.PublicKey),
+				BaseAssetQuantity: big.NewInt(-5000000000000000000), // -5
+				Price:             big.NewInt(980000000),
+				Salt:              big.NewInt(1688994806105),
+				ReduceOnly:        true,
+			},
+		},
+		OrderType:    uint8(hu.Trigger),
+		ExpireAt:     big.NewInt(1688994854),
+		TriggerPrice: big.NewInt(990000000),
+		TriggerKind:  uint8(hu.StopLoss),
+	}
+	h, err := order.Hash()
+	assert.Nil(t, err)
+	order.Sig, err = crypto.Sign(h.Bytes(), key)
+	assert.Nil(t, err)
+	order.Sig[crypto.RecoveryIDOffset] += 27
+	encodedOrder, err := order.EncodeToABIWithoutType()
+	assert.Nil(t, err)
+
+	expectCommonChecks := func(marketAddressLookups int, oraclePrice *big.Int) {
+		mockBibliophile.EXPECT().GetTimeStamp().Return(order.ExpireAt.Uint64()).Times(2)
+		mockBibliophile.EXPECT().GetActiveMarketsCount().Return(int64(1)).Times(1)
+		mockBibliophile.EXPECT().GetMinSizeRequirement(order.AmmIndex.Int64()).Return(big.NewInt(1e18)).Times(1)
+		mockBibliophile.EXPECT().GetMarketAddressFromMarketID(order.AmmIndex.Int64()).Return(marketAddress).Times(marketAddressLookups)
+		mockBibliophile.EXPECT().GetPriceMultiplier(marketAddress).Return(big.NewInt(1e6)).Times(1)
+		mockBibliophile.EXPECT().GetSignedOrderStatus(h).Return(int64(0)).Times(1) // Invalid
+		mockBibliophile.EXPECT().GetUnderlyingPrice(order.AmmIndex.Int64()).Return(oraclePrice).Times(1)
+	}
+
+	t.Run("triggered order is validated as a taker order", func(t *testing.T) {
+		expectCommonChecks(2, big.NewInt(985000000))
+		mockBibliophile.EXPECT().GetSignedOrderFilledAmount(h).Return(big.NewInt(0)).Times(1)
+		mockBibliophile.EXPECT().GetSize(marketAddress, &order.Trader).Return(big.NewInt(5000000000000000000)).Times(1)
+		mockBibliophile.EXPECT().HasReferrer(order.Trader).Return(true).Times(1)
+
+		m, err := validateOrder(mockBibliophile, ob.Trigger, encodedOrder, Short, big.NewInt(-3000000000000000000))
+		assert.Nil(t, err)
+		assert.Equal(t, h, m.OrderHash)
+		assert.Equal(t, order.Trader, m.Signer)
+		assert.Equal(t, ob.Trigger, m.OrderType)
+		assert.False(t, m.PostOnly)
+		assert.Equal(t, 1, m.BlockPlaced.Cmp(big.NewInt(1e18))) // after every other order
+	})
+	t.Run("order is not matched before the oracle price reaches the trigger price", func(t *testing.T) {
+		expectCommonChecks(1, big.NewInt(995000000))
+
+		m, err := validateOrder(mockBibliophile, ob.Trigger, encodedOrder, Short, big.NewInt(-3000000000000000000))
+		assert.EqualError(t, err, hu.ErrNotTriggered.Error())
+		assert.Equal(t, h, m.OrderHash)
+	})
+	t.Run("reduce only order can't increase the position", func(t *testing.T) {
+		expectCommonChecks(2, big.NewInt(985000000))
+		mockBibliophile.EXPECT().GetSignedOrderFilledAmount(h).Return(big.NewInt(0)).Times(1)
+		mockBibliophile.EXPECT().GetSize(marketAddress, &order.Trader).Return(big.NewInt(2000000000000000000)).Times(1)
+
+		_, err := validateOrder(mockBibliophile, ob.Trigger, encodedOrder, Short, big.NewInt(-3000000000000000000))
+		assert.EqualError(t, err, ErrReduceOnlyAmountExceeded.Error())
+	})
+	t.Run("order type is invalid before trigger orders are activated", func(t *testing.T) {
+		mockBibliophile.EXPECT().GetTimeStamp().Return(hu.TriggerOrdersActivationTime - 1).Times(1)
+
+		_, err := validateOrder(mockBibliophile, ob.Trigger, encodedOrder, Short, big.NewInt(-3000000000000000000))
+		assert.EqualError(t, err, "invalid order type")
+	})
+}
+
+func TestValidateExecuteReduceOnlySignedOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	defer func(activationTime uint64) { hu.ReduceOnlySignedOrdersActivationTime = activationTime }(hu.ReduceOnlySignedOrdersActivationTime)
+	hu.ReduceOnlySignedOrdersActivationTime = 1688994800
+
+	mockBibliophile := b.NewMockBibliophileClient(ctrl)
+	hu.SetChainIdAndVerifyingSignedOrdersContract(321123, "0x4c5859f0F772848b2D91F1D83E2Fe57935348029")
+	key, err := crypto.GenerateKey()
+	assert.Nil(t, err)
+	marketAddress := common.HexToAddress("0xa72b463C21dA61cCc86069cFab82e9e8491152a0")
+
+	// reduce only order that closes a long position
+	order := &hu.SignedOrder{
+		LimitOrder: hu.LimitOrder{
+			BaseOrder: hu.BaseOrder{
+				AmmIndex:          big.NewInt(0),
+				Trader:            crypto.PubkeyToAddress(key.PublicKey),
+				BaseAssetQuantity: big.NewInt(-5000000000000000000), // -5
+				Price:             big.NewInt(1000000000),
+				Salt:              big.NewInt(1688994806105),
+				ReduceOnly:        true,
+			},
+			PostOnly: true,
+		},
+		OrderType: uint8(hu.Signed),
+		ExpireAt:  big.NewInt(1688994854),
+	}
+	h, err := order.Hash()
+	assert.Nil(t, err)
+	order.Sig, err = crypto.Sign(h.Bytes(), key)
+	assert.Nil(t, err)
+	order.Sig[crypto.RecoveryIDOffset] += 27
+	encodedOrder, err := order.EncodeToABIWithoutType()
+	assert.Nil(t, err)
+
+	expectCommonChecks := func(marketAddressLookups int) {
+		mockBibliophile.EXPECT().GetTimeStamp().Return(order.ExpireAt.Uint64()).Times(1)
+		mockBibliophile.EXPECT().GetActiveMarketsCount().Return(int64(1)).Times(1)
+		mockBibliophile.EXPECT().GetMinSizeRequirement(order.AmmIndex.Int64()).Return(big.NewInt(1e18)).Times(1)
+		mockBibliophile.EXPECT().GetMarketAddressFromMarketID(order.AmmIndex.Int64()).Return(marketAddress).Times(marketAddressLookups)
+		mockBibliophile.EXPECT().GetPriceMultiplier(marketAddress).Return(big.NewInt(1e6)).Times(1)
+		mockBibliophile.EXPECT().GetSignedOrderStatus(h).Return(int64(0)).Times(1) // Invalid
+	}
+
+	t.Run("reduce only order is matched against the position", func(t *testing.T) {
+		expectCommonChecks(2)
+		mockBibliophile.EXPECT().GetSize(marketAddress, &order.Trader).Return(big.NewInt(5000000000000000000)).Times(2)
+		mockBibliophile.EXPECT().GetSignedOrderFilledAmount(h).Return(big.NewInt(0)).Times(1)
+		mockBibliophile.EXPECT().HasReferrer(order.Trader).Return(true).Times(1)
+
+		m, err := validateOrder(mockBibliophile, ob.Signed, encodedOrder, Short, big.NewInt(-3000000000000000000))
+		assert.Nil(t, err)
+		assert.Equal(t, h, m.OrderHash)
+		assert.Equal(t, ob.Signed, m.OrderType)
+	})
+	t.Run("reduce only order can't be matched without an opposite position", func(t *testing.T) {
+		expectCommonChecks(1)
+		mockBibliophile.EXPECT().GetSize(marketAddress, &order.Trader).Return(big.NewInt(-5000000000000000000)).Times(1)
+
+		m, err := validateOrder(mockBibliophile, ob.Signed, encodedOrder, Short, big.NewInt(-3000000000000000000))
+		assert.EqualError(t, err, hu.ErrNotReducingPosition.Error())
+		assert.Equal(t, h, m.OrderHash)
+	})
+	t.Run("reduce only order can't be filled beyond the position", func(t *testing.T) {
+		expectCommonChecks(2)
+		mockBibliophile.EXPECT().GetSize(marketAddress, &order.Trader).Return(big.NewInt(2000000000000000000)).Times(2)
+		mockBibliophile.EXPECT().GetSignedOrderFilledAmount(h).Return(big.NewInt(0)).Times(1)
+
+		_, err := validateOrder(mockBibliophile, ob.Signed, encodedOrder, Short, big.NewInt(-3000000000000000000))
+		assert.EqualError(t, err, ErrReduceOnlyAmountExceeded.Error())
+	})
+	t.Run("only the fill is checked against the position before reduce only signed orders are activated", func(t *testing.T) {
+		hu.ReduceOnlySignedOrdersActivationTime = order.ExpireAt.Uint64() + 1
+		defer func() { hu.ReduceOnlySignedOrdersActivationTime = 1688994800 }()
+		expectCommonChecks(2)
+		mockBibliophile.EXPECT().GetSize(marketAddress, &order.Trader).Return(big.NewInt(-5000000000000000000)).Times(1)
+		mockBibliophile.EXPECT().GetSignedOrderFilledAmount(h).Return(big.NewInt(0)).Times(1)
+
+		_, err := validateOrder(mockBibliophile, ob.Signed, encodedOrder, Short, big.NewInt(-3000000000000000000))
+		assert.EqualError(t, err, ErrReduceOnlyAmountExceeded.Error())
+	})
+}
+
 func TestValidateExecuteLimitOrder(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -1183,6 +1436,8 @@ func TestValidateOrdersAndDetermineFillPrice(t *testing.T) {
 		mockBibliophile.EXPECT().GetBlockPlaced(order1Hash).Return(big.NewInt(12))
 
 		mockBibliophile.EXPECT().GetMinSizeRequirement(order1.AmmIndex.Int64()).Return(big.NewInt(1))
+		mockBibliophile.EXPECT().GetMatchingMode(order1.AmmIndex.Int64()).Return(hu.FIFO)
+		mockBibliophile.EXPECT().GetSelfTradePreventionMode(order1.AmmIndex.Int64()).Return(hu.NoSelfTradePrevention)
 		mockBibliophile.EXPECT().GetUpperAndLowerBoundForMarket(order1.AmmIndex.Int64()).Return(big.NewInt(110), big.NewInt(90))
 
 		testCase := ValidateOrdersAndDetermineFillPriceTestCase{
@@ -1255,6 +1510,8 @@ func TestValidateOrdersAndDetermineFillPrice(t *testing.T) {
 		mockBibliophile.EXPECT().GetTimeStamp().Times(2).Return(uint64(99)) // expiry is 100
 
 		mockBibliophile.EXPECT().GetMinSizeRequirement(order1.AmmIndex.Int64()).Return(big.NewInt(1))
+		mockBibliophile.EXPECT().GetMatchingMode(order1.AmmIndex.Int64()).Return(hu.FIFO)
+		mockBibliophile.EXPECT().GetSelfTradePreventionMode(order1.AmmIndex.Int64()).Return(hu.NoSelfTradePrevention)
 		mockBibliophile.EXPECT().GetUpperAndLowerBoundForMarket(order1.AmmIndex.Int64()).Return(big.NewInt(110), big.NewInt(90))
 
 		testCase := ValidateOrdersAndDetermineFillPriceTestCase{
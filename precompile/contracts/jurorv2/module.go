@@ -8,6 +8,7 @@ import (
 	"fmt"
 
 	"github.com/ava-labs/subnet-evm/precompile/contract"
+	"github.com/ava-labs/subnet-evm/precompile/contracts/bibliophile"
 	"github.com/ava-labs/subnet-evm/precompile/modules"
 	"github.com/ava-labs/subnet-evm/precompile/precompileconfig"
 
@@ -59,5 +60,9 @@ func (*configurator) Configure(chainConfig precompileconfig.ChainConfig, cfg pre
 		return fmt.Errorf("incorrect config %T: %v", config, config)
 	}
 	// CUSTOM CODE STARTS HERE
+	for marketID, marketConfig := range config.MarketConfigs {
+		bibliophile.SetMatchingMode(state, marketID, marketConfig.MatchingMode)
+		bibliophile.SetSelfTradePreventionMode(state, marketID, marketConfig.SelfTradePreventionMode)
+	}
 	return nil
 }